@@ -9,15 +9,16 @@ import (
 	"os"
 	"os/exec"
 	"path"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/getsentry/raven-go"
+	"github.com/republicprotocol/renex-ingress-go/config"
 	renExContract "github.com/republicprotocol/renex-ingress-go/contract"
 	"github.com/republicprotocol/renex-ingress-go/httpadapter"
 	"github.com/republicprotocol/renex-ingress-go/ingress"
+	"github.com/republicprotocol/renex-ingress-go/localnet"
 	"github.com/republicprotocol/republic-go/contract"
 	"github.com/republicprotocol/republic-go/crypto"
 	"github.com/republicprotocol/republic-go/grpc"
@@ -28,69 +29,44 @@ import (
 	"github.com/republicprotocol/republic-go/swarm"
 )
 
-func init() {
-	sentryDSN := os.Getenv("SENTRY_DSN")
-	if sentryDSN == "" {
-		log.Fatalln("cannot find SENTRY_DSN environment variable")
-	}
-	raven.SetDSN(sentryDSN)
-}
+// Settings for the Darknodes that are run in local mode.
+const (
+	localDarknodes    = 6
+	localDarknodePort = 18514
+)
 
 func main() {
 	logger.SetFilterLevel(logger.LevelDebugLow)
-	alpha := os.Getenv("ALPHA")
-	if alpha == "" {
-		alpha = "5"
-	}
-	alphaNum, err := strconv.Atoi(alpha)
-	if err != nil {
-		log.Fatal("cannot parse alpha factor")
-	}
 
 	done := make(chan struct{})
 	defer close(done)
 	defer logger.Info("shutting down...")
 
-	networkParam := os.Getenv("NETWORK")
-	if networkParam == "" {
-		log.Fatalf("cannot read network environment")
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
 	}
-	configParam := fmt.Sprintf("env/%v/config.json", networkParam)
-	keystoreParam := fmt.Sprintf("env/%v/%v.keystore.json", networkParam, os.Getenv("DYNO"))
-	keystorePassphraseParam := os.Getenv("KEYSTORE_PASSPHRASE")
-	dbParam := os.Getenv("DATABASE_URL")
-	kyberID := os.Getenv("KYBER_ID")
-	kyberSecret := os.Getenv("KYBER_SECRET")
 
-	config, err := loadConfig(configParam)
+	conf, err := config.Load()
 	if err != nil {
 		log.Fatalf("cannot load config: %v", err)
 	}
-	infuraKey := os.Getenv("INFURA_KEY")
-	if infuraKey == "" {
-		panic("infuraKey cannot be empty")
-	}
-	if config.RepublicEthereum.URI == "" {
-		var network string
-		switch config.RepublicEthereum.Network {
-		case contract.NetworkMainnet:
-			network = "mainnet"
-		default:
-			network = "kovan"
-		}
-		config.RepublicEthereum.URI = fmt.Sprintf("https://%v.infura.io/v3/%v", network, infuraKey)
+	if conf.Local() {
+		runLocal(conf, done)
+		return
 	}
+	raven.SetDSN(conf.SentryDSN)
 
-	keystore, err := loadKeystore(keystoreParam, keystorePassphraseParam)
+	keystore, err := loadKeystore(conf.KeystorePath, conf.KeystorePassphrase)
 	if err != nil {
 		log.Fatalf("cannot load keystore: %v", err)
 	}
 
-	multiAddr, err := getMultiaddress(keystore, os.Getenv("PORT"))
+	multiAddr, err := getMultiaddress(keystore, conf.Port)
 	if err != nil {
 		log.Fatalf("cannot get multi-address: %v", err)
 	}
-	conn, err := contract.Connect(config.RepublicEthereum)
+	conn, err := contract.Connect(conf.Republic)
 	if err != nil {
 		log.Fatalf("cannot connect to ethereum: %v", err)
 	}
@@ -100,7 +76,7 @@ func main() {
 		log.Fatalf("cannot create contract binder: %v", err)
 	}
 
-	contractConn, err := renExContract.Connect(config.RenExEthereum)
+	contractConn, err := renExContract.Connect(conf.RenEx)
 	if err != nil {
 		log.Fatalf("cannot connect to ethereum: %v", err)
 	}
@@ -108,13 +84,23 @@ func main() {
 	if err != nil {
 		log.Fatalf("cannot create contract binder: %v", err)
 	}
-	swapper, err := ingress.NewSwapper(dbParam, contractBinder)
+	db, err := ingress.OpenDB(conf.DatabaseURL)
 	if err != nil {
 		log.Fatalf("cannot connect to the database: %v", err)
 	}
-	loginer, err := ingress.NewLoginer(dbParam)
+	defer db.Close()
+	if err := prepareSchema(db, conf.DisableMigrations); err != nil {
+		log.Fatalf("cannot prepare database schema: %v", err)
+	}
+	webhooker := ingress.NewWebhookerWithDB(db)
+	dispatcher := ingress.NewWebhookDispatcher(webhooker, &contractBinder, conf.WatchPollInterval)
+	stream := ingress.NewEventStream(&contractBinder, ingress.NewBroadcasterWithDB(db), conf.WatchPollInterval)
+	notifier := ingress.MultiNotifier(dispatcher, stream)
+	swapper := ingress.NewSwapperWithDB(db, &contractBinder, notifier)
+	loginer := ingress.NewLoginerWithDB(db)
+	approver, err := ingress.NewApproverWithDB(db, conf.ApprovedTraders)
 	if err != nil {
-		log.Fatalf("cannot connect to the database: %v", err)
+		log.Fatalf("cannot seed approved traders: %v", err)
 	}
 
 	// New database for persistent storage
@@ -133,15 +119,33 @@ func main() {
 
 	crypter := registry.NewCrypter(keystore, &binder, 256, time.Minute)
 	swarmClient := grpc.NewSwarmClient(store.SwarmMultiAddressStore(), multiAddr.Address())
-	swarmer := swarm.NewSwarmer(swarmClient, store.SwarmMultiAddressStore(), alphaNum, &crypter)
+	swarmer := swarm.NewSwarmer(swarmClient, store.SwarmMultiAddressStore(), conf.Alpha, &crypter)
 
 	orderbookClient := grpc.NewOrderbookClient()
-	ingresser := ingress.NewIngress(keystore.EcdsaKey, &binder, &contractBinder, swarmer, orderbookClient, 4*time.Second, swapper, loginer)
-	ingressAdapter := httpadapter.NewIngressAdapter(ingresser)
+	kycVerifier, err := ingress.NewKYCVerifier(conf, loginer, &contractBinder)
+	if err != nil {
+		log.Fatalf("cannot create kyc verifier: %v", err)
+	}
+	orderer := ingress.NewOrdererWithDB(db, &contractBinder)
+	orderIndex := ingress.NewOrderIndexWithDB(db)
+	ingresser := ingress.NewIngress(conf, keystore.EcdsaKey, &binder, &contractBinder, swarmer, orderbookClient, ingress.Services{
+		Swapper:     swapper,
+		Loginer:     loginer,
+		Approver:    approver,
+		Noncer:      ingress.NewNoncerWithDB(db),
+		KYCVerifier: kycVerifier,
+		Webhooker:   webhooker,
+		Sessioner:   ingress.NewSessionerWithDB(db),
+		Notifier:    notifier,
+		Subscriber:  stream,
+		Orderer:     orderer,
+		OrderIndex:  orderIndex,
+		Balancer:    ingress.NewBalancer(&contractBinder),
+	})
 
 	go func() {
 		// Add bootstrap nodes in the store or load from the file.
-		for _, multiAddr := range config.BootstrapMultiAddresses {
+		for _, multiAddr := range conf.BootstrapMultiAddresses {
 			_, err := store.SwarmMultiAddressStore().MultiAddress(multiAddr.Address())
 			if err == nil {
 				// Only add bootstrap multi-addresses that are not already in the store.
@@ -162,25 +166,189 @@ func main() {
 		}
 		log.Printf("[info] connected to %v peers", len(peers)-1)
 
-		syncErrs := ingresser.Sync(done)
+		runIngress(ingresser, done)
+	}()
+
+	if !conf.DisableKYC {
+		kycLinks, err := ingress.NewReverifyKYCLinks(conf, &contractBinder)
+		if err != nil {
+			log.Fatalf("cannot create kyc providers: %v", err)
+		}
+		reverifier := ingress.NewKYCReverifier(loginer, kycVerifier, kycLinks, conf.KYCReverifyAge, conf.KYCReverifyInterval)
 		go func() {
-			for err := range syncErrs {
-				logger.Error(fmt.Sprintf("error syncing: %v", err))
+			for err := range reverifier.Run(done) {
+				logger.Error(fmt.Sprintf("error reverifying kyc: %v", err))
 			}
 		}()
-
-		processErrs := ingresser.ProcessRequests(done)
+	}
+	if !conf.DisableKYC && !conf.DisableWyreWatcher {
+		wyreWatcher := ingress.NewWyreWatcher(&contractBinder, loginer, ingress.NewCursorerWithDB(db), ingress.NewLeaserWithDB(db), kycVerifier, conf.WyreStartBlock, conf.WatchPollInterval)
 		go func() {
-			for err := range processErrs {
-				logger.Error(fmt.Sprintf("error processing: %v", err))
+			for err := range wyreWatcher.Run(done) {
+				logger.Error(fmt.Sprintf("error watching wyre transfers: %v", err))
 			}
 		}()
+	}
+	go runWebhookDispatcher(dispatcher, done)
+	go runEventStream(stream, done)
+	swapMonitor := ingress.NewSwapMonitor(swapper, &contractBinder, notifier, conf.SwapRetention, conf.SwapMonitorInterval)
+	go func() {
+		for err := range swapMonitor.Run(done) {
+			logger.Error(fmt.Sprintf("error monitoring swaps: %v", err))
+		}
 	}()
+	if !conf.DisableSettlementWatcher {
+		settlementWatcher := ingress.NewSettlementWatcher(&contractBinder, swapper, orderer, ingress.NewCursorerWithDB(db), ingress.NewLeaserWithDB(db), notifier, conf.SettlementStartBlock, conf.WatchPollInterval)
+		go func() {
+			for err := range settlementWatcher.Run(done) {
+				logger.Error(fmt.Sprintf("error watching order settlements: %v", err))
+			}
+		}()
+	}
+	if !conf.DisableOrderIndexer {
+		orderIndexer := ingress.NewOrderIndexer(&contractBinder, orderIndex, ingress.NewCursorerWithDB(db), ingress.NewLeaserWithDB(db), conf.SettlementStartBlock, conf.WatchPollInterval)
+		go func() {
+			for err := range orderIndexer.Run(done) {
+				logger.Error(fmt.Sprintf("error indexing orders: %v", err))
+			}
+		}()
+	}
+
+	serve(conf, ingresser, multiAddr, auth.From.Hex())
+}
+
+// runLocal runs the Ingress against an in-process Localnet. Contracts are
+// deployed to a simulated backend, and orders are sent to local Darknodes.
+func runLocal(conf config.Config, done chan struct{}) {
+	keystore, err := localKeystore(conf.KeystorePath, conf.KeystorePassphrase)
+	if err != nil {
+		log.Fatalf("cannot load keystore: %v", err)
+	}
+	multiAddr, err := identity.NewMultiAddressFromString(fmt.Sprintf("/ip4/127.0.0.1/tcp/%s/republic/%s", conf.Port, keystore.Address()))
+	if err != nil {
+		log.Fatalf("cannot get multi-address: %v", err)
+	}
+
+	auth := bind.NewKeyedTransactor(keystore.EcdsaKey.PrivateKey)
+	localNetwork, err := localnet.New(auth, localDarknodes, localDarknodePort)
+	if err != nil {
+		log.Fatalf("cannot create localnet: %v", err)
+	}
+	if err := localNetwork.Start(done); err != nil {
+		log.Fatalf("cannot start localnet: %v", err)
+	}
+	contractBinder, err := renExContract.NewBinderWithBackend(auth, localNetwork.Config, localNetwork.Backend)
+	if err != nil {
+		log.Fatalf("cannot create contract binder: %v", err)
+	}
+
+	services, newSwapper := localStorage(conf, &contractBinder)
+	// Webhooks of a local network are expected to be served locally.
+	dispatcher := ingress.NewWebhookDispatcherWithClient(services.Webhooker, &contractBinder, &http.Client{Timeout: ingress.WebhookTimeout}, conf.WatchPollInterval)
+	// A local network is served by one process, so events are not broadcast.
+	stream := ingress.NewEventStream(&contractBinder, ingress.NewMemoryBroadcaster(), conf.WatchPollInterval)
+	services.KYCVerifier = ingress.NewDisabledKYCVerifier()
+	services.Notifier = ingress.MultiNotifier(dispatcher, stream)
+	services.Swapper = newSwapper(services.Notifier)
+	services.Subscriber = stream
+	services.Balancer = ingress.NewBalancer(&contractBinder)
+	ingresser := ingress.NewIngress(conf, keystore.EcdsaKey, localNetwork.ContractBinder(), &contractBinder, localNetwork.Swarmer(multiAddr), grpc.NewOrderbookClient(), services)
+
+	go runIngress(ingresser, done)
+	go runWebhookDispatcher(dispatcher, done)
+	go runEventStream(stream, done)
+
+	log.Printf("[info] (localnet) running %v darknodes from port %v", len(localNetwork.Darknodes), localDarknodePort)
+	log.Printf("[info] (localnet) orderbook %v", localNetwork.Config.OrderbookAddress)
+	log.Printf("[info] (localnet) renex settlement %v", localNetwork.Config.RenExSettlementAddress)
+	serve(conf, ingresser, multiAddr, auth.From.Hex())
+}
+
+// localStorage returns the storage backends of the Ingress, except for the
+// Swapper, and a function that returns a Swapper which notifies the Notifier.
+// They use the database when one is configured (e.g. an SQLite database), and
+// otherwise store data in memory.
+func localStorage(conf config.Config, binder *renExContract.Binder) (ingress.Services, func(ingress.Notifier) ingress.Swapper) {
+	if conf.DatabaseURL == "" {
+		services := ingress.Services{
+			Loginer:    ingress.NewMemoryLoginer(),
+			Approver:   ingress.NewMemoryApprover(conf.ApprovedTraders),
+			Noncer:     ingress.NewMemoryNoncer(),
+			Webhooker:  ingress.NewMemoryWebhooker(),
+			Sessioner:  ingress.NewMemorySessioner(),
+			Orderer:    ingress.NewMemoryOrderer(binder),
+			OrderIndex: ingress.NewMemoryOrderIndex(),
+		}
+		return services, func(notifier ingress.Notifier) ingress.Swapper {
+			return ingress.NewMemorySwapper(binder, notifier)
+		}
+	}
+	db, err := ingress.OpenDB(conf.DatabaseURL)
+	if err != nil {
+		log.Fatalf("cannot connect to the database: %v", err)
+	}
+	if err := prepareSchema(db, conf.DisableMigrations); err != nil {
+		log.Fatalf("cannot prepare database schema: %v", err)
+	}
+	approver, err := ingress.NewApproverWithDB(db, conf.ApprovedTraders)
+	if err != nil {
+		log.Fatalf("cannot seed approved traders: %v", err)
+	}
+	services := ingress.Services{
+		Loginer:    ingress.NewLoginerWithDB(db),
+		Approver:   approver,
+		Noncer:     ingress.NewNoncerWithDB(db),
+		Webhooker:  ingress.NewWebhookerWithDB(db),
+		Sessioner:  ingress.NewSessionerWithDB(db),
+		Orderer:    ingress.NewOrdererWithDB(db, binder),
+		OrderIndex: ingress.NewOrderIndexWithDB(db),
+	}
+	return services, func(notifier ingress.Notifier) ingress.Swapper {
+		return ingress.NewSwapperWithDB(db, binder, notifier)
+	}
+}
+
+// runIngress syncs the Ingress with the Darknode registry and processes
+// requests until the done channel is closed.
+func runIngress(ingresser ingress.Ingress, done <-chan struct{}) {
+	syncErrs := ingresser.Sync(done)
+	go func() {
+		for err := range syncErrs {
+			logger.Error(fmt.Sprintf("error syncing: %v", err))
+		}
+	}()
+
+	processErrs := ingresser.ProcessRequests(done)
+	go func() {
+		for err := range processErrs {
+			logger.Error(fmt.Sprintf("error processing: %v", err))
+		}
+	}()
+}
+
+// runWebhookDispatcher delivers events to the webhooks of traders until the
+// done channel is closed.
+func runWebhookDispatcher(dispatcher ingress.WebhookDispatcher, done <-chan struct{}) {
+	for err := range dispatcher.Run(done) {
+		logger.Error(fmt.Sprintf("error dispatching webhooks: %v", err))
+	}
+}
+
+// runEventStream follows the orders of traders that are streaming their
+// events until the done channel is closed.
+func runEventStream(stream ingress.EventStream, done <-chan struct{}) {
+	for err := range stream.Run(done) {
+		logger.Error(fmt.Sprintf("error streaming events: %v", err))
+	}
+}
+
+func serve(conf config.Config, ingresser ingress.Ingress, multiAddr identity.MultiAddress, ethereumAddress string) {
+	ingressAdapter := httpadapter.NewIngressAdapter(ingresser)
 
 	log.Printf("address %v", multiAddr)
-	log.Printf("ethereum %v", auth.From.Hex())
-	log.Printf("listening at 0.0.0.0:%v...", os.Getenv("PORT"))
-	if err := http.ListenAndServe(fmt.Sprintf("0.0.0.0:%v", os.Getenv("PORT")), httpadapter.NewIngressServer(ingressAdapter, config.ApprovedTraders, kyberID, kyberSecret)); err != nil {
+	log.Printf("ethereum %v", ethereumAddress)
+	log.Printf("listening at 0.0.0.0:%v...", conf.Port)
+	if err := http.ListenAndServe(fmt.Sprintf("0.0.0.0:%v", conf.Port), httpadapter.NewIngressServer(ingressAdapter, conf)); err != nil {
 		log.Fatalf("error listening and serving: %v", err)
 	}
 }
@@ -202,17 +370,13 @@ func getMultiaddress(keystore crypto.Keystore, port string) (identity.MultiAddre
 	return ingressMultiaddress, nil
 }
 
-func loadConfig(configFile string) (renExContract.Config, error) {
-	file, err := os.Open(configFile)
-	if err != nil {
-		return renExContract.Config{}, err
-	}
-	defer file.Close()
-	c := renExContract.Config{}
-	if err := json.NewDecoder(file).Decode(&c); err != nil {
-		return renExContract.Config{}, err
+// localKeystore loads the keystore when one is configured, and otherwise
+// returns a random keystore.
+func localKeystore(keystoreFile, passphrase string) (crypto.Keystore, error) {
+	if keystoreFile == "" {
+		return crypto.RandomKeystore()
 	}
-	return c, nil
+	return loadKeystore(keystoreFile, passphrase)
 }
 
 func loadKeystore(keystoreFile, passphrase string) (crypto.Keystore, error) {
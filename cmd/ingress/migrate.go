@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/republicprotocol/renex-ingress-go/ingress"
+)
+
+// runMigrate runs the migrate subcommand. With no arguments it applies all
+// pending migrations to the database at DATABASE_URL. With the "status"
+// argument it only reports the state of the schema. It exits with a non-zero
+// status if the schema has drifted, or if migrations are pending when
+// reporting the status.
+func runMigrate(args []string) {
+	db, err := ingress.OpenDB(os.Getenv("DATABASE_URL"))
+	if err != nil {
+		log.Fatalf("cannot connect to the database: %v", err)
+	}
+	defer db.Close()
+
+	var status ingress.SchemaStatus
+	switch {
+	case len(args) == 0:
+		status, err = db.Migrate()
+	case len(args) == 1 && args[0] == "status":
+		status, err = db.CheckSchema()
+	default:
+		log.Fatalf("usage: %v migrate [status]", os.Args[0])
+	}
+
+	fmt.Printf("schema version: %v (latest %v)\n", status.Version, len(ingress.Migrations))
+	for _, migration := range status.Pending {
+		fmt.Printf("pending: %v %v\n", migration.Version, migration.Name)
+	}
+	for _, drift := range status.Drift {
+		fmt.Printf("drift: %v\n", drift)
+	}
+	if err != nil {
+		log.Fatalf("cannot migrate: %v", err)
+	}
+}
+
+// prepareSchema applies pending migrations to the database, or when
+// migrations are disabled, checks that there are no pending migrations. It
+// returns an error if the schema has drifted.
+func prepareSchema(db *ingress.DB, disableMigrations bool) error {
+	var status ingress.SchemaStatus
+	var err error
+	if disableMigrations {
+		status, err = db.CheckSchema()
+	} else {
+		status, err = db.Migrate()
+	}
+	for _, drift := range status.Drift {
+		log.Printf("[error] (migrate) %v", drift)
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("[info] (migrate) schema version %v", status.Version)
+	return nil
+}
@@ -0,0 +1,497 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/republicprotocol/renex-ingress-go/contract"
+	republicContract "github.com/republicprotocol/republic-go/contract"
+	"github.com/republicprotocol/republic-go/identity"
+)
+
+// Default values used for settings that are not configured.
+const (
+	DefaultAlpha             = 5
+	DefaultEpochPollInterval = 4 * time.Second
+
+	DefaultKYCCacheTTL         = 5 * time.Minute
+	DefaultKYCNegativeCacheTTL = 30 * time.Second
+	DefaultKYCReverifyInterval = time.Hour
+	DefaultKYCReverifyAge      = 24 * time.Hour
+	DefaultWatchPollInterval   = 15 * time.Second
+
+	DefaultSwapMonitorInterval = time.Hour
+	DefaultSwapRetention       = 7 * 24 * time.Hour
+)
+
+// Names of the KYC providers that can be configured.
+const (
+	KYCProviderWyre  = "wyre"
+	KYCProviderKyber = "kyber"
+)
+
+// DefaultKYCProviders is the KYC chain used when KYC_PROVIDERS is not
+// configured. Wyre verification is checked on every request, and Kyber
+// verification is trusted for 24 hours.
+var DefaultKYCProviders = []KYCProviderConfig{
+	{Name: KYCProviderWyre, Freshness: 0},
+	{Name: KYCProviderKyber, Freshness: 24 * time.Hour},
+}
+
+// ErrEmptyNetwork is returned when the network is not configured.
+var ErrEmptyNetwork = errors.New("network cannot be empty")
+
+// Config is the runtime configuration of the Ingress. It is loaded from the
+// network config file, and the environment.
+type Config struct {
+	// Settings loaded from the network config file.
+	Republic                republicContract.Config `json:"republic"`
+	RenEx                   contract.RenExConfig    `json:"renex"`
+	BootstrapMultiAddresses identity.MultiAddresses `json:"bootstrapMultiAddresses"`
+	ApprovedTraders         []string                `json:"approvedTraders"`
+
+	// Settings loaded from the environment.
+	Network            string        `json:"-"`
+	Dyno               string        `json:"-"`
+	Port               string        `json:"-"`
+	Alpha              int           `json:"-"`
+	EpochPollInterval  time.Duration `json:"-"`
+	KeystorePath       string        `json:"-"`
+	KeystorePassphrase string        `json:"-"`
+	DatabaseURL        string        `json:"-"`
+	SentryDSN          string        `json:"-"`
+	InfuraKey          string        `json:"-"`
+	AdminToken         string        `json:"-"`
+	DisableKYC         bool          `json:"-"`
+	DisableMigrations  bool          `json:"-"`
+	Kyber              KyberConfig   `json:"-"`
+	Vaults             VaultRegistry `json:"-"`
+
+	// TimeLocks are the policies used for the timelocks of atomic swaps.
+	// Swaps without a policy use DefaultTimeLockDuration,
+	// DefaultTimeLockGap and DefaultTimeLockMargin.
+	TimeLocks TimeLockRegistry `json:"-"`
+
+	// KYCProviders is the chain of providers used to verify traders.
+	KYCProviders []KYCProviderConfig `json:"-"`
+
+	// KYCCacheTTL is the duration for which a trader that is verified is
+	// cached, and KYCNegativeCacheTTL is the duration for which a trader that
+	// is not verified is cached.
+	KYCCacheTTL         time.Duration `json:"-"`
+	KYCNegativeCacheTTL time.Duration `json:"-"`
+
+	// KYCReverifyInterval is the interval at which traders last verified
+	// more than KYCReverifyAge ago are re-verified in the background.
+	KYCReverifyInterval time.Duration `json:"-"`
+	KYCReverifyAge      time.Duration `json:"-"`
+
+	// SwapMonitorInterval is the interval at which swaps past their timelock
+	// are marked as refundable, and partial swaps of orders that have not
+	// been settled within SwapRetention are purged.
+	SwapMonitorInterval time.Duration `json:"-"`
+	SwapRetention       time.Duration `json:"-"`
+
+	// Settings for watchers that follow contract events. WyreStartBlock and
+	// SettlementStartBlock are the blocks from which the Wyre and settlement
+	// watchers start when they have no cursor. The order indexer also indexes
+	// settlements from the SettlementStartBlock.
+	DisableWyreWatcher       bool          `json:"-"`
+	WyreStartBlock           uint64        `json:"-"`
+	DisableSettlementWatcher bool          `json:"-"`
+	SettlementStartBlock     uint64        `json:"-"`
+	DisableOrderIndexer      bool          `json:"-"`
+	WatchPollInterval        time.Duration `json:"-"`
+}
+
+// KyberConfig defines the settings for the Kyber KYC API.
+type KyberConfig struct {
+	URL    string
+	ID     string
+	Secret string
+
+	// Timeout for requests to the Kyber API. The default timeout of the
+	// Kyber client is used when it is zero.
+	Timeout time.Duration
+}
+
+// KYCProviderConfig defines a KYC provider in the KYC chain. Providers are
+// checked in the order that they are configured. A verification by the
+// provider is trusted for the Freshness duration before the trader is
+// verified with the provider again.
+type KYCProviderConfig struct {
+	Name      string
+	Freshness time.Duration
+}
+
+// Load the Config for the network defined by the NETWORK environment
+// variable. The network is the name of a directory in env/. Defaults are
+// applied to settings that are not configured and an error is returned if the
+// resulting Config is invalid.
+func Load() (Config, error) {
+	return LoadWithEnv(os.Getenv)
+}
+
+// LoadWithEnv loads the Config in the same way as Load, but uses getenv to
+// look up environment variables. The network config file is loaded from
+// CONFIG_PATH, or from env/<network>/config.json when CONFIG_PATH is empty.
+func LoadWithEnv(getenv func(string) string) (Config, error) {
+	network := getenv("NETWORK")
+	if network == "" {
+		return Config{}, ErrEmptyNetwork
+	}
+	configFile := getenv("CONFIG_PATH")
+	if configFile == "" {
+		configFile = path.Join("env", network, "config.json")
+	}
+
+	conf, err := LoadFile(configFile)
+	if err != nil {
+		return Config{}, err
+	}
+	conf.Network = network
+	if err := conf.loadEnv(getenv); err != nil {
+		return Config{}, err
+	}
+	conf.setDefaults()
+	if err := conf.Validate(); err != nil {
+		return Config{}, err
+	}
+	return conf, nil
+}
+
+// LoadFile loads the settings that are stored in a network config file.
+func LoadFile(configFile string) (Config, error) {
+	file, err := os.Open(configFile)
+	if err != nil {
+		return Config{}, err
+	}
+	defer file.Close()
+
+	conf := Config{}
+	if err := json.NewDecoder(file).Decode(&conf); err != nil {
+		return Config{}, fmt.Errorf("cannot decode %v: %v", configFile, err)
+	}
+	return conf, nil
+}
+
+// Local returns true if the Ingress is configured to run without network
+// access, against a simulated Ethereum backend and in-process Darknodes.
+func (conf *Config) Local() bool {
+	return conf.RenEx.Network == contract.NetworkLocal
+}
+
+// Validate returns an error if a required setting is missing, or if a setting
+// is malformed. Settings for external services are not required when running
+// locally, but malformed settings are always rejected.
+func (conf *Config) Validate() error {
+	if conf.Network == "" {
+		return ErrEmptyNetwork
+	}
+	switch conf.RenEx.Network {
+	case contract.NetworkMainnet, contract.NetworkTestnet, contract.NetworkNightly, contract.NetworkLocal:
+	default:
+		return fmt.Errorf("unsupported renex network %v", conf.RenEx.Network)
+	}
+	if conf.Port == "" {
+		return errors.New("PORT cannot be empty")
+	}
+	if conf.Alpha <= 0 {
+		return fmt.Errorf("ALPHA must be positive: got %v", conf.Alpha)
+	}
+	if conf.EpochPollInterval <= 0 {
+		return fmt.Errorf("EPOCH_POLL_INTERVAL must be positive: got %v", conf.EpochPollInterval)
+	}
+	if err := conf.Vaults.Validate(); err != nil {
+		return err
+	}
+	if err := conf.TimeLocks.Validate(); err != nil {
+		return err
+	}
+	if conf.KYCCacheTTL < 0 || conf.KYCNegativeCacheTTL < 0 {
+		return errors.New("KYC_CACHE_TTL and KYC_NEGATIVE_CACHE_TTL cannot be negative")
+	}
+	if conf.KYCReverifyInterval < 0 || conf.KYCReverifyAge < 0 {
+		return errors.New("KYC_REVERIFY_INTERVAL and KYC_REVERIFY_AGE cannot be negative")
+	}
+	if conf.SwapMonitorInterval < 0 || conf.SwapRetention < 0 {
+		return errors.New("SWAP_MONITOR_INTERVAL and SWAP_RETENTION cannot be negative")
+	}
+	if conf.WatchPollInterval <= 0 {
+		return fmt.Errorf("WATCH_POLL_INTERVAL must be positive: got %v", conf.WatchPollInterval)
+	}
+	if conf.Local() {
+		return nil
+	}
+	if conf.KeystorePath == "" {
+		return errors.New("KEYSTORE_PATH or DYNO cannot be empty")
+	}
+	if conf.DatabaseURL == "" {
+		return errors.New("DATABASE_URL cannot be empty")
+	}
+	if conf.SentryDSN == "" {
+		return errors.New("SENTRY_DSN cannot be empty")
+	}
+	if conf.RenEx.URI == "" || conf.Republic.URI == "" {
+		return errors.New("INFURA_KEY cannot be empty when no ethereum uri is configured")
+	}
+	if !conf.DisableKYC {
+		if err := conf.validateKYCProviders(); err != nil {
+			return err
+		}
+	}
+	for _, blockchain := range []string{BlockchainEthereum, BlockchainBitcoin} {
+		if _, ok := conf.Vaults.Address(blockchain, ""); !ok {
+			return fmt.Errorf("VAULTS, or ETH_VAULT and BTC_VAULT, must configure a vault for %v", blockchain)
+		}
+	}
+	return nil
+}
+
+func (conf *Config) validateKYCProviders() error {
+	names := map[string]bool{}
+	for _, provider := range conf.KYCProviders {
+		if names[provider.Name] {
+			return fmt.Errorf("KYC_PROVIDERS cannot contain %v more than once", provider.Name)
+		}
+		names[provider.Name] = true
+		if provider.Freshness < 0 {
+			return fmt.Errorf("KYC_PROVIDERS freshness cannot be negative: got %v for %v", provider.Freshness, provider.Name)
+		}
+
+		switch provider.Name {
+		case KYCProviderWyre:
+		case KYCProviderKyber:
+			if conf.Kyber.URL == "" || conf.Kyber.ID == "" || conf.Kyber.Secret == "" {
+				return errors.New("KYBER_URL, KYBER_ID and KYBER_SECRET cannot be empty when kyber kyc is enabled")
+			}
+			if conf.Kyber.Timeout < 0 {
+				return fmt.Errorf("KYBER_TIMEOUT cannot be negative: got %v", conf.Kyber.Timeout)
+			}
+		default:
+			return fmt.Errorf("unsupported kyc provider %v", provider.Name)
+		}
+	}
+	return nil
+}
+
+func (conf *Config) loadEnv(getenv func(string) string) error {
+	conf.Dyno = getenv("DYNO")
+	conf.Port = getenv("PORT")
+	conf.KeystorePath = getenv("KEYSTORE_PATH")
+	conf.KeystorePassphrase = getenv("KEYSTORE_PASSPHRASE")
+	conf.DatabaseURL = getenv("DATABASE_URL")
+	conf.SentryDSN = getenv("SENTRY_DSN")
+	conf.InfuraKey = getenv("INFURA_KEY")
+	conf.AdminToken = getenv("ADMIN_TOKEN")
+	conf.DisableKYC = getenv("DISABLE_KYC") == "1"
+	conf.DisableMigrations = getenv("DISABLE_MIGRATIONS") == "1"
+	conf.DisableWyreWatcher = getenv("DISABLE_WYRE_WATCHER") == "1"
+	conf.DisableSettlementWatcher = getenv("DISABLE_SETTLEMENT_WATCHER") == "1"
+	conf.DisableOrderIndexer = getenv("DISABLE_ORDER_INDEXER") == "1"
+	conf.Kyber = KyberConfig{
+		URL:    getenv("KYBER_URL"),
+		ID:     getenv("KYBER_ID"),
+		Secret: getenv("KYBER_SECRET"),
+	}
+	if vaults := getenv("VAULTS"); vaults != "" {
+		registry, err := parseVaults(vaults)
+		if err != nil {
+			return fmt.Errorf("cannot parse VAULTS: %v", err)
+		}
+		conf.Vaults = registry
+	}
+	if timeLocks := getenv("TIME_LOCKS"); timeLocks != "" {
+		registry, err := parseTimeLocks(timeLocks)
+		if err != nil {
+			return fmt.Errorf("cannot parse TIME_LOCKS: %v", err)
+		}
+		conf.TimeLocks = registry
+	}
+	if vault := getenv("ETH_VAULT"); vault != "" {
+		conf.Vaults = append(conf.Vaults, Vault{Blockchain: BlockchainEthereum, Address: vault}, Vault{Blockchain: BlockchainERC20, Address: vault})
+	}
+	if vault := getenv("BTC_VAULT"); vault != "" {
+		conf.Vaults = append(conf.Vaults, Vault{Blockchain: BlockchainBitcoin, Address: vault})
+	}
+
+	if alpha := getenv("ALPHA"); alpha != "" {
+		alphaNum, err := strconv.Atoi(alpha)
+		if err != nil {
+			return fmt.Errorf("cannot parse ALPHA: %v", err)
+		}
+		conf.Alpha = alphaNum
+	}
+	if interval := getenv("EPOCH_POLL_INTERVAL"); interval != "" {
+		duration, err := time.ParseDuration(interval)
+		if err != nil {
+			return fmt.Errorf("cannot parse EPOCH_POLL_INTERVAL: %v", err)
+		}
+		conf.EpochPollInterval = duration
+	}
+	if providers := getenv("KYC_PROVIDERS"); providers != "" {
+		kycProviders, err := parseKYCProviders(providers)
+		if err != nil {
+			return fmt.Errorf("cannot parse KYC_PROVIDERS: %v", err)
+		}
+		conf.KYCProviders = kycProviders
+	}
+	if timeout := getenv("KYBER_TIMEOUT"); timeout != "" {
+		duration, err := time.ParseDuration(timeout)
+		if err != nil {
+			return fmt.Errorf("cannot parse KYBER_TIMEOUT: %v", err)
+		}
+		conf.Kyber.Timeout = duration
+	}
+	if ttl := getenv("KYC_CACHE_TTL"); ttl != "" {
+		duration, err := time.ParseDuration(ttl)
+		if err != nil {
+			return fmt.Errorf("cannot parse KYC_CACHE_TTL: %v", err)
+		}
+		conf.KYCCacheTTL = duration
+	}
+	if ttl := getenv("KYC_NEGATIVE_CACHE_TTL"); ttl != "" {
+		duration, err := time.ParseDuration(ttl)
+		if err != nil {
+			return fmt.Errorf("cannot parse KYC_NEGATIVE_CACHE_TTL: %v", err)
+		}
+		conf.KYCNegativeCacheTTL = duration
+	}
+	if interval := getenv("KYC_REVERIFY_INTERVAL"); interval != "" {
+		duration, err := time.ParseDuration(interval)
+		if err != nil {
+			return fmt.Errorf("cannot parse KYC_REVERIFY_INTERVAL: %v", err)
+		}
+		conf.KYCReverifyInterval = duration
+	}
+	if age := getenv("KYC_REVERIFY_AGE"); age != "" {
+		duration, err := time.ParseDuration(age)
+		if err != nil {
+			return fmt.Errorf("cannot parse KYC_REVERIFY_AGE: %v", err)
+		}
+		conf.KYCReverifyAge = duration
+	}
+	if interval := getenv("SWAP_MONITOR_INTERVAL"); interval != "" {
+		duration, err := time.ParseDuration(interval)
+		if err != nil {
+			return fmt.Errorf("cannot parse SWAP_MONITOR_INTERVAL: %v", err)
+		}
+		conf.SwapMonitorInterval = duration
+	}
+	if retention := getenv("SWAP_RETENTION"); retention != "" {
+		duration, err := time.ParseDuration(retention)
+		if err != nil {
+			return fmt.Errorf("cannot parse SWAP_RETENTION: %v", err)
+		}
+		conf.SwapRetention = duration
+	}
+	if block := getenv("WYRE_START_BLOCK"); block != "" {
+		blockNum, err := strconv.ParseUint(block, 10, 64)
+		if err != nil {
+			return fmt.Errorf("cannot parse WYRE_START_BLOCK: %v", err)
+		}
+		conf.WyreStartBlock = blockNum
+	}
+	if block := getenv("SETTLEMENT_START_BLOCK"); block != "" {
+		blockNum, err := strconv.ParseUint(block, 10, 64)
+		if err != nil {
+			return fmt.Errorf("cannot parse SETTLEMENT_START_BLOCK: %v", err)
+		}
+		conf.SettlementStartBlock = blockNum
+	}
+	if interval := getenv("WATCH_POLL_INTERVAL"); interval != "" {
+		duration, err := time.ParseDuration(interval)
+		if err != nil {
+			return fmt.Errorf("cannot parse WATCH_POLL_INTERVAL: %v", err)
+		}
+		conf.WatchPollInterval = duration
+	}
+	if conf.KeystorePath == "" && conf.Dyno != "" {
+		conf.KeystorePath = path.Join("env", conf.Network, fmt.Sprintf("%v.keystore.json", conf.Dyno))
+	}
+	return nil
+}
+
+func (conf *Config) setDefaults() {
+	if conf.Alpha == 0 {
+		conf.Alpha = DefaultAlpha
+	}
+	if conf.EpochPollInterval == 0 {
+		conf.EpochPollInterval = DefaultEpochPollInterval
+	}
+	if conf.KYCCacheTTL == 0 {
+		conf.KYCCacheTTL = DefaultKYCCacheTTL
+	}
+	if conf.KYCNegativeCacheTTL == 0 {
+		conf.KYCNegativeCacheTTL = DefaultKYCNegativeCacheTTL
+	}
+	if conf.KYCReverifyInterval == 0 {
+		conf.KYCReverifyInterval = DefaultKYCReverifyInterval
+	}
+	if conf.KYCReverifyAge == 0 {
+		conf.KYCReverifyAge = DefaultKYCReverifyAge
+	}
+	if conf.SwapMonitorInterval == 0 {
+		conf.SwapMonitorInterval = DefaultSwapMonitorInterval
+	}
+	if conf.SwapRetention == 0 {
+		conf.SwapRetention = DefaultSwapRetention
+	}
+	if conf.WatchPollInterval == 0 {
+		conf.WatchPollInterval = DefaultWatchPollInterval
+	}
+	if conf.KYCProviders == nil {
+		conf.KYCProviders = make([]KYCProviderConfig, len(DefaultKYCProviders))
+		copy(conf.KYCProviders, DefaultKYCProviders)
+	}
+	if conf.Local() {
+		// Local Darknodes do not require KYC and there is no access to the
+		// Kyber API.
+		conf.DisableKYC = true
+		conf.SentryDSN = ""
+	}
+	if conf.InfuraKey != "" {
+		if conf.Republic.URI == "" {
+			conf.Republic.URI = infuraURI(conf.Republic.Network == republicContract.NetworkMainnet, conf.InfuraKey)
+		}
+		if conf.RenEx.URI == "" {
+			conf.RenEx.URI = infuraURI(conf.RenEx.Network == contract.NetworkMainnet, conf.InfuraKey)
+		}
+	}
+}
+
+// parseKYCProviders parses a comma separated list of KYC providers. Each
+// provider is a name, optionally followed by a colon and a freshness duration
+// (e.g. "wyre,kyber:24h").
+func parseKYCProviders(value string) ([]KYCProviderConfig, error) {
+	providers := []KYCProviderConfig{}
+	for _, provider := range strings.Split(value, ",") {
+		parts := strings.SplitN(strings.TrimSpace(provider), ":", 2)
+		config := KYCProviderConfig{Name: strings.ToLower(parts[0])}
+		if config.Name == "" {
+			return nil, errors.New("kyc provider cannot be empty")
+		}
+		if len(parts) == 2 {
+			freshness, err := time.ParseDuration(parts[1])
+			if err != nil {
+				return nil, err
+			}
+			config.Freshness = freshness
+		}
+		providers = append(providers, config)
+	}
+	return providers, nil
+}
+
+func infuraURI(mainnet bool, infuraKey string) string {
+	if mainnet {
+		return fmt.Sprintf("https://mainnet.infura.io/v3/%v", infuraKey)
+	}
+	return fmt.Sprintf("https://kovan.infura.io/v3/%v", infuraKey)
+}
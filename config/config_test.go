@@ -0,0 +1,378 @@
+package config_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/republicprotocol/renex-ingress-go/config"
+
+	"github.com/republicprotocol/renex-ingress-go/contract"
+)
+
+var _ = Describe("Config", func() {
+
+	var env map[string]string
+
+	getenv := func(key string) string {
+		return env[key]
+	}
+
+	BeforeEach(func() {
+		env = map[string]string{
+			"NETWORK":      "test",
+			"CONFIG_PATH":  "../env/test/config.json",
+			"DYNO":         "web.1",
+			"PORT":         "18515",
+			"DATABASE_URL": "postgres://localhost/ingress",
+			"SENTRY_DSN":   "https://sentry.io/ingress",
+			"KYBER_URL":    "https://kyber.network",
+			"KYBER_ID":     "id",
+			"KYBER_SECRET": "secret",
+			"ETH_VAULT":    "0x62026B5aC38f1b186c7af0b18Aee8B2EccC2d852",
+			"BTC_VAULT":    "mv4rnyY3Su5gjcDNzbMLKBQkBicCtHUtFB",
+		}
+	})
+
+	Context("when loading a valid config", func() {
+
+		It("should combine the config file and the environment", func() {
+			conf, err := LoadWithEnv(getenv)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(conf.Network).Should(Equal("test"))
+			Expect(conf.RenEx.Network).Should(Equal(contract.NetworkTestnet))
+			Expect(conf.ApprovedTraders).ShouldNot(BeEmpty())
+			Expect(conf.KeystorePath).Should(Equal("env/test/web.1.keystore.json"))
+			Expect(conf.Kyber.Secret).Should(Equal("secret"))
+		})
+
+		It("should apply defaults to settings that are not configured", func() {
+			conf, err := LoadWithEnv(getenv)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(conf.Alpha).Should(Equal(DefaultAlpha))
+			Expect(conf.EpochPollInterval).Should(Equal(DefaultEpochPollInterval))
+		})
+
+		It("should override defaults from the environment", func() {
+			env["ALPHA"] = "3"
+			env["EPOCH_POLL_INTERVAL"] = "10s"
+			conf, err := LoadWithEnv(getenv)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(conf.Alpha).Should(Equal(3))
+			Expect(conf.EpochPollInterval).Should(Equal(10 * time.Second))
+		})
+	})
+
+	Context("when configuring kyc providers", func() {
+
+		It("should use the default kyc providers", func() {
+			conf, err := LoadWithEnv(getenv)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(conf.KYCProviders).Should(Equal(DefaultKYCProviders))
+		})
+
+		It("should load the kyc providers in order", func() {
+			env["KYC_PROVIDERS"] = "kyber:1h, wyre"
+			conf, err := LoadWithEnv(getenv)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(conf.KYCProviders).Should(Equal([]KYCProviderConfig{
+				{Name: KYCProviderKyber, Freshness: time.Hour},
+				{Name: KYCProviderWyre, Freshness: 0},
+			}))
+		})
+
+		It("should not require kyber settings when kyber is not a kyc provider", func() {
+			env["KYC_PROVIDERS"] = "wyre"
+			delete(env, "KYBER_SECRET")
+			_, err := LoadWithEnv(getenv)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("should return an error for unsupported kyc providers", func() {
+			env["KYC_PROVIDERS"] = "wyre,unknown"
+			_, err := LoadWithEnv(getenv)
+			Expect(err).Should(HaveOccurred())
+		})
+
+		It("should return an error for malformed freshness windows", func() {
+			env["KYC_PROVIDERS"] = "kyber:tomorrow"
+			_, err := LoadWithEnv(getenv)
+			Expect(err).Should(HaveOccurred())
+		})
+
+		It("should load the kyc cache ttls", func() {
+			conf, err := LoadWithEnv(getenv)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(conf.KYCCacheTTL).Should(Equal(DefaultKYCCacheTTL))
+			Expect(conf.KYCNegativeCacheTTL).Should(Equal(DefaultKYCNegativeCacheTTL))
+
+			env["KYC_CACHE_TTL"] = "1m"
+			env["KYC_NEGATIVE_CACHE_TTL"] = "5s"
+			conf, err = LoadWithEnv(getenv)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(conf.KYCCacheTTL).Should(Equal(time.Minute))
+			Expect(conf.KYCNegativeCacheTTL).Should(Equal(5 * time.Second))
+		})
+
+		It("should load the wyre watcher settings", func() {
+			env["WYRE_START_BLOCK"] = "6000000"
+			env["WATCH_POLL_INTERVAL"] = "5s"
+			conf, err := LoadWithEnv(getenv)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(conf.WyreStartBlock).Should(Equal(uint64(6000000)))
+			Expect(conf.WatchPollInterval).Should(Equal(5 * time.Second))
+			Expect(conf.DisableWyreWatcher).Should(BeFalse())
+		})
+
+		It("should load the settlement watcher settings", func() {
+			env["SETTLEMENT_START_BLOCK"] = "6000000"
+			env["DISABLE_SETTLEMENT_WATCHER"] = "1"
+			conf, err := LoadWithEnv(getenv)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(conf.SettlementStartBlock).Should(Equal(uint64(6000000)))
+			Expect(conf.DisableSettlementWatcher).Should(BeTrue())
+
+			env["SETTLEMENT_START_BLOCK"] = "latest"
+			_, err = LoadWithEnv(getenv)
+			Expect(err).Should(HaveOccurred())
+		})
+
+		It("should load the order indexer settings", func() {
+			conf, err := LoadWithEnv(getenv)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(conf.DisableOrderIndexer).Should(BeFalse())
+
+			env["DISABLE_ORDER_INDEXER"] = "1"
+			conf, err = LoadWithEnv(getenv)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(conf.DisableOrderIndexer).Should(BeTrue())
+		})
+
+		It("should load the swap monitor settings", func() {
+			conf, err := LoadWithEnv(getenv)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(conf.SwapMonitorInterval).Should(Equal(DefaultSwapMonitorInterval))
+			Expect(conf.SwapRetention).Should(Equal(DefaultSwapRetention))
+
+			env["SWAP_MONITOR_INTERVAL"] = "10m"
+			env["SWAP_RETENTION"] = "72h"
+			conf, err = LoadWithEnv(getenv)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(conf.SwapMonitorInterval).Should(Equal(10 * time.Minute))
+			Expect(conf.SwapRetention).Should(Equal(72 * time.Hour))
+
+			env["SWAP_RETENTION"] = "-72h"
+			_, err = LoadWithEnv(getenv)
+			Expect(err).Should(HaveOccurred())
+		})
+
+		It("should load the kyber timeout", func() {
+			env["KYBER_TIMEOUT"] = "3s"
+			conf, err := LoadWithEnv(getenv)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(conf.Kyber.Timeout).Should(Equal(3 * time.Second))
+
+			env["KYBER_TIMEOUT"] = "-3s"
+			_, err = LoadWithEnv(getenv)
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	Context("when loading an invalid config", func() {
+
+		It("should return an error when the network is missing", func() {
+			delete(env, "NETWORK")
+			_, err := LoadWithEnv(getenv)
+			Expect(err).Should(Equal(ErrEmptyNetwork))
+		})
+
+		It("should return an error when a required setting is missing", func() {
+			delete(env, "DATABASE_URL")
+			_, err := LoadWithEnv(getenv)
+			Expect(err).Should(HaveOccurred())
+		})
+
+		It("should return an error when a setting is malformed", func() {
+			env["ALPHA"] = "five"
+			_, err := LoadWithEnv(getenv)
+			Expect(err).Should(HaveOccurred())
+		})
+
+		It("should not require kyber settings when kyc is disabled", func() {
+			delete(env, "KYBER_SECRET")
+			_, err := LoadWithEnv(getenv)
+			Expect(err).Should(HaveOccurred())
+
+			env["DISABLE_KYC"] = "1"
+			_, err = LoadWithEnv(getenv)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+	})
+
+	Context("when loading vaults", func() {
+
+		It("should use the ethereum vault for erc20 tokens", func() {
+			conf, err := LoadWithEnv(getenv)
+			Expect(err).ShouldNot(HaveOccurred())
+			address, ok := conf.Vaults.Address(BlockchainERC20, "DGX")
+			Expect(ok).Should(BeTrue())
+			Expect(address).Should(Equal(env["ETH_VAULT"]))
+			address, ok = conf.Vaults.Address(BlockchainBitcoin, "BTC")
+			Expect(ok).Should(BeTrue())
+			Expect(address).Should(Equal(env["BTC_VAULT"]))
+		})
+
+		It("should prefer the vault of a token to the vault of its blockchain", func() {
+			env["VAULTS"] = "erc20/DGX:0x0000000000000000000000000000000000000001,zcash:t1Hsc1LR8yKnbbe3twRp88p6vFfC5t7DLbs"
+			conf, err := LoadWithEnv(getenv)
+			Expect(err).ShouldNot(HaveOccurred())
+			address, ok := conf.Vaults.Address("ERC20", "dgx")
+			Expect(ok).Should(BeTrue())
+			Expect(address).Should(Equal("0x0000000000000000000000000000000000000001"))
+			address, ok = conf.Vaults.Address(BlockchainERC20, "REN")
+			Expect(ok).Should(BeTrue())
+			Expect(address).Should(Equal(env["ETH_VAULT"]))
+			address, ok = conf.Vaults.Address("zcash", "ZEC")
+			Expect(ok).Should(BeTrue())
+			Expect(address).Should(Equal("t1Hsc1LR8yKnbbe3twRp88p6vFfC5t7DLbs"))
+			_, ok = conf.Vaults.Address("monero", "XMR")
+			Expect(ok).Should(BeFalse())
+		})
+
+		It("should accept bech32 bitcoin addresses", func() {
+			delete(env, "BTC_VAULT")
+			env["VAULTS"] = "bitcoin:bc1qar0srrr7xfkvy5l643lydnw9re59gtzzwf5mdq"
+			_, err := LoadWithEnv(getenv)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("should return an error for malformed addresses", func() {
+			env["ETH_VAULT"] = "0x62026B5aC38f1b186c7af0b18Aee8B2EccC2d85"
+			_, err := LoadWithEnv(getenv)
+			Expect(err).Should(HaveOccurred())
+
+			env["ETH_VAULT"] = "0x62026B5aC38f1b186c7af0b18Aee8B2EccC2d852"
+			env["BTC_VAULT"] = "mv4rnyY3Su5gjcDNzbMLKBQkBicCtHUtFC"
+			_, err = LoadWithEnv(getenv)
+			Expect(err).Should(HaveOccurred())
+		})
+
+		It("should return an error for vaults that are configured more than once", func() {
+			env["VAULTS"] = "bitcoin:mv4rnyY3Su5gjcDNzbMLKBQkBicCtHUtFB"
+			_, err := LoadWithEnv(getenv)
+			Expect(err).Should(HaveOccurred())
+		})
+
+		It("should return an error when the ethereum or bitcoin vault is missing", func() {
+			delete(env, "BTC_VAULT")
+			_, err := LoadWithEnv(getenv)
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	Context("when loading timelock policies", func() {
+
+		It("should use the default policy when no policy is configured", func() {
+			conf, err := LoadWithEnv(getenv)
+			Expect(err).ShouldNot(HaveOccurred())
+			policy := conf.TimeLocks.Policy(BlockchainBitcoin, "BTC", BlockchainEthereum, "ETH")
+			Expect(policy.Duration).Should(Equal(DefaultTimeLockDuration))
+			Expect(policy.Gap).Should(Equal(DefaultTimeLockGap))
+			Expect(policy.Margin).Should(Equal(DefaultTimeLockMargin))
+		})
+
+		It("should prefer the policy of a token pair to the policies of blockchains", func() {
+			env["TIME_LOCKS"] = "ETH-BTC:24h:6h,bitcoin:72h:36h,ethereum:96h:12h"
+			conf, err := LoadWithEnv(getenv)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			policy := conf.TimeLocks.Policy(BlockchainBitcoin, "BTC", BlockchainEthereum, "ETH")
+			Expect(policy.Duration).Should(Equal(24 * time.Hour))
+			Expect(policy.Gap).Should(Equal(6 * time.Hour))
+
+			policy = conf.TimeLocks.Policy(BlockchainBitcoin, "BTC", BlockchainERC20, "DGX")
+			Expect(policy.Duration).Should(Equal(72 * time.Hour))
+			Expect(policy.Gap).Should(Equal(36 * time.Hour))
+
+			policy = conf.TimeLocks.Policy(BlockchainBitcoin, "BTC", BlockchainEthereum, "WETH")
+			Expect(policy.Duration).Should(Equal(96 * time.Hour))
+			Expect(policy.Gap).Should(Equal(36 * time.Hour))
+		})
+
+		It("should use half of the gap as the margin unless a margin is configured", func() {
+			env["TIME_LOCKS"] = "ETH-BTC:24h:6h:1h,bitcoin:72h:36h"
+			conf, err := LoadWithEnv(getenv)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			policy := conf.TimeLocks.Policy(BlockchainBitcoin, "BTC", BlockchainEthereum, "ETH")
+			Expect(policy.Margin).Should(Equal(time.Hour))
+			policy = conf.TimeLocks.Policy(BlockchainBitcoin, "BTC", BlockchainERC20, "DGX")
+			Expect(policy.Margin).Should(Equal(18 * time.Hour))
+		})
+
+		It("should return an error for unsafe margins", func() {
+			env["TIME_LOCKS"] = "bitcoin:48h:24h:24h"
+			_, err := LoadWithEnv(getenv)
+			Expect(err).Should(HaveOccurred())
+
+			env["TIME_LOCKS"] = "bitcoin:48h:24h:-1h"
+			_, err = LoadWithEnv(getenv)
+			Expect(err).Should(HaveOccurred())
+		})
+
+		It("should return an error for unsafe gaps", func() {
+			env["TIME_LOCKS"] = "bitcoin:24h:24h"
+			_, err := LoadWithEnv(getenv)
+			Expect(err).Should(HaveOccurred())
+
+			env["TIME_LOCKS"] = "bitcoin:24h:0s"
+			_, err = LoadWithEnv(getenv)
+			Expect(err).Should(HaveOccurred())
+		})
+
+		It("should return an error for policies that are configured more than once", func() {
+			env["TIME_LOCKS"] = "BTC-ETH:48h:24h,ETH-BTC:24h:12h"
+			_, err := LoadWithEnv(getenv)
+			Expect(err).Should(HaveOccurred())
+		})
+
+		It("should return an error for malformed policies", func() {
+			env["TIME_LOCKS"] = "bitcoin:48h"
+			_, err := LoadWithEnv(getenv)
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	Context("when loading a local config", func() {
+
+		It("should not require settings for external services", func() {
+			conf, err := LoadWithEnv(func(key string) string {
+				return map[string]string{
+					"NETWORK":     "local",
+					"CONFIG_PATH": "../env/local/config.json",
+					"PORT":        "18515",
+					"SENTRY_DSN":  "https://sentry.io/ingress",
+				}[key]
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(conf.Local()).Should(BeTrue())
+			Expect(conf.DisableKYC).Should(BeTrue())
+			Expect(conf.SentryDSN).Should(BeEmpty())
+		})
+
+		It("should return an error for negative durations", func() {
+			for _, key := range []string{"KYC_CACHE_TTL", "KYC_NEGATIVE_CACHE_TTL", "KYC_REVERIFY_AGE", "SWAP_RETENTION", "WATCH_POLL_INTERVAL"} {
+				key := key
+				_, err := LoadWithEnv(func(name string) string {
+					return map[string]string{
+						"NETWORK":     "local",
+						"CONFIG_PATH": "../env/local/config.json",
+						"PORT":        "18515",
+						key:           "-1m",
+					}[name]
+				})
+				Expect(err).Should(HaveOccurred(), key)
+			}
+		})
+	})
+})
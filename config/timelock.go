@@ -0,0 +1,147 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Default values used for swaps that do not have a TimeLockPolicy.
+const (
+	DefaultTimeLockDuration = 48 * time.Hour
+	DefaultTimeLockGap      = 24 * time.Hour
+	DefaultTimeLockMargin   = 12 * time.Hour
+)
+
+// A TimeLockPolicy defines the timelocks of atomic swaps. The initiator of a
+// swap locks its funds for the Duration, and the follower locks its funds
+// until at least the Gap before the initiator's timelock expires, so that the
+// follower has time to redeem the funds of the initiator after the secret is
+// revealed. Swaps are only finalized while more than the Margin remains
+// before the timelock of the follower expires, so that the follower has time
+// to audit the initiator and lock its funds.
+//
+// A TimeLockPolicy applies to swaps between a pair of tokens, or to swaps
+// involving a blockchain when its Pair is empty.
+type TimeLockPolicy struct {
+	Blockchain string
+	Pair       [2]string
+	Duration   time.Duration
+	Gap        time.Duration
+	Margin     time.Duration
+}
+
+// A TimeLockRegistry is the list of TimeLockPolicies used for atomic swaps.
+type TimeLockRegistry []TimeLockPolicy
+
+// Policy returns the TimeLockPolicy for a swap from the send token on its
+// blockchain to the receive token on its blockchain. The policy of the token
+// pair is preferred. Otherwise, the longest policy of the two blockchains is
+// used, or the default policy if neither blockchain has a policy.
+func (registry TimeLockRegistry) Policy(sendBlockchain, sendToken, receiveBlockchain, receiveToken string) TimeLockPolicy {
+	var policy TimeLockPolicy
+	for _, candidate := range registry {
+		if candidate.Blockchain != "" {
+			continue
+		}
+		if (strings.EqualFold(candidate.Pair[0], sendToken) && strings.EqualFold(candidate.Pair[1], receiveToken)) ||
+			(strings.EqualFold(candidate.Pair[0], receiveToken) && strings.EqualFold(candidate.Pair[1], sendToken)) {
+			return candidate
+		}
+	}
+	for _, candidate := range registry {
+		if candidate.Blockchain == "" {
+			continue
+		}
+		if !strings.EqualFold(candidate.Blockchain, sendBlockchain) && !strings.EqualFold(candidate.Blockchain, receiveBlockchain) {
+			continue
+		}
+		if candidate.Duration > policy.Duration {
+			policy.Duration = candidate.Duration
+		}
+		if candidate.Gap > policy.Gap {
+			policy.Gap = candidate.Gap
+		}
+		if candidate.Margin > policy.Margin {
+			policy.Margin = candidate.Margin
+		}
+	}
+	if policy.Duration == 0 {
+		return TimeLockPolicy{Duration: DefaultTimeLockDuration, Gap: DefaultTimeLockGap, Margin: DefaultTimeLockMargin}
+	}
+	return policy
+}
+
+// Validate returns an error if a TimeLockPolicy is configured more than once,
+// if the Gap of a TimeLockPolicy does not leave time for the follower to lock
+// its funds, or if its Margin is not shorter than the Gap.
+func (registry TimeLockRegistry) Validate() error {
+	seen := map[string]bool{}
+	for _, policy := range registry {
+		key := strings.ToLower(timeLockPolicyName(policy))
+		if policy.Blockchain == "" && strings.ToLower(policy.Pair[0]) > strings.ToLower(policy.Pair[1]) {
+			key = strings.ToLower(policy.Pair[1] + "-" + policy.Pair[0])
+		}
+		if seen[key] {
+			return fmt.Errorf("TIME_LOCKS cannot contain %v more than once", timeLockPolicyName(policy))
+		}
+		seen[key] = true
+		if policy.Gap <= 0 || policy.Duration <= policy.Gap {
+			return fmt.Errorf("TIME_LOCKS gap must be positive and shorter than the duration: got %v and %v for %v", policy.Gap, policy.Duration, timeLockPolicyName(policy))
+		}
+		if policy.Margin < 0 || policy.Margin >= policy.Gap {
+			return fmt.Errorf("TIME_LOCKS margin cannot be negative and must be shorter than the gap: got %v and %v for %v", policy.Margin, policy.Gap, timeLockPolicyName(policy))
+		}
+	}
+	return nil
+}
+
+// parseTimeLocks parses a comma separated list of timelock policies. Each
+// policy is a blockchain, or a pair of tokens separated by a dash, followed
+// by the duration, the gap and optionally the margin separated by colons
+// (e.g. "BTC-ETH:48h:24h:12h"). The margin is half of the gap by default.
+func parseTimeLocks(value string) (TimeLockRegistry, error) {
+	registry := TimeLockRegistry{}
+	for _, policy := range strings.Split(value, ",") {
+		parts := strings.Split(strings.TrimSpace(policy), ":")
+		if len(parts) != 3 && len(parts) != 4 {
+			return nil, fmt.Errorf("time lock %v must be of the form blockchain:duration:gap[:margin] or token-token:duration:gap[:margin]", policy)
+		}
+		var config TimeLockPolicy
+		if tokens := strings.Split(parts[0], "-"); len(tokens) == 2 {
+			config.Pair = [2]string{strings.ToUpper(tokens[0]), strings.ToUpper(tokens[1])}
+			if config.Pair[0] == "" || config.Pair[1] == "" {
+				return nil, fmt.Errorf("time lock tokens cannot be empty: got %v", policy)
+			}
+		} else {
+			config.Blockchain = strings.ToLower(parts[0])
+			if config.Blockchain == "" {
+				return nil, errors.New("time lock blockchain cannot be empty")
+			}
+		}
+		duration, err := time.ParseDuration(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		gap, err := time.ParseDuration(parts[2])
+		if err != nil {
+			return nil, err
+		}
+		config.Duration, config.Gap, config.Margin = duration, gap, gap/2
+		if len(parts) == 4 {
+			if config.Margin, err = time.ParseDuration(parts[3]); err != nil {
+				return nil, err
+			}
+		}
+		registry = append(registry, config)
+	}
+	return registry, nil
+}
+
+func timeLockPolicyName(policy TimeLockPolicy) string {
+	if policy.Blockchain != "" {
+		return policy.Blockchain
+	}
+	return policy.Pair[0] + "-" + policy.Pair[1]
+}
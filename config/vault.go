@@ -0,0 +1,201 @@
+package config
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Blockchains with broker addresses that are validated when the config is
+// loaded. Vaults for other blockchains are accepted without validation, so
+// that swap assets can be added without code changes.
+const (
+	BlockchainEthereum = "ethereum"
+	BlockchainERC20    = "erc20"
+	BlockchainBitcoin  = "bitcoin"
+)
+
+// A Vault is the broker address used for atomic swaps of tokens on a
+// blockchain. A Vault without a token is used for all tokens on the
+// blockchain that do not have a Vault of their own.
+type Vault struct {
+	Blockchain string `json:"blockchain"`
+	Token      string `json:"token,omitempty"`
+	Address    string `json:"address"`
+}
+
+// A VaultRegistry is the list of Vaults used for atomic swaps.
+type VaultRegistry []Vault
+
+// Address returns the broker address for the token on the blockchain, and
+// false if no Vault has been configured for the token or the blockchain.
+func (registry VaultRegistry) Address(blockchain, token string) (string, bool) {
+	address, ok := "", false
+	for _, vault := range registry {
+		if !strings.EqualFold(vault.Blockchain, blockchain) {
+			continue
+		}
+		if vault.Token == "" && !ok {
+			address, ok = vault.Address, true
+		}
+		if vault.Token != "" && strings.EqualFold(vault.Token, token) {
+			return vault.Address, true
+		}
+	}
+	return address, ok
+}
+
+// Validate returns an error if a Vault is configured more than once, or if
+// the address of a Vault is malformed for its blockchain.
+func (registry VaultRegistry) Validate() error {
+	seen := map[string]bool{}
+	for _, vault := range registry {
+		key := strings.ToLower(vault.Blockchain) + "/" + strings.ToLower(vault.Token)
+		if seen[key] {
+			return fmt.Errorf("VAULTS cannot contain %v more than once", vaultName(vault))
+		}
+		seen[key] = true
+		if err := validateVaultAddress(vault.Blockchain, vault.Address); err != nil {
+			return fmt.Errorf("invalid vault address for %v: %v", vaultName(vault), err)
+		}
+	}
+	return nil
+}
+
+// parseVaults parses a comma separated list of vaults. Each vault is a
+// blockchain, optionally followed by a slash and a token, then a colon and
+// the broker address (e.g. "erc20/DGX:0x...").
+func parseVaults(value string) (VaultRegistry, error) {
+	registry := VaultRegistry{}
+	for _, vault := range strings.Split(value, ",") {
+		parts := strings.SplitN(strings.TrimSpace(vault), ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("vault %v must be of the form blockchain[/token]:address", vault)
+		}
+		names := strings.SplitN(parts[0], "/", 2)
+		config := Vault{Blockchain: strings.ToLower(names[0]), Address: parts[1]}
+		if len(names) == 2 {
+			config.Token = strings.ToUpper(names[1])
+			if config.Token == "" {
+				return nil, fmt.Errorf("vault token cannot be empty: got %v", vault)
+			}
+		}
+		if config.Blockchain == "" {
+			return nil, errors.New("vault blockchain cannot be empty")
+		}
+		registry = append(registry, config)
+	}
+	return registry, nil
+}
+
+func vaultName(vault Vault) string {
+	if vault.Token == "" {
+		return vault.Blockchain
+	}
+	return vault.Blockchain + "/" + vault.Token
+}
+
+func validateVaultAddress(blockchain, address string) error {
+	if address == "" || strings.TrimSpace(address) != address {
+		return fmt.Errorf("malformed address %q", address)
+	}
+	switch strings.ToLower(blockchain) {
+	case BlockchainEthereum, BlockchainERC20:
+		if !common.IsHexAddress(address) || !strings.HasPrefix(address, "0x") {
+			return fmt.Errorf("malformed ethereum address %v", address)
+		}
+	case BlockchainBitcoin:
+		if !validBase58CheckAddress(address) && !validBech32Address(address) {
+			return fmt.Errorf("malformed bitcoin address %v", address)
+		}
+	}
+	return nil
+}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// validBase58CheckAddress returns true if the address is a base58 encoded
+// version byte and 20 byte hash with a valid checksum, as used by P2PKH and
+// P2SH addresses.
+func validBase58CheckAddress(address string) bool {
+	num := new(big.Int)
+	for _, c := range address {
+		i := strings.IndexRune(base58Alphabet, c)
+		if i < 0 {
+			return false
+		}
+		num.Mul(num, big.NewInt(58))
+		num.Add(num, big.NewInt(int64(i)))
+	}
+	decoded := num.Bytes()
+	for _, c := range address {
+		if c != '1' {
+			break
+		}
+		decoded = append([]byte{0}, decoded...)
+	}
+	if len(decoded) != 25 {
+		return false
+	}
+	first := sha256.Sum256(decoded[:21])
+	second := sha256.Sum256(first[:])
+	return bytes.Equal(second[:4], decoded[21:])
+}
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// validBech32Address returns true if the address is a bech32 encoded segwit
+// address for mainnet, testnet or regtest.
+func validBech32Address(address string) bool {
+	if strings.ToLower(address) != address && strings.ToUpper(address) != address {
+		return false
+	}
+	address = strings.ToLower(address)
+	sep := strings.LastIndex(address, "1")
+	if sep < 1 || len(address)-sep-1 < 6 || len(address) > 90 {
+		return false
+	}
+	hrp, data := address[:sep], address[sep+1:]
+	switch hrp {
+	case "bc", "tb", "bcrt":
+	default:
+		return false
+	}
+
+	values := make([]int, 0, 2*len(hrp)+1+len(data))
+	for _, c := range hrp {
+		values = append(values, int(c)>>5)
+	}
+	values = append(values, 0)
+	for _, c := range hrp {
+		values = append(values, int(c)&31)
+	}
+	for _, c := range data {
+		i := strings.IndexRune(bech32Charset, c)
+		if i < 0 {
+			return false
+		}
+		values = append(values, i)
+	}
+	return bech32Polymod(values) == 1
+}
+
+func bech32Polymod(values []int) int {
+	generator := []int{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := 1
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ v
+		for i := 0; i < 5; i++ {
+			if (top>>uint(i))&1 == 1 {
+				chk ^= generator[i]
+			}
+		}
+	}
+	return chk
+}
@@ -2,29 +2,23 @@ package contract
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/big"
+	"sort"
 	"sync"
 
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/republicprotocol/renex-ingress-go/contract/bindings"
 )
 
-// CREATE TABLE withdrawals (
-//     hash        bytea,
-//     address     varchar(42),
-//     token       int,
-// 	amount      varchar,
-//     timestamp   bigint,
-//     nonce       int
-// );
-
 // Binder implements all methods that will communicate with the smart contracts
 type Binder struct {
 	mu           *sync.RWMutex
 	network      Network
-	conn         Conn
+	backend      bind.ContractBackend
 	transactOpts *bind.TransactOpts
 	callOpts     *bind.CallOpts
 
@@ -32,46 +26,136 @@ type Binder struct {
 	renExSettlement     *bindings.RenExSettlement
 	orderbook           *bindings.Orderbook
 	wyre                *bindings.Wyre
+	renExBalances       *bindings.RenExBalances
+	renExTokens         *bindings.RenExTokens
+}
+
+// MatchDetails of a settled order, as returned by the RenExSettlement
+// contract.
+type MatchDetails struct {
+	Settled         bool
+	OrderIsBuy      bool
+	MatchedID       [32]byte
+	PriorityVolume  *big.Int
+	SecondaryVolume *big.Int
+	PriorityFee     *big.Int
+	SecondaryFee    *big.Int
+	PriorityToken   uint32
+	SecondaryToken  uint32
+}
+
+// WyreTransfer is a transfer of a Wyre KYC token. Tokens are minted from, and
+// burned to, the zero address.
+type WyreTransfer struct {
+	From        common.Address
+	To          common.Address
+	TokenID     *big.Int
+	BlockNumber uint64
+}
+
+// OrderSettlement is the settlement of an order by the RenExSettlement
+// contract. Both orders of a match are settled in the same transaction.
+type OrderSettlement struct {
+	OrderID     [32]byte
+	BlockNumber uint64
+}
+
+// OrderbookOrder is an order opened in the Orderbook contract. The Orderbook
+// stores orders in the order in which they were opened.
+type OrderbookOrder struct {
+	OrderID     [32]byte
+	Trader      common.Address
+	State       uint8
+	Confirmer   common.Address
+	MatchID     [32]byte
+	BlockNumber uint64
+}
+
+// RegisteredToken is a token that is registered in the RenExTokens contract.
+// The Symbol is empty if the token does not implement the ERC20 symbol
+// method.
+type RegisteredToken struct {
+	Code     uint32
+	Address  common.Address
+	Decimals uint8
+	Symbol   string
+}
+
+// ErrCannotReadHeaders is returned when the backend of a Binder cannot read
+// block headers.
+var ErrCannotReadHeaders = errors.New("backend cannot read block headers")
+
+type headerReader interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
 }
 
 // NewBinder returns a Binder to communicate with contracts
 func NewBinder(auth *bind.TransactOpts, conn Conn) (Binder, error) {
+	return NewBinderWithBackend(auth, conn.Config, conn.Client)
+}
+
+// NewBinderWithBackend returns a Binder to communicate with contracts that are
+// deployed to an arbitrary backend, such as a simulated backend.
+func NewBinderWithBackend(auth *bind.TransactOpts, config RenExConfig, backend bind.ContractBackend) (Binder, error) {
 	transactOpts := *auth
 	transactOpts.GasPrice = big.NewInt(5000000000)
 
-	nonce, err := conn.Client.PendingNonceAt(context.Background(), transactOpts.From)
+	nonce, err := backend.PendingNonceAt(context.Background(), transactOpts.From)
 	if err != nil {
 		return Binder{}, err
 	}
 	transactOpts.Nonce = big.NewInt(int64(nonce))
 
-	renExBrokerVerifier, err := bindings.NewRenExBrokerVerifier(common.HexToAddress(conn.Config.RenExBrokerVerifierAddress), bind.ContractBackend(conn.Client))
+	renExBrokerVerifier, err := bindings.NewRenExBrokerVerifier(common.HexToAddress(config.RenExBrokerVerifierAddress), backend)
 	if err != nil {
 		fmt.Println(fmt.Errorf("cannot bind to RenExBrokerVerifier: %v", err))
 		return Binder{}, err
 	}
 
-	orderbook, err := bindings.NewOrderbook(common.HexToAddress(conn.Config.OrderbookAddress), bind.ContractBackend(conn.Client))
+	orderbook, err := bindings.NewOrderbook(common.HexToAddress(config.OrderbookAddress), backend)
 	if err != nil {
 		fmt.Println(fmt.Errorf("cannot bind to Orderbook: %v", err))
 		return Binder{}, err
 	}
-	settlement, err := bindings.NewRenExSettlement(common.HexToAddress(conn.Config.RenExSettlementAddress), bind.ContractBackend(conn.Client))
+	settlement, err := bindings.NewRenExSettlement(common.HexToAddress(config.RenExSettlementAddress), backend)
 	if err != nil {
 		fmt.Println(fmt.Errorf("cannot bind to Settlement: %v", err))
 		return Binder{}, err
 	}
 
-	wyre, err := bindings.NewWyre(common.HexToAddress(conn.Config.WyreAddress), bind.ContractBackend(conn.Client))
+	wyre, err := bindings.NewWyre(common.HexToAddress(config.WyreAddress), backend)
 	if err != nil {
 		fmt.Println(fmt.Errorf("cannot bind to Wyre: %v", err))
 		return Binder{}, err
 	}
 
+	// The RenExBalances and RenExTokens contracts are not configured, and are
+	// looked up from the contracts that use them.
+	renExBalancesAddress, err := renExBrokerVerifier.BalancesContract(&bind.CallOpts{})
+	if err != nil {
+		fmt.Println(fmt.Errorf("cannot get RenExBalances address: %v", err))
+		return Binder{}, err
+	}
+	renExBalances, err := bindings.NewRenExBalances(renExBalancesAddress, backend)
+	if err != nil {
+		fmt.Println(fmt.Errorf("cannot bind to RenExBalances: %v", err))
+		return Binder{}, err
+	}
+	renExTokensAddress, err := settlement.RenExTokensContract(&bind.CallOpts{})
+	if err != nil {
+		fmt.Println(fmt.Errorf("cannot get RenExTokens address: %v", err))
+		return Binder{}, err
+	}
+	renExTokens, err := bindings.NewRenExTokens(renExTokensAddress, backend)
+	if err != nil {
+		fmt.Println(fmt.Errorf("cannot bind to RenExTokens: %v", err))
+		return Binder{}, err
+	}
+
 	return Binder{
 		mu:           new(sync.RWMutex),
-		network:      conn.Config.Network,
-		conn:         conn,
+		network:      config.Network,
+		backend:      backend,
 		transactOpts: &transactOpts,
 		callOpts:     &bind.CallOpts{},
 
@@ -79,6 +163,8 @@ func NewBinder(auth *bind.TransactOpts, conn Conn) (Binder, error) {
 		renExSettlement:     settlement,
 		orderbook:           orderbook,
 		wyre:                wyre,
+		renExBalances:       renExBalances,
+		renExTokens:         renExTokens,
 	}, nil
 }
 
@@ -107,29 +193,77 @@ func (binder *Binder) balanceOf(trader common.Address) (*big.Int, error) {
 	return binder.wyre.BalanceOf(binder.callOpts, trader)
 }
 
+// BlockNumber returns the number of the latest block.
+func (binder *Binder) BlockNumber() (uint64, error) {
+	reader, ok := binder.backend.(headerReader)
+	if !ok {
+		return 0, ErrCannotReadHeaders
+	}
+	header, err := reader.HeaderByNumber(context.Background(), nil)
+	if err != nil {
+		return 0, err
+	}
+	return header.Number.Uint64(), nil
+}
+
+// WyreTransfers returns the transfers of Wyre KYC tokens in the blocks from
+// start to end, inclusive.
+func (binder *Binder) WyreTransfers(start, end uint64) ([]WyreTransfer, error) {
+	binder.mu.RLock()
+	defer binder.mu.RUnlock()
+
+	iter, err := binder.wyre.FilterTransfer(&bind.FilterOpts{Start: start, End: &end}, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	transfers := []WyreTransfer{}
+	for iter.Next() {
+		transfers = append(transfers, WyreTransfer{
+			From:        iter.Event.From,
+			To:          iter.Event.To,
+			TokenID:     iter.Event.TokenId,
+			BlockNumber: iter.Event.Raw.BlockNumber,
+		})
+	}
+	return transfers, iter.Error()
+}
+
 // GetOrderTrader of the given order id.
 func (binder *Binder) GetOrderTrader(orderID [32]byte) (common.Address, error) {
 	return binder.orderbook.OrderTrader(&bind.CallOpts{}, orderID)
 }
 
-// func (binder *Binder) WatchLogOrderSettled(ids [][32]byte) (chan *bindings.RenExSettlementLogOrderSettled, error) {
-// 	orderSettled := make(chan *bindings.RenExSettlementLogOrderSettled)
-// 	_, err := binder.renExSettlementWs.WatchLogOrderSettled(&bind.WatchOpts{}, orderSettled, ids)
-// 	return orderSettled, err
-// }
+// OrderSettlements returns the settlements of orders by the RenExSettlement
+// contract in the blocks from start to end, inclusive.
+func (binder *Binder) OrderSettlements(start, end uint64) ([]OrderSettlement, error) {
+	binder.mu.RLock()
+	defer binder.mu.RUnlock()
 
-func (binder *Binder) GetMatchDetails(id [32]byte) (struct {
-	Settled         bool
-	OrderIsBuy      bool
-	MatchedID       [32]byte
-	PriorityVolume  *big.Int
-	SecondaryVolume *big.Int
-	PriorityFee     *big.Int
-	SecondaryFee    *big.Int
-	PriorityToken   uint32
-	SecondaryToken  uint32
-}, error) {
-	return binder.renExSettlement.GetMatchDetails(&bind.CallOpts{}, id)
+	iter, err := binder.renExSettlement.FilterLogOrderSettled(&bind.FilterOpts{Start: start, End: &end}, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	settlements := []OrderSettlement{}
+	for iter.Next() {
+		settlements = append(settlements, OrderSettlement{
+			OrderID:     iter.Event.OrderID,
+			BlockNumber: iter.Event.Raw.BlockNumber,
+		})
+	}
+	return settlements, iter.Error()
+}
+
+// GetMatchDetails of the given order id.
+func (binder *Binder) GetMatchDetails(id [32]byte) (MatchDetails, error) {
+	details, err := binder.renExSettlement.GetMatchDetails(&bind.CallOpts{}, id)
+	if err != nil {
+		return MatchDetails{}, err
+	}
+	return MatchDetails(details), nil
 }
 
 func (binder *Binder) OrderTrader(id [32]byte) (string, error) {
@@ -144,3 +278,155 @@ func (binder *Binder) OrderTrader(id [32]byte) (string, error) {
 func (binder *Binder) OrderState(id [32]byte) (uint8, error) {
 	return binder.orderbook.OrderState(&bind.CallOpts{}, id)
 }
+
+// OrderBlockNumber returns the number of the block in which the order was
+// opened.
+func (binder *Binder) OrderBlockNumber(id [32]byte) (uint64, error) {
+	blockNumber, err := binder.orderbook.OrderBlockNumber(&bind.CallOpts{}, id)
+	if err != nil {
+		return 0, err
+	}
+	return blockNumber.Uint64(), nil
+}
+
+// OrderConfirmer returns the address of the Darknode that confirmed the
+// order.
+func (binder *Binder) OrderConfirmer(id [32]byte) (common.Address, error) {
+	return binder.orderbook.OrderConfirmer(&bind.CallOpts{}, id)
+}
+
+// OrderMatch returns the ID of the order that the order was confirmed with.
+func (binder *Binder) OrderMatch(id [32]byte) ([32]byte, error) {
+	return binder.orderbook.OrderMatch(&bind.CallOpts{}, id)
+}
+
+// OrderDepth returns the number of blocks since the order was opened.
+func (binder *Binder) OrderDepth(id [32]byte) (uint64, error) {
+	depth, err := binder.orderbook.OrderDepth(&bind.CallOpts{}, id)
+	if err != nil {
+		return 0, err
+	}
+	return depth.Uint64(), nil
+}
+
+// OrderbookOrders returns at most limit orders of the Orderbook after skipping
+// offset orders. Only the ID, trader and state of the orders are set.
+func (binder *Binder) OrderbookOrders(offset, limit uint64) ([]OrderbookOrder, error) {
+	ids, traders, states, err := binder.orderbook.GetOrders(&bind.CallOpts{}, new(big.Int).SetUint64(offset), new(big.Int).SetUint64(limit))
+	if err != nil {
+		return nil, err
+	}
+	if len(traders) != len(ids) || len(states) != len(ids) {
+		return nil, fmt.Errorf("cannot get orders: got %v ids, %v traders and %v states", len(ids), len(traders), len(states))
+	}
+
+	orders := make([]OrderbookOrder, len(ids))
+	for i := range ids {
+		orders[i] = OrderbookOrder{
+			OrderID: ids[i],
+			Trader:  traders[i],
+			State:   states[i],
+		}
+	}
+	return orders, nil
+}
+
+// OrderbookOrder returns the order with the given id from the Orderbook.
+func (binder *Binder) OrderbookOrder(id [32]byte) (OrderbookOrder, error) {
+	order, err := binder.orderbook.Orders(&bind.CallOpts{}, id)
+	if err != nil {
+		return OrderbookOrder{}, err
+	}
+	return OrderbookOrder{
+		OrderID:     id,
+		Trader:      order.Trader,
+		State:       order.State,
+		Confirmer:   order.Confirmer,
+		MatchID:     order.MatchedOrder,
+		BlockNumber: order.BlockNumber.Uint64(),
+	}, nil
+}
+
+// RegisteredTokens returns the tokens that are registered in the RenExTokens
+// contract, ordered by their code.
+func (binder *Binder) RegisteredTokens() ([]RegisteredToken, error) {
+	binder.mu.RLock()
+	defer binder.mu.RUnlock()
+
+	iter, err := binder.renExTokens.FilterLogTokenRegistered(&bind.FilterOpts{})
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	codes := []uint32{}
+	seen := map[uint32]bool{}
+	for iter.Next() {
+		if !seen[iter.Event.TokenCode] {
+			seen[iter.Event.TokenCode] = true
+			codes = append(codes, iter.Event.TokenCode)
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		return codes[i] < codes[j]
+	})
+
+	ethereum, err := binder.renExBalances.ETHEREUM(&bind.CallOpts{})
+	if err != nil {
+		return nil, err
+	}
+	tokens := []RegisteredToken{}
+	for _, code := range codes {
+		// Tokens can be deregistered, and registered again with new details.
+		details, err := binder.renExTokens.Tokens(&bind.CallOpts{}, code)
+		if err != nil {
+			return nil, err
+		}
+		if !details.Registered {
+			continue
+		}
+		tokens = append(tokens, RegisteredToken{
+			Code:     code,
+			Address:  details.Addr,
+			Decimals: details.Decimals,
+			Symbol:   binder.tokenSymbol(details.Addr, ethereum),
+		})
+	}
+	return tokens, nil
+}
+
+// tokenSymbol returns the symbol of an ERC20 token, or an empty string if the
+// token does not implement the symbol method. Ether is stored by the
+// RenExBalances contract using the ethereum address.
+func (binder *Binder) tokenSymbol(token, ethereum common.Address) string {
+	if token == ethereum {
+		return "ETH"
+	}
+	// The RepublicToken is a detailed ERC20 token, so its binding can call the
+	// symbol method of other tokens.
+	erc20, err := bindings.NewRepublicTokenCaller(token, binder.backend)
+	if err != nil {
+		return ""
+	}
+	symbol, err := erc20.Symbol(&bind.CallOpts{})
+	if err != nil {
+		return ""
+	}
+	return symbol
+}
+
+// TraderBalance returns the balance of a token that the trader has deposited
+// in the RenExBalances contract.
+func (binder *Binder) TraderBalance(trader, token common.Address) (*big.Int, error) {
+	return binder.renExBalances.TraderBalances(&bind.CallOpts{}, trader, token)
+}
+
+// TraderWithdrawalSignal returns the time at which the trader signaled a
+// backup withdrawal of a token from the RenExBalances contract, or zero if
+// the trader has not signaled a withdrawal.
+func (binder *Binder) TraderWithdrawalSignal(trader, token common.Address) (*big.Int, error) {
+	return binder.renExBalances.TraderWithdrawalSignals(&bind.CallOpts{}, trader, token)
+}
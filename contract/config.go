@@ -1,10 +1,5 @@
 package contract
 
-import (
-	republicContract "github.com/republicprotocol/republic-go/contract"
-	"github.com/republicprotocol/republic-go/identity"
-)
-
 // Network is used to represent a Republic Protocol network.
 type Network string
 
@@ -19,13 +14,6 @@ const (
 	NetworkLocal Network = "local"
 )
 
-type Config struct {
-	RepublicEthereum        republicContract.Config `json:"republic"`
-	RenExEthereum           RenExConfig             `json:"renex"`
-	BootstrapMultiAddresses identity.MultiAddresses `json:"bootstrapMultiAddresses"`
-	ApprovedTraders         []string                `json:"approvedTraders"`
-}
-
 // RenExConfig defines the different settings for connecting to Ethereum on
 // different Republic Protocol networks.
 type RenExConfig struct {
@@ -2,7 +2,6 @@ package contract
 
 import (
 	"fmt"
-	"os"
 
 	"github.com/ethereum/go-ethereum/ethclient"
 	ethrpc "github.com/ethereum/go-ethereum/rpc"
@@ -15,24 +14,15 @@ type Conn struct {
 	Config    RenExConfig
 }
 
-// Connect to a URI.
+// Connect to a URI. The URI must be configured for all networks except the
+// local network.
 func Connect(config RenExConfig) (Conn, error) {
-	infuraKey := os.Getenv("INFURA_KEY")
-	if infuraKey == "" {
-		panic("fail to read infura project id")
-	}
 	if config.URI == "" {
 		switch config.Network {
-		case NetworkMainnet:
-			config.URI = fmt.Sprintf("https://mainnet.infura.io/v3/%v", infuraKey)
-		case NetworkTestnet:
-			config.URI = fmt.Sprintf("https://kovan.infura.io/v3/%v", infuraKey)
-		case NetworkNightly:
-			config.URI = fmt.Sprintf("https://kovan.infura.io/v3/%v", infuraKey)
 		case NetworkLocal:
 			config.URI = "http://localhost:8545"
 		default:
-			return Conn{}, fmt.Errorf("cannot connect to %s: unsupported", config.Network)
+			return Conn{}, fmt.Errorf("cannot connect to %s: no uri configured", config.Network)
 		}
 	}
 
@@ -0,0 +1,115 @@
+package httpadapter
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/republicprotocol/renex-ingress-go/ingress"
+)
+
+// GetApprovedTradersHandler returns all traders that have been manually
+// approved.
+func GetApprovedTradersHandler(approverAdapter ApproverAdapter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		traders, err := approverAdapter.ApprovedTraders()
+		if err != nil {
+			handleErr(w, fmt.Sprintf("cannot get approved traders: %v", err), http.StatusInternalServerError)
+			return
+		}
+		response, err := json.Marshal(traders)
+		if err != nil {
+			handleErr(w, fmt.Sprintf("cannot marshal approved traders: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(response)
+	}
+}
+
+// PostApprovedTraderHandler approves a trader, or updates the approval of an
+// already approved trader. Approvals cannot expire in the past.
+func PostApprovedTraderHandler(approverAdapter ApproverAdapter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req PostApprovedTraderRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			handleErr(w, fmt.Sprintf("cannot decode request: %v", err), http.StatusBadRequest)
+			return
+		}
+		if _, err := UnmarshalAddress(req.Address); err != nil {
+			handleErr(w, fmt.Sprintf("cannot approve trader: %v", err), http.StatusBadRequest)
+			return
+		}
+		if req.ApprovedBy == "" {
+			handleErr(w, "cannot approve trader: approver is required", http.StatusBadRequest)
+			return
+		}
+		if req.ExpiresAt != 0 && req.ExpiresAt <= time.Now().Unix() {
+			handleErr(w, "cannot approve trader: approval has already expired", http.StatusBadRequest)
+			return
+		}
+
+		trader := ingress.ApprovedTrader{
+			Address:    req.Address,
+			Note:       req.Note,
+			ApprovedBy: req.ApprovedBy,
+			ExpiresAt:  req.ExpiresAt,
+		}
+		if err := approverAdapter.InsertApprovedTrader(trader); err != nil {
+			handleErr(w, fmt.Sprintf("cannot approve trader: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+// DeleteApprovedTraderHandler removes the approval of a trader.
+func DeleteApprovedTraderHandler(approverAdapter ApproverAdapter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		address := mux.Vars(r)["address"]
+		if err := approverAdapter.DeleteApprovedTrader(address); err != nil {
+			if err == sql.ErrNoRows {
+				handleErr(w, fmt.Sprintf("cannot remove approved trader: %v has not been approved", address), http.StatusNotFound)
+				return
+			}
+			handleErr(w, fmt.Sprintf("cannot remove approved trader: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// adminAuth only forwards requests that present the admin token as a bearer
+// token. All requests are rejected if the admin token is empty.
+func adminAuth(adminToken string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if adminToken == "" {
+			http.Error(w, "admin endpoints are disabled", http.StatusForbidden)
+			return
+		}
+		if !adminAuthorized(adminToken, r) {
+			http.Error(w, ErrUnauthorized.Error(), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+// adminAuthorized returns true if the request presents the admin token as a
+// bearer token, and the admin token is not empty.
+func adminAuthorized(adminToken string, r *http.Request) bool {
+	if adminToken == "" {
+		return false
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1
+}
@@ -0,0 +1,92 @@
+package httpadapter
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gorilla/mux"
+)
+
+// GetAuthorizeHandler returns the addresses that have been authorized by a
+// trader to share its verification. The request must be signed by the trader,
+// using the nonce and signature query parameters, present a session of the
+// trader, or present the admin token.
+func GetAuthorizeHandler(loginAdapter LoginAdapter, nonceAdapter NonceAdapter, sessionAdapter SessionAdapter, domain, adminToken string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		address := mux.Vars(r)["address"]
+		if _, err := UnmarshalAddress(address); err != nil {
+			handleErr(w, fmt.Sprintf("cannot get authorized addresses: %v", err), http.StatusBadRequest)
+			return
+		}
+		if !verifyTraderRead(w, r, nonceAdapter, sessionAdapter, domain, adminToken, ActionAuthorizedAddresses, address) {
+			return
+		}
+		addresses, err := loginAdapter.AuthorizedAddresses(address)
+		if err != nil {
+			handleErr(w, fmt.Sprintf("cannot get authorized addresses: %v", err), http.StatusInternalServerError)
+			return
+		}
+		response, err := json.Marshal(addresses)
+		if err != nil {
+			handleErr(w, fmt.Sprintf("cannot marshal authorized addresses: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(response)
+	}
+}
+
+// DeleteAuthorizeHandler revokes an authorized address. The request must be
+// signed by the trader that authorized the address.
+func DeleteAuthorizeHandler(loginAdapter LoginAdapter, nonceAdapter NonceAdapter, domain string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		address := mux.Vars(r)["address"]
+		if _, err := UnmarshalAddress(address); err != nil {
+			handleErr(w, fmt.Sprintf("cannot revoke authorized address: %v", err), http.StatusBadRequest)
+			return
+		}
+		var req DeleteAuthorizeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			handleErr(w, fmt.Sprintf("cannot decode request: %v", err), http.StatusBadRequest)
+			return
+		}
+		signerAddr, ok := verifySignedRequest(w, nonceAdapter, domain, ActionUnauthorize, address, req.Nonce, req.Signature, "")
+		if !ok {
+			return
+		}
+
+		if err := loginAdapter.Unauthorize(signerAddr, address); err != nil {
+			if err == sql.ErrNoRows {
+				handleErr(w, fmt.Sprintf("cannot revoke authorized address: %v is not authorized by %v", address, signerAddr), http.StatusNotFound)
+				return
+			}
+			handleErr(w, fmt.Sprintf("cannot revoke authorized address: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// recoverSigner returns the address that signed the message, using the
+// Ethereum signed message prefix. The signature is encoded using base64.
+func recoverSigner(message, signature string) (string, error) {
+	data := []byte(message)
+	signatureData := append([]byte(fmt.Sprintf("\x19Ethereum Signed Message:\n%d", len(data))), data...)
+	hash := crypto.Keccak256(signatureData)
+	sigBytes, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return "", fmt.Errorf("unable marshal the signature, %v", err)
+	}
+	publicKey, err := crypto.SigToPub(hash, sigBytes)
+	if err != nil {
+		return "", fmt.Errorf("unable verify signature address, %v", err)
+	}
+	return crypto.PubkeyToAddress(*publicKey).Hex(), nil
+}
@@ -0,0 +1,37 @@
+package httpadapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// GetBalancesHandler returns the balances of a trader in the RenExBalances
+// contract for every token registered in the RenExTokens contract, the times
+// at which the trader signaled backup withdrawals, and the nonce that the next
+// withdrawal signature will be signed with.
+func GetBalancesHandler(balanceAdapter BalanceAdapter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		address := mux.Vars(r)["address"]
+		if _, err := UnmarshalAddress(address); err != nil {
+			handleErr(w, fmt.Sprintf("cannot get balances: %v", err), http.StatusBadRequest)
+			return
+		}
+		balances, err := balanceAdapter.Balances(address)
+		if err != nil {
+			handleErr(w, fmt.Sprintf("cannot get balances: %v", err), http.StatusInternalServerError)
+			return
+		}
+		response, err := json.Marshal(balances)
+		if err != nil {
+			handleErr(w, fmt.Sprintf("cannot marshal balances: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(response)
+	}
+}
@@ -0,0 +1,148 @@
+package httpadapter
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/republicprotocol/renex-ingress-go/ingress"
+)
+
+// SessionTTL is the duration for which a session can be used to stream the
+// events of a trader after it has been issued.
+const SessionTTL = 24 * time.Hour
+
+// EventStreamHeartbeat is the interval at which comments are written to event
+// streams, so that idle connections are not closed by proxies.
+var EventStreamHeartbeat = 15 * time.Second
+
+// A Session authenticates the event stream of a trader. Unlike a nonce, a
+// session can be used until it expires, so that event streams can reconnect.
+type Session struct {
+	Token     string `json:"token"`
+	Trader    string `json:"trader"`
+	ExpiresAt int64  `json:"expiresAt"`
+}
+
+// IssueSession implements the SessionAdapter interface.
+func (adapter *ingressAdapter) IssueSession(trader string) (Session, error) {
+	if _, err := UnmarshalAddress(trader); err != nil {
+		return Session{}, err
+	}
+	tokenBytes := [32]byte{}
+	if _, err := rand.Read(tokenBytes[:]); err != nil {
+		return Session{}, err
+	}
+	session := Session{
+		Token:     hex.EncodeToString(tokenBytes[:]),
+		Trader:    trader,
+		ExpiresAt: time.Now().Add(SessionTTL).Unix(),
+	}
+	if err := adapter.InsertSession(session.Token, session.Trader, session.ExpiresAt); err != nil {
+		return Session{}, err
+	}
+	return session, nil
+}
+
+// PostSessionHandler issues a Session that a trader uses to stream its
+// events. The request must be signed by the trader.
+func PostSessionHandler(sessionAdapter SessionAdapter, nonceAdapter NonceAdapter, domain string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req PostSessionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			handleErr(w, fmt.Sprintf("cannot decode request: %v", err), http.StatusBadRequest)
+			return
+		}
+		if _, err := UnmarshalAddress(req.Address); err != nil {
+			handleErr(w, fmt.Sprintf("cannot issue session: %v", err), http.StatusBadRequest)
+			return
+		}
+		if _, ok := verifySignedRequest(w, nonceAdapter, domain, ActionSession, req.Address, req.Nonce, req.Signature, req.Address); !ok {
+			return
+		}
+
+		session, err := sessionAdapter.IssueSession(req.Address)
+		if err != nil {
+			handleErr(w, fmt.Sprintf("cannot issue session: %v", err), http.StatusInternalServerError)
+			return
+		}
+		response, err := json.Marshal(session)
+		if err != nil {
+			handleErr(w, fmt.Sprintf("cannot marshal session: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write(response)
+	}
+}
+
+// GetEventsHandler streams the events of a trader as server-sent events,
+// until the client disconnects. The request must present a session of the
+// trader in the session query parameter, because browsers cannot set headers
+// on event streams.
+func GetEventsHandler(sessionAdapter SessionAdapter, eventAdapter EventAdapter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		address := mux.Vars(r)["address"]
+		if _, err := UnmarshalAddress(address); err != nil {
+			handleErr(w, fmt.Sprintf("cannot stream events: %v", err), http.StatusBadRequest)
+			return
+		}
+		trader, err := sessionAdapter.SessionTrader(r.URL.Query().Get("session"))
+		if err != nil {
+			if err == ingress.ErrInvalidSession {
+				handleErr(w, fmt.Sprintf("cannot stream events: %v", err), http.StatusUnauthorized)
+				return
+			}
+			handleErr(w, fmt.Sprintf("cannot get session: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if !sameAddress(trader, address) {
+			handleErr(w, fmt.Sprintf("cannot stream events: session of %v cannot stream events of %v", trader, address), http.StatusUnauthorized)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			handleErr(w, "cannot stream events: streaming is not supported", http.StatusInternalServerError)
+			return
+		}
+
+		events, unsubscribe := eventAdapter.Subscribe(address)
+		defer unsubscribe()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		heartbeat := time.NewTicker(EventStreamHeartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": heartbeat\n\n")
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				data, err := json.Marshal(event)
+				if err != nil {
+					log.Printf("[error] (events) cannot marshal event = %v: %v", event.ID, err)
+					continue
+				}
+				fmt.Fprintf(w, "id: %v\nevent: %v\ndata: %s\n\n", event.ID, event.Type, data)
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -1,19 +1,14 @@
 package httpadapter
 
 import (
-	"bytes"
 	"database/sql"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"expvar"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"net/http"
-	"net/url"
-	"os"
-	"strconv"
-	"strings"
 	"time"
 
 	"github.com/ethereum/go-ethereum/crypto"
@@ -21,15 +16,25 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/renproject/swapperd/foundation/blockchain"
 	"github.com/renproject/swapperd/foundation/swap"
+	"github.com/republicprotocol/renex-ingress-go/config"
 	"github.com/republicprotocol/renex-ingress-go/ingress"
+	"github.com/republicprotocol/renex-ingress-go/kyber"
 	"github.com/rs/cors"
 	"golang.org/x/crypto/sha3"
 	"golang.org/x/time/rate"
 )
 
 type loginRequest struct {
-	Address  string `json:"address"`
-	Referrer string `json:"referrer"`
+	Address string `json:"address"`
+
+	// Referrer is the address or the referral code of the trader that
+	// referred the trader. ReferralCode takes precedence when both are set.
+	Referrer     string `json:"referrer"`
+	ReferralCode string `json:"referralCode"`
+
+	// The request is signed by the trader (see SignedMessage).
+	Nonce     string `json:"nonce"`
+	Signature string `json:"signature"`
 }
 
 type loginResponse struct {
@@ -37,33 +42,8 @@ type loginResponse struct {
 }
 
 type kyberRequest struct {
-	Address string            `json:"address"`
-	Request clientAuthRequest `json:"request"`
-}
-
-type clientAuthRequest struct {
-	Type   string `json:"grant_type"`
-	Code   string `json:"code"`
-	URI    string `json:"redirect_uri"`
-	Key    string `json:"client_id"`
-	Secret string `json:"client_secret"`
-}
-
-type tokenResponse struct {
-	Type         string `json:"token_type"`
-	AccessToken  string `json:"access_token"`
-	Expiry       int    `json:"expires_in"`
-	RefreshToken string `json:"refresh_token"`
-}
-
-type userResponse struct {
-	UID       int      `json:"uid"`
-	Status    string   `json:"kyc_status"`
-	Addresses []string `json:"active_wallets"`
-}
-
-type usersResponse struct {
-	Users []userResponse `json:"authorized_users"`
+	Address string                     `json:"address"`
+	Request kyber.AuthorizationRequest `json:"request"`
 }
 
 type Message struct {
@@ -78,31 +58,39 @@ type delayInfo struct {
 	Signature string  `json:"signature"`
 }
 
-const (
-	statusApproved string = "approved"
-	statusPending  string = "pending"
-	statusNone     string = "none"
-)
-
-// TODO: Make this an environment variable
-var KYBER_URL string
-
-func init() {
-	KYBER_URL = os.Getenv("KYBER_URL")
-}
-
 // NewIngressServer returns an http server that forwards requests to an
 // IngressAdapter.
-func NewIngressServer(ingressAdapter IngressAdapter, approvedTraders []string, kyberID, kyberSecret string) http.Handler {
+func NewIngressServer(ingressAdapter IngressAdapter, conf config.Config) http.Handler {
 	limiter := rate.NewLimiter(3, 20)
+	kyberClient := kyber.NewClient(conf.Kyber.URL, conf.Kyber.ID, conf.Kyber.Secret, conf.Kyber.Timeout)
 	r := mux.NewRouter().StrictSlash(true)
-	r.HandleFunc("/kyc/{address}", rateLimit(limiter, GetKYCHandler(ingressAdapter, kyberID, kyberSecret))).Methods("GET")
-	r.HandleFunc("/orders", rateLimit(limiter, PostOrderHandler(ingressAdapter, approvedTraders, kyberID, kyberSecret))).Methods("POST")
-	r.HandleFunc("/login", rateLimit(limiter, PostLoginHandler(ingressAdapter, kyberID, kyberSecret))).Methods("POST")
-	r.HandleFunc("/kyber", rateLimit(limiter, PostKyberHandler(ingressAdapter, kyberID, kyberSecret))).Methods("POST")
-	r.HandleFunc("/withdrawals", rateLimit(limiter, PostWithdrawalHandler(ingressAdapter))).Methods("POST")
-	r.HandleFunc("/swapperd/cb", rateLimit(limiter, PostSwapCallbackHandler(ingressAdapter, kyberID, kyberSecret))).Methods("POST")
-	r.HandleFunc("/authorize", rateLimit(limiter, PostAuthorizeHandler(ingressAdapter, kyberID, kyberSecret))).Methods("POST")
+	r.HandleFunc("/kyc/{address}", rateLimit(limiter, GetKYCHandler(ingressAdapter))).Methods("GET")
+	r.HandleFunc("/nonces", rateLimit(limiter, PostNonceHandler(ingressAdapter, conf.Network))).Methods("POST")
+	r.HandleFunc("/orders", rateLimit(limiter, PostOrderHandler(ingressAdapter, conf.Network))).Methods("POST")
+	r.HandleFunc("/login", rateLimit(limiter, PostLoginHandler(ingressAdapter, ingressAdapter, conf.Network))).Methods("POST")
+	r.HandleFunc("/kyber", rateLimit(limiter, PostKyberHandler(ingressAdapter, kyberClient))).Methods("POST")
+	r.HandleFunc("/withdrawals", rateLimit(limiter, PostWithdrawalHandler(ingressAdapter, ingressAdapter, conf.Network))).Methods("POST")
+	r.HandleFunc("/swapperd/cb", rateLimit(limiter, PostSwapCallbackHandler(ingressAdapter, conf))).Methods("POST")
+	r.HandleFunc("/authorize", rateLimit(limiter, PostAuthorizeHandler(ingressAdapter, conf.Network))).Methods("POST")
+	r.HandleFunc("/authorize/{address}", rateLimit(limiter, GetAuthorizeHandler(ingressAdapter, ingressAdapter, ingressAdapter, conf.Network, conf.AdminToken))).Methods("GET")
+	r.HandleFunc("/authorize/{address}", rateLimit(limiter, DeleteAuthorizeHandler(ingressAdapter, ingressAdapter, conf.Network))).Methods("DELETE")
+	r.HandleFunc("/swaps/{orderID:.+}", rateLimit(limiter, GetSwapHandler(ingressAdapter, ingressAdapter, conf.Network, conf.AdminToken))).Methods("GET")
+	r.HandleFunc("/vaults", rateLimit(limiter, GetVaultsHandler(conf.Vaults))).Methods("GET")
+	r.HandleFunc("/webhooks", rateLimit(limiter, PostWebhookHandler(ingressAdapter, ingressAdapter, ingressAdapter, conf.Network, conf.Local()))).Methods("POST")
+	r.HandleFunc("/webhooks/{id}", rateLimit(limiter, DeleteWebhookHandler(ingressAdapter, ingressAdapter, conf.Network))).Methods("DELETE")
+	r.HandleFunc("/sessions", rateLimit(limiter, PostSessionHandler(ingressAdapter, ingressAdapter, conf.Network))).Methods("POST")
+	r.HandleFunc("/orderbook/orders", rateLimit(limiter, GetIndexedOrdersHandler(ingressAdapter))).Methods("GET")
+	r.HandleFunc("/orderbook/orders/{orderID:.+}", rateLimit(limiter, GetIndexedOrderHandler(ingressAdapter))).Methods("GET")
+	r.HandleFunc("/traders/{address}/orders", rateLimit(limiter, GetTraderOrdersHandler(ingressAdapter))).Methods("GET")
+	r.HandleFunc("/traders/{address}/events", rateLimit(limiter, GetEventsHandler(ingressAdapter, ingressAdapter))).Methods("GET")
+	r.HandleFunc("/balances/{address}", rateLimit(limiter, GetBalancesHandler(ingressAdapter))).Methods("GET")
+	r.HandleFunc("/referrals/{address}", rateLimit(limiter, GetReferralsHandler(ingressAdapter, ingressAdapter, ingressAdapter, conf.Network, conf.AdminToken))).Methods("GET")
+	r.HandleFunc("/admin/traders", adminAuth(conf.AdminToken, GetApprovedTradersHandler(ingressAdapter))).Methods("GET")
+	r.HandleFunc("/admin/traders", adminAuth(conf.AdminToken, PostApprovedTraderHandler(ingressAdapter))).Methods("POST")
+	r.HandleFunc("/admin/traders/{address}", adminAuth(conf.AdminToken, DeleteApprovedTraderHandler(ingressAdapter))).Methods("DELETE")
+	r.HandleFunc("/admin/swaps/refundable", adminAuth(conf.AdminToken, GetRefundableSwapsHandler(ingressAdapter))).Methods("GET")
+	r.HandleFunc("/admin/webhooks/failed", adminAuth(conf.AdminToken, GetFailedWebhookDeliveriesHandler(ingressAdapter))).Methods("GET")
+	r.HandleFunc("/admin/metrics", adminAuth(conf.AdminToken, expvar.Handler().ServeHTTP)).Methods("GET")
 	r.Use(RecoveryHandler)
 
 	handler := cors.New(cors.Options{
@@ -115,7 +103,7 @@ func NewIngressServer(ingressAdapter IngressAdapter, approvedTraders []string, k
 }
 
 // PostOrderHandler handles all HTTP open order requests
-func PostOrderHandler(ingressAdapter IngressAdapter, approvedTraders []string, kyberID, kyberSecret string) http.HandlerFunc {
+func PostOrderHandler(ingressAdapter IngressAdapter, domain string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		openOrderRequest := OpenOrderRequest{}
 		if err := json.NewDecoder(r.Body).Decode(&openOrderRequest); err != nil {
@@ -123,11 +111,26 @@ func PostOrderHandler(ingressAdapter IngressAdapter, approvedTraders []string, k
 			w.Write([]byte(fmt.Sprintf("cannot decode json into an order or a list of order fragments: %v", err)))
 			return
 		}
+		orderID := requestOrderID(openOrderRequest.OrderFragmentMappings)
+		if _, ok := verifySignedRequest(w, ingressAdapter, domain, ActionOpenOrder, orderID, openOrderRequest.Nonce, openOrderRequest.Signature, openOrderRequest.Address); !ok {
+			return
+		}
 
 		// If the trader has not been manually approved (e.g. Lotan traders),
 		// check their verification status.
-		if !traderApproved(openOrderRequest.Address, approvedTraders) {
-			kycType, err := traderVerified(ingressAdapter, kyberID, kyberSecret, openOrderRequest.Address)
+		approved, err := ingressAdapter.TraderApproved(openOrderRequest.Address)
+		if err != nil {
+			errString := fmt.Sprintf("cannot check trader approval: %v", err)
+			log.Println(errString)
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(errString))
+			raven.CaptureErrorAndWait(errors.New(errString), map[string]string{
+				"trader": openOrderRequest.Address,
+			})
+			return
+		}
+		if !approved {
+			kycType, err := ingressAdapter.TraderVerified(openOrderRequest.Address)
 			if err != nil {
 				errString := fmt.Sprintf("cannot check trader verification: %v", err)
 				log.Println(errString)
@@ -173,7 +176,7 @@ func PostOrderHandler(ingressAdapter IngressAdapter, approvedTraders []string, k
 }
 
 // PostLoginHandler handles trader login requests
-func PostLoginHandler(loginAdapter LoginAdapter, kyberID, kyberSecret string) http.HandlerFunc {
+func PostLoginHandler(loginAdapter LoginAdapter, nonceAdapter NonceAdapter, domain string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Decode POST request data
 		var data loginRequest
@@ -183,9 +186,20 @@ func PostLoginHandler(loginAdapter LoginAdapter, kyberID, kyberSecret string) ht
 			http.Error(w, errString, http.StatusBadRequest)
 			return
 		}
+		if _, ok := verifySignedRequest(w, nonceAdapter, domain, ActionLogin, data.Address, data.Nonce, data.Signature, data.Address); !ok {
+			return
+		}
 
 		// Store address in database if it does not already exist
-		if err := loginAdapter.PostLogin(data.Address, data.Referrer); err != nil {
+		referrer := data.Referrer
+		if data.ReferralCode != "" {
+			referrer = data.ReferralCode
+		}
+		if err := loginAdapter.PostLogin(data.Address, referrer); err != nil {
+			if err == ErrUnknownReferralCode {
+				http.Error(w, fmt.Sprintf("cannot store login address: %v", err), http.StatusBadRequest)
+				return
+			}
 			errString := fmt.Sprintf("cannot store login address: %v", err)
 			log.Println(errString)
 			http.Error(w, errString, http.StatusInternalServerError)
@@ -196,7 +210,7 @@ func PostLoginHandler(loginAdapter LoginAdapter, kyberID, kyberSecret string) ht
 		}
 
 		// Check if the trader is verified
-		kycType, err := traderVerified(loginAdapter, kyberID, kyberSecret, data.Address)
+		kycType, err := loginAdapter.TraderVerified(data.Address)
 		if err != nil {
 			errString := fmt.Sprintf("cannot check trader verification: %v", err)
 			log.Println(errString)
@@ -223,7 +237,7 @@ func PostLoginHandler(loginAdapter LoginAdapter, kyberID, kyberSecret string) ht
 }
 
 // PostKyberHandler handles all Kyber authorization requests
-func PostKyberHandler(loginAdapter LoginAdapter, kyberID, kyberSecret string) http.HandlerFunc {
+func PostKyberHandler(loginAdapter LoginAdapter, kyberClient *kyber.Client) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Decode POST request data
 		decoder := json.NewDecoder(r.Body)
@@ -235,98 +249,53 @@ func PostKyberHandler(loginAdapter LoginAdapter, kyberID, kyberSecret string) ht
 			return
 		}
 
-		// Construct new request object with Kyber secret key
-		authRequest := data.Request
-		authRequest.Secret = kyberSecret
-		byteArray, err := json.Marshal(authRequest)
-		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte(fmt.Sprintf("cannot marshal data: %v", err)))
-			return
-		}
-
-		// Forward updated request data to Kyber
-		url := KYBER_URL + "/oauth/token"
-		postRequest, err := http.NewRequest("POST", url, bytes.NewBuffer(byteArray))
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte(fmt.Sprintf("cannot construct new request: %v", err)))
-			return
-		}
-		postRequest.Header.Set("Content-Type", "application/json")
-
-		client := &http.Client{}
-		resp, err := client.Do(postRequest)
+		// Exchange the authorization code for an access token
+		token, err := kyberClient.ExchangeCode(r.Context(), data.Request)
 		if err != nil {
+			if err == kyber.ErrEmptyToken || err == kyber.ErrUnauthorized {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("invalid authorization code"))
+				return
+			}
 			w.WriteHeader(http.StatusInternalServerError)
 			w.Write([]byte(fmt.Sprintf("unable to forward request: %v", err)))
 			return
 		}
-		defer resp.Body.Close()
 
-		bodyBytes, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte(fmt.Sprintf("unable to read kyber response: %v", err)))
-			return
-		}
-
-		if len(bodyBytes) == 0 {
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte("invalid authorization code"))
-			return
-		}
-
-		var tokenResp tokenResponse
-		if err := json.Unmarshal(bodyBytes, &tokenResp); err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte(fmt.Sprintf("unable to read kyber response: %v", err)))
-			return
-		}
-
-		// Send retrieved access token to Kyber to access user information
-		userResp, err := http.Get(KYBER_URL + "/api/user_info?access_token=" + tokenResp.AccessToken)
+		// Use the access token to retrieve the trader's Kyber account
+		user, err := kyberClient.UserInfo(r.Context(), token.AccessToken)
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			w.Write([]byte(fmt.Sprintf("unable to retrieve user info: %v", err)))
 			return
 		}
-		defer userResp.Body.Close()
 
-		userBytes, err := ioutil.ReadAll(userResp.Body)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte(fmt.Sprintf("unable to read kyber response: %v", err)))
-			return
-		}
-
-		var userData userResponse
-		if err := json.Unmarshal(userBytes, &userData); err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte(fmt.Sprintf("unable to read kyber response: %v", err)))
-			return
-		}
-
-		if userData.Status != statusApproved {
+		if !user.Approved() {
 			w.WriteHeader(http.StatusUnauthorized)
-			w.Write([]byte(fmt.Sprintf("trader is not authorized: kyber status = %v", userData.Status)))
+			w.Write([]byte(fmt.Sprintf("trader is not authorized: kyber status = %v", user.Status)))
 			return
 		}
 
 		// Update verification time in database
-		if err := loginAdapter.PostVerification(data.Address, int64(userData.UID), ingress.KYCKyber); err != nil {
+		if err := loginAdapter.PostVerification(data.Address, user.UID, ingress.KYCKyber); err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			w.Write([]byte(fmt.Sprintf("failed to post verification: %v", err)))
 			return
 		}
 
+		userBytes, err := json.Marshal(user)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(fmt.Sprintf("cannot marshal user info: %v", err)))
+			return
+		}
 		w.WriteHeader(http.StatusOK)
 		w.Write(userBytes)
 	}
 }
 
 // PostWithdrawalHandler handles all HTTP open order requests
-func PostWithdrawalHandler(approveWithdrawalAdapter ApproveWithdrawalAdapter) http.HandlerFunc {
+func PostWithdrawalHandler(approveWithdrawalAdapter ApproveWithdrawalAdapter, nonceAdapter NonceAdapter, domain string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		approveWithdrawalRequest := ApproveWithdrawalRequest{}
 		if err := json.NewDecoder(r.Body).Decode(&approveWithdrawalRequest); err != nil {
@@ -334,6 +303,10 @@ func PostWithdrawalHandler(approveWithdrawalAdapter ApproveWithdrawalAdapter) ht
 			w.Write([]byte(fmt.Sprintf("cannot decode json into approve withdrawal request: %v", err)))
 			return
 		}
+		tokenID := fmt.Sprintf("%v", approveWithdrawalRequest.TokenID)
+		if _, ok := verifySignedRequest(w, nonceAdapter, domain, ActionWithdraw, tokenID, approveWithdrawalRequest.Nonce, approveWithdrawalRequest.Signature, approveWithdrawalRequest.Trader); !ok {
+			return
+		}
 		signature, err := approveWithdrawalAdapter.ApproveWithdrawal(approveWithdrawalRequest.Trader, approveWithdrawalRequest.TokenID)
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
@@ -355,11 +328,11 @@ func PostWithdrawalHandler(approveWithdrawalAdapter ApproveWithdrawalAdapter) ht
 	}
 }
 
-func GetKYCHandler(ingressAdapter IngressAdapter, kyberID, kyberSecret string) http.HandlerFunc {
+func GetKYCHandler(ingressAdapter IngressAdapter) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		params := mux.Vars(r)
 		address := params["address"]
-		kycType, err := traderVerified(ingressAdapter, kyberID, kyberSecret, address)
+		kycType, err := ingressAdapter.TraderVerified(address)
 		if err != nil {
 			errString := fmt.Sprintf("cannot check trader verification: %v", err)
 			log.Println(errString)
@@ -379,7 +352,7 @@ func GetKYCHandler(ingressAdapter IngressAdapter, kyberID, kyberSecret string) h
 	}
 }
 
-func PostSwapCallbackHandler(ingressAdapter IngressAdapter, kyberID, kyberSecret string) http.HandlerFunc {
+func PostSwapCallbackHandler(ingressAdapter IngressAdapter, conf config.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var blob swap.SwapBlob
 		if err := json.NewDecoder(r.Body).Decode(&blob); err != nil {
@@ -414,7 +387,7 @@ func PostSwapCallbackHandler(ingressAdapter IngressAdapter, kyberID, kyberSecret
 			return
 		}
 		signerAddr := crypto.PubkeyToAddress(*publicKey).Hex()
-		kycType, err := traderVerified(ingressAdapter, kyberID, kyberSecret, signerAddr)
+		kycType, err := ingressAdapter.TraderVerified(signerAddr)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusUnauthorized)
 			return
@@ -424,19 +397,61 @@ func PostSwapCallbackHandler(ingressAdapter IngressAdapter, kyberID, kyberSecret
 			return
 		}
 
+		if _, err := UnmarshalSecretHash(blob.SecretHash); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sendToken, err := blockchain.PatchToken(string(blob.SendToken))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		receiveToken, err := blockchain.PatchToken(string(blob.ReceiveToken))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		policy := conf.TimeLocks.Policy(string(sendToken.Blockchain), string(sendToken.Name), string(receiveToken.Blockchain), string(receiveToken.Name))
+
 		// return the finalized blob if we have the finalized blob
+		now := time.Now()
 		pSwap := ingress.PartialSwap{
-			OrderID:     info.Message.OrderID,
-			KycAddr:     info.Message.KycAddr,
-			SendTo:      info.Message.SendTokenAddr,
-			ReceiveFrom: info.Message.ReceiveTokenAddr,
-			SecretHash:  blob.SecretHash,
-			TimeLock:    time.Now().Add(48 * time.Hour).Unix(),
+			OrderID:        info.Message.OrderID,
+			KycAddr:        info.Message.KycAddr,
+			SendTo:         info.Message.SendTokenAddr,
+			ReceiveFrom:    info.Message.ReceiveTokenAddr,
+			SecretHash:     blob.SecretHash,
+			TimeLock:       now.Add(policy.Duration).Unix(),
+			CreatedAt:      now.Unix(),
+			TimeLockGap:    int64(policy.Gap / time.Second),
+			TimeLockMargin: int64(policy.Margin / time.Second),
+		}
+		// Partial swaps that are registered again keep their timelocks, so
+		// that swapperd can retry its registration.
+		stored, err := ingressAdapter.PartialSwap(pSwap.OrderID)
+		if err != nil && err != sql.ErrNoRows {
+			http.Error(w, fmt.Sprintf("cannot get partial swap: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if err == nil {
+			pSwap.TimeLock, pSwap.CreatedAt = stored.TimeLock, stored.CreatedAt
+			pSwap.TimeLockGap, pSwap.TimeLockMargin = stored.TimeLockGap, stored.TimeLockMargin
+		}
+		if err := ingressAdapter.InsertPartialSwap(pSwap); err != nil {
+			if err == ingress.ErrSecretHashReused || err == ingress.ErrPartialSwapConflict {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+			http.Error(w, fmt.Sprintf("cannot store partial swap: %v", err), http.StatusInternalServerError)
+			return
 		}
-		defer ingressAdapter.InsertPartialSwap(pSwap)
 
 		// Check if we have the finalized blob info.
 		finalizedSwap, canceled, err := ingressAdapter.FinalizedSwap(pSwap.OrderID)
+		if err == ingress.ErrUnsafeTimeLock {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
 		if err != nil {
 			log.Println("no content:", err)
 			http.Error(w, err.Error(), http.StatusNoContent)
@@ -458,22 +473,12 @@ func PostSwapCallbackHandler(ingressAdapter IngressAdapter, kyberID, kyberSecret
 		blob.TimeLock = finalizedSwap.TimeLock
 		blob.SecretHash = finalizedSwap.SecretHash
 
-		sendToken, err := blockchain.PatchToken(string(blob.SendToken))
+		blob.BrokerSendTokenAddr, err = brokerAddress(conf.Vaults, sendToken)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		blob.BrokerSendTokenAddr, err = brokerAddress(sendToken.Blockchain)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
-		receiveToken, err := blockchain.PatchToken(string(blob.ReceiveToken))
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
-		blob.BrokerReceiveTokenAddr, err = brokerAddress(receiveToken.Blockchain)
+		blob.BrokerReceiveTokenAddr, err = brokerAddress(conf.Vaults, receiveToken)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
@@ -492,7 +497,7 @@ func PostSwapCallbackHandler(ingressAdapter IngressAdapter, kyberID, kyberSecret
 	}
 }
 
-func PostAuthorizeHandler(ingressAdapter IngressAdapter, kyberID, kyberSecret string) http.HandlerFunc {
+func PostAuthorizeHandler(ingressAdapter IngressAdapter, domain string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Decode the request
 		var auth PostAuthorizeRequest
@@ -501,23 +506,13 @@ func PostAuthorizeHandler(ingressAdapter IngressAdapter, kyberID, kyberSecret st
 			return
 		}
 
-		message := []byte(fmt.Sprintf("RenEx: authorize: %v", auth.Address))
-		signatureData := append([]byte(fmt.Sprintf("\x19Ethereum Signed Message:\n%d", len(message))), message...)
-		hash := crypto.Keccak256(signatureData)
-		sigBytes, err := base64.StdEncoding.DecodeString(auth.Signature)
-		if err != nil {
-			handleErr(w, fmt.Sprintf("unable marshal the signature, %v", err), http.StatusInternalServerError)
-			return
-		}
-		publicKey, err := crypto.SigToPub(hash[:], sigBytes)
-		if err != nil {
-			http.Error(w, fmt.Sprintf("unable verify signature address, %v", err), http.StatusInternalServerError)
+		signerAddr, ok := verifySignedRequest(w, ingressAdapter, domain, ActionAuthorize, auth.Address, auth.Nonce, auth.Signature, "")
+		if !ok {
 			return
 		}
-		signerAddr := crypto.PubkeyToAddress(*publicKey).Hex()
 
 		// Verify if the singer is kyced
-		kycType, err := traderVerified(ingressAdapter, kyberID, kyberSecret, signerAddr)
+		kycType, err := ingressAdapter.TraderVerified(signerAddr)
 		if err != nil {
 			http.Error(w, fmt.Sprintf("%v: signer = %v", err.Error(), signerAddr), http.StatusUnauthorized)
 			return
@@ -535,25 +530,31 @@ func PostAuthorizeHandler(ingressAdapter IngressAdapter, kyberID, kyberSecret st
 			addr = auth.Address
 		default:
 			handleErr(w, "invalid address", http.StatusBadRequest)
+			return
 		}
 
 		if err := ingressAdapter.Authorize(signerAddr, addr); err != nil {
 			handleErr(w, fmt.Sprintf("cannot store the new address, %v", err), http.StatusInternalServerError)
+			return
 		}
 
 		w.WriteHeader(http.StatusCreated)
 	}
 }
 
-func brokerAddress(bcName blockchain.BlockchainName) (string, error) {
-	switch bcName {
-	case blockchain.Ethereum, blockchain.ERC20:
-		return os.Getenv("ETH_VAULT"), nil
-	case blockchain.Bitcoin:
-		return os.Getenv("BTC_VAULT"), nil
-	default:
-		return "", blockchain.NewErrUnsupportedBlockchain(bcName)
+// requestOrderID returns the order ID of the first order fragment in the
+// mappings, or an empty string if there are no order fragments. The order IDs
+// of all order fragments are checked to be equal when the mappings are
+// unmarshaled.
+func requestOrderID(orderFragmentMappings OrderFragmentMappings) string {
+	for _, orderFragmentMapping := range orderFragmentMappings {
+		for _, orderFragments := range orderFragmentMapping {
+			for _, orderFragment := range orderFragments {
+				return orderFragment.OrderID
+			}
+		}
 	}
+	return ""
 }
 
 // RecoveryHandler handles errors while processing the requests and populates the errors in the response
@@ -569,115 +570,6 @@ func RecoveryHandler(h http.Handler) http.Handler {
 	})
 }
 
-func traderVerified(loginAdapter LoginAdapter, kyberID, kyberSecret, address string) (int, error) {
-	disableKYC := os.Getenv("DISABLE_KYC") == "1"
-	if disableKYC {
-		return ingress.KYCWyre, nil
-	}
-	address = strings.TrimSpace(strings.ToLower(address))
-	if address[:2] != "0x" {
-		address = "0x" + address
-	}
-
-	verified, err := loginAdapter.WyreVerified(address)
-	if err != nil {
-		return ingress.KYCNone, fmt.Errorf("cannot check wyre verification: %v", err)
-	}
-	if verified {
-		if err := loginAdapter.PostVerification(address, 0, ingress.KYCWyre); err != nil {
-			return ingress.KYCNone, fmt.Errorf("cannot update wyre verification information in database: %v", err)
-		}
-		return ingress.KYCWyre, nil
-	}
-
-	// If the Wyre verification is unsuccessful, check if the
-	// trader has verified using Kyber
-	kyberUID, timestamp, err := loginAdapter.GetLogin(address)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return ingress.KYCNone, nil
-		}
-		return ingress.KYCNone, fmt.Errorf("cannot get verification information from database: %v", err)
-	}
-
-	// Check to see if the trader has verified using Kyber in the last 24
-	// hours
-	if timestamp != "" {
-		unix, err := strconv.ParseInt(timestamp, 10, 64)
-		if err != nil {
-			return ingress.KYCNone, fmt.Errorf("cannot parse timestamp: %v", err)
-		}
-		if time.Unix(unix, 0).After(time.Now().AddDate(0, 0, -1)) {
-			return ingress.KYCKyber, nil
-		}
-	}
-
-	// If user has not verified recently, retrieve access token for interacting
-	// with Kyber API
-	urlString := KYBER_URL + "/oauth/token"
-	resp, err := http.PostForm(urlString, url.Values{"grant_type": {"client_credentials"}, "client_id": {kyberID}, "client_secret": {kyberSecret}})
-	if err != nil {
-		return ingress.KYCNone, fmt.Errorf("cannot send information to kyber: %v", err)
-	}
-	defer resp.Body.Close()
-
-	bodyBytes, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return ingress.KYCNone, fmt.Errorf("cannot read information from kyber: %v", err)
-	}
-
-	var tokenResp tokenResponse
-	if err := json.Unmarshal(bodyBytes, &tokenResp); err != nil {
-		return ingress.KYCNone, fmt.Errorf("cannot unmarshal kyber access token data: %v", err)
-	}
-
-	// Retrieve information for trader with uID
-	resp, err = http.Get(KYBER_URL + "/api/authorized_users?access_token=" + tokenResp.AccessToken + "&uid=" + fmt.Sprintf("%v", kyberUID))
-	if err != nil {
-		return ingress.KYCNone, fmt.Errorf("cannot send user information to kyber: %v", err)
-	}
-	defer resp.Body.Close()
-
-	bodyBytes, err = ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return ingress.KYCNone, fmt.Errorf("cannot read user information from kyber: %v", err)
-	}
-
-	var usersResp usersResponse
-	if err := json.Unmarshal(bodyBytes, &usersResp); err != nil {
-		return ingress.KYCNone, fmt.Errorf("cannot unmarshal authorized kyber users: %v", err)
-	}
-
-	// Submit verification if the selected address is still verified with the
-	// trader's Kyber account
-	if len(usersResp.Users) > 0 {
-		for _, addr := range usersResp.Users[0].Addresses {
-			if addr == address {
-				if err := loginAdapter.PostVerification(address, kyberUID, ingress.KYCKyber); err != nil {
-					return ingress.KYCNone, fmt.Errorf("cannot update kyber verification information in database: %v", err)
-				}
-				return ingress.KYCKyber, nil
-			}
-		}
-		return ingress.KYCNone, nil
-	}
-
-	return ingress.KYCNone, nil
-}
-
-func traderApproved(address string, approvedTraders []string) bool {
-	if address[:2] == "0x" {
-		address = address[2:]
-	}
-	address = strings.ToLower(address)
-	for _, trader := range approvedTraders {
-		if strings.ToLower(trader) == address {
-			return true
-		}
-	}
-	return false
-}
-
 func rateLimit(limiter *rate.Limiter, next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// netAddr := r.RemoteAddr
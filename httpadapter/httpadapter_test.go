@@ -2,13 +2,24 @@ package httpadapter_test
 
 import (
 	"bytes"
+	"crypto/ecdsa"
+	"database/sql"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"sync/atomic"
+	"time"
 
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/renproject/swapperd/foundation/swap"
+	"github.com/republicprotocol/renex-ingress-go/config"
 	"github.com/republicprotocol/renex-ingress-go/ingress"
+	"golang.org/x/crypto/sha3"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -16,19 +27,68 @@ import (
 )
 
 type weakAdapter struct {
-	numOpened    int64
-	numWithdrawn int64
+	numOpened       int64
+	numWithdrawn    int64
+	numApproved     int64
+	numUnauthorized int64
+	numWebhooks     int64
+
+	// trader of all orders, and of the "session" session
+	trader string
+
+	// events streamed to all subscriptions, which are closed once the events
+	// have been streamed
+	events []ingress.Event
+
+	// address authorized by all traders, in addition to "0xauthorized"
+	authorized string
+
+	// the only registered webhook
+	webhook ingress.Webhook
+
+	// query of the last request for the orders of a trader
+	orderQuery ingress.OrderQuery
+
+	// query of the last request for indexed orders
+	indexQuery ingress.IndexQuery
+
+	// the only approved trader
+	approved string
 }
 
 var WEAK_SIGNATURE = [65]byte{'W', 'E', 'A', 'K'}
 
+// usedNonce is rejected by the weakAdapter.
+const usedNonce = "used"
+
+// unknownOrderID has not been indexed by the weakAdapter.
+var unknownOrderID = base64.StdEncoding.EncodeToString(make([]byte, 32))
+
+// signRequest signs the action using a new key, and returns the address of
+// the key and the signature.
+func signRequest(action, payload, nonce string) (string, string) {
+	key, err := crypto.GenerateKey()
+	Expect(err).ShouldNot(HaveOccurred())
+	return signRequestWithKey(key, action, payload, nonce)
+}
+
+// signRequestWithKey signs the action using the key, and returns the address
+// of the key and the signature.
+func signRequestWithKey(key *ecdsa.PrivateKey, action, payload, nonce string) (string, string) {
+	message := []byte(SignedMessage("", action, payload, nonce))
+	hash := crypto.Keccak256(append([]byte(fmt.Sprintf("\x19Ethereum Signed Message:\n%d", len(message))), message...))
+	signature, err := crypto.Sign(hash, key)
+	Expect(err).ShouldNot(HaveOccurred())
+	return crypto.PubkeyToAddress(key.PublicKey).Hex(), base64.StdEncoding.EncodeToString(signature)
+}
+
 func (adapter *weakAdapter) OpenOrder(trader string, orderFragmentMapping OrderFragmentMappings) ([65]byte, error) {
 	atomic.AddInt64(&adapter.numOpened, 1)
 	return WEAK_SIGNATURE, nil
 }
 
-func (adapter *weakAdapter) WyreVerified(trader string) (bool, error) {
-	return true, nil
+func (adapter *weakAdapter) TraderVerified(trader string) (int, error) {
+	return ingress.KYCWyre, nil
 }
 
 func (adapter *weakAdapter) ApproveWithdrawal(trader string, tokenID uint32) ([65]byte, error) {
@@ -36,8 +96,8 @@ func (adapter *weakAdapter) ApproveWithdrawal(trader string, tokenID uint32) ([6
 	return WEAK_SIGNATURE, nil
 }
 
-func (adapter *weakAdapter) GetLogin(string) (int64, string, error) {
-	return 0, "", nil
+func (adapter *weakAdapter) GetLogin(string) (ingress.Login, error) {
+	return ingress.Login{}, nil
 }
 
 func (adapter *weakAdapter) PostLogin(string, string) error {
@@ -48,10 +108,57 @@ func (adapter *weakAdapter) PostVerification(string, int64, int) error {
 	return nil
 }
 
+func (adapter *weakAdapter) Referrals(address string) (Referrals, error) {
+	return Referrals{
+		Address:      address,
+		ReferralCode: "code",
+		Referrals:    []Referral{{Address: "0xreferred", KYC: KYCStatusNone}},
+	}, nil
+}
+
 func (adapter *weakAdapter) Authorize(authorizer, authorizedAddr string) error {
 	return nil
 }
 
+func (adapter *weakAdapter) AuthorizedAddresses(authorizer string) ([]GetAuthorizeResponse, error) {
+	addresses := []GetAuthorizeResponse{{AtomAddress: "0xauthorized", Status: true}}
+	if adapter.authorized != "" {
+		addresses = append(addresses, GetAuthorizeResponse{AtomAddress: adapter.authorized, Status: true})
+	}
+	return addresses, nil
+}
+
+func (adapter *weakAdapter) Unauthorize(authorizer, authorizedAddr string) error {
+	atomic.AddInt64(&adapter.numUnauthorized, 1)
+	return nil
+}
+
+func (adapter *weakAdapter) IssueNonce() (Challenge, error) {
+	return Challenge{Nonce: "nonce"}, nil
+}
+
+func (adapter *weakAdapter) ConsumeNonce(nonce string) error {
+	if nonce == usedNonce {
+		return ingress.ErrInvalidNonce
+	}
+	return nil
+}
+
+func (adapter *weakAdapter) SwapStatus(orderID string) (ingress.SwapStatus, error) {
+	return ingress.SwapStatus{OrderID: orderID, Matched: true}, nil
+}
+
+func (adapter *weakAdapter) RefundableSwaps() ([]ingress.RefundableSwap, error) {
+	return []ingress.RefundableSwap{{OrderID: "order", KycAddr: "0xtrader", TimeLock: 1, RefundableAt: 2}}, nil
+}
+
+func (adapter *weakAdapter) OrderTrader(orderID string) (string, error) {
+	if adapter.trader == "" {
+		return "", ErrUnknownOrder
+	}
+	return adapter.trader, nil
+}
+
 func (adapter *weakAdapter) InsertPartialSwap(swap ingress.PartialSwap) error {
 	return nil
 }
@@ -64,6 +171,130 @@ func (adapter *weakAdapter) FinalizedSwap(id string) (ingress.FinalizedSwap, boo
 	return ingress.FinalizedSwap{}, false, nil
 }
 
+func (adapter *weakAdapter) ApprovedTraders() ([]ingress.ApprovedTrader, error) {
+	return []ingress.ApprovedTrader{}, nil
+}
+
+func (adapter *weakAdapter) InsertApprovedTrader(trader ingress.ApprovedTrader) error {
+	atomic.AddInt64(&adapter.numApproved, 1)
+	return nil
+}
+
+func (adapter *weakAdapter) DeleteApprovedTrader(address string) error {
+	if !strings.EqualFold(address, adapter.approved) {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (adapter *weakAdapter) TraderApproved(address string) (bool, error) {
+	return false, nil
+}
+
+func (adapter *weakAdapter) RegisterWebhook(trader, url, registeredBy string) (ingress.Webhook, error) {
+	atomic.AddInt64(&adapter.numWebhooks, 1)
+	return ingress.Webhook{ID: "webhook", Trader: trader, URL: url, Secret: "secret", RegisteredBy: registeredBy}, nil
+}
+
+func (adapter *weakAdapter) Webhook(id string) (ingress.Webhook, error) {
+	if adapter.webhook.ID != id {
+		return ingress.Webhook{}, sql.ErrNoRows
+	}
+	return adapter.webhook, nil
+}
+
+func (adapter *weakAdapter) Webhooks(trader string) ([]ingress.Webhook, error) {
+	return []ingress.Webhook{}, nil
+}
+
+func (adapter *weakAdapter) DeleteWebhook(id string) error {
+	atomic.AddInt64(&adapter.numWebhooks, -1)
+	return nil
+}
+
+func (adapter *weakAdapter) FailedWebhookDeliveries() ([]ingress.WebhookDelivery, error) {
+	return []ingress.WebhookDelivery{{WebhookID: "webhook", EventID: "order.settled:order", Attempts: 8, FailedAt: 1}}, nil
+}
+
+func (adapter *weakAdapter) IssueSession(trader string) (Session, error) {
+	return Session{Token: "session", Trader: trader, ExpiresAt: 1}, nil
+}
+
+func (adapter *weakAdapter) SessionTrader(token string) (string, error) {
+	if token != "session" || adapter.trader == "" {
+		return "", ingress.ErrInvalidSession
+	}
+	return adapter.trader, nil
+}
+
+func (adapter *weakAdapter) Subscribe(trader string) (<-chan ingress.Event, func()) {
+	events := make(chan ingress.Event, len(adapter.events))
+	for _, event := range adapter.events {
+		events <- event
+	}
+	close(events)
+	return events, func() {}
+}
+
+func (adapter *weakAdapter) TraderOrders(trader string, query ingress.OrderQuery) (ingress.OrderPage, error) {
+	if query.State == "unknown" {
+		return ingress.OrderPage{}, ingress.ErrUnknownOrderState
+	}
+	adapter.orderQuery = query
+	return ingress.OrderPage{
+		Orders: []ingress.TraderOrder{{OrderID: "order", Trader: trader, State: ingress.OrderStateOpen}},
+		Offset: query.Offset,
+		Limit:  query.Limit,
+	}, nil
+}
+
+// IndexedOrder returns an open order, unless the order is the
+// unknownOrderID.
+func (adapter *weakAdapter) IndexedOrder(orderID string) (ingress.IndexedOrder, error) {
+	if orderID == unknownOrderID {
+		return ingress.IndexedOrder{}, sql.ErrNoRows
+	}
+	return ingress.IndexedOrder{OrderID: orderID, State: ingress.OrderStateOpen}, nil
+}
+
+func (adapter *weakAdapter) IndexedOrders(query ingress.IndexQuery) (ingress.IndexedOrderPage, error) {
+	if query.State == "unknown" {
+		return ingress.IndexedOrderPage{}, ingress.ErrUnknownOrderState
+	}
+	adapter.indexQuery = query
+	return ingress.IndexedOrderPage{
+		Orders: []ingress.IndexedOrder{{OrderID: "order", State: ingress.OrderStateOpen}},
+		Offset: query.Offset,
+		Limit:  query.Limit,
+	}, nil
+}
+
+func (adapter *weakAdapter) Balances(trader string) (ingress.TraderBalances, error) {
+	return ingress.TraderBalances{
+		Trader:          trader,
+		WithdrawalNonce: "1",
+		Balances:        []ingress.TokenBalance{{Token: 1, Symbol: "ETH", Decimals: 18, Balance: "100"}},
+	}, nil
+}
+
+// swapAdapter stores partial swaps in memory, and has not finalized any swap.
+type swapAdapter struct {
+	weakAdapter
+	swapper ingress.Swapper
+}
+
+func (adapter *swapAdapter) InsertPartialSwap(swap ingress.PartialSwap) error {
+	return adapter.swapper.InsertPartialSwap(swap)
+}
+
+func (adapter *swapAdapter) PartialSwap(id string) (ingress.PartialSwap, error) {
+	return adapter.swapper.PartialSwap(id)
+}
+
+func (adapter *swapAdapter) FinalizedSwap(id string) (ingress.FinalizedSwap, bool, error) {
+	return ingress.FinalizedSwap{}, false, sql.ErrNoRows
+}
+
 type errAdapter struct {
 }
 
@@ -71,16 +302,16 @@ func (adapter *errAdapter) OpenOrder(trader string, orderFragmentMapping OrderFr
 	return [65]byte{}, errors.New("cannot open order")
 }
 
-func (adapter *errAdapter) WyreVerified(trader string) (bool, error) {
-	return false, errors.New("trader not verified")
+func (adapter *errAdapter) TraderVerified(trader string) (int, error) {
+	return ingress.KYCNone, errors.New("cannot check trader verification")
 }
 
 func (adapter *errAdapter) ApproveWithdrawal(trader string, tokenID uint32) ([65]byte, error) {
 	return [65]byte{}, errors.New("cannot approve withdrawal")
 }
 
-func (adapter *errAdapter) GetLogin(string) (int64, string, error) {
-	return 0, "", errors.New("cannot get login")
+func (adapter *errAdapter) GetLogin(string) (ingress.Login, error) {
+	return ingress.Login{}, errors.New("cannot get login")
 }
 
 func (adapter *errAdapter) PostLogin(string, string) error {
@@ -91,10 +322,42 @@ func (adapter *errAdapter) PostVerification(string, int64, int) error {
 	return errors.New("cannot post verification")
 }
 
+func (adapter *errAdapter) Referrals(address string) (Referrals, error) {
+	return Referrals{}, errors.New("cannot get referrals")
+}
+
 func (adapter *errAdapter) Authorize(authorizer, authorizedAddr string) error {
 	return nil
 }
 
+func (adapter *errAdapter) AuthorizedAddresses(authorizer string) ([]GetAuthorizeResponse, error) {
+	return nil, errors.New("cannot get authorized addresses")
+}
+
+func (adapter *errAdapter) Unauthorize(authorizer, authorizedAddr string) error {
+	return errors.New("cannot revoke authorized address")
+}
+
+func (adapter *errAdapter) IssueNonce() (Challenge, error) {
+	return Challenge{}, errors.New("cannot issue nonce")
+}
+
+func (adapter *errAdapter) ConsumeNonce(nonce string) error {
+	return nil
+}
+
+func (adapter *errAdapter) SwapStatus(orderID string) (ingress.SwapStatus, error) {
+	return ingress.SwapStatus{}, errors.New("cannot get swap status")
+}
+
+func (adapter *errAdapter) RefundableSwaps() ([]ingress.RefundableSwap, error) {
+	return nil, errors.New("cannot get refundable swaps")
+}
+
+func (adapter *errAdapter) OrderTrader(orderID string) (string, error) {
+	return "", errors.New("cannot get order trader")
+}
+
 func (adapter *errAdapter) InsertPartialSwap(swap ingress.PartialSwap) error {
 	return nil
 }
@@ -107,6 +370,72 @@ func (adapter *errAdapter) FinalizedSwap(id string) (ingress.FinalizedSwap, bool
 	return ingress.FinalizedSwap{}, false, nil
 }
 
+func (adapter *errAdapter) ApprovedTraders() ([]ingress.ApprovedTrader, error) {
+	return nil, errors.New("cannot get approved traders")
+}
+
+func (adapter *errAdapter) InsertApprovedTrader(trader ingress.ApprovedTrader) error {
+	return errors.New("cannot insert approved trader")
+}
+
+func (adapter *errAdapter) DeleteApprovedTrader(address string) error {
+	return errors.New("cannot delete approved trader")
+}
+
+func (adapter *errAdapter) TraderApproved(address string) (bool, error) {
+	return false, nil
+}
+
+func (adapter *errAdapter) RegisterWebhook(trader, url, registeredBy string) (ingress.Webhook, error) {
+	return ingress.Webhook{}, errors.New("cannot register webhook")
+}
+
+func (adapter *errAdapter) Webhook(id string) (ingress.Webhook, error) {
+	return ingress.Webhook{}, errors.New("cannot get webhook")
+}
+
+func (adapter *errAdapter) Webhooks(trader string) ([]ingress.Webhook, error) {
+	return nil, errors.New("cannot get webhooks")
+}
+
+func (adapter *errAdapter) DeleteWebhook(id string) error {
+	return errors.New("cannot delete webhook")
+}
+
+func (adapter *errAdapter) FailedWebhookDeliveries() ([]ingress.WebhookDelivery, error) {
+	return nil, errors.New("cannot get failed webhook deliveries")
+}
+
+func (adapter *errAdapter) IssueSession(trader string) (Session, error) {
+	return Session{}, errors.New("cannot issue session")
+}
+
+func (adapter *errAdapter) SessionTrader(token string) (string, error) {
+	return "", errors.New("cannot get session")
+}
+
+func (adapter *errAdapter) Subscribe(trader string) (<-chan ingress.Event, func()) {
+	events := make(chan ingress.Event)
+	close(events)
+	return events, func() {}
+}
+
+func (adapter *errAdapter) TraderOrders(trader string, query ingress.OrderQuery) (ingress.OrderPage, error) {
+	return ingress.OrderPage{}, errors.New("cannot get trader orders")
+}
+
+func (adapter *errAdapter) IndexedOrder(orderID string) (ingress.IndexedOrder, error) {
+	return ingress.IndexedOrder{}, errors.New("cannot get indexed order")
+}
+
+func (adapter *errAdapter) IndexedOrders(query ingress.IndexQuery) (ingress.IndexedOrderPage, error) {
+	return ingress.IndexedOrderPage{}, errors.New("cannot get indexed orders")
+}
+
+func (adapter *errAdapter) Balances(trader string) (ingress.TraderBalances, error) {
+	return ingress.TraderBalances{}, errors.New("cannot get balances")
+}
+
 var _ = Describe("HTTP handlers", func() {
 
 	Context("when opening orders", func() {
@@ -114,6 +443,8 @@ var _ = Describe("HTTP handlers", func() {
 		It("should return status 201 for a valid request", func() {
 
 			mockOrder := new(OpenOrderRequest)
+			mockOrder.Nonce = "nonce"
+			mockOrder.Address, mockOrder.Signature = signRequest(ActionOpenOrder, "", mockOrder.Nonce)
 			data, err := json.Marshal(mockOrder)
 			Expect(err).ShouldNot(HaveOccurred())
 
@@ -122,7 +453,7 @@ var _ = Describe("HTTP handlers", func() {
 			r := httptest.NewRequest("POST", "http://localhost/orders", body)
 
 			adapter := weakAdapter{}
-			server := NewIngressServer(&adapter, []string{}, "", "")
+			server := NewIngressServer(&adapter, config.Config{})
 			server.ServeHTTP(w, r)
 
 			Expect(w.Code).To(Equal(http.StatusCreated))
@@ -146,16 +477,59 @@ var _ = Describe("HTTP handlers", func() {
 			r := httptest.NewRequest("POST", "http://localhost/orders", body)
 
 			adapter := weakAdapter{}
-			server := NewIngressServer(&adapter, []string{}, "", "")
+			server := NewIngressServer(&adapter, config.Config{})
 			server.ServeHTTP(w, r)
 
 			Expect(w.Code).To(Equal(http.StatusBadRequest))
 			Expect(atomic.LoadInt64(&adapter.numOpened)).To(Equal(int64(0)))
 		})
 
+		It("should return status 401 for requests signed by another trader", func() {
+
+			mockOrder := new(OpenOrderRequest)
+			mockOrder.Nonce = "nonce"
+			_, mockOrder.Signature = signRequest(ActionOpenOrder, "", mockOrder.Nonce)
+			mockOrder.Address, _ = signRequest(ActionOpenOrder, "", mockOrder.Nonce)
+			data, err := json.Marshal(mockOrder)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			body := bytes.NewBuffer(data)
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", "http://localhost/orders", body)
+
+			adapter := weakAdapter{}
+			server := NewIngressServer(&adapter, config.Config{})
+			server.ServeHTTP(w, r)
+
+			Expect(w.Code).To(Equal(http.StatusUnauthorized))
+			Expect(atomic.LoadInt64(&adapter.numOpened)).To(Equal(int64(0)))
+		})
+
+		It("should return status 401 for requests with a used nonce", func() {
+
+			mockOrder := new(OpenOrderRequest)
+			mockOrder.Nonce = usedNonce
+			mockOrder.Address, mockOrder.Signature = signRequest(ActionOpenOrder, "", mockOrder.Nonce)
+			data, err := json.Marshal(mockOrder)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			body := bytes.NewBuffer(data)
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", "http://localhost/orders", body)
+
+			adapter := weakAdapter{}
+			server := NewIngressServer(&adapter, config.Config{})
+			server.ServeHTTP(w, r)
+
+			Expect(w.Code).To(Equal(http.StatusUnauthorized))
+			Expect(atomic.LoadInt64(&adapter.numOpened)).To(Equal(int64(0)))
+		})
+
 		It("should return status 500 for ingress adapter errors", func() {
 
 			mockOrder := new(OpenOrderRequest)
+			mockOrder.Nonce = "nonce"
+			mockOrder.Address, mockOrder.Signature = signRequest(ActionOpenOrder, "", mockOrder.Nonce)
 			data, err := json.Marshal(mockOrder)
 			Expect(err).ShouldNot(HaveOccurred())
 
@@ -164,7 +538,7 @@ var _ = Describe("HTTP handlers", func() {
 			r := httptest.NewRequest("POST", "http://localhost/orders", body)
 
 			adapter := errAdapter{}
-			server := NewIngressServer(&adapter, []string{}, "", "")
+			server := NewIngressServer(&adapter, config.Config{})
 			server.ServeHTTP(w, r)
 
 			Expect(w.Code).To(Equal(http.StatusInternalServerError))
@@ -176,6 +550,8 @@ var _ = Describe("HTTP handlers", func() {
 		It("should return status 201 for a valid request", func() {
 
 			mockOrder := new(ApproveWithdrawalRequest)
+			mockOrder.Nonce = "nonce"
+			mockOrder.Trader, mockOrder.Signature = signRequest(ActionWithdraw, "0", mockOrder.Nonce)
 			data, err := json.Marshal(mockOrder)
 			Expect(err).ShouldNot(HaveOccurred())
 
@@ -184,7 +560,7 @@ var _ = Describe("HTTP handlers", func() {
 			r := httptest.NewRequest("POST", "http://localhost/withdrawals", body)
 
 			adapter := weakAdapter{}
-			server := NewIngressServer(&adapter, []string{}, "", "")
+			server := NewIngressServer(&adapter, config.Config{})
 			server.ServeHTTP(w, r)
 
 			Expect(w.Code).To(Equal(http.StatusCreated))
@@ -208,7 +584,7 @@ var _ = Describe("HTTP handlers", func() {
 			r := httptest.NewRequest("POST", "http://localhost/withdrawals", body)
 
 			adapter := weakAdapter{}
-			server := NewIngressServer(&adapter, []string{}, "", "")
+			server := NewIngressServer(&adapter, config.Config{})
 			server.ServeHTTP(w, r)
 
 			Expect(w.Code).To(Equal(http.StatusBadRequest))
@@ -218,6 +594,8 @@ var _ = Describe("HTTP handlers", func() {
 		It("should return status 500 for ingress adapter errors", func() {
 
 			mockOrder := new(ApproveWithdrawalRequest)
+			mockOrder.Nonce = "nonce"
+			mockOrder.Trader, mockOrder.Signature = signRequest(ActionWithdraw, "0", mockOrder.Nonce)
 			data, err := json.Marshal(mockOrder)
 			Expect(err).ShouldNot(HaveOccurred())
 
@@ -226,9 +604,1032 @@ var _ = Describe("HTTP handlers", func() {
 			r := httptest.NewRequest("POST", "http://localhost/withdrawals", body)
 
 			adapter := errAdapter{}
-			server := NewIngressServer(&adapter, []string{}, "", "")
+			server := NewIngressServer(&adapter, config.Config{})
+			server.ServeHTTP(w, r)
+
+			Expect(w.Code).To(Equal(http.StatusInternalServerError))
+		})
+	})
+	Context("when managing approved traders", func() {
+
+		It("should return status 401 for requests without the admin token", func() {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "http://localhost/admin/traders", nil)
+
+			adapter := weakAdapter{}
+			server := NewIngressServer(&adapter, config.Config{AdminToken: "secret"})
+			server.ServeHTTP(w, r)
+
+			Expect(w.Code).To(Equal(http.StatusUnauthorized))
+		})
+
+		It("should return status 403 when the admin token is not configured", func() {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "http://localhost/admin/traders", nil)
+			r.Header.Set("Authorization", "Bearer ")
+
+			adapter := weakAdapter{}
+			server := NewIngressServer(&adapter, config.Config{})
+			server.ServeHTTP(w, r)
+
+			Expect(w.Code).To(Equal(http.StatusForbidden))
+		})
+
+		It("should return status 201 for a valid approval", func() {
+			data, err := json.Marshal(PostApprovedTraderRequest{
+				Address:    "0x62026B5aC38f1b186c7af0b18Aee8B2EccC2d852",
+				Note:       "market maker",
+				ApprovedBy: "admin",
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", "http://localhost/admin/traders", bytes.NewBuffer(data))
+			r.Header.Set("Authorization", "Bearer secret")
+
+			adapter := weakAdapter{}
+			server := NewIngressServer(&adapter, config.Config{AdminToken: "secret"})
+			server.ServeHTTP(w, r)
+
+			Expect(w.Code).To(Equal(http.StatusCreated))
+			Expect(atomic.LoadInt64(&adapter.numApproved)).To(Equal(int64(1)))
+		})
+
+		It("should return status 400 for approvals that have expired", func() {
+			data, err := json.Marshal(PostApprovedTraderRequest{
+				Address:    "0x62026B5aC38f1b186c7af0b18Aee8B2EccC2d852",
+				ApprovedBy: "admin",
+				ExpiresAt:  time.Now().Unix() - 1,
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", "http://localhost/admin/traders", bytes.NewBuffer(data))
+			r.Header.Set("Authorization", "Bearer secret")
+
+			adapter := weakAdapter{}
+			server := NewIngressServer(&adapter, config.Config{AdminToken: "secret"})
+			server.ServeHTTP(w, r)
+
+			Expect(w.Code).To(Equal(http.StatusBadRequest))
+			Expect(atomic.LoadInt64(&adapter.numApproved)).To(Equal(int64(0)))
+		})
+
+		It("should return status 204 when removing an approved trader", func() {
+			trader := "0x62026B5aC38f1b186c7af0b18Aee8B2EccC2d852"
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("DELETE", "http://localhost/admin/traders/"+trader, nil)
+			r.Header.Set("Authorization", "Bearer secret")
+
+			adapter := weakAdapter{approved: trader}
+			server := NewIngressServer(&adapter, config.Config{AdminToken: "secret"})
+			server.ServeHTTP(w, r)
+
+			Expect(w.Code).To(Equal(http.StatusNoContent))
+		})
+
+		It("should return status 404 when removing a trader that has not been approved", func() {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("DELETE", "http://localhost/admin/traders/0x62026B5aC38f1b186c7af0b18Aee8B2EccC2d852", nil)
+			r.Header.Set("Authorization", "Bearer secret")
+
+			adapter := weakAdapter{}
+			server := NewIngressServer(&adapter, config.Config{AdminToken: "secret"})
+			server.ServeHTTP(w, r)
+
+			Expect(w.Code).To(Equal(http.StatusNotFound))
+		})
+
+		It("should return status 400 for an invalid address", func() {
+			data, err := json.Marshal(PostApprovedTraderRequest{
+				Address:    "invalid",
+				ApprovedBy: "admin",
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", "http://localhost/admin/traders", bytes.NewBuffer(data))
+			r.Header.Set("Authorization", "Bearer secret")
+
+			adapter := weakAdapter{}
+			server := NewIngressServer(&adapter, config.Config{AdminToken: "secret"})
+			server.ServeHTTP(w, r)
+
+			Expect(w.Code).To(Equal(http.StatusBadRequest))
+			Expect(atomic.LoadInt64(&adapter.numApproved)).To(Equal(int64(0)))
+		})
+
+		It("should return status 500 for ingress adapter errors", func() {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "http://localhost/admin/traders", nil)
+			r.Header.Set("Authorization", "Bearer secret")
+
+			adapter := errAdapter{}
+			server := NewIngressServer(&adapter, config.Config{AdminToken: "secret"})
+			server.ServeHTTP(w, r)
+
+			Expect(w.Code).To(Equal(http.StatusInternalServerError))
+		})
+	})
+
+	Context("when getting referrals", func() {
+
+		// signReferrals returns the address of a new trader, and the query of
+		// a request for its referrals that is signed by the trader.
+		signReferrals := func() (string, string) {
+			key, err := crypto.GenerateKey()
+			Expect(err).ShouldNot(HaveOccurred())
+			trader := crypto.PubkeyToAddress(key.PublicKey).Hex()
+			_, signature := signRequestWithKey(key, ActionReferrals, trader, "nonce")
+			return trader, url.Values{"nonce": {"nonce"}, "signature": {signature}}.Encode()
+		}
+
+		It("should return status 200 with the referrals of the trader", func() {
+			trader, query := signReferrals()
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "http://localhost/referrals/"+trader+"?"+query, nil)
+
+			adapter := weakAdapter{}
+			server := NewIngressServer(&adapter, config.Config{})
+			server.ServeHTTP(w, r)
+
+			Expect(w.Code).To(Equal(http.StatusOK))
+
+			var response Referrals
+			err := json.Unmarshal(w.Body.Bytes(), &response)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(response.ReferralCode).To(Equal("code"))
+			Expect(response.Referrals).To(HaveLen(1))
+			Expect(response.Referrals[0].KYC).To(Equal(KYCStatusNone))
+		})
+
+		It("should return status 200 for requests with a session of the trader", func() {
+			trader := "0x62026B5aC38f1b186c7af0b18Aee8B2EccC2d852"
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "http://localhost/referrals/"+trader+"?session=session", nil)
+
+			adapter := weakAdapter{trader: trader}
+			server := NewIngressServer(&adapter, config.Config{})
 			server.ServeHTTP(w, r)
 
+			Expect(w.Code).To(Equal(http.StatusOK))
+		})
+
+		It("should return status 401 for requests signed by another trader", func() {
+			_, query := signReferrals()
+			trader, _ := signReferrals()
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "http://localhost/referrals/"+trader+"?"+query, nil)
+
+			adapter := weakAdapter{}
+			server := NewIngressServer(&adapter, config.Config{})
+			server.ServeHTTP(w, r)
+
+			Expect(w.Code).To(Equal(http.StatusUnauthorized))
+		})
+
+		It("should return status 401 for requests with a session of another trader", func() {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "http://localhost/referrals/0x62026B5aC38f1b186c7af0b18Aee8B2EccC2d852?session=session", nil)
+
+			adapter := weakAdapter{trader: "0x5B3B5D4d8b4C53F6eD9e1e2C30A4fE1b2e8D7e1A"}
+			server := NewIngressServer(&adapter, config.Config{})
+			server.ServeHTTP(w, r)
+
+			Expect(w.Code).To(Equal(http.StatusUnauthorized))
+		})
+
+		It("should return status 400 for an invalid address", func() {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "http://localhost/referrals/invalid", nil)
+
+			adapter := weakAdapter{}
+			server := NewIngressServer(&adapter, config.Config{})
+			server.ServeHTTP(w, r)
+
+			Expect(w.Code).To(Equal(http.StatusBadRequest))
+		})
+
+		It("should return status 500 for ingress adapter errors", func() {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "http://localhost/referrals/0x62026B5aC38f1b186c7af0b18Aee8B2EccC2d852", nil)
+			r.Header.Set("Authorization", "Bearer secret")
+
+			adapter := errAdapter{}
+			server := NewIngressServer(&adapter, config.Config{AdminToken: "secret"})
+			server.ServeHTTP(w, r)
+
+			Expect(w.Code).To(Equal(http.StatusInternalServerError))
+		})
+	})
+
+	Context("when managing authorized addresses", func() {
+
+		authorized := "0x62026B5aC38f1b186c7af0b18Aee8B2EccC2d852"
+
+		signUnauthorize := func(address string) string {
+			_, signature := signRequest(ActionUnauthorize, address, "nonce")
+			return signature
+		}
+
+		It("should return status 200 with the authorized addresses", func() {
+			key, err := crypto.GenerateKey()
+			Expect(err).ShouldNot(HaveOccurred())
+			trader := crypto.PubkeyToAddress(key.PublicKey).Hex()
+			_, signature := signRequestWithKey(key, ActionAuthorizedAddresses, trader, "nonce")
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "http://localhost/authorize/"+trader+"?"+url.Values{"nonce": {"nonce"}, "signature": {signature}}.Encode(), nil)
+
+			adapter := weakAdapter{}
+			server := NewIngressServer(&adapter, config.Config{})
+			server.ServeHTTP(w, r)
+
+			Expect(w.Code).To(Equal(http.StatusOK))
+
+			var response []GetAuthorizeResponse
+			err = json.Unmarshal(w.Body.Bytes(), &response)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(response).To(HaveLen(1))
+			Expect(response[0].Status).To(BeTrue())
+		})
+
+		It("should return status 200 for requests with the admin token", func() {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "http://localhost/authorize/"+authorized, nil)
+			r.Header.Set("Authorization", "Bearer secret")
+
+			adapter := weakAdapter{}
+			server := NewIngressServer(&adapter, config.Config{AdminToken: "secret"})
+			server.ServeHTTP(w, r)
+
+			Expect(w.Code).To(Equal(http.StatusOK))
+		})
+
+		It("should return status 400 for unsigned requests", func() {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "http://localhost/authorize/"+authorized, nil)
+
+			adapter := weakAdapter{}
+			server := NewIngressServer(&adapter, config.Config{})
+			server.ServeHTTP(w, r)
+
+			Expect(w.Code).To(Equal(http.StatusBadRequest))
+		})
+
+		It("should return status 400 for an invalid address", func() {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "http://localhost/authorize/invalid", nil)
+
+			adapter := weakAdapter{}
+			server := NewIngressServer(&adapter, config.Config{})
+			server.ServeHTTP(w, r)
+
+			Expect(w.Code).To(Equal(http.StatusBadRequest))
+		})
+
+		It("should return status 204 for a signed revocation", func() {
+			data, err := json.Marshal(DeleteAuthorizeRequest{Nonce: "nonce", Signature: signUnauthorize(authorized)})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("DELETE", "http://localhost/authorize/"+authorized, bytes.NewBuffer(data))
+
+			adapter := weakAdapter{}
+			server := NewIngressServer(&adapter, config.Config{})
+			server.ServeHTTP(w, r)
+
+			Expect(w.Code).To(Equal(http.StatusNoContent))
+			Expect(atomic.LoadInt64(&adapter.numUnauthorized)).To(Equal(int64(1)))
+		})
+
+		It("should return status 400 for an invalid signature", func() {
+			data, err := json.Marshal(DeleteAuthorizeRequest{Nonce: "nonce", Signature: "invalid"})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("DELETE", "http://localhost/authorize/"+authorized, bytes.NewBuffer(data))
+
+			adapter := weakAdapter{}
+			server := NewIngressServer(&adapter, config.Config{})
+			server.ServeHTTP(w, r)
+
+			Expect(w.Code).To(Equal(http.StatusBadRequest))
+			Expect(atomic.LoadInt64(&adapter.numUnauthorized)).To(Equal(int64(0)))
+		})
+
+		It("should return status 500 for ingress adapter errors", func() {
+			data, err := json.Marshal(DeleteAuthorizeRequest{Nonce: "nonce", Signature: signUnauthorize(authorized)})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("DELETE", "http://localhost/authorize/"+authorized, bytes.NewBuffer(data))
+
+			adapter := errAdapter{}
+			server := NewIngressServer(&adapter, config.Config{})
+			server.ServeHTTP(w, r)
+
+			Expect(w.Code).To(Equal(http.StatusInternalServerError))
+		})
+	})
+
+	Context("when issuing nonces", func() {
+
+		It("should return status 201 with a challenge for the network", func() {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", "http://localhost/nonces", nil)
+
+			adapter := weakAdapter{}
+			server := NewIngressServer(&adapter, config.Config{Network: "testnet"})
+			server.ServeHTTP(w, r)
+
+			Expect(w.Code).To(Equal(http.StatusCreated))
+
+			var response Challenge
+			err := json.Unmarshal(w.Body.Bytes(), &response)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(response.Nonce).To(Equal("nonce"))
+			Expect(response.Domain).To(Equal("testnet"))
+		})
+
+		It("should return status 500 for ingress adapter errors", func() {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", "http://localhost/nonces", nil)
+
+			adapter := errAdapter{}
+			server := NewIngressServer(&adapter, config.Config{})
+			server.ServeHTTP(w, r)
+
+			Expect(w.Code).To(Equal(http.StatusInternalServerError))
+		})
+	})
+
+	Context("when getting swaps", func() {
+
+		// orderID is encoded using URL safe base64
+		orderID := "AQ" + strings.Repeat("A", 41) + "="
+		swapURL := func(nonce, signature string) string {
+			return "http://localhost/swaps/" + orderID + "?" + url.Values{"nonce": {nonce}, "signature": {signature}}.Encode()
+		}
+
+		It("should return status 200 for requests signed by the trader", func() {
+			trader, signature := signRequest(ActionSwapStatus, "AQ"+strings.Repeat("A", 41)+"=", "nonce")
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", swapURL("nonce", signature), nil)
+
+			adapter := weakAdapter{trader: trader}
+			server := NewIngressServer(&adapter, config.Config{})
+			server.ServeHTTP(w, r)
+
+			Expect(w.Code).To(Equal(http.StatusOK))
+
+			var response ingress.SwapStatus
+			err := json.Unmarshal(w.Body.Bytes(), &response)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(response.Matched).To(BeTrue())
+		})
+
+		It("should return status 200 for requests with the admin token", func() {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "http://localhost/swaps/"+orderID, nil)
+			r.Header.Set("Authorization", "Bearer secret")
+
+			adapter := weakAdapter{}
+			server := NewIngressServer(&adapter, config.Config{AdminToken: "secret"})
+			server.ServeHTTP(w, r)
+
+			Expect(w.Code).To(Equal(http.StatusOK))
+		})
+
+		It("should return status 401 for requests signed by another trader", func() {
+			_, signature := signRequest(ActionSwapStatus, "AQ"+strings.Repeat("A", 41)+"=", "nonce")
+			trader, _ := signRequest(ActionSwapStatus, "AQ"+strings.Repeat("A", 41)+"=", "nonce")
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", swapURL("nonce", signature), nil)
+
+			adapter := weakAdapter{trader: trader}
+			server := NewIngressServer(&adapter, config.Config{})
+			server.ServeHTTP(w, r)
+
+			Expect(w.Code).To(Equal(http.StatusUnauthorized))
+		})
+
+		It("should return status 404 for unknown orders", func() {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", swapURL("nonce", ""), nil)
+
+			adapter := weakAdapter{}
+			server := NewIngressServer(&adapter, config.Config{})
+			server.ServeHTTP(w, r)
+
+			Expect(w.Code).To(Equal(http.StatusNotFound))
+		})
+
+		It("should return status 400 for invalid order IDs", func() {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "http://localhost/swaps/invalid", nil)
+
+			adapter := weakAdapter{}
+			server := NewIngressServer(&adapter, config.Config{})
+			server.ServeHTTP(w, r)
+
+			Expect(w.Code).To(Equal(http.StatusBadRequest))
+		})
+
+		It("should return status 500 for ingress adapter errors", func() {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "http://localhost/swaps/"+orderID, nil)
+			r.Header.Set("Authorization", "Bearer secret")
+
+			adapter := errAdapter{}
+			server := NewIngressServer(&adapter, config.Config{AdminToken: "secret"})
+			server.ServeHTTP(w, r)
+
+			Expect(w.Code).To(Equal(http.StatusInternalServerError))
+		})
+	})
+
+	Context("when getting vaults", func() {
+
+		It("should return the vault registry", func() {
+			vaults := config.VaultRegistry{
+				{Blockchain: config.BlockchainEthereum, Address: "0x62026B5aC38f1b186c7af0b18Aee8B2EccC2d852"},
+				{Blockchain: config.BlockchainERC20, Token: "DGX", Address: "0x0000000000000000000000000000000000000001"},
+			}
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "http://localhost/vaults", nil)
+
+			adapter := weakAdapter{}
+			server := NewIngressServer(&adapter, config.Config{Vaults: vaults})
+			server.ServeHTTP(w, r)
+
+			Expect(w.Code).To(Equal(http.StatusOK))
+
+			var response config.VaultRegistry
+			err := json.Unmarshal(w.Body.Bytes(), &response)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(response).To(Equal(vaults))
+		})
+
+		It("should return an empty list when no vaults are configured", func() {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "http://localhost/vaults", nil)
+
+			adapter := weakAdapter{}
+			server := NewIngressServer(&adapter, config.Config{})
+			server.ServeHTTP(w, r)
+
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Body.String()).To(Equal("[]"))
+		})
+	})
+
+	Context("when receiving swap callbacks", func() {
+
+		callbackWithKey := func(key *ecdsa.PrivateKey, secretHash string) *http.Request {
+			message := Message{
+				KycAddr:          crypto.PubkeyToAddress(key.PublicKey).Hex(),
+				OrderID:          base64.StdEncoding.EncodeToString(make([]byte, 32)),
+				ReceiveTokenAddr: "receive",
+				SendTokenAddr:    "send",
+			}
+			messageBytes, err := json.Marshal(message)
+			Expect(err).ShouldNot(HaveOccurred())
+			hash := sha3.Sum256(messageBytes)
+			signature, err := crypto.Sign(hash[:], key)
+			Expect(err).ShouldNot(HaveOccurred())
+			info, err := json.Marshal(map[string]interface{}{
+				"message":   message,
+				"signature": base64.StdEncoding.EncodeToString(signature),
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			body, err := json.Marshal(swap.SwapBlob{
+				SendToken:    "ETH",
+				ReceiveToken: "BTC",
+				SecretHash:   secretHash,
+				DelayInfo:    info,
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+			return httptest.NewRequest("POST", "http://localhost/swapperd/cb", bytes.NewBuffer(body))
+		}
+
+		callback := func(secretHash string) *http.Request {
+			key, err := crypto.GenerateKey()
+			Expect(err).ShouldNot(HaveOccurred())
+			return callbackWithKey(key, secretHash)
+		}
+
+		It("should return status 400 for malformed secret hashes", func() {
+			for _, secretHash := range []string{"", "not base64", base64.StdEncoding.EncodeToString(make([]byte, 31))} {
+				w := httptest.NewRecorder()
+				adapter := weakAdapter{}
+				server := NewIngressServer(&adapter, config.Config{})
+				server.ServeHTTP(w, callback(secretHash))
+
+				Expect(w.Code).To(Equal(http.StatusBadRequest))
+				Expect(w.Body.String()).To(ContainSubstring("secret hash"))
+			}
+		})
+
+		It("should keep the timelock of partial swaps that are registered again", func() {
+			key, err := crypto.GenerateKey()
+			Expect(err).ShouldNot(HaveOccurred())
+			secretHash := base64.StdEncoding.EncodeToString(make([]byte, 32))
+			stored := ingress.PartialSwap{
+				OrderID:     base64.StdEncoding.EncodeToString(make([]byte, 32)),
+				KycAddr:     crypto.PubkeyToAddress(key.PublicKey).Hex(),
+				SendTo:      "send",
+				ReceiveFrom: "receive",
+				SecretHash:  secretHash,
+				TimeLock:    time.Now().Add(time.Hour).Unix(),
+				CreatedAt:   1,
+				TimeLockGap: 60,
+			}
+			adapter := swapAdapter{swapper: ingress.NewMemorySwapper(nil, nil)}
+			Expect(adapter.swapper.InsertPartialSwap(stored)).ShouldNot(HaveOccurred())
+
+			w := httptest.NewRecorder()
+			server := NewIngressServer(&adapter, config.Config{})
+			server.ServeHTTP(w, callbackWithKey(key, secretHash))
+
+			Expect(w.Code).To(Equal(http.StatusNoContent))
+			registered, err := adapter.swapper.PartialSwap(stored.OrderID)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(registered).To(Equal(stored))
+		})
+	})
+
+	Context("when getting refundable swaps", func() {
+
+		It("should return the refundable swaps with the admin token", func() {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "http://localhost/admin/swaps/refundable", nil)
+			r.Header.Set("Authorization", "Bearer secret")
+
+			adapter := weakAdapter{}
+			server := NewIngressServer(&adapter, config.Config{AdminToken: "secret"})
+			server.ServeHTTP(w, r)
+
+			Expect(w.Code).To(Equal(http.StatusOK))
+
+			var response []ingress.RefundableSwap
+			err := json.Unmarshal(w.Body.Bytes(), &response)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(response).To(HaveLen(1))
+			Expect(response[0].OrderID).To(Equal("order"))
+		})
+
+		It("should return status 401 without the admin token", func() {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "http://localhost/admin/swaps/refundable", nil)
+
+			adapter := weakAdapter{}
+			server := NewIngressServer(&adapter, config.Config{AdminToken: "secret"})
+			server.ServeHTTP(w, r)
+
+			Expect(w.Code).To(Equal(http.StatusUnauthorized))
+		})
+
+		It("should return status 500 for ingress adapter errors", func() {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "http://localhost/admin/swaps/refundable", nil)
+			r.Header.Set("Authorization", "Bearer secret")
+
+			adapter := errAdapter{}
+			server := NewIngressServer(&adapter, config.Config{AdminToken: "secret"})
+			server.ServeHTTP(w, r)
+
+			Expect(w.Code).To(Equal(http.StatusInternalServerError))
+		})
+	})
+
+	Context("when managing webhooks", func() {
+
+		webhookURL := "https://1.1.1.1/events"
+
+		registerWebhook := func(adapter IngressAdapter, req PostWebhookRequest) *httptest.ResponseRecorder {
+			data, err := json.Marshal(req)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", "http://localhost/webhooks", bytes.NewBuffer(data))
+
+			server := NewIngressServer(adapter, config.Config{})
+			server.ServeHTTP(w, r)
+			return w
+		}
+
+		unregisterWebhook := func(adapter IngressAdapter, id, signature string) *httptest.ResponseRecorder {
+			data, err := json.Marshal(DeleteWebhookRequest{Nonce: "nonce", Signature: signature})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("DELETE", "http://localhost/webhooks/"+id, bytes.NewBuffer(data))
+
+			server := NewIngressServer(adapter, config.Config{})
+			server.ServeHTTP(w, r)
+			return w
+		}
+
+		It("should return status 201 with the secret for a webhook registered by the trader", func() {
+			key, err := crypto.GenerateKey()
+			Expect(err).ShouldNot(HaveOccurred())
+			trader := crypto.PubkeyToAddress(key.PublicKey).Hex()
+			_, signature := signRequestWithKey(key, ActionRegisterWebhook, trader+" "+webhookURL, "nonce")
+
+			adapter := weakAdapter{}
+			w := registerWebhook(&adapter, PostWebhookRequest{Trader: trader, URL: webhookURL, Nonce: "nonce", Signature: signature})
+
+			Expect(w.Code).To(Equal(http.StatusCreated))
+			Expect(atomic.LoadInt64(&adapter.numWebhooks)).To(Equal(int64(1)))
+
+			var response ingress.Webhook
+			err = json.Unmarshal(w.Body.Bytes(), &response)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(response.Secret).To(Equal("secret"))
+			Expect(response.RegisteredBy).To(Equal(trader))
+		})
+
+		It("should return status 201 for a webhook registered by an authorizer of the trader", func() {
+			trader := "0x62026B5aC38f1b186c7af0b18Aee8B2EccC2d852"
+			broker, signature := signRequest(ActionRegisterWebhook, trader+" "+webhookURL, "nonce")
+
+			adapter := weakAdapter{authorized: trader}
+			w := registerWebhook(&adapter, PostWebhookRequest{Trader: trader, URL: webhookURL, Nonce: "nonce", Signature: signature})
+
+			Expect(w.Code).To(Equal(http.StatusCreated))
+
+			var response ingress.Webhook
+			err := json.Unmarshal(w.Body.Bytes(), &response)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(response.Trader).To(Equal(trader))
+			Expect(response.RegisteredBy).To(Equal(broker))
+		})
+
+		It("should return status 401 for a webhook registered by another address", func() {
+			trader := "0x62026B5aC38f1b186c7af0b18Aee8B2EccC2d852"
+			_, signature := signRequest(ActionRegisterWebhook, trader+" "+webhookURL, "nonce")
+
+			adapter := weakAdapter{}
+			w := registerWebhook(&adapter, PostWebhookRequest{Trader: trader, URL: webhookURL, Nonce: "nonce", Signature: signature})
+
+			Expect(w.Code).To(Equal(http.StatusUnauthorized))
+			Expect(atomic.LoadInt64(&adapter.numWebhooks)).To(Equal(int64(0)))
+		})
+
+		It("should return status 400 for invalid webhook urls", func() {
+			trader := "0x62026B5aC38f1b186c7af0b18Aee8B2EccC2d852"
+			for _, invalidURL := range []string{"", "example.com/events", "ftp://example.com", "https://", "http://1.1.1.1/events", "https://127.0.0.1/events", "https://203.0.113.1/events", "https://100.64.0.1/events", "https://[::ffff:10.0.0.1]/events", "https://[64:ff9b::a9fe:a9fe]/latest", "https://10.0.0.1/events", "https://169.254.169.254/latest", "https://[::1]/events", "https://localhost/events"} {
+				_, signature := signRequest(ActionRegisterWebhook, trader+" "+invalidURL, "nonce")
+
+				adapter := weakAdapter{authorized: trader}
+				w := registerWebhook(&adapter, PostWebhookRequest{Trader: trader, URL: invalidURL, Nonce: "nonce", Signature: signature})
+
+				Expect(w.Code).To(Equal(http.StatusBadRequest))
+				Expect(atomic.LoadInt64(&adapter.numWebhooks)).To(Equal(int64(0)))
+			}
+		})
+
+		It("should return status 500 for ingress adapter errors", func() {
+			key, err := crypto.GenerateKey()
+			Expect(err).ShouldNot(HaveOccurred())
+			trader := crypto.PubkeyToAddress(key.PublicKey).Hex()
+			_, signature := signRequestWithKey(key, ActionRegisterWebhook, trader+" "+webhookURL, "nonce")
+
+			w := registerWebhook(&errAdapter{}, PostWebhookRequest{Trader: trader, URL: webhookURL, Nonce: "nonce", Signature: signature})
+
+			Expect(w.Code).To(Equal(http.StatusInternalServerError))
+		})
+
+		It("should return status 204 for a webhook unregistered by the address that registered it", func() {
+			registeredBy, signature := signRequest(ActionUnregisterWebhook, "webhook", "nonce")
+
+			adapter := weakAdapter{numWebhooks: 1, webhook: ingress.Webhook{ID: "webhook", Trader: "0x62026b5ac38f1b186c7af0b18aee8b2eccc2d852", RegisteredBy: registeredBy}}
+			w := unregisterWebhook(&adapter, "webhook", signature)
+
+			Expect(w.Code).To(Equal(http.StatusNoContent))
+			Expect(atomic.LoadInt64(&adapter.numWebhooks)).To(Equal(int64(0)))
+		})
+
+		It("should return status 401 for a webhook unregistered by another address", func() {
+			_, signature := signRequest(ActionUnregisterWebhook, "webhook", "nonce")
+
+			trader := "0x62026b5ac38f1b186c7af0b18aee8b2eccc2d852"
+			adapter := weakAdapter{numWebhooks: 1, webhook: ingress.Webhook{ID: "webhook", Trader: trader, RegisteredBy: trader}}
+			w := unregisterWebhook(&adapter, "webhook", signature)
+
+			Expect(w.Code).To(Equal(http.StatusUnauthorized))
+			Expect(atomic.LoadInt64(&adapter.numWebhooks)).To(Equal(int64(1)))
+		})
+
+		It("should return status 404 for unknown webhooks", func() {
+			_, signature := signRequest(ActionUnregisterWebhook, "unknown", "nonce")
+
+			adapter := weakAdapter{}
+			w := unregisterWebhook(&adapter, "unknown", signature)
+
+			Expect(w.Code).To(Equal(http.StatusNotFound))
+		})
+
+		It("should return the failed deliveries with the admin token", func() {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "http://localhost/admin/webhooks/failed", nil)
+			r.Header.Set("Authorization", "Bearer secret")
+
+			adapter := weakAdapter{}
+			server := NewIngressServer(&adapter, config.Config{AdminToken: "secret"})
+			server.ServeHTTP(w, r)
+
+			Expect(w.Code).To(Equal(http.StatusOK))
+
+			var response []ingress.WebhookDelivery
+			err := json.Unmarshal(w.Body.Bytes(), &response)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(response).To(HaveLen(1))
+			Expect(response[0].WebhookID).To(Equal("webhook"))
+		})
+
+		It("should return status 401 for failed deliveries without the admin token", func() {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "http://localhost/admin/webhooks/failed", nil)
+
+			adapter := weakAdapter{}
+			server := NewIngressServer(&adapter, config.Config{AdminToken: "secret"})
+			server.ServeHTTP(w, r)
+
+			Expect(w.Code).To(Equal(http.StatusUnauthorized))
+		})
+	})
+
+	Context("when streaming events", func() {
+
+		trader := "0x62026B5aC38f1b186c7af0b18Aee8B2EccC2d852"
+
+		postSession := func(adapter IngressAdapter, req PostSessionRequest) *httptest.ResponseRecorder {
+			data, err := json.Marshal(req)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", "http://localhost/sessions", bytes.NewBuffer(data))
+
+			server := NewIngressServer(adapter, config.Config{})
+			server.ServeHTTP(w, r)
+			return w
+		}
+
+		getEvents := func(adapter IngressAdapter, address, session string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "http://localhost/traders/"+address+"/events?"+url.Values{"session": {session}}.Encode(), nil)
+
+			server := NewIngressServer(adapter, config.Config{})
+			server.ServeHTTP(w, r)
+			return w
+		}
+
+		It("should return status 201 with a session for a signed request", func() {
+			req := PostSessionRequest{Nonce: "nonce"}
+			key, err := crypto.GenerateKey()
+			Expect(err).ShouldNot(HaveOccurred())
+			req.Address = crypto.PubkeyToAddress(key.PublicKey).Hex()
+			_, req.Signature = signRequestWithKey(key, ActionSession, req.Address, "nonce")
+
+			w := postSession(&weakAdapter{}, req)
+			Expect(w.Code).To(Equal(http.StatusCreated))
+
+			var response Session
+			err = json.Unmarshal(w.Body.Bytes(), &response)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(response.Token).To(Equal("session"))
+			Expect(response.Trader).To(Equal(req.Address))
+		})
+
+		It("should return status 401 for a session signed by another address", func() {
+			_, signature := signRequest(ActionSession, trader, "nonce")
+
+			w := postSession(&weakAdapter{}, PostSessionRequest{Address: trader, Nonce: "nonce", Signature: signature})
+			Expect(w.Code).To(Equal(http.StatusUnauthorized))
+		})
+
+		It("should stream the events of the trader of the session", func() {
+			adapter := weakAdapter{
+				trader: trader,
+				events: []ingress.Event{
+					{ID: "order.approved:order", Type: ingress.EventOrderApproved, OrderID: "order", Trader: trader},
+					{ID: "order.settled:order", Type: ingress.EventOrderSettled, OrderID: "order", Trader: trader},
+				},
+			}
+			w := getEvents(&adapter, strings.ToLower(trader), "session")
+
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Header().Get("Content-Type")).To(Equal("text/event-stream"))
+			messages := strings.Split(strings.TrimSpace(w.Body.String()), "\n\n")
+			Expect(messages).To(HaveLen(2))
+			Expect(messages[0]).To(HavePrefix("id: order.approved:order\nevent: order.approved\ndata: {"))
+			Expect(messages[1]).To(HavePrefix("id: order.settled:order\nevent: order.settled\ndata: {"))
+		})
+
+		It("should return status 401 for invalid sessions", func() {
+			adapter := weakAdapter{trader: trader}
+			w := getEvents(&adapter, trader, "invalid")
+			Expect(w.Code).To(Equal(http.StatusUnauthorized))
+		})
+
+		It("should return status 401 for the session of another trader", func() {
+			adapter := weakAdapter{trader: "0x5B3B5D4d8b4C53F6eD9e1e2C30A4fE1b2e8D7e1A"}
+			w := getEvents(&adapter, trader, "session")
+			Expect(w.Code).To(Equal(http.StatusUnauthorized))
+		})
+
+		It("should return status 400 for an invalid address", func() {
+			adapter := weakAdapter{trader: trader}
+			w := getEvents(&adapter, "invalid", "session")
+			Expect(w.Code).To(Equal(http.StatusBadRequest))
+		})
+
+		It("should return status 500 for ingress adapter errors", func() {
+			w := getEvents(&errAdapter{}, trader, "session")
+			Expect(w.Code).To(Equal(http.StatusInternalServerError))
+		})
+	})
+
+	Context("when listing the orders of a trader", func() {
+
+		trader := "0x62026B5aC38f1b186c7af0b18Aee8B2EccC2d852"
+
+		getOrders := func(adapter IngressAdapter, address string, query url.Values) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "http://localhost/traders/"+address+"/orders?"+query.Encode(), nil)
+
+			server := NewIngressServer(adapter, config.Config{})
+			server.ServeHTTP(w, r)
+			return w
+		}
+
+		It("should return status 200 with the first page of orders by default", func() {
+			adapter := weakAdapter{}
+			w := getOrders(&adapter, trader, url.Values{})
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(adapter.orderQuery).To(Equal(ingress.OrderQuery{Limit: DefaultOrderPageLimit}))
+
+			var page ingress.OrderPage
+			err := json.Unmarshal(w.Body.Bytes(), &page)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(page.Orders).To(HaveLen(1))
+			Expect(page.Orders[0].State).To(Equal(ingress.OrderStateOpen))
+		})
+
+		It("should filter and paginate the orders", func() {
+			adapter := weakAdapter{}
+			w := getOrders(&adapter, trader, url.Values{"state": {ingress.OrderStateSettled}, "cursor": {"10"}, "limit": {"5"}})
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(adapter.orderQuery).To(Equal(ingress.OrderQuery{State: ingress.OrderStateSettled, Cursor: 10, Limit: 5}))
+
+			w = getOrders(&adapter, trader, url.Values{"offset": {"10"}, "limit": {"5"}})
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(adapter.orderQuery).To(Equal(ingress.OrderQuery{Offset: 10, Limit: 5}))
+		})
+
+		It("should return status 400 for unknown states", func() {
+			w := getOrders(&weakAdapter{}, trader, url.Values{"state": {"unknown"}})
+			Expect(w.Code).To(Equal(http.StatusBadRequest))
+		})
+
+		It("should return status 400 for invalid pagination", func() {
+			for _, query := range []url.Values{{"offset": {"-1"}}, {"limit": {"many"}}, {"limit": {"101"}}, {"cursor": {"-1"}, "state": {ingress.OrderStateOpen}}, {"cursor": {"10"}}, {"offset": {"10"}, "state": {ingress.OrderStateOpen}}} {
+				w := getOrders(&weakAdapter{}, trader, query)
+				Expect(w.Code).To(Equal(http.StatusBadRequest))
+			}
+		})
+
+		It("should return status 400 for an invalid address", func() {
+			w := getOrders(&weakAdapter{}, "invalid", url.Values{})
+			Expect(w.Code).To(Equal(http.StatusBadRequest))
+		})
+
+		It("should return status 500 for ingress adapter errors", func() {
+			w := getOrders(&errAdapter{}, trader, url.Values{})
+			Expect(w.Code).To(Equal(http.StatusInternalServerError))
+		})
+	})
+
+	Context("when getting the balances of a trader", func() {
+
+		trader := "0x62026B5aC38f1b186c7af0b18Aee8B2EccC2d852"
+
+		getBalances := func(adapter IngressAdapter, address string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "http://localhost/balances/"+address, nil)
+
+			server := NewIngressServer(adapter, config.Config{})
+			server.ServeHTTP(w, r)
+			return w
+		}
+
+		It("should return status 200 with the balances", func() {
+			w := getBalances(&weakAdapter{}, trader)
+			Expect(w.Code).To(Equal(http.StatusOK))
+
+			var balances ingress.TraderBalances
+			err := json.Unmarshal(w.Body.Bytes(), &balances)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(balances.Trader).To(Equal(trader))
+			Expect(balances.WithdrawalNonce).To(Equal("1"))
+			Expect(balances.Balances).To(HaveLen(1))
+			Expect(balances.Balances[0].Symbol).To(Equal("ETH"))
+		})
+
+		It("should return status 400 for an invalid address", func() {
+			w := getBalances(&weakAdapter{}, "invalid")
+			Expect(w.Code).To(Equal(http.StatusBadRequest))
+		})
+
+		It("should return status 500 for ingress adapter errors", func() {
+			w := getBalances(&errAdapter{}, trader)
+			Expect(w.Code).To(Equal(http.StatusInternalServerError))
+		})
+	})
+
+	Context("when querying indexed orders", func() {
+
+		orderID := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{0xfb}, 32))
+
+		getIndexedOrders := func(adapter IngressAdapter, query url.Values) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "http://localhost/orderbook/orders?"+query.Encode(), nil)
+
+			server := NewIngressServer(adapter, config.Config{})
+			server.ServeHTTP(w, r)
+			return w
+		}
+
+		getIndexedOrder := func(adapter IngressAdapter, orderID string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "http://localhost/orderbook/orders/"+orderID, nil)
+
+			server := NewIngressServer(adapter, config.Config{})
+			server.ServeHTTP(w, r)
+			return w
+		}
+
+		It("should return status 200 with the filtered page of orders", func() {
+			adapter := weakAdapter{}
+			w := getIndexedOrders(&adapter, url.Values{
+				"trader":    {"0x62026B5aC38f1b186c7af0b18Aee8B2EccC2d852"},
+				"state":     {ingress.OrderStateSettled},
+				"fromBlock": {"100"},
+				"toBlock":   {"200"},
+				"offset":    {"10"},
+			})
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(adapter.indexQuery).To(Equal(ingress.IndexQuery{
+				Trader:    "0x62026B5aC38f1b186c7af0b18Aee8B2EccC2d852",
+				State:     ingress.OrderStateSettled,
+				FromBlock: 100,
+				ToBlock:   200,
+				Offset:    10,
+				Limit:     DefaultOrderPageLimit,
+			}))
+
+			var page ingress.IndexedOrderPage
+			err := json.Unmarshal(w.Body.Bytes(), &page)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(page.Orders).To(HaveLen(1))
+		})
+
+		It("should return status 400 for invalid queries", func() {
+			for _, query := range []url.Values{{"trader": {"invalid"}}, {"state": {"unknown"}}, {"fromBlock": {"-1"}}, {"toBlock": {"latest"}}, {"limit": {"101"}}} {
+				w := getIndexedOrders(&weakAdapter{}, query)
+				Expect(w.Code).To(Equal(http.StatusBadRequest))
+			}
+		})
+
+		It("should return status 500 for ingress adapter errors", func() {
+			w := getIndexedOrders(&errAdapter{}, url.Values{})
+			Expect(w.Code).To(Equal(http.StatusInternalServerError))
+		})
+
+		It("should return status 200 with an order identified by url safe base64", func() {
+			w := getIndexedOrder(&weakAdapter{}, base64.URLEncoding.EncodeToString(bytes.Repeat([]byte{0xfb}, 32)))
+			Expect(w.Code).To(Equal(http.StatusOK))
+
+			var order ingress.IndexedOrder
+			err := json.Unmarshal(w.Body.Bytes(), &order)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(order.OrderID).To(Equal(orderID))
+		})
+
+		It("should return status 404 for orders that have not been indexed", func() {
+			w := getIndexedOrder(&weakAdapter{}, unknownOrderID)
+			Expect(w.Code).To(Equal(http.StatusNotFound))
+		})
+
+		It("should return status 400 for invalid order ids", func() {
+			w := getIndexedOrder(&weakAdapter{}, "invalid")
+			Expect(w.Code).To(Equal(http.StatusBadRequest))
+		})
+
+		It("should return status 500 for ingress adapter errors", func() {
+			w := getIndexedOrder(&errAdapter{}, orderID)
 			Expect(w.Code).To(Equal(http.StatusInternalServerError))
 		})
 	})
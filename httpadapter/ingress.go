@@ -1,8 +1,10 @@
 package httpadapter
 
 import (
+	"database/sql"
 	"errors"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/republicprotocol/renex-ingress-go/ingress"
 )
 
@@ -22,6 +24,10 @@ var ErrInvalidOrderID = errors.New("invalid order id")
 // required length of 32 bytes.
 var ErrInvalidOrderIDLength = errors.New("invalid order id length")
 
+// ErrInvalidSecretHashLength is returned when a secret hash does not have the
+// required length of 32 bytes.
+var ErrInvalidSecretHashLength = errors.New("invalid secret hash length")
+
 // ErrInvalidOrderFragmentIDLength is returned when an order fragment ID does
 // not have the required length of 32 bytes.
 var ErrInvalidOrderFragmentIDLength = errors.New("invalid order fragment id length")
@@ -41,6 +47,25 @@ var ErrEmptyOrderFragmentMapping = errors.New("empty order fragment mapping")
 
 var ErrUnauthorized = errors.New("unauthorized address")
 
+// ErrUnknownOrder is returned when an order has not been opened.
+var ErrUnknownOrder = errors.New("unknown order")
+
+// ErrInvalidWebhookURL is returned when a webhook URL is not an absolute HTTPS
+// URL with a public host.
+var ErrInvalidWebhookURL = errors.New("invalid webhook url")
+
+// ErrInvalidPagination is returned when the offset or limit of a page is not
+// a non-negative integer, or the limit exceeds MaxOrderPageLimit.
+var ErrInvalidPagination = errors.New("invalid pagination")
+
+// ErrInvalidBlockNumber is returned when a block number is not a
+// non-negative integer.
+var ErrInvalidBlockNumber = errors.New("invalid block number")
+
+// ErrUnknownReferralCode is returned when a trader logs in with a referral
+// code that does not belong to any trader.
+var ErrUnknownReferralCode = errors.New("unknown referral code")
+
 // An OpenOrderAdapter can be used to open an order.Order by sending an
 // OrderFragmentMapping to the Darknodes in the network.
 type OpenOrderAdapter interface {
@@ -52,11 +77,13 @@ type ApproveWithdrawalAdapter interface {
 }
 
 type LoginAdapter interface {
-	GetLogin(address string) (int64, string, error)
+	GetLogin(address string) (ingress.Login, error)
 	PostLogin(address, referrer string) error
 	PostVerification(address string, kyberUID int64, kycType int) error
-	WyreVerified(traderIn string) (bool, error)
+	TraderVerified(address string) (int, error)
 	Authorize(authorizer, authorizedAddr string) error
+	AuthorizedAddresses(authorizer string) ([]GetAuthorizeResponse, error)
+	Unauthorize(authorizer, authorizedAddr string) error
 }
 
 type OrderAdapter interface {
@@ -65,6 +92,81 @@ type OrderAdapter interface {
 	FinalizedSwap(id string) (ingress.FinalizedSwap, bool, error)
 }
 
+// A SwapAdapter can be used to get the status of the atomic swap of an order.
+type SwapAdapter interface {
+	SwapStatus(orderID string) (ingress.SwapStatus, error)
+
+	// OrderTrader returns the address of the trader that opened the order,
+	// or ErrUnknownOrder if the order has not been opened.
+	OrderTrader(orderID string) (string, error)
+
+	// RefundableSwaps returns the swaps that are past their timelock.
+	RefundableSwaps() ([]ingress.RefundableSwap, error)
+}
+
+// A ReferralAdapter can be used to get the referrals of a trader.
+type ReferralAdapter interface {
+	Referrals(address string) (Referrals, error)
+}
+
+// An ApproverAdapter can be used to manage the traders that have been
+// manually approved to open orders.
+type ApproverAdapter interface {
+	ApprovedTraders() ([]ingress.ApprovedTrader, error)
+	InsertApprovedTrader(trader ingress.ApprovedTrader) error
+	DeleteApprovedTrader(address string) error
+	TraderApproved(address string) (bool, error)
+}
+
+// A WebhookAdapter can be used to manage the webhooks to which the events of
+// traders are delivered.
+type WebhookAdapter interface {
+	// RegisterWebhook returns the registered Webhook, including its secret.
+	RegisterWebhook(trader, url, registeredBy string) (ingress.Webhook, error)
+
+	// Webhook returns sql.ErrNoRows if the Webhook does not exist.
+	Webhook(id string) (ingress.Webhook, error)
+	Webhooks(trader string) ([]ingress.Webhook, error)
+	DeleteWebhook(id string) error
+
+	// FailedWebhookDeliveries returns the deliveries that have failed after
+	// the maximum number of attempts.
+	FailedWebhookDeliveries() ([]ingress.WebhookDelivery, error)
+}
+
+// A SessionAdapter issues and verifies the sessions used to stream the events
+// of traders.
+type SessionAdapter interface {
+	// IssueSession returns a new Session of the trader.
+	IssueSession(trader string) (Session, error)
+
+	// SessionTrader returns the trader of the session, or
+	// ingress.ErrInvalidSession if the session is unknown or has expired.
+	SessionTrader(token string) (string, error)
+}
+
+// An EventAdapter can be used to stream the events of a trader.
+type EventAdapter interface {
+	ingress.Subscriber
+}
+
+// An OrderHistoryAdapter can be used to list the orders of a trader.
+type OrderHistoryAdapter interface {
+	TraderOrders(trader string, query ingress.OrderQuery) (ingress.OrderPage, error)
+}
+
+// An OrderIndexAdapter can be used to query the orders of the Orderbook that
+// have been indexed.
+type OrderIndexAdapter interface {
+	IndexedOrder(orderID string) (ingress.IndexedOrder, error)
+	IndexedOrders(query ingress.IndexQuery) (ingress.IndexedOrderPage, error)
+}
+
+// A BalanceAdapter can be used to get the balances of a trader.
+type BalanceAdapter interface {
+	Balances(trader string) (ingress.TraderBalances, error)
+}
+
 // An IngressAdapter implements the OpenOrderAdapter and the
 // ApproveWithdrawalAdapter.
 type IngressAdapter interface {
@@ -72,6 +174,16 @@ type IngressAdapter interface {
 	ApproveWithdrawalAdapter
 	LoginAdapter
 	OrderAdapter
+	ApproverAdapter
+	ReferralAdapter
+	NonceAdapter
+	SwapAdapter
+	WebhookAdapter
+	SessionAdapter
+	EventAdapter
+	OrderHistoryAdapter
+	OrderIndexAdapter
+	BalanceAdapter
 }
 
 type ingressAdapter struct {
@@ -105,15 +217,6 @@ func (adapter *ingressAdapter) OpenOrder(traderIn string, orderFragmentMappingsI
 	)
 }
 
-func (adapter *ingressAdapter) WyreVerified(traderIn string) (bool, error) {
-	trader, err := UnmarshalAddress(traderIn)
-	if err != nil {
-		return false, err
-	}
-
-	return adapter.Ingress.WyreVerified(trader)
-}
-
 // ApproveWithdrawal implements the ApproveWithdrawalAdapter interface.
 func (adapter *ingressAdapter) ApproveWithdrawal(traderIn string, tokenIDIn uint32) ([65]byte, error) {
 	trader, err := UnmarshalAddress(traderIn)
@@ -127,20 +230,113 @@ func (adapter *ingressAdapter) ApproveWithdrawal(traderIn string, tokenIDIn uint
 	)
 }
 
-func (adapter *ingressAdapter) GetLogin(address string) (int64, string, error) {
+func (adapter *ingressAdapter) GetLogin(address string) (ingress.Login, error) {
 	return adapter.SelectLogin(address)
 }
 
+// PostLogin stores the trader. The referrer is either the address of the
+// referring trader, or their referral code.
 func (adapter *ingressAdapter) PostLogin(address, referrer string) error {
+	referrer, err := adapter.resolveReferrer(referrer)
+	if err != nil {
+		return err
+	}
 	return adapter.InsertLogin(address, referrer)
 }
 
+func (adapter *ingressAdapter) resolveReferrer(referrer string) (string, error) {
+	if referrer == "" {
+		return "", nil
+	}
+	if _, err := UnmarshalAddress(referrer); err == nil {
+		return referrer, nil
+	}
+	login, err := adapter.SelectLoginByReferralCode(referrer)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrUnknownReferralCode
+		}
+		return "", err
+	}
+	return login.Address, nil
+}
+
+// Referrals implements the ReferralAdapter interface.
+func (adapter *ingressAdapter) Referrals(address string) (Referrals, error) {
+	if _, err := UnmarshalAddress(address); err != nil {
+		return Referrals{}, err
+	}
+	login, err := adapter.SelectLogin(address)
+	if err != nil {
+		return Referrals{}, err
+	}
+	referred, err := adapter.ReferredLogins(login.Address)
+	if err != nil {
+		return Referrals{}, err
+	}
+
+	referrals := Referrals{
+		Address:      login.Address,
+		ReferralCode: login.ReferralCode,
+		Referrer:     login.Referrer,
+		Referrals:    make([]Referral, len(referred)),
+	}
+	for i, trader := range referred {
+		referrals.Referrals[i] = Referral{
+			Address:   trader.Address,
+			CreatedAt: trader.CreatedAt,
+			KYC:       kycStatus(trader),
+		}
+	}
+	return referrals, nil
+}
+
 func (adapter *ingressAdapter) PostVerification(address string, kyberUID int64, kycType int) error {
-	return adapter.UpdateLogin(address, kyberUID, kycType)
+	if err := adapter.UpdateLogin(address, kyberUID, kycType); err != nil {
+		return err
+	}
+	adapter.ForgetVerification(address)
+	return nil
+}
+
+func (adapter *ingressAdapter) TraderVerified(address string) (int, error) {
+	return adapter.Ingress.TraderVerified(address)
 }
 
 func (adapter *ingressAdapter) Authorize(authorizer, authorizedAddr string) error {
-	return adapter.Ingress.Authorize(authorizer, authorizedAddr)
+	if err := adapter.Ingress.Authorize(authorizer, authorizedAddr); err != nil {
+		return err
+	}
+	adapter.ForgetVerification(authorizedAddr)
+	return nil
+}
+
+// AuthorizedAddresses returns the addresses authorized by the authorizer, and
+// whether they are verified.
+func (adapter *ingressAdapter) AuthorizedAddresses(authorizer string) ([]GetAuthorizeResponse, error) {
+	if _, err := UnmarshalAddress(authorizer); err != nil {
+		return nil, err
+	}
+	logins, err := adapter.AuthorizedLogins(authorizer)
+	if err != nil {
+		return nil, err
+	}
+	addresses := make([]GetAuthorizeResponse, len(logins))
+	for i, login := range logins {
+		addresses[i] = GetAuthorizeResponse{
+			AtomAddress: login.Address,
+			Status:      login.KYCWyre != "" || login.KYCKyber != 0,
+		}
+	}
+	return addresses, nil
+}
+
+func (adapter *ingressAdapter) Unauthorize(authorizer, authorizedAddr string) error {
+	if err := adapter.Ingress.Unauthorize(authorizer, authorizedAddr); err != nil {
+		return err
+	}
+	adapter.ForgetVerification(authorizedAddr)
+	return nil
 }
 
 func (adapter *ingressAdapter) InsertPartialSwap(swap ingress.PartialSwap) error {
@@ -154,3 +350,58 @@ func (adapter *ingressAdapter) PartialSwap(id string) (ingress.PartialSwap, erro
 func (adapter *ingressAdapter) FinalizedSwap(id string) (ingress.FinalizedSwap, bool, error) {
 	return adapter.Ingress.FinalizedSwap(id)
 }
+
+// SwapStatus implements the SwapAdapter interface.
+func (adapter *ingressAdapter) SwapStatus(orderID string) (ingress.SwapStatus, error) {
+	if _, err := UnmarshalOrderID(orderID); err != nil {
+		return ingress.SwapStatus{}, err
+	}
+	return adapter.Ingress.SwapStatus(orderID)
+}
+
+// OrderTrader implements the SwapAdapter interface.
+func (adapter *ingressAdapter) OrderTrader(orderID string) (string, error) {
+	id, err := UnmarshalOrderID(orderID)
+	if err != nil {
+		return "", err
+	}
+	trader, err := adapter.GetOrderTrader(id)
+	if err != nil {
+		return "", err
+	}
+	if trader == (common.Address{}) {
+		return "", ErrUnknownOrder
+	}
+	return trader.Hex(), nil
+}
+
+func (adapter *ingressAdapter) ApprovedTraders() ([]ingress.ApprovedTrader, error) {
+	return adapter.Ingress.ApprovedTraders()
+}
+
+func (adapter *ingressAdapter) InsertApprovedTrader(trader ingress.ApprovedTrader) error {
+	return adapter.Ingress.InsertApprovedTrader(trader)
+}
+
+func (adapter *ingressAdapter) DeleteApprovedTrader(address string) error {
+	return adapter.Ingress.DeleteApprovedTrader(address)
+}
+
+func (adapter *ingressAdapter) TraderApproved(address string) (bool, error) {
+	return adapter.Ingress.TraderApproved(address)
+}
+
+// RegisterWebhook implements the WebhookAdapter interface.
+func (adapter *ingressAdapter) RegisterWebhook(trader, url, registeredBy string) (ingress.Webhook, error) {
+	if _, err := UnmarshalAddress(trader); err != nil {
+		return ingress.Webhook{}, err
+	}
+	webhook, err := ingress.NewWebhook(trader, url, registeredBy)
+	if err != nil {
+		return ingress.Webhook{}, err
+	}
+	if err := adapter.InsertWebhook(webhook); err != nil {
+		return ingress.Webhook{}, err
+	}
+	return webhook, nil
+}
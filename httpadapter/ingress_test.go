@@ -2,6 +2,7 @@ package httpadapter_test
 
 import (
 	"crypto/rand"
+	"database/sql"
 	"encoding/base64"
 	"encoding/hex"
 	mathRand "math/rand"
@@ -165,7 +166,7 @@ var _ = Describe("Ingress Adapter", func() {
 	Context("when opening orders", func() {
 
 		It("should forward data to the ingress if the signature and mapping are well formed", func() {
-			ingress := &mockIngress{&mockSwapper{}, &mockLoginer{}, 0, 0}
+			ingress := newMockIngress()
 			ingressAdapter := NewIngressAdapter(ingress)
 
 			traderBytes := [20]byte{}
@@ -184,7 +185,7 @@ var _ = Describe("Ingress Adapter", func() {
 		})
 
 		It("should not call ingress.OpenOrder if trader is invalid", func() {
-			ingress := &mockIngress{&mockSwapper{}, &mockLoginer{}, 0, 0}
+			ingress := newMockIngress()
 			ingressAdapter := NewIngressAdapter(ingress)
 			traderBytes := []byte{}
 			copy(traderBytes[:], "incorrect trader")
@@ -200,7 +201,7 @@ var _ = Describe("Ingress Adapter", func() {
 		})
 
 		It("should not call ingress.OpenOrder if pool hash is invalid", func() {
-			ingress := &mockIngress{&mockSwapper{}, &mockLoginer{}, 0, 0}
+			ingress := newMockIngress()
 			ingressAdapter := NewIngressAdapter(ingress)
 			traderBytes := [20]byte{}
 			_, err := rand.Read(traderBytes[:])
@@ -221,7 +222,7 @@ var _ = Describe("Ingress Adapter", func() {
 	Context("when approving withdrawals", func() {
 
 		It("should forward data to the ingress if the signature and mapping are well formed", func() {
-			ingress := &mockIngress{&mockSwapper{}, &mockLoginer{}, 0, 0}
+			ingress := newMockIngress()
 			ingressAdapter := NewIngressAdapter(ingress)
 
 			traderBytes := [20]byte{}
@@ -235,7 +236,7 @@ var _ = Describe("Ingress Adapter", func() {
 		})
 
 		It("should not call ingress.ApproveWithdrawal if trader is invalid", func() {
-			ingress := &mockIngress{&mockSwapper{}, &mockLoginer{}, 0, 0}
+			ingress := newMockIngress()
 			ingressAdapter := NewIngressAdapter(ingress)
 			traderBytes := []byte{}
 			copy(traderBytes[:], "incorrect trader")
@@ -245,6 +246,76 @@ var _ = Describe("Ingress Adapter", func() {
 			Expect(atomic.LoadInt64(&ingress.numWithdrawn)).To(Equal(int64(0)))
 		})
 	})
+
+	Context("when issuing nonces", func() {
+
+		It("should issue nonces that can only be consumed once", func() {
+			ingresser := newMockIngress()
+			ingressAdapter := NewIngressAdapter(ingresser)
+
+			challenge, err := ingressAdapter.IssueNonce()
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(challenge.Nonce).ShouldNot(BeEmpty())
+			Expect(challenge.ExpiresAt).Should(BeNumerically(">", 0))
+
+			Expect(ingressAdapter.ConsumeNonce(challenge.Nonce)).ShouldNot(HaveOccurred())
+			Expect(ingressAdapter.ConsumeNonce(challenge.Nonce)).Should(Equal(ingress.ErrInvalidNonce))
+		})
+	})
+
+	Context("when registering webhooks", func() {
+
+		It("should store webhooks with a secret that is not listed", func() {
+			ingresser := newMockIngress()
+			ingressAdapter := NewIngressAdapter(ingresser)
+
+			trader := "0x62026B5aC38f1b186c7af0b18Aee8B2EccC2d852"
+			webhook, err := ingressAdapter.RegisterWebhook(trader, "https://example.com/events", trader)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(webhook.ID).ShouldNot(BeEmpty())
+			Expect(webhook.Secret).ShouldNot(BeEmpty())
+
+			stored, err := ingressAdapter.Webhook(webhook.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(stored.Secret).To(Equal(webhook.Secret))
+
+			webhooks, err := ingressAdapter.Webhooks(trader)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(webhooks).To(HaveLen(1))
+			Expect(webhooks[0].ID).To(Equal(webhook.ID))
+			Expect(webhooks[0].Secret).To(BeEmpty())
+		})
+
+		It("should not store webhooks for invalid traders", func() {
+			ingresser := newMockIngress()
+			ingressAdapter := NewIngressAdapter(ingresser)
+
+			_, err := ingressAdapter.RegisterWebhook("invalid", "https://example.com/events", "invalid")
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	Context("when issuing sessions", func() {
+
+		It("should issue sessions that can be used until they expire", func() {
+			ingresser := newMockIngress()
+			ingressAdapter := NewIngressAdapter(ingresser)
+
+			trader := "0x62026B5aC38f1b186c7af0b18Aee8B2EccC2d852"
+			session, err := ingressAdapter.IssueSession(trader)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(session.Token).ShouldNot(BeEmpty())
+			Expect(session.ExpiresAt).Should(BeNumerically(">", time.Now().Unix()))
+
+			for i := 0; i < 2; i++ {
+				sessionTrader, err := ingressAdapter.SessionTrader(session.Token)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(sessionTrader).Should(Equal("0x62026b5ac38f1b186c7af0b18aee8b2eccc2d852"))
+			}
+			_, err = ingressAdapter.SessionTrader("unknown")
+			Expect(err).Should(Equal(ingress.ErrInvalidSession))
+		})
+	})
 })
 
 type mockSwapper struct {
@@ -262,11 +333,31 @@ func (swapper *mockSwapper) FinalizedSwap(id string) (ingress.FinalizedSwap, boo
 	return ingress.FinalizedSwap{}, false, nil
 }
 
+func (swapper *mockSwapper) SwapStatus(id string) (ingress.SwapStatus, error) {
+	return ingress.SwapStatus{OrderID: id}, nil
+}
+
+func (swapper *mockSwapper) MarkRefundableSwaps(now int64) ([]ingress.RefundableSwap, error) {
+	return []ingress.RefundableSwap{}, nil
+}
+
+func (swapper *mockSwapper) RefundableSwaps() ([]ingress.RefundableSwap, error) {
+	return []ingress.RefundableSwap{}, nil
+}
+
+func (swapper *mockSwapper) UnfinalizedPartialSwaps(before int64) ([]string, error) {
+	return []string{}, nil
+}
+
+func (swapper *mockSwapper) DeletePartialSwap(id string) error {
+	return nil
+}
+
 type mockLoginer struct {
 }
 
-func (loginer *mockLoginer) SelectLogin(address string) (int64, string, error) {
-	return 0, "", nil
+func (loginer *mockLoginer) SelectLogin(address string) (ingress.Login, error) {
+	return ingress.Login{}, nil
 }
 
 func (loginer *mockLoginer) InsertLogin(address, referrer string) error {
@@ -281,13 +372,89 @@ func (Loginer *mockLoginer) Authorize(authorizer, authorizedAddr string) error {
 	return nil
 }
 
+func (loginer *mockLoginer) StaleLogins(verifiedBefore int64, limit int) ([]ingress.Login, error) {
+	return []ingress.Login{}, nil
+}
+
+func (loginer *mockLoginer) RevokeLogin(address string, kycType int, reason string) error {
+	return nil
+}
+
+func (loginer *mockLoginer) KYCAudits(address string) ([]ingress.KYCAudit, error) {
+	return []ingress.KYCAudit{}, nil
+}
+
+func (loginer *mockLoginer) SelectLoginByReferralCode(code string) (ingress.Login, error) {
+	return ingress.Login{}, sql.ErrNoRows
+}
+
+func (loginer *mockLoginer) ReferredLogins(referrer string) ([]ingress.Login, error) {
+	return []ingress.Login{}, nil
+}
+
+func (loginer *mockLoginer) AuthorizedLogins(authorizer string) ([]ingress.Login, error) {
+	return []ingress.Login{}, nil
+}
+
+func (loginer *mockLoginer) Unauthorize(authorizer, authorizedAddr string) error {
+	return nil
+}
+
+type mockApprover struct {
+}
+
+func (approver *mockApprover) ApprovedTraders() ([]ingress.ApprovedTrader, error) {
+	return []ingress.ApprovedTrader{}, nil
+}
+
+func (approver *mockApprover) InsertApprovedTrader(trader ingress.ApprovedTrader) error {
+	return nil
+}
+
+func (approver *mockApprover) DeleteApprovedTrader(address string) error {
+	return nil
+}
+
+func (approver *mockApprover) TraderApproved(address string) (bool, error) {
+	return false, nil
+}
+
+type mockKYCVerifier struct {
+}
+
+func (verifier *mockKYCVerifier) TraderVerified(address string) (int, error) {
+	return ingress.KYCWyre, nil
+}
+
+func (verifier *mockKYCVerifier) ForgetVerification(address string) {
+}
+
 type mockIngress struct {
 	ingress.Swapper
 	ingress.Loginer
+	ingress.Approver
+	ingress.Noncer
+	ingress.KYCVerifier
+	ingress.Webhooker
+	ingress.Sessioner
+	ingress.Subscriber
+	ingress.Orderer
+	ingress.OrderIndex
+	ingress.Balancer
 	numOpened    int64
 	numWithdrawn int64
 }
 
+// newMockIngress returns a mockIngress that stores nonces, webhooks, sessions
+// and orders in a migrated SQLite database in memory.
+func newMockIngress() *mockIngress {
+	db, err := ingress.OpenDB(ingress.SQLiteURLPrefix + ":memory:")
+	Expect(err).ShouldNot(HaveOccurred())
+	_, err = db.Migrate()
+	Expect(err).ShouldNot(HaveOccurred())
+	return &mockIngress{&mockSwapper{}, &mockLoginer{}, &mockApprover{}, ingress.NewNoncerWithDB(db), &mockKYCVerifier{}, ingress.NewWebhookerWithDB(db), ingress.NewSessionerWithDB(db), ingress.NewEventStream(nil, ingress.NewMemoryBroadcaster(), time.Hour), ingress.NewOrdererWithDB(db, nil), ingress.NewOrderIndexWithDB(db), ingress.NewBalancer(nil), 0, 0}
+}
+
 func (ingress *mockIngress) Sync(done <-chan struct{}) <-chan error {
 	return nil
 }
@@ -297,10 +464,6 @@ func (ingress *mockIngress) OpenOrder(address [20]byte, orderID order.ID, orderF
 	return [65]byte{}, nil
 }
 
-func (ingress *mockIngress) WyreVerified(address [20]byte) (bool, error) {
-	return true, nil
-}
-
 func (ingress *mockIngress) ApproveWithdrawal(trader [20]byte, tokenID uint32) ([65]byte, error) {
 	atomic.AddInt64(&ingress.numWithdrawn, 1)
 	return [65]byte{}, nil
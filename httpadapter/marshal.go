@@ -43,10 +43,12 @@ type OrderFragmentMapping map[string]OrderFragments
 type OrderFragmentMappings []OrderFragmentMapping
 
 // OpenOrderRequest is an JSON object sent to the HTTP handlers to request the
-// opening of an order.
+// opening of an order. The request is signed by the trader (see SignedMessage).
 type OpenOrderRequest struct {
 	Address               string                `json:"address"`
 	OrderFragmentMappings OrderFragmentMappings `json:"orderFragmentMappings"`
+	Nonce                 string                `json:"nonce"`
+	Signature             string                `json:"signature"`
 }
 
 type OpenOrderResponse struct {
@@ -54,10 +56,13 @@ type OpenOrderResponse struct {
 }
 
 // ApproveWithdrawalRequest is an JSON object sent to the HTTP handlers to
-// request the approval of a withdrawal.
+// request the approval of a withdrawal. The request is signed by the trader
+// (see SignedMessage).
 type ApproveWithdrawalRequest struct {
-	Trader  string `json:"address"`
-	TokenID uint32 `json:"tokenID"`
+	Trader    string `json:"address"`
+	TokenID   uint32 `json:"tokenID"`
+	Nonce     string `json:"nonce"`
+	Signature string `json:"signature"`
 }
 
 type ApproveWithdrawalResponse struct {
@@ -84,16 +89,66 @@ type PostSwapRequest struct {
 	Signature string       `json:"signature"`
 }
 
+// PostAuthorizeRequest is an JSON object sent to the HTTP handlers to
+// authorize an address to share the verification of the signer (see
+// SignedMessage).
 type PostAuthorizeRequest struct {
 	Address   string `json:"address"`
+	Nonce     string `json:"nonce"`
+	Signature string `json:"signature"`
+}
+
+// DeleteAuthorizeRequest is an JSON object sent to the HTTP handlers to revoke
+// an authorized address. The request is signed by the authorizer (see
+// SignedMessage).
+type DeleteAuthorizeRequest struct {
+	Nonce     string `json:"nonce"`
 	Signature string `json:"signature"`
 }
 
+// GetAuthorizeResponse is an address authorized by a trader, and whether the
+// address is verified.
 type GetAuthorizeResponse struct {
 	AtomAddress string `json:"atomAddress"`
 	Status      bool   `json:"status"`
 }
 
+// PostSessionRequest is an JSON object sent to the HTTP handlers to start a
+// session for streaming the events of a trader. The request is signed by the
+// trader (see SignedMessage).
+type PostSessionRequest struct {
+	Address   string `json:"address"`
+	Nonce     string `json:"nonce"`
+	Signature string `json:"signature"`
+}
+
+// PostWebhookRequest is an JSON object sent to the HTTP handlers to register
+// a webhook for a trader. The request is signed by the trader, or by an
+// address that has authorized the trader (see SignedMessage).
+type PostWebhookRequest struct {
+	Trader    string `json:"trader"`
+	URL       string `json:"url"`
+	Nonce     string `json:"nonce"`
+	Signature string `json:"signature"`
+}
+
+// DeleteWebhookRequest is an JSON object sent to the HTTP handlers to
+// unregister a webhook. The request is signed by the trader of the webhook, or
+// by the address that registered it (see SignedMessage).
+type DeleteWebhookRequest struct {
+	Nonce     string `json:"nonce"`
+	Signature string `json:"signature"`
+}
+
+// PostApprovedTraderRequest is an JSON object sent to the HTTP handlers to
+// manually approve a trader.
+type PostApprovedTraderRequest struct {
+	Address    string `json:"address"`
+	Note       string `json:"note"`
+	ApprovedBy string `json:"approvedBy"`
+	ExpiresAt  int64  `json:"expiresAt"`
+}
+
 func MarshalSignature(signatureIn [65]byte) string {
 	return base64.StdEncoding.EncodeToString(signatureIn[:])
 }
@@ -179,6 +234,19 @@ func UnmarshalOrderID(orderIDIn string) (order.ID, error) {
 	return orderID, nil
 }
 
+func UnmarshalSecretHash(secretHashIn string) ([32]byte, error) {
+	secretHash := [32]byte{}
+	secretHashBytes, err := base64.StdEncoding.DecodeString(secretHashIn)
+	if err != nil {
+		return secretHash, fmt.Errorf("cannot decode secret hash %v: %v", secretHashIn, err)
+	}
+	if len(secretHashBytes) != 32 {
+		return secretHash, ErrInvalidSecretHashLength
+	}
+	copy(secretHash[:], secretHashBytes)
+	return secretHash, nil
+}
+
 func UnmarshalOrderFragmentID(orderFragmentIDIn string) (order.FragmentID, error) {
 	orderFragmentID := order.FragmentID{}
 	orderFragmentIDBytes, err := base64.StdEncoding.DecodeString(orderFragmentIDIn)
@@ -0,0 +1,170 @@
+package httpadapter
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/republicprotocol/renex-ingress-go/ingress"
+)
+
+// NonceTTL is the duration for which a nonce can be used to sign a request
+// after it has been issued.
+const NonceTTL = 5 * time.Minute
+
+// Actions that are signed by traders. The payload of the signed message is
+// the address of the trader for ActionLogin, ActionSession,
+// ActionAuthorizedAddresses and ActionReferrals, the order ID for
+// ActionOpenOrder and ActionSwapStatus, the token ID for ActionWithdraw, the
+// authorized address for ActionAuthorize and ActionUnauthorize, the trader and
+// the URL separated by a space for ActionRegisterWebhook, and the webhook ID
+// for ActionUnregisterWebhook.
+const (
+	ActionLogin               = "login"
+	ActionOpenOrder           = "open order"
+	ActionWithdraw            = "withdraw"
+	ActionAuthorize           = "authorize"
+	ActionUnauthorize         = "unauthorize"
+	ActionSwapStatus          = "swap status"
+	ActionRegisterWebhook     = "register webhook"
+	ActionUnregisterWebhook   = "unregister webhook"
+	ActionSession             = "session"
+	ActionAuthorizedAddresses = "authorized addresses"
+	ActionReferrals           = "referrals"
+)
+
+// A Challenge is a nonce issued to a trader. The trader signs a request by
+// signing a message that embeds the domain and the nonce (see
+// SignedMessage). Each nonce can only be used once, before it expires.
+type Challenge struct {
+	Nonce     string `json:"nonce"`
+	Domain    string `json:"domain"`
+	ExpiresAt int64  `json:"expiresAt"`
+}
+
+// A NonceAdapter issues and consumes the nonces used to sign requests.
+type NonceAdapter interface {
+	// IssueNonce returns a Challenge with a new nonce. The domain of the
+	// Challenge is set by the HTTP handlers.
+	IssueNonce() (Challenge, error)
+
+	// ConsumeNonce returns ingress.ErrInvalidNonce if the nonce is unknown,
+	// has expired, or has already been used.
+	ConsumeNonce(nonce string) error
+}
+
+// IssueNonce implements the NonceAdapter interface.
+func (adapter *ingressAdapter) IssueNonce() (Challenge, error) {
+	nonceBytes := [32]byte{}
+	if _, err := rand.Read(nonceBytes[:]); err != nil {
+		return Challenge{}, err
+	}
+	challenge := Challenge{
+		Nonce:     hex.EncodeToString(nonceBytes[:]),
+		ExpiresAt: time.Now().Add(NonceTTL).Unix(),
+	}
+	if err := adapter.InsertNonce(challenge.Nonce, challenge.ExpiresAt); err != nil {
+		return Challenge{}, err
+	}
+	return challenge, nil
+}
+
+// SignedMessage returns the message that a trader signs, using the Ethereum
+// signed message prefix, to perform an action. The domain is the network of
+// the Ingress, so that signatures cannot be used on other networks.
+func SignedMessage(domain, action, payload, nonce string) string {
+	return fmt.Sprintf("RenEx: %v: %v\nDomain: %v\nNonce: %v", action, payload, domain, nonce)
+}
+
+// PostNonceHandler issues a Challenge that traders use to sign a request.
+func PostNonceHandler(nonceAdapter NonceAdapter, domain string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		challenge, err := nonceAdapter.IssueNonce()
+		if err != nil {
+			handleErr(w, fmt.Sprintf("cannot issue nonce: %v", err), http.StatusInternalServerError)
+			return
+		}
+		challenge.Domain = domain
+		response, err := json.Marshal(challenge)
+		if err != nil {
+			handleErr(w, fmt.Sprintf("cannot marshal challenge: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write(response)
+	}
+}
+
+// verifySignedRequest returns the address that signed the action, and
+// consumes the nonce of the request. If the trader is not empty, the request
+// must be signed by the trader. If the request cannot be verified, an error is
+// written to the response and false is returned.
+func verifySignedRequest(w http.ResponseWriter, nonceAdapter NonceAdapter, domain, action, payload, nonce, signature, trader string) (string, bool) {
+	signerAddr, err := recoverSigner(SignedMessage(domain, action, payload, nonce), signature)
+	if err != nil {
+		handleErr(w, fmt.Sprintf("cannot verify signature: %v", err), http.StatusBadRequest)
+		return "", false
+	}
+	if trader != "" && !sameAddress(signerAddr, trader) {
+		handleErr(w, fmt.Sprintf("cannot verify signature: signer = %v, trader = %v", signerAddr, trader), http.StatusUnauthorized)
+		return "", false
+	}
+	if err := nonceAdapter.ConsumeNonce(nonce); err != nil {
+		if err == ingress.ErrInvalidNonce {
+			handleErr(w, fmt.Sprintf("cannot verify signature: %v", err), http.StatusUnauthorized)
+			return "", false
+		}
+		handleErr(w, fmt.Sprintf("cannot consume nonce: %v", err), http.StatusInternalServerError)
+		return "", false
+	}
+	return signerAddr, true
+}
+
+// verifyTraderRead returns true if a request to read the data of the trader
+// presents the admin token, presents a session of the trader in the session
+// query parameter, or is signed by the trader using the nonce and signature
+// query parameters. If the request cannot be verified, an error is written to
+// the response and false is returned.
+func verifyTraderRead(w http.ResponseWriter, r *http.Request, nonceAdapter NonceAdapter, sessionAdapter SessionAdapter, domain, adminToken, action, trader string) bool {
+	if adminAuthorized(adminToken, r) {
+		return true
+	}
+	query := r.URL.Query()
+	if token := query.Get("session"); token != "" {
+		sessionTrader, err := sessionAdapter.SessionTrader(token)
+		if err != nil {
+			if err == ingress.ErrInvalidSession {
+				handleErr(w, fmt.Sprintf("cannot verify session: %v", err), http.StatusUnauthorized)
+				return false
+			}
+			handleErr(w, fmt.Sprintf("cannot get session: %v", err), http.StatusInternalServerError)
+			return false
+		}
+		if !sameAddress(sessionTrader, trader) {
+			handleErr(w, fmt.Sprintf("cannot verify session: session = %v, trader = %v", sessionTrader, trader), http.StatusUnauthorized)
+			return false
+		}
+		return true
+	}
+	_, ok := verifySignedRequest(w, nonceAdapter, domain, action, trader, query.Get("nonce"), query.Get("signature"), trader)
+	return ok
+}
+
+// sameAddress returns true if both addresses are valid and equal, without
+// regard to case or the "0x" prefix.
+func sameAddress(a, b string) bool {
+	addrA, err := UnmarshalAddress(a)
+	if err != nil {
+		return false
+	}
+	addrB, err := UnmarshalAddress(b)
+	if err != nil {
+		return false
+	}
+	return addrA == addrB
+}
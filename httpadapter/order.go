@@ -0,0 +1,190 @@
+package httpadapter
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/republicprotocol/renex-ingress-go/ingress"
+)
+
+// DefaultOrderPageLimit is the number of orders returned when a request does
+// not set a limit.
+const DefaultOrderPageLimit = 20
+
+// MaxOrderPageLimit is the maximum number of orders returned by a request.
+const MaxOrderPageLimit = 100
+
+// GetTraderOrdersHandler returns a page of the orders that the Ingress has
+// approved for a trader, and their state in the Orderbook. Orders are filtered
+// using the state query parameter, and paginated using the offset and limit
+// query parameters, or the cursor and limit query parameters when they are
+// filtered.
+func GetTraderOrdersHandler(orderHistoryAdapter OrderHistoryAdapter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		address := mux.Vars(r)["address"]
+		if _, err := UnmarshalAddress(address); err != nil {
+			handleErr(w, fmt.Sprintf("cannot get orders: %v", err), http.StatusBadRequest)
+			return
+		}
+		query := r.URL.Query()
+		state := query.Get("state")
+		offset, err := pageParam(query.Get("offset"), 0)
+		if err != nil || (state != "" && offset != 0) {
+			handleErr(w, fmt.Sprintf("cannot get orders: %v", ErrInvalidPagination), http.StatusBadRequest)
+			return
+		}
+		cursor, err := pageParam(query.Get("cursor"), 0)
+		if err != nil || (state == "" && cursor != 0) {
+			handleErr(w, fmt.Sprintf("cannot get orders: %v", ErrInvalidPagination), http.StatusBadRequest)
+			return
+		}
+		limit, err := pageParam(query.Get("limit"), DefaultOrderPageLimit)
+		if err != nil || limit > MaxOrderPageLimit {
+			handleErr(w, fmt.Sprintf("cannot get orders: %v", ErrInvalidPagination), http.StatusBadRequest)
+			return
+		}
+
+		page, err := orderHistoryAdapter.TraderOrders(address, ingress.OrderQuery{State: state, Offset: offset, Cursor: cursor, Limit: limit})
+		if err != nil {
+			if err == ingress.ErrUnknownOrderState {
+				handleErr(w, fmt.Sprintf("cannot get orders: %v", err), http.StatusBadRequest)
+				return
+			}
+			handleErr(w, fmt.Sprintf("cannot get orders: %v", err), http.StatusInternalServerError)
+			return
+		}
+		response, err := json.Marshal(page)
+		if err != nil {
+			handleErr(w, fmt.Sprintf("cannot marshal orders: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(response)
+	}
+}
+
+// GetIndexedOrdersHandler returns a page of the orders of the Orderbook that
+// have been indexed, most recently opened first. Orders are filtered using the
+// trader, state, fromBlock and toBlock query parameters, and paginated using
+// the offset and limit query parameters.
+func GetIndexedOrdersHandler(orderIndexAdapter OrderIndexAdapter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		trader := query.Get("trader")
+		if trader != "" {
+			if _, err := UnmarshalAddress(trader); err != nil {
+				handleErr(w, fmt.Sprintf("cannot get indexed orders: %v", err), http.StatusBadRequest)
+				return
+			}
+		}
+		fromBlock, err := blockParam(query.Get("fromBlock"))
+		if err != nil {
+			handleErr(w, fmt.Sprintf("cannot get indexed orders: %v", err), http.StatusBadRequest)
+			return
+		}
+		toBlock, err := blockParam(query.Get("toBlock"))
+		if err != nil {
+			handleErr(w, fmt.Sprintf("cannot get indexed orders: %v", err), http.StatusBadRequest)
+			return
+		}
+		offset, err := pageParam(query.Get("offset"), 0)
+		if err != nil {
+			handleErr(w, fmt.Sprintf("cannot get indexed orders: %v", err), http.StatusBadRequest)
+			return
+		}
+		limit, err := pageParam(query.Get("limit"), DefaultOrderPageLimit)
+		if err != nil || limit > MaxOrderPageLimit {
+			handleErr(w, fmt.Sprintf("cannot get indexed orders: %v", ErrInvalidPagination), http.StatusBadRequest)
+			return
+		}
+
+		page, err := orderIndexAdapter.IndexedOrders(ingress.IndexQuery{
+			Trader:    trader,
+			State:     query.Get("state"),
+			FromBlock: fromBlock,
+			ToBlock:   toBlock,
+			Offset:    offset,
+			Limit:     limit,
+		})
+		if err != nil {
+			if err == ingress.ErrUnknownOrderState {
+				handleErr(w, fmt.Sprintf("cannot get indexed orders: %v", err), http.StatusBadRequest)
+				return
+			}
+			handleErr(w, fmt.Sprintf("cannot get indexed orders: %v", err), http.StatusInternalServerError)
+			return
+		}
+		response, err := json.Marshal(page)
+		if err != nil {
+			handleErr(w, fmt.Sprintf("cannot marshal indexed orders: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(response)
+	}
+}
+
+// GetIndexedOrderHandler returns an order of the Orderbook that has been
+// indexed.
+func GetIndexedOrderHandler(orderIndexAdapter OrderIndexAdapter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		orderID := urlSafeOrderID.Replace(mux.Vars(r)["orderID"])
+		if _, err := UnmarshalOrderID(orderID); err != nil {
+			handleErr(w, fmt.Sprintf("cannot get indexed order: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		order, err := orderIndexAdapter.IndexedOrder(orderID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				handleErr(w, fmt.Sprintf("cannot get indexed order: order %v has not been indexed", orderID), http.StatusNotFound)
+				return
+			}
+			handleErr(w, fmt.Sprintf("cannot get indexed order: %v", err), http.StatusInternalServerError)
+			return
+		}
+		response, err := json.Marshal(order)
+		if err != nil {
+			handleErr(w, fmt.Sprintf("cannot marshal indexed order: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(response)
+	}
+}
+
+// blockParam returns the value of a block number query parameter, or zero
+// when the parameter is not set.
+func blockParam(param string) (uint64, error) {
+	if param == "" {
+		return 0, nil
+	}
+	value, err := strconv.ParseUint(param, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidBlockNumber
+	}
+	return value, nil
+}
+
+// pageParam returns the value of a pagination query parameter, or the
+// default value when the parameter is not set.
+func pageParam(param string, defaultValue int) (int, error) {
+	if param == "" {
+		return defaultValue, nil
+	}
+	value, err := strconv.Atoi(param)
+	if err != nil || value < 0 {
+		return 0, ErrInvalidPagination
+	}
+	return value, nil
+}
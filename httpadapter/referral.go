@@ -0,0 +1,81 @@
+package httpadapter
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/republicprotocol/renex-ingress-go/config"
+	"github.com/republicprotocol/renex-ingress-go/ingress"
+)
+
+// KYCStatusNone is the KYC status of a Referral that has not been verified.
+const KYCStatusNone = "none"
+
+// Referrals of a trader. The Referrer is empty if the trader was not
+// referred.
+type Referrals struct {
+	Address      string     `json:"address"`
+	ReferralCode string     `json:"referralCode"`
+	Referrer     string     `json:"referrer"`
+	Referrals    []Referral `json:"referrals"`
+}
+
+// Referral is a trader that was referred by another trader. The KYC status
+// is the name of the KYC provider that verified the trader, or
+// KYCStatusNone.
+type Referral struct {
+	Address   string `json:"address"`
+	CreatedAt int64  `json:"createdAt"`
+	KYC       string `json:"kyc"`
+}
+
+// kycStatus returns the KYC status of a Login, as recorded when the trader
+// was last verified.
+func kycStatus(login ingress.Login) string {
+	switch {
+	case login.KYCWyre != "":
+		return config.KYCProviderWyre
+	case login.KYCKyber != 0:
+		return config.KYCProviderKyber
+	default:
+		return KYCStatusNone
+	}
+}
+
+// GetReferralsHandler returns the referral code of a trader, the trader that
+// referred them, and the traders that they referred. The request must be
+// signed by the trader, using the nonce and signature query parameters,
+// present a session of the trader, or present the admin token.
+func GetReferralsHandler(referralAdapter ReferralAdapter, nonceAdapter NonceAdapter, sessionAdapter SessionAdapter, domain, adminToken string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		address := mux.Vars(r)["address"]
+		if _, err := UnmarshalAddress(address); err != nil {
+			handleErr(w, fmt.Sprintf("cannot get referrals: %v", err), http.StatusBadRequest)
+			return
+		}
+		if !verifyTraderRead(w, r, nonceAdapter, sessionAdapter, domain, adminToken, ActionReferrals, address) {
+			return
+		}
+		referrals, err := referralAdapter.Referrals(address)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				handleErr(w, fmt.Sprintf("cannot get referrals: trader %v has not logged in", address), http.StatusNotFound)
+				return
+			}
+			handleErr(w, fmt.Sprintf("cannot get referrals: %v", err), http.StatusInternalServerError)
+			return
+		}
+		response, err := json.Marshal(referrals)
+		if err != nil {
+			handleErr(w, fmt.Sprintf("cannot marshal referrals: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(response)
+	}
+}
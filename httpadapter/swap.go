@@ -0,0 +1,79 @@
+package httpadapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// urlSafeOrderID converts an order ID encoded using URL safe base64 to
+// standard base64, so that order IDs can be used in paths without escaping.
+var urlSafeOrderID = strings.NewReplacer("-", "+", "_", "/")
+
+// GetSwapHandler returns the SwapStatus of an order. The request must be
+// signed by the trader of the order, using the nonce and signature query
+// parameters, or present the admin token.
+func GetSwapHandler(swapAdapter SwapAdapter, nonceAdapter NonceAdapter, domain, adminToken string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		orderID := urlSafeOrderID.Replace(mux.Vars(r)["orderID"])
+		if _, err := UnmarshalOrderID(orderID); err != nil {
+			handleErr(w, fmt.Sprintf("cannot get swap: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		if !adminAuthorized(adminToken, r) {
+			trader, err := swapAdapter.OrderTrader(orderID)
+			if err != nil {
+				if err == ErrUnknownOrder {
+					handleErr(w, fmt.Sprintf("cannot get swap: %v", err), http.StatusNotFound)
+					return
+				}
+				handleErr(w, fmt.Sprintf("cannot get order trader: %v", err), http.StatusInternalServerError)
+				return
+			}
+			query := r.URL.Query()
+			if _, ok := verifySignedRequest(w, nonceAdapter, domain, ActionSwapStatus, orderID, query.Get("nonce"), query.Get("signature"), trader); !ok {
+				return
+			}
+		}
+
+		status, err := swapAdapter.SwapStatus(orderID)
+		if err != nil {
+			handleErr(w, fmt.Sprintf("cannot get swap: %v", err), http.StatusInternalServerError)
+			return
+		}
+		response, err := json.Marshal(status)
+		if err != nil {
+			handleErr(w, fmt.Sprintf("cannot marshal swap: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(response)
+	}
+}
+
+// GetRefundableSwapsHandler returns the swaps that are past their timelock, so
+// that traders can be told to refund their side of the swap.
+func GetRefundableSwapsHandler(swapAdapter SwapAdapter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		swaps, err := swapAdapter.RefundableSwaps()
+		if err != nil {
+			handleErr(w, fmt.Sprintf("cannot get refundable swaps: %v", err), http.StatusInternalServerError)
+			return
+		}
+		response, err := json.Marshal(swaps)
+		if err != nil {
+			handleErr(w, fmt.Sprintf("cannot marshal refundable swaps: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(response)
+	}
+}
@@ -0,0 +1,39 @@
+package httpadapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/renproject/swapperd/foundation/blockchain"
+	"github.com/republicprotocol/renex-ingress-go/config"
+)
+
+// GetVaultsHandler returns the broker addresses that are used for atomic
+// swaps of each blockchain and token.
+func GetVaultsHandler(vaults config.VaultRegistry) http.HandlerFunc {
+	if vaults == nil {
+		vaults = config.VaultRegistry{}
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		response, err := json.Marshal(vaults)
+		if err != nil {
+			handleErr(w, fmt.Sprintf("cannot marshal vaults: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(response)
+	}
+}
+
+// brokerAddress returns the broker address of the vault for the token, or an
+// error if no vault has been configured for the token or its blockchain.
+func brokerAddress(vaults config.VaultRegistry, token blockchain.Token) (string, error) {
+	address, ok := vaults.Address(string(token.Blockchain), string(token.Name))
+	if !ok {
+		return "", blockchain.NewErrUnsupportedBlockchain(token.Blockchain)
+	}
+	return address, nil
+}
@@ -0,0 +1,165 @@
+package httpadapter
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/gorilla/mux"
+	"github.com/republicprotocol/renex-ingress-go/ingress"
+)
+
+// PostWebhookHandler registers a webhook to which the events of a trader are
+// delivered. The request must be signed by the trader, or by an address that
+// has authorized the trader to share its verification. Unless the Ingress is
+// running on a local network, the webhook must be an HTTPS URL whose host
+// resolves to public addresses.
+func PostWebhookHandler(webhookAdapter WebhookAdapter, loginAdapter LoginAdapter, nonceAdapter NonceAdapter, domain string, local bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req PostWebhookRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			handleErr(w, fmt.Sprintf("cannot decode request: %v", err), http.StatusBadRequest)
+			return
+		}
+		if _, err := UnmarshalAddress(req.Trader); err != nil {
+			handleErr(w, fmt.Sprintf("cannot register webhook: %v", err), http.StatusBadRequest)
+			return
+		}
+		if !validWebhookURL(req.URL, local) {
+			handleErr(w, fmt.Sprintf("cannot register webhook: %v", ErrInvalidWebhookURL), http.StatusBadRequest)
+			return
+		}
+		signerAddr, ok := verifySignedRequest(w, nonceAdapter, domain, ActionRegisterWebhook, req.Trader+" "+req.URL, req.Nonce, req.Signature, "")
+		if !ok {
+			return
+		}
+		if !sameAddress(signerAddr, req.Trader) {
+			authorized, err := loginAdapter.AuthorizedAddresses(signerAddr)
+			if err != nil {
+				handleErr(w, fmt.Sprintf("cannot get authorized addresses: %v", err), http.StatusInternalServerError)
+				return
+			}
+			if !containsAddress(authorized, req.Trader) {
+				handleErr(w, fmt.Sprintf("cannot register webhook: %v is not authorized by %v", req.Trader, signerAddr), http.StatusUnauthorized)
+				return
+			}
+		}
+
+		webhook, err := webhookAdapter.RegisterWebhook(req.Trader, req.URL, signerAddr)
+		if err != nil {
+			handleErr(w, fmt.Sprintf("cannot register webhook: %v", err), http.StatusInternalServerError)
+			return
+		}
+		response, err := json.Marshal(webhook)
+		if err != nil {
+			handleErr(w, fmt.Sprintf("cannot marshal webhook: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write(response)
+	}
+}
+
+// DeleteWebhookHandler unregisters a webhook. The request must be signed by
+// the trader of the webhook, or by the address that registered it.
+func DeleteWebhookHandler(webhookAdapter WebhookAdapter, nonceAdapter NonceAdapter, domain string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+		var req DeleteWebhookRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			handleErr(w, fmt.Sprintf("cannot decode request: %v", err), http.StatusBadRequest)
+			return
+		}
+		webhook, err := webhookAdapter.Webhook(id)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				handleErr(w, fmt.Sprintf("cannot unregister webhook: unknown webhook %v", id), http.StatusNotFound)
+				return
+			}
+			handleErr(w, fmt.Sprintf("cannot get webhook: %v", err), http.StatusInternalServerError)
+			return
+		}
+		signerAddr, ok := verifySignedRequest(w, nonceAdapter, domain, ActionUnregisterWebhook, id, req.Nonce, req.Signature, "")
+		if !ok {
+			return
+		}
+		if !sameAddress(signerAddr, webhook.Trader) && !sameAddress(signerAddr, webhook.RegisteredBy) {
+			handleErr(w, fmt.Sprintf("cannot unregister webhook: %v", ErrUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		if err := webhookAdapter.DeleteWebhook(id); err != nil {
+			if err == sql.ErrNoRows {
+				handleErr(w, fmt.Sprintf("cannot unregister webhook: unknown webhook %v", id), http.StatusNotFound)
+				return
+			}
+			handleErr(w, fmt.Sprintf("cannot unregister webhook: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// GetFailedWebhookDeliveriesHandler returns the deliveries that have failed
+// after the maximum number of attempts.
+func GetFailedWebhookDeliveriesHandler(webhookAdapter WebhookAdapter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		deliveries, err := webhookAdapter.FailedWebhookDeliveries()
+		if err != nil {
+			handleErr(w, fmt.Sprintf("cannot get failed webhook deliveries: %v", err), http.StatusInternalServerError)
+			return
+		}
+		response, err := json.Marshal(deliveries)
+		if err != nil {
+			handleErr(w, fmt.Sprintf("cannot marshal failed webhook deliveries: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(response)
+	}
+}
+
+// validWebhookURL returns true if the URL is an absolute HTTPS URL whose host
+// only resolves to public addresses. On a local network, HTTP URLs and private
+// addresses are allowed.
+func validWebhookURL(rawURL string, local bool) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return false
+	}
+	if local {
+		return u.Scheme == "http" || u.Scheme == "https"
+	}
+	if u.Scheme != "https" {
+		return false
+	}
+	ips, err := net.LookupIP(u.Hostname())
+	if err != nil || len(ips) == 0 {
+		return false
+	}
+	for _, ip := range ips {
+		if !ingress.PublicIP(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// containsAddress returns true if the address is one of the authorized
+// addresses.
+func containsAddress(authorized []GetAuthorizeResponse, address string) bool {
+	for _, addr := range authorized {
+		if sameAddress(addr.AtomAddress, address) {
+			return true
+		}
+	}
+	return false
+}
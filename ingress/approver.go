@@ -0,0 +1,222 @@
+package ingress
+
+import (
+	"database/sql"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The schema of the approved_traders table is defined by Migrations.
+
+// ApprovedTraderSeeder is used as the approver for traders that are seeded
+// from the approved traders in the config file.
+const ApprovedTraderSeeder = "config"
+
+// DefaultApprovedTradersCacheTTL is the duration for which approved traders
+// are cached before being reloaded from the database.
+const DefaultApprovedTradersCacheTTL = time.Minute
+
+// ApprovedTrader is a trader that has been manually approved to open orders
+// without KYC verification (e.g. market makers).
+type ApprovedTrader struct {
+	Address    string `json:"address"`
+	Note       string `json:"note"`
+	ApprovedBy string `json:"approvedBy"`
+	CreatedAt  int64  `json:"createdAt"`
+
+	// ExpiresAt is the unix timestamp after which the approval is no longer
+	// valid. A zero value means the approval does not expire.
+	ExpiresAt int64 `json:"expiresAt"`
+}
+
+// Expired returns true if the approval has expired at the given time.
+func (trader ApprovedTrader) Expired(now time.Time) bool {
+	return trader.ExpiresAt != 0 && now.Unix() >= trader.ExpiresAt
+}
+
+// Approver manages the traders that have been manually approved.
+type Approver interface {
+	ApprovedTraders() ([]ApprovedTrader, error)
+	InsertApprovedTrader(trader ApprovedTrader) error
+
+	// DeleteApprovedTrader removes the approval of a trader. It returns
+	// sql.ErrNoRows if the trader has not been approved.
+	DeleteApprovedTrader(address string) error
+
+	TraderApproved(address string) (bool, error)
+}
+
+type approver struct {
+	*DB
+
+	cacheTTL      time.Duration
+	cacheMu       *sync.Mutex
+	cache         map[string]ApprovedTrader
+	cacheLoadedAt time.Time
+
+	// cacheVersion is incremented whenever the cache is invalidated, so that
+	// traders loaded before then are not cached.
+	cacheVersion uint64
+}
+
+// NewApprover returns an Approver that stores approved traders in the
+// database at the URL. The seed traders are inserted if they are not already
+// stored.
+func NewApprover(databaseURL string, seed []string) (Approver, error) {
+	db, err := OpenDB(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	return NewApproverWithDB(db, seed)
+}
+
+// NewApproverWithDB returns an Approver that stores approved traders in an
+// open database.
+func NewApproverWithDB(db *DB, seed []string) (Approver, error) {
+	return NewApproverWithCacheTTL(db, seed, DefaultApprovedTradersCacheTTL)
+}
+
+// NewApproverWithCacheTTL returns an Approver that stores approved traders in
+// an open database, and caches them for the cache TTL.
+func NewApproverWithCacheTTL(db *DB, seed []string, cacheTTL time.Duration) (Approver, error) {
+	approver := &approver{
+		DB: db,
+
+		cacheTTL: cacheTTL,
+		cacheMu:  new(sync.Mutex),
+	}
+	if err := approver.seed(seed); err != nil {
+		return nil, err
+	}
+	return approver, nil
+}
+
+func (approver *approver) ApprovedTraders() ([]ApprovedTrader, error) {
+	rows, err := approver.Query("SELECT address, note, approved_by, created_at, expires_at FROM approved_traders ORDER BY created_at")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	traders := []ApprovedTrader{}
+	for rows.Next() {
+		var trader ApprovedTrader
+		var note, approvedBy sql.NullString
+		var expiresAt sql.NullInt64
+		if err := rows.Scan(&trader.Address, &note, &approvedBy, &trader.CreatedAt, &expiresAt); err != nil {
+			return nil, err
+		}
+		trader.Note = note.String
+		trader.ApprovedBy = approvedBy.String
+		trader.ExpiresAt = expiresAt.Int64
+		traders = append(traders, trader)
+	}
+	return traders, rows.Err()
+}
+
+func (approver *approver) InsertApprovedTrader(trader ApprovedTrader) error {
+	if trader.CreatedAt == 0 {
+		trader.CreatedAt = time.Now().Unix()
+	}
+	_, err := approver.Exec("INSERT INTO approved_traders (address, note, approved_by, created_at, expires_at) VALUES ($1, $2, $3, $4, $5) ON CONFLICT (address) DO UPDATE SET note=$2, approved_by=$3, expires_at=$5",
+		normalizeAddress(trader.Address), trader.Note, trader.ApprovedBy, trader.CreatedAt, trader.ExpiresAt)
+	if err != nil {
+		return err
+	}
+	approver.invalidateCache()
+	return nil
+}
+
+func (approver *approver) DeleteApprovedTrader(address string) error {
+	res, err := approver.Exec("DELETE FROM approved_traders WHERE address=$1", normalizeAddress(address))
+	if err != nil {
+		return err
+	}
+	approver.invalidateCache()
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// TraderApproved returns true if the trader has an approval that has not
+// expired. Approved traders are read from a cache that is reloaded from the
+// database once it is older than the cache TTL.
+func (approver *approver) TraderApproved(address string) (bool, error) {
+	cache, err := approver.cachedTraders()
+	if err != nil {
+		return false, err
+	}
+	trader, ok := cache[normalizeAddress(address)]
+	if !ok {
+		return false, nil
+	}
+	return !trader.Expired(time.Now()), nil
+}
+
+// cachedTraders returns the cached approved traders, and reloads them once the
+// cache is older than the cache TTL. The database is queried without holding
+// the lock, so that concurrent requests are not blocked by a slow query. A
+// cache is never modified once it has been loaded.
+func (approver *approver) cachedTraders() (map[string]ApprovedTrader, error) {
+	approver.cacheMu.Lock()
+	cache, version := approver.cache, approver.cacheVersion
+	expired := cache == nil || time.Since(approver.cacheLoadedAt) > approver.cacheTTL
+	approver.cacheMu.Unlock()
+	if !expired {
+		return cache, nil
+	}
+
+	traders, err := approver.ApprovedTraders()
+	if err != nil {
+		return nil, err
+	}
+	cache = make(map[string]ApprovedTrader, len(traders))
+	for _, trader := range traders {
+		cache[trader.Address] = trader
+	}
+
+	approver.cacheMu.Lock()
+	defer approver.cacheMu.Unlock()
+
+	if approver.cacheVersion == version {
+		approver.cache = cache
+		approver.cacheLoadedAt = time.Now()
+	}
+	return cache, nil
+}
+
+func (approver *approver) seed(addresses []string) error {
+	timestamp := time.Now().Unix()
+	for _, address := range addresses {
+		if _, err := approver.Exec("INSERT INTO approved_traders (address, note, approved_by, created_at, expires_at) VALUES ($1, $2, $3, $4, 0) ON CONFLICT DO NOTHING",
+			normalizeAddress(address), "", ApprovedTraderSeeder, timestamp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (approver *approver) invalidateCache() {
+	approver.cacheMu.Lock()
+	defer approver.cacheMu.Unlock()
+
+	approver.cache = nil
+	approver.cacheVersion++
+}
+
+// normalizeAddress returns the lower case, 0x prefixed, representation of an
+// Ethereum address.
+func normalizeAddress(address string) string {
+	address = strings.ToLower(strings.TrimSpace(address))
+	if !strings.HasPrefix(address, "0x") {
+		address = "0x" + address
+	}
+	return address
+}
@@ -0,0 +1,99 @@
+package ingress_test
+
+import (
+	"database/sql"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/republicprotocol/renex-ingress-go/ingress"
+)
+
+var _ = Describe("Approver", func() {
+
+	trader := "0x62026B5aC38f1b186c7af0b18Aee8B2EccC2d852"
+
+	var db *DB
+	var approver Approver
+
+	BeforeEach(func() {
+		var err error
+		db, err = OpenDB(SQLiteURLPrefix + ":memory:")
+		Expect(err).ShouldNot(HaveOccurred())
+		_, err = db.Migrate()
+		Expect(err).ShouldNot(HaveOccurred())
+		approver, err = NewApproverWithCacheTTL(db, nil, 100*time.Millisecond)
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	It("should return cached approvals until the cache expires", func() {
+		Expect(approver.InsertApprovedTrader(ApprovedTrader{Address: trader})).ShouldNot(HaveOccurred())
+		Expect(approver.TraderApproved(trader)).Should(BeTrue())
+
+		// Deleting the trader from the database bypasses the cache.
+		_, err := db.Exec("DELETE FROM approved_traders")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(approver.TraderApproved(trader)).Should(BeTrue())
+
+		time.Sleep(150 * time.Millisecond)
+		Expect(approver.TraderApproved(trader)).Should(BeFalse())
+	})
+
+	It("should invalidate the cache when traders are inserted and deleted", func() {
+		Expect(approver.TraderApproved(trader)).Should(BeFalse())
+		Expect(approver.InsertApprovedTrader(ApprovedTrader{Address: trader})).ShouldNot(HaveOccurred())
+		Expect(approver.TraderApproved(trader)).Should(BeTrue())
+		Expect(approver.DeleteApprovedTrader(trader)).ShouldNot(HaveOccurred())
+		Expect(approver.TraderApproved(trader)).Should(BeFalse())
+	})
+
+	It("should return an error when deleting traders that have not been approved", func() {
+		Expect(approver.DeleteApprovedTrader(trader)).Should(Equal(sql.ErrNoRows))
+	})
+
+	It("should return approvals to concurrent requests that reload the cache", func() {
+		Expect(approver.InsertApprovedTrader(ApprovedTrader{Address: trader})).ShouldNot(HaveOccurred())
+
+		// Concurrent reloads load the traders from the database, and return
+		// the same approvals.
+		results := make(chan bool, 10)
+		for i := 0; i < cap(results); i++ {
+			go func() {
+				defer GinkgoRecover()
+				approved, err := approver.TraderApproved(trader)
+				Expect(err).ShouldNot(HaveOccurred())
+				results <- approved
+			}()
+		}
+		for i := 0; i < cap(results); i++ {
+			Eventually(results).Should(Receive(BeTrue()))
+		}
+	})
+
+	It("should not approve traders whose approval has expired", func() {
+		now := time.Now().Unix()
+		Expect(approver.InsertApprovedTrader(ApprovedTrader{Address: trader, ExpiresAt: now - 1})).ShouldNot(HaveOccurred())
+		Expect(approver.TraderApproved(trader)).Should(BeFalse())
+		Expect(approver.InsertApprovedTrader(ApprovedTrader{Address: trader, ExpiresAt: now + 60})).ShouldNot(HaveOccurred())
+		Expect(approver.TraderApproved(trader)).Should(BeTrue())
+
+		approval := ApprovedTrader{ExpiresAt: now}
+		Expect(approval.Expired(time.Unix(now-1, 0))).Should(BeFalse())
+		Expect(approval.Expired(time.Unix(now, 0))).Should(BeTrue())
+		Expect(ApprovedTrader{}.Expired(time.Now())).Should(BeFalse())
+	})
+
+	It("should normalize the case and prefix of addresses", func() {
+		Expect(approver.InsertApprovedTrader(ApprovedTrader{Address: " " + trader})).ShouldNot(HaveOccurred())
+		Expect(approver.TraderApproved("0x62026b5ac38f1b186c7af0b18aee8b2eccc2d852")).Should(BeTrue())
+		Expect(approver.TraderApproved("62026B5AC38F1B186C7AF0B18AEE8B2ECCC2D852")).Should(BeTrue())
+
+		traders, err := approver.ApprovedTraders()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(traders).Should(HaveLen(1))
+		Expect(traders[0].Address).Should(Equal("0x62026b5ac38f1b186c7af0b18aee8b2eccc2d852"))
+
+		Expect(approver.DeleteApprovedTrader("0X62026B5AC38F1B186C7AF0B18AEE8B2ECCC2D852")).ShouldNot(HaveOccurred())
+		Expect(approver.TraderApproved(trader)).Should(BeFalse())
+	})
+})
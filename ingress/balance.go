@@ -0,0 +1,115 @@
+package ingress
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/republicprotocol/renex-ingress-go/contract"
+)
+
+// RegisteredTokensTTL is the duration for which the tokens registered in the
+// RenExTokens contract are cached.
+const RegisteredTokensTTL = 10 * time.Minute
+
+// A TokenBalance is the balance of a trader in the RenExBalances contract for
+// a token registered in the RenExTokens contract. The Balance is a decimal
+// string in the smallest unit of the token, and the WithdrawalSignal is the
+// Unix time at which the trader signaled a backup withdrawal of the token.
+type TokenBalance struct {
+	Token            uint32 `json:"token"`
+	Symbol           string `json:"symbol"`
+	Address          string `json:"address"`
+	Decimals         uint8  `json:"decimals"`
+	Balance          string `json:"balance"`
+	WithdrawalSignal int64  `json:"withdrawalSignal,omitempty"`
+}
+
+// TraderBalances are the balances of a trader, and the nonce that the next
+// withdrawal signature of the trader will be signed with.
+type TraderBalances struct {
+	Trader          string         `json:"trader"`
+	WithdrawalNonce string         `json:"withdrawalNonce"`
+	Balances        []TokenBalance `json:"balances"`
+}
+
+// A Balancer reads the balances of traders from the RenExBalances contract.
+type Balancer interface {
+	// Balances returns the balances of the trader for every token that is
+	// registered in the RenExTokens contract.
+	Balances(trader string) (TraderBalances, error)
+}
+
+type balancer struct {
+	binder BalanceContractBinder
+
+	mu       *sync.Mutex
+	tokens   []contract.RegisteredToken
+	loadedAt time.Time
+}
+
+// NewBalancer returns a Balancer that reads balances using the RenExBalances
+// contract.
+func NewBalancer(binder BalanceContractBinder) Balancer {
+	return &balancer{
+		binder: binder,
+		mu:     new(sync.Mutex),
+	}
+}
+
+func (balancer *balancer) Balances(trader string) (TraderBalances, error) {
+	address := common.HexToAddress(trader)
+	tokens, err := balancer.registeredTokens()
+	if err != nil {
+		return TraderBalances{}, fmt.Errorf("cannot get registered tokens: %v", err)
+	}
+	nonce, err := balancer.binder.GetTraderWithdrawalNonce(address)
+	if err != nil {
+		return TraderBalances{}, fmt.Errorf("cannot get withdrawal nonce for trader=%v: %v", trader, err)
+	}
+
+	balances := TraderBalances{
+		Trader:          normalizeAddress(trader),
+		WithdrawalNonce: bigIntString(nonce),
+		Balances:        make([]TokenBalance, len(tokens)),
+	}
+	for i, token := range tokens {
+		balance, err := balancer.binder.TraderBalance(address, token.Address)
+		if err != nil {
+			return TraderBalances{}, fmt.Errorf("cannot get balance of token=%v for trader=%v: %v", token.Code, trader, err)
+		}
+		signal, err := balancer.binder.TraderWithdrawalSignal(address, token.Address)
+		if err != nil {
+			return TraderBalances{}, fmt.Errorf("cannot get withdrawal signal of token=%v for trader=%v: %v", token.Code, trader, err)
+		}
+		balances.Balances[i] = TokenBalance{
+			Token:    token.Code,
+			Symbol:   token.Symbol,
+			Address:  token.Address.Hex(),
+			Decimals: token.Decimals,
+			Balance:  bigIntString(balance),
+		}
+		if signal != nil {
+			balances.Balances[i].WithdrawalSignal = signal.Int64()
+		}
+	}
+	return balances, nil
+}
+
+// registeredTokens returns the tokens registered in the RenExTokens contract,
+// and loads them again once they have been cached for RegisteredTokensTTL.
+func (balancer *balancer) registeredTokens() ([]contract.RegisteredToken, error) {
+	balancer.mu.Lock()
+	defer balancer.mu.Unlock()
+
+	if balancer.tokens != nil && time.Since(balancer.loadedAt) < RegisteredTokensTTL {
+		return balancer.tokens, nil
+	}
+	tokens, err := balancer.binder.RegisteredTokens()
+	if err != nil {
+		return nil, err
+	}
+	balancer.tokens, balancer.loadedAt = tokens, time.Now()
+	return tokens, nil
+}
@@ -0,0 +1,102 @@
+package ingress_test
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/republicprotocol/renex-ingress-go/ingress"
+
+	"github.com/republicprotocol/renex-ingress-go/contract"
+)
+
+var _ = Describe("Balancer", func() {
+
+	trader := common.HexToAddress("0x62026B5aC38f1b186c7af0b18Aee8B2EccC2d852")
+	eth := common.HexToAddress("0xEeeeeEeeeEeEeeEeEeEeeEEEeeeeEeeeeeeeEEeE")
+	dgx := common.HexToAddress("0x0000000000000000000000000000000000000100")
+
+	var binder *mockBalanceBinder
+	var balancer Balancer
+
+	BeforeEach(func() {
+		binder = newMockBalanceBinder()
+		binder.tokens = []contract.RegisteredToken{
+			{Code: 1, Address: eth, Decimals: 18, Symbol: "ETH"},
+			{Code: 256, Address: dgx, Decimals: 9, Symbol: "DGX"},
+		}
+		binder.nonces[trader] = big.NewInt(3)
+		binder.balances[trader] = map[common.Address]*big.Int{eth: big.NewInt(1000000000000000000)}
+		binder.signals[trader] = map[common.Address]*big.Int{dgx: big.NewInt(1530000000)}
+		balancer = NewBalancer(binder)
+	})
+
+	It("should return the balances of the trader for every registered token", func() {
+		balances, err := balancer.Balances(trader.Hex())
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(balances.Trader).Should(Equal("0x62026b5ac38f1b186c7af0b18aee8b2eccc2d852"))
+		Expect(balances.WithdrawalNonce).Should(Equal("3"))
+		Expect(balances.Balances).Should(Equal([]TokenBalance{
+			{Token: 1, Symbol: "ETH", Address: eth.Hex(), Decimals: 18, Balance: "1000000000000000000"},
+			{Token: 256, Symbol: "DGX", Address: dgx.Hex(), Decimals: 9, Balance: "0", WithdrawalSignal: 1530000000},
+		}))
+	})
+
+	It("should cache the registered tokens", func() {
+		_, err := balancer.Balances(trader.Hex())
+		Expect(err).ShouldNot(HaveOccurred())
+		binder.tokens = nil
+		balances, err := balancer.Balances(trader.Hex())
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(balances.Balances).Should(HaveLen(2))
+		Expect(binder.loaded).Should(Equal(1))
+	})
+
+	It("should return an error when the contracts cannot be read", func() {
+		binder.err = errors.New("cannot connect")
+		_, err := balancer.Balances(trader.Hex())
+		Expect(err).Should(HaveOccurred())
+	})
+})
+
+// mockBalanceBinder is a RenExBalances contract. Balances, signals and nonces
+// that have not been set are nil, as they are for the contract bindings.
+type mockBalanceBinder struct {
+	tokens   []contract.RegisteredToken
+	loaded   int
+	balances map[common.Address]map[common.Address]*big.Int
+	signals  map[common.Address]map[common.Address]*big.Int
+	nonces   map[common.Address]*big.Int
+	err      error
+}
+
+func newMockBalanceBinder() *mockBalanceBinder {
+	return &mockBalanceBinder{
+		balances: map[common.Address]map[common.Address]*big.Int{},
+		signals:  map[common.Address]map[common.Address]*big.Int{},
+		nonces:   map[common.Address]*big.Int{},
+	}
+}
+
+func (binder *mockBalanceBinder) RegisteredTokens() ([]contract.RegisteredToken, error) {
+	if binder.err != nil {
+		return nil, binder.err
+	}
+	binder.loaded++
+	return binder.tokens, nil
+}
+
+func (binder *mockBalanceBinder) TraderBalance(trader, token common.Address) (*big.Int, error) {
+	return binder.balances[trader][token], binder.err
+}
+
+func (binder *mockBalanceBinder) TraderWithdrawalSignal(trader, token common.Address) (*big.Int, error) {
+	return binder.signals[trader][token], binder.err
+}
+
+func (binder *mockBalanceBinder) GetTraderWithdrawalNonce(trader common.Address) (*big.Int, error) {
+	return binder.nonces[trader], binder.err
+}
@@ -0,0 +1,149 @@
+package ingress
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/satori/go.uuid"
+)
+
+// The schema of the broadcast_events table is defined by Migrations.
+
+// BroadcastInterval is the interval at which the Events that are broadcast by
+// the other processes of the Ingress are received.
+var BroadcastInterval = time.Second
+
+// BroadcastWindow is the duration for which an Event can be received after it
+// is broadcast. It must be longer than the BroadcastInterval, and than the
+// clock skew between the processes of the Ingress.
+var BroadcastWindow = time.Minute
+
+// A Broadcaster shares the Events that are observed by each process of the
+// Ingress with the other processes, so that they can be streamed to the
+// traders that are subscribed to any of the processes.
+type Broadcaster interface {
+	// Broadcast an Event to the other Broadcasters.
+	Broadcast(event Event) error
+
+	// Received returns the Events that were broadcast by the other
+	// Broadcasters within the BroadcastWindow, and that have not been
+	// returned before, in the order in which they were broadcast.
+	Received() ([]Event, error)
+
+	// PurgeBroadcasts deletes the Events that were broadcast before the
+	// BroadcastWindow.
+	PurgeBroadcasts() error
+}
+
+type broadcaster struct {
+	*DB
+	source string
+
+	mu       *sync.Mutex
+	seq      int64
+	received map[string]int64
+}
+
+// NewBroadcaster returns a Broadcaster that stores Events in the database at
+// the URL. Each Broadcaster is a different process.
+func NewBroadcaster(databaseURL string) (Broadcaster, error) {
+	db, err := OpenDB(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	return NewBroadcasterWithDB(db), nil
+}
+
+// NewBroadcasterWithDB returns a Broadcaster that stores Events in an open
+// database. Each Broadcaster is a different process.
+func NewBroadcasterWithDB(db *DB) Broadcaster {
+	return &broadcaster{
+		DB:     db,
+		source: uuid.NewV4().String(),
+
+		mu:       new(sync.Mutex),
+		received: map[string]int64{},
+	}
+}
+
+func (broadcaster *broadcaster) Broadcast(event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	broadcaster.mu.Lock()
+	broadcaster.seq++
+	seq := broadcaster.seq
+	broadcaster.mu.Unlock()
+
+	_, err = broadcaster.Exec("INSERT INTO broadcast_events (source, seq, payload, created_at) VALUES ($1, $2, $3, $4)",
+		broadcaster.source, seq, string(payload), time.Now().UnixNano())
+	return err
+}
+
+func (broadcaster *broadcaster) Received() ([]Event, error) {
+	since := time.Now().Add(-BroadcastWindow).UnixNano()
+	rows, err := broadcaster.Query("SELECT source, seq, payload, created_at FROM broadcast_events WHERE created_at > $1 AND source <> $2 ORDER BY created_at, source, seq",
+		since, broadcaster.source)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	broadcaster.mu.Lock()
+	defer broadcaster.mu.Unlock()
+
+	events := []Event{}
+	for rows.Next() {
+		var source, payload string
+		var seq, createdAt int64
+		if err := rows.Scan(&source, &seq, &payload, &createdAt); err != nil {
+			return nil, err
+		}
+		key := fmt.Sprintf("%v:%v", source, seq)
+		if _, ok := broadcaster.received[key]; ok {
+			continue
+		}
+		event, err := unmarshalBroadcast(payload)
+		if err != nil {
+			return nil, fmt.Errorf("cannot decode event broadcast by %v: %v", source, err)
+		}
+		broadcaster.received[key] = createdAt
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for key, createdAt := range broadcaster.received {
+		if createdAt <= since {
+			delete(broadcaster.received, key)
+		}
+	}
+	return events, nil
+}
+
+func (broadcaster *broadcaster) PurgeBroadcasts() error {
+	_, err := broadcaster.Exec("DELETE FROM broadcast_events WHERE created_at <= $1", time.Now().Add(-BroadcastWindow).UnixNano())
+	return err
+}
+
+// unmarshalBroadcast decodes a broadcast Event. The data of the Event is kept
+// as it was encoded, so that it is streamed as it was observed.
+func unmarshalBroadcast(payload string) (Event, error) {
+	broadcast := struct {
+		Event
+		Data json.RawMessage `json:"data,omitempty"`
+	}{}
+	if err := json.Unmarshal([]byte(payload), &broadcast); err != nil {
+		return Event{}, err
+	}
+	event := broadcast.Event
+	if len(broadcast.Data) > 0 {
+		event.Data = broadcast.Data
+	}
+	return event, nil
+}
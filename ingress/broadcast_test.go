@@ -0,0 +1,75 @@
+package ingress_test
+
+import (
+	"encoding/json"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/republicprotocol/renex-ingress-go/ingress"
+)
+
+var _ = Describe("Broadcaster", func() {
+
+	var db *DB
+	var broadcaster, other Broadcaster
+
+	BeforeEach(func() {
+		db = newSQLiteDB()
+		broadcaster, other = NewBroadcasterWithDB(db), NewBroadcasterWithDB(db)
+	})
+
+	It("should receive the events of other broadcasters once", func() {
+		first := Event{ID: EventOrderApproved + ":order", Type: EventOrderApproved, OrderID: "order", Trader: webhookTrader, CreatedAt: 1}
+		second := Event{ID: EventFragmentsDelivered + ":order:1", Type: EventFragmentsDelivered, OrderID: "order", Trader: webhookTrader, Data: FragmentsDelivery{Depth: 1}, CreatedAt: 2}
+		Expect(broadcaster.Broadcast(first)).ShouldNot(HaveOccurred())
+		Expect(broadcaster.Broadcast(second)).ShouldNot(HaveOccurred())
+
+		events, err := other.Received()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(events).Should(HaveLen(2))
+		Expect(events[0]).Should(Equal(first))
+		Expect(events[1].ID).Should(Equal(second.ID))
+		data, err := json.Marshal(events[1])
+		Expect(err).ShouldNot(HaveOccurred())
+		expected, err := json.Marshal(second)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(data).Should(MatchJSON(expected))
+
+		events, err = other.Received()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(events).Should(BeEmpty())
+	})
+
+	It("should not receive its own events", func() {
+		Expect(broadcaster.Broadcast(Event{Type: EventOrderApproved, OrderID: "order", Trader: webhookTrader})).ShouldNot(HaveOccurred())
+		events, err := broadcaster.Received()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(events).Should(BeEmpty())
+	})
+
+	Context("when events are broadcast in a short window", func() {
+
+		var window time.Duration
+
+		BeforeEach(func() {
+			window = BroadcastWindow
+			BroadcastWindow = 50 * time.Millisecond
+		})
+
+		AfterEach(func() {
+			BroadcastWindow = window
+		})
+
+		It("should purge the events that can no longer be received", func() {
+			Expect(broadcaster.Broadcast(Event{Type: EventOrderApproved, OrderID: "order", Trader: webhookTrader})).ShouldNot(HaveOccurred())
+			time.Sleep(2 * BroadcastWindow)
+			Expect(broadcaster.PurgeBroadcasts()).ShouldNot(HaveOccurred())
+
+			BroadcastWindow = window
+			events, err := other.Received()
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(events).Should(BeEmpty())
+		})
+	})
+})
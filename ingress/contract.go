@@ -4,6 +4,7 @@ import (
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/republicprotocol/renex-ingress-go/contract"
 	"github.com/republicprotocol/republic-go/registry"
 )
 
@@ -31,3 +32,69 @@ type RenExContractBinder interface {
 
 	GetOrderTrader(orderID [32]byte) (common.Address, error)
 }
+
+// WyreContractBinder defines the methods that the WyreWatcher will require to
+// follow transfers of Wyre KYC tokens.
+type WyreContractBinder interface {
+	BlockNumber() (uint64, error)
+
+	WyreTransfers(start, end uint64) ([]contract.WyreTransfer, error)
+
+	BalanceOf(common.Address) (*big.Int, error)
+}
+
+// SettlementContractBinder defines the methods that the SettlementWatcher
+// will require to follow settlements of orders.
+type SettlementContractBinder interface {
+	BlockNumber() (uint64, error)
+
+	OrderSettlements(start, end uint64) ([]contract.OrderSettlement, error)
+}
+
+// SwapContractBinder defines the methods that the Swapper will require to
+// finalize atomic swaps.
+type SwapContractBinder interface {
+	OrderState(id [32]byte) (uint8, error)
+
+	GetMatchDetails(id [32]byte) (contract.MatchDetails, error)
+}
+
+// OrderContractBinder defines the methods that the Orderer will require to
+// describe the orders of traders.
+type OrderContractBinder interface {
+	SwapContractBinder
+
+	OrderBlockNumber(id [32]byte) (uint64, error)
+
+	OrderConfirmer(id [32]byte) (common.Address, error)
+
+	OrderMatch(id [32]byte) ([32]byte, error)
+
+	OrderDepth(id [32]byte) (uint64, error)
+}
+
+// OrderIndexContractBinder defines the methods that the OrderIndexer will
+// require to index the Orderbook and RenExSettlement contracts.
+type OrderIndexContractBinder interface {
+	BlockNumber() (uint64, error)
+
+	OrderbookOrders(offset, limit uint64) ([]contract.OrderbookOrder, error)
+
+	OrderbookOrder(id [32]byte) (contract.OrderbookOrder, error)
+
+	OrderSettlements(start, end uint64) ([]contract.OrderSettlement, error)
+
+	GetMatchDetails(id [32]byte) (contract.MatchDetails, error)
+}
+
+// BalanceContractBinder defines the methods that the Balancer will require to
+// read the balances of traders from the RenExBalances contract.
+type BalanceContractBinder interface {
+	RegisteredTokens() ([]contract.RegisteredToken, error)
+
+	TraderBalance(trader, token common.Address) (*big.Int, error)
+
+	TraderWithdrawalSignal(trader, token common.Address) (*big.Int, error)
+
+	GetTraderWithdrawalNonce(trader common.Address) (*big.Int, error)
+}
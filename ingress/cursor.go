@@ -0,0 +1,56 @@
+package ingress
+
+import (
+	"database/sql"
+)
+
+// The schema of the watch_cursors table is defined by Migrations.
+
+// A Cursorer stores the next block to be processed by each watcher that
+// follows contract events, so that watchers can resume after a restart.
+type Cursorer interface {
+	// Cursor returns the next block to be processed by the watcher, and false
+	// if the watcher has not stored a cursor.
+	Cursor(name string) (uint64, bool, error)
+
+	// UpdateCursor stores the next block to be processed by the watcher.
+	UpdateCursor(name string, block uint64) error
+}
+
+type cursorer struct {
+	*DB
+}
+
+// NewCursorer returns a Cursorer that stores cursors in the database at the
+// URL.
+func NewCursorer(databaseURL string) (Cursorer, error) {
+	db, err := OpenDB(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	return NewCursorerWithDB(db), nil
+}
+
+// NewCursorerWithDB returns a Cursorer that stores cursors in an open
+// database.
+func NewCursorerWithDB(db *DB) Cursorer {
+	return &cursorer{
+		db,
+	}
+}
+
+func (cursorer *cursorer) Cursor(name string) (uint64, bool, error) {
+	var block int64
+	if err := cursorer.QueryRow("SELECT block FROM watch_cursors WHERE name=$1", name).Scan(&block); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+	return uint64(block), true, nil
+}
+
+func (cursorer *cursorer) UpdateCursor(name string, block uint64) error {
+	_, err := cursorer.Exec("INSERT INTO watch_cursors (name, block) VALUES ($1, $2) ON CONFLICT (name) DO UPDATE SET block=excluded.block", name, int64(block))
+	return err
+}
@@ -0,0 +1,49 @@
+package ingress_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/republicprotocol/renex-ingress-go/ingress"
+)
+
+var _ = Describe("Cursorer", func() {
+
+	newSQLiteCursorer := func() Cursorer {
+		db, err := OpenDB(SQLiteURLPrefix + ":memory:")
+		Expect(err).ShouldNot(HaveOccurred())
+		_, err = db.Migrate()
+		Expect(err).ShouldNot(HaveOccurred())
+		return NewCursorerWithDB(db)
+	}
+
+	for _, backend := range []struct {
+		name        string
+		newCursorer func() Cursorer
+	}{
+		{"memory", NewMemoryCursorer},
+		{"sqlite", newSQLiteCursorer},
+	} {
+		backend := backend
+
+		Context("when using "+backend.name+" storage", func() {
+
+			It("should return false for unknown cursors", func() {
+				_, ok, err := backend.newCursorer().Cursor("wyre")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(ok).Should(BeFalse())
+			})
+
+			It("should store and update cursors", func() {
+				cursorer := backend.newCursorer()
+				Expect(cursorer.UpdateCursor("wyre", 10)).ShouldNot(HaveOccurred())
+				Expect(cursorer.UpdateCursor("wyre", 20)).ShouldNot(HaveOccurred())
+				Expect(cursorer.UpdateCursor("settlement", 5)).ShouldNot(HaveOccurred())
+
+				block, ok, err := cursorer.Cursor("wyre")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(ok).Should(BeTrue())
+				Expect(block).Should(Equal(uint64(20)))
+			})
+		})
+	}
+})
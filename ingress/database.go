@@ -0,0 +1,99 @@
+package ingress
+
+import (
+	"database/sql"
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+// SQLiteURLPrefix is the prefix of database URLs that refer to an SQLite
+// database. The remainder of the URL is the path of the database file, or
+// ":memory:" for a database that is stored in memory.
+const SQLiteURLPrefix = "sqlite3://"
+
+// ErrEmptyDatabaseURL is returned when opening a database without a URL.
+var ErrEmptyDatabaseURL = errors.New("database url cannot be empty")
+
+// Dialect is the SQL dialect spoken by a database.
+type Dialect string
+
+// Values for the Dialect type.
+const (
+	DialectPostgres = Dialect("postgres")
+	DialectSQLite   = Dialect("sqlite3")
+)
+
+// DB is a database connection that is shared by the storage backends. Queries
+// are written using Postgres placeholders ($1, $2, ...) and are rewritten for
+// the dialect of the database.
+type DB struct {
+	*sql.DB
+	Dialect Dialect
+}
+
+// OpenDB opens the database at the URL. URLs with the SQLiteURLPrefix are
+// opened as SQLite databases, and all other URLs are opened as Postgres
+// databases.
+func OpenDB(databaseURL string) (*DB, error) {
+	if databaseURL == "" {
+		return nil, ErrEmptyDatabaseURL
+	}
+	if strings.HasPrefix(databaseURL, SQLiteURLPrefix) {
+		db, err := sql.Open(string(DialectSQLite), strings.TrimPrefix(databaseURL, SQLiteURLPrefix))
+		if err != nil {
+			return nil, err
+		}
+		// SQLite does not support concurrent writers, and every connection
+		// to an in-memory database opens a different database.
+		db.SetMaxOpenConns(1)
+		return &DB{DB: db, Dialect: DialectSQLite}, nil
+	}
+	db, err := sql.Open(string(DialectPostgres), databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	return &DB{DB: db, Dialect: DialectPostgres}, nil
+}
+
+// Exec executes a query without returning any rows.
+func (db *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return db.DB.Exec(db.rebind(query), args...)
+}
+
+// Query executes a query that returns rows.
+func (db *DB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return db.DB.Query(db.rebind(query), args...)
+}
+
+// QueryRow executes a query that is expected to return at most one row.
+func (db *DB) QueryRow(query string, args ...interface{}) *sql.Row {
+	return db.DB.QueryRow(db.rebind(query), args...)
+}
+
+// uniqueViolation returns true if the error was returned because a statement
+// violated a unique constraint.
+func uniqueViolation(err error) bool {
+	switch err := err.(type) {
+	case *pq.Error:
+		return err.Code == "23505"
+	case sqlite3.Error:
+		return err.ExtendedCode == sqlite3.ErrConstraintUnique
+	}
+	return false
+}
+
+var postgresPlaceholder = regexp.MustCompile(`\$([0-9]+)`)
+
+// rebind rewrites Postgres placeholders for the dialect of the database.
+// SQLite numbers $NAME parameters in order of appearance, so they are
+// rewritten as ?NNN parameters to preserve the numbering.
+func (db *DB) rebind(query string) string {
+	if db.Dialect != DialectSQLite {
+		return query
+	}
+	return postgresPlaceholder.ReplaceAllString(query, "?$1")
+}
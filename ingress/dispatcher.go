@@ -0,0 +1,496 @@
+package ingress
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Headers of the requests that deliver Events to Webhooks. The signature is
+// the hex encoded HMAC-SHA256 of the request body, using the secret of the
+// Webhook as the key.
+const (
+	WebhookEventHeader     = "X-RenEx-Event"
+	WebhookSignatureHeader = "X-RenEx-Signature"
+)
+
+// WebhookMaxAttempts is the number of times the delivery of an Event is
+// attempted before the delivery is recorded as failed.
+const WebhookMaxAttempts = 8
+
+// WebhookTimeout is the timeout of a request that delivers an Event.
+const WebhookTimeout = 10 * time.Second
+
+// WebhookClaimDuration is the duration for which a process claims a delivery
+// while attempting it, so that the delivery is not attempted by the other
+// processes of the Ingress at the same time. It is longer than the
+// WebhookTimeout, so that a claim only expires when a process stops during an
+// attempt.
+const WebhookClaimDuration = 3 * WebhookTimeout
+
+// WebhookWorkers is the maximum number of Webhooks that deliveries are
+// attempted to concurrently. Deliveries to the same Webhook are attempted in
+// order, so that an unresponsive Webhook only delays its own deliveries.
+const WebhookWorkers = 8
+
+// WebhookOrderTTL is the duration for which orders are followed until they
+// are confirmed. Orders that are not confirmed within this duration are
+// forgotten.
+const WebhookOrderTTL = 7 * 24 * time.Hour
+
+// WebhookBackoff is the delay before the first retry of a failed delivery.
+// The delay doubles after each attempt.
+var WebhookBackoff = 30 * time.Second
+
+// webhookBatchSize is the maximum number of deliveries attempted in a pass.
+const webhookBatchSize = 100
+
+// webhookMetrics are the counts of the WebhookDispatcher, published by expvar
+// under "webhooks".
+var webhookMetrics = expvar.NewMap("webhooks")
+
+// A Notifier is notified of Events as they are observed.
+type Notifier interface {
+	// Notify the Notifier of an Event. When the trader of the Event is empty,
+	// it is the trader of the order.
+	Notify(event Event) error
+}
+
+type multiNotifier []Notifier
+
+// MultiNotifier returns a Notifier that notifies each of the Notifiers in
+// turn. All of the Notifiers are notified, even when one of them returns an
+// error, and the first error is returned.
+func MultiNotifier(notifiers ...Notifier) Notifier {
+	return multiNotifier(notifiers)
+}
+
+// Notify implements the Notifier interface.
+func (notifiers multiNotifier) Notify(event Event) error {
+	var err error
+	for _, notifier := range notifiers {
+		if errLocal := notifier.Notify(event); errLocal != nil && err == nil {
+			err = errLocal
+		}
+	}
+	return err
+}
+
+// A WebhookDispatcher stores the deliveries of Events to the Webhooks of
+// their trader, and delivers them in the background. It also follows the
+// orders of traders with Webhooks, so that they are notified when their
+// orders are confirmed.
+type WebhookDispatcher interface {
+	Notifier
+
+	// Run dispatches Events on every interval until the done channel is
+	// closed. Errors are written to the returned channel.
+	Run(done <-chan struct{}) <-chan error
+
+	// Dispatch checks the followed orders, and attempts all deliveries that
+	// are due.
+	Dispatch() error
+}
+
+type webhookDispatcher struct {
+	webhooker Webhooker
+	binder    SwapContractBinder
+	client    *http.Client
+	interval  time.Duration
+}
+
+// NewWebhookDispatcher returns a WebhookDispatcher that checks the state of
+// orders using the RenExSettlement contract. Events are delivered using a
+// NewWebhookClient.
+func NewWebhookDispatcher(webhooker Webhooker, binder SwapContractBinder, interval time.Duration) WebhookDispatcher {
+	return NewWebhookDispatcherWithClient(webhooker, binder, NewWebhookClient(), interval)
+}
+
+// NewWebhookDispatcherWithClient returns a WebhookDispatcher that delivers
+// Events using the client.
+func NewWebhookDispatcherWithClient(webhooker Webhooker, binder SwapContractBinder, client *http.Client, interval time.Duration) WebhookDispatcher {
+	return &webhookDispatcher{
+		webhooker: webhooker,
+		binder:    binder,
+		client:    client,
+		interval:  interval,
+	}
+}
+
+// NewWebhookClient returns the http.Client used to deliver Events to
+// Webhooks. It refuses to connect to addresses that are not public. The
+// address is checked when the connection is dialed, after the host has been
+// resolved, so that a host cannot be resolved to a public address when the
+// Webhook is registered and to a private address when an Event is delivered.
+func NewWebhookClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: WebhookTimeout,
+		Control: func(network, address string, conn syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !PublicIP(ip) {
+				return ErrPrivateWebhookAddress
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: WebhookTimeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: WebhookTimeout,
+		},
+	}
+}
+
+// reservedNetworks are the IPv4 and IPv6 address ranges of the IANA special
+// purpose address registries that are not globally reachable. IPv4-mapped
+// IPv6 addresses are matched by the IPv4 ranges.
+var reservedNetworks = parseNetworks(
+	// IPv4
+	"0.0.0.0/8", "10.0.0.0/8", "100.64.0.0/10", "127.0.0.0/8", "169.254.0.0/16",
+	"172.16.0.0/12", "192.0.0.0/24", "192.0.2.0/24", "192.88.99.0/24", "192.168.0.0/16",
+	"198.18.0.0/15", "198.51.100.0/24", "203.0.113.0/24", "224.0.0.0/4", "240.0.0.0/4",
+	// IPv6
+	"::/96", "64:ff9b:1::/48", "100::/64", "2001::/23", "2001:db8::/32", "3fff::/20",
+	"5f00::/16", "fc00::/7", "fe80::/10", "ff00::/8",
+)
+
+// nat64Network and sixToFourNetwork are the IPv6 ranges that reach the IPv4
+// address that is embedded in their addresses.
+var nat64Network, sixToFourNetwork = parseNetworks("64:ff9b::/96")[0], parseNetworks("2002::/16")[0]
+
+func parseNetworks(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// PublicIP returns false if the IP address is not a global unicast address, or
+// is in a reserved range, and true otherwise. NAT64 and 6to4 addresses are
+// only public if the IPv4 address that they embed is public. Webhooks can only
+// be delivered to public addresses, so that they cannot be used to reach the
+// network of the Ingress.
+func PublicIP(ip net.IP) bool {
+	if !ip.IsGlobalUnicast() {
+		return false
+	}
+	if ip.To4() == nil && len(ip) == net.IPv6len {
+		if nat64Network.Contains(ip) {
+			return PublicIP(ip[12:16])
+		}
+		if sixToFourNetwork.Contains(ip) {
+			return PublicIP(ip[2:6])
+		}
+	}
+	for _, network := range reservedNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// Notify implements the Notifier interface.
+func (dispatcher *webhookDispatcher) Notify(event Event) error {
+	order, err := dispatcher.webhooker.WebhookOrder(event.OrderID)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("cannot get webhook order=%v: %v", event.OrderID, err)
+	}
+	followed := err == nil
+	if event.Trader == "" {
+		if !followed {
+			return nil
+		}
+		event.Trader = order.Trader
+	}
+	event.Trader = normalizeAddress(event.Trader)
+
+	webhooks, err := dispatcher.webhooker.Webhooks(event.Trader)
+	if err != nil {
+		return fmt.Errorf("cannot get webhooks for trader=%v: %v", event.Trader, err)
+	}
+	if len(webhooks) == 0 {
+		return nil
+	}
+
+	switch event.Type {
+	case EventFragmentsDelivered:
+		if err := dispatcher.webhooker.InsertWebhookOrder(WebhookOrder{OrderID: event.OrderID, Trader: event.Trader, CreatedAt: time.Now().Unix()}); err != nil {
+			return fmt.Errorf("cannot follow order=%v: %v", event.OrderID, err)
+		}
+	case EventOrderSettled:
+		// Orders are confirmed before they are settled, but the confirmation
+		// may not have been observed yet.
+		if followed && !order.Confirmed {
+			if err := dispatcher.enqueue(webhooks, Event{Type: EventOrderConfirmed, OrderID: event.OrderID, Trader: event.Trader}); err != nil {
+				return err
+			}
+		}
+		if err := dispatcher.enqueue(webhooks, event); err != nil {
+			return err
+		}
+		if err := dispatcher.webhooker.DeleteWebhookOrder(event.OrderID); err != nil {
+			return fmt.Errorf("cannot forget order=%v: %v", event.OrderID, err)
+		}
+		return nil
+	}
+	return dispatcher.enqueue(webhooks, event)
+}
+
+// Run implements the WebhookDispatcher interface.
+func (dispatcher *webhookDispatcher) Run(done <-chan struct{}) <-chan error {
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(errs)
+
+		ticker := time.NewTicker(dispatcher.interval)
+		defer ticker.Stop()
+
+		for {
+			if err := dispatcher.Dispatch(); err != nil {
+				select {
+				case <-done:
+					return
+				case errs <- err:
+				}
+			}
+
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return errs
+}
+
+// Dispatch implements the WebhookDispatcher interface.
+func (dispatcher *webhookDispatcher) Dispatch() error {
+	if err := dispatcher.checkOrders(); err != nil {
+		return err
+	}
+
+	deliveries, err := dispatcher.webhooker.PendingWebhookDeliveries(time.Now().Unix(), webhookBatchSize)
+	if err != nil {
+		return fmt.Errorf("cannot load pending webhook deliveries: %v", err)
+	}
+
+	// Deliveries are grouped by Webhook, and the groups are delivered
+	// concurrently by at most WebhookWorkers workers.
+	webhookIDs := []string{}
+	deliveriesByWebhook := map[string][]WebhookDelivery{}
+	for _, delivery := range deliveries {
+		if _, ok := deliveriesByWebhook[delivery.WebhookID]; !ok {
+			webhookIDs = append(webhookIDs, delivery.WebhookID)
+		}
+		deliveriesByWebhook[delivery.WebhookID] = append(deliveriesByWebhook[delivery.WebhookID], delivery)
+	}
+
+	var errMu sync.Mutex
+	var firstErr error
+	var wg sync.WaitGroup
+	workers := make(chan struct{}, WebhookWorkers)
+	for _, webhookID := range webhookIDs {
+		wg.Add(1)
+		workers <- struct{}{}
+		go func(deliveries []WebhookDelivery) {
+			defer wg.Done()
+			defer func() { <-workers }()
+
+			if err := dispatcher.deliverAll(deliveries); err != nil {
+				errMu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				errMu.Unlock()
+			}
+		}(deliveriesByWebhook[webhookID])
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// deliverAll attempts the deliveries to a Webhook in order. Once an attempt
+// fails, the remaining deliveries are left until the next pass, so that an
+// unresponsive Webhook is not attempted again until then.
+func (dispatcher *webhookDispatcher) deliverAll(deliveries []WebhookDelivery) error {
+	for _, delivery := range deliveries {
+		delivered, err := dispatcher.deliver(delivery)
+		if err != nil {
+			return err
+		}
+		if !delivered {
+			return nil
+		}
+	}
+	return nil
+}
+
+// checkOrders notifies the trader of each followed order that has been
+// confirmed. Orders that have been canceled, or that have not been confirmed
+// within the WebhookOrderTTL, are forgotten.
+func (dispatcher *webhookDispatcher) checkOrders() error {
+	orders, err := dispatcher.webhooker.UnconfirmedWebhookOrders()
+	if err != nil {
+		return fmt.Errorf("cannot load webhook orders: %v", err)
+	}
+	expiredAt := time.Now().Add(-WebhookOrderTTL).Unix()
+	for _, order := range orders {
+		if order.CreatedAt < expiredAt {
+			if err := dispatcher.webhooker.DeleteWebhookOrder(order.OrderID); err != nil {
+				return fmt.Errorf("cannot forget order=%v: %v", order.OrderID, err)
+			}
+			continue
+		}
+		orderID, err := orderIdStringToBytes(order.OrderID)
+		if err != nil {
+			return fmt.Errorf("cannot decode order=%v: %v", order.OrderID, err)
+		}
+		state, err := dispatcher.binder.OrderState(orderID)
+		if err != nil {
+			return fmt.Errorf("cannot get order state for order=%v: %v", order.OrderID, err)
+		}
+		switch state {
+		case orderStateConfirmed:
+			if err := dispatcher.Notify(Event{Type: EventOrderConfirmed, OrderID: order.OrderID, Trader: order.Trader}); err != nil {
+				return err
+			}
+			if err := dispatcher.webhooker.ConfirmWebhookOrder(order.OrderID); err != nil {
+				return fmt.Errorf("cannot confirm order=%v: %v", order.OrderID, err)
+			}
+		case orderStateCanceled:
+			if err := dispatcher.webhooker.DeleteWebhookOrder(order.OrderID); err != nil {
+				return fmt.Errorf("cannot forget order=%v: %v", order.OrderID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// enqueue stores a delivery of the Event for each of the Webhooks.
+func (dispatcher *webhookDispatcher) enqueue(webhooks []Webhook, event Event) error {
+	now := time.Now().Unix()
+	event.ID = eventID(event)
+	event.CreatedAt = now
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("cannot marshal event=%v: %v", event.ID, err)
+	}
+	for _, webhook := range webhooks {
+		delivery := WebhookDelivery{
+			WebhookID:     webhook.ID,
+			EventID:       event.ID,
+			Payload:       string(payload),
+			NextAttemptAt: now,
+		}
+		if err := dispatcher.webhooker.InsertWebhookDelivery(delivery); err != nil {
+			return fmt.Errorf("cannot store delivery of event=%v to webhook=%v: %v", event.ID, webhook.ID, err)
+		}
+	}
+	webhookMetrics.Add("events", 1)
+	return nil
+}
+
+// deliver claims a delivery, attempts it, stores its outcome, and returns true
+// if the Event was delivered. Deliveries that are claimed by another process
+// are not attempted. Deliveries that fail are retried with an exponential
+// backoff, until WebhookMaxAttempts is reached.
+func (dispatcher *webhookDispatcher) deliver(delivery WebhookDelivery) (bool, error) {
+	now := time.Now()
+	claimed, err := dispatcher.webhooker.ClaimWebhookDelivery(delivery, now.Unix(), now.Add(WebhookClaimDuration).Unix())
+	if err != nil {
+		return false, fmt.Errorf("cannot claim delivery of event=%v to webhook=%v: %v", delivery.EventID, delivery.WebhookID, err)
+	}
+	if !claimed {
+		webhookMetrics.Add("claimed", 1)
+		return false, nil
+	}
+
+	webhook, err := dispatcher.webhooker.Webhook(delivery.WebhookID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, fmt.Errorf("cannot get webhook=%v: %v", delivery.WebhookID, err)
+	}
+
+	delivery.Attempts++
+	delivered := false
+	if err := dispatcher.post(webhook, delivery); err != nil {
+		delivery.LastError = err.Error()
+		if delivery.Attempts >= WebhookMaxAttempts {
+			delivery.FailedAt = now.Unix()
+			webhookMetrics.Add("failed", 1)
+			log.Printf("[error] (webhook) cannot deliver event = %v to webhook = %v after %v attempts: %v", delivery.EventID, webhook.ID, delivery.Attempts, err)
+		} else {
+			delivery.NextAttemptAt = now.Add(WebhookBackoff << uint(delivery.Attempts-1)).Unix()
+			webhookMetrics.Add("retried", 1)
+		}
+	} else {
+		delivered = true
+		delivery.LastError = ""
+		delivery.DeliveredAt = now.Unix()
+		webhookMetrics.Add("delivered", 1)
+	}
+	if err := dispatcher.webhooker.UpdateWebhookDelivery(delivery); err != nil {
+		return false, fmt.Errorf("cannot store delivery of event=%v to webhook=%v: %v", delivery.EventID, webhook.ID, err)
+	}
+	return delivered, nil
+}
+
+// post sends the payload of the delivery to the Webhook, signed using the
+// secret of the Webhook.
+func (dispatcher *webhookDispatcher) post(webhook Webhook, delivery WebhookDelivery) error {
+	req, err := http.NewRequest("POST", webhook.URL, bytes.NewBufferString(delivery.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, delivery.EventID)
+	req.Header.Set(WebhookSignatureHeader, WebhookSignature(webhook.Secret, []byte(delivery.Payload)))
+
+	res, err := dispatcher.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(ioutil.Discard, res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %v", res.StatusCode)
+	}
+	return nil
+}
+
+// WebhookSignature returns the signature of a payload delivered to a Webhook
+// with the secret.
+func WebhookSignature(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
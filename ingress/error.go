@@ -28,3 +28,44 @@ var ErrInvalidEpochDepth = errors.New("invalid epoch depth")
 // ErrCannotOpenOrderFragments is returned when none of the pods were available
 // to receive order fragments
 var ErrCannotOpenOrderFragments = errors.New("cannot open order fragments: no pod received an order fragment")
+
+// ErrSchemaDrift is returned when the database schema does not match the
+// migrations that have been applied to it.
+var ErrSchemaDrift = errors.New("database schema has drifted from its migrations")
+
+// ErrPendingMigrations is returned when the database schema is older than the
+// migrations compiled into the binary.
+var ErrPendingMigrations = errors.New("database schema has pending migrations")
+
+// ErrUnknownKYCType is returned when a verification is stored or revoked for
+// an unknown KYC type.
+var ErrUnknownKYCType = errors.New("unknown kyc type")
+
+// ErrUnsafeTimeLock is returned when a swap is finalized too close to the
+// timelock of the follower, because the follower would not have time to
+// redeem the funds of the initiator.
+var ErrUnsafeTimeLock = errors.New("unsafe timelock: the timelock of the follower expires too soon")
+
+// ErrPrivateWebhookAddress is returned when an Event would be delivered to a
+// Webhook at an address that is not public.
+var ErrPrivateWebhookAddress = errors.New("webhook address is not public")
+
+// ErrSecretHashReused is returned when a partial swap is registered with a
+// secret hash that belongs to the partial swap of another order.
+var ErrSecretHashReused = errors.New("secret hash has been used by another order")
+
+// ErrPartialSwapConflict is returned when a partial swap is registered again
+// with different parameters.
+var ErrPartialSwapConflict = errors.New("partial swap has been registered with different parameters")
+
+// ErrInvalidNonce is returned when a nonce is unknown, has expired, or has
+// already been consumed.
+var ErrInvalidNonce = errors.New("invalid nonce")
+
+// ErrInvalidSession is returned when a session token is unknown, or has
+// expired.
+var ErrInvalidSession = errors.New("invalid session")
+
+// ErrUnknownOrderState is returned when orders are filtered by a state that
+// is not one of the states of orders.
+var ErrUnknownOrderState = errors.New("unknown order state")
@@ -0,0 +1,270 @@
+package ingress
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// The schema of the indexed_orders and indexed_settlements tables is defined
+// by Migrations.
+
+// An IndexedOrder is an order of the Orderbook contract that has been indexed
+// by the OrderIndexer. The Position is the position of the order in the
+// Orderbook, and the BlockNumber is the block number that the Orderbook
+// stores for the order. Orders are settled once the RenExSettlement contract
+// has settled them in the block SettledAt.
+type IndexedOrder struct {
+	OrderID     string             `json:"orderID"`
+	Position    uint64             `json:"position"`
+	Trader      string             `json:"trader"`
+	State       string             `json:"state"`
+	BlockNumber uint64             `json:"blockNumber"`
+	Confirmer   string             `json:"confirmer,omitempty"`
+	MatchID     string             `json:"matchID,omitempty"`
+	SettledAt   uint64             `json:"settledAt,omitempty"`
+	Settlement  *SettlementDetails `json:"settlement,omitempty"`
+
+	// PendingState is a change to the state of the order that was observed
+	// in the block PendingBlock, and that has not been confirmed.
+	PendingState string `json:"-"`
+	PendingBlock uint64 `json:"-"`
+}
+
+// An IndexQuery selects a page of IndexedOrders. Orders are filtered by their
+// trader, their state, and the range of their block numbers, unless the
+// filters are empty. A ToBlock of zero does not bound the block numbers.
+type IndexQuery struct {
+	Trader    string
+	State     string
+	FromBlock uint64
+	ToBlock   uint64
+	Offset    int
+	Limit     int
+}
+
+// An IndexedOrderPage is a page of IndexedOrders, most recently opened first.
+// More is true if there are orders after the page.
+type IndexedOrderPage struct {
+	Orders []IndexedOrder `json:"orders"`
+	Offset int            `json:"offset"`
+	Limit  int            `json:"limit"`
+	More   bool           `json:"more"`
+}
+
+// An OrderIndex stores the orders of the Orderbook contract and their
+// settlements by the RenExSettlement contract, so that they can be queried
+// without calling the contracts.
+type OrderIndex interface {
+	// NextOrderPosition returns the position in the Orderbook of the next
+	// order to be indexed.
+	NextOrderPosition() (uint64, error)
+
+	// InsertIndexedOrder stores an order, unless it is already stored.
+	InsertIndexedOrder(order IndexedOrder) error
+
+	// UpdateIndexedOrder stores the state, block number, confirmer, match and
+	// pending state of an order. It returns sql.ErrNoRows if the order has
+	// not been indexed.
+	UpdateIndexedOrder(order IndexedOrder) error
+
+	// OpenIndexedOrders returns the orders that are open, ordered by their
+	// position in the Orderbook.
+	OpenIndexedOrders() ([]IndexedOrder, error)
+
+	// InsertIndexedSettlement stores the settlement of an order in a block,
+	// unless it is already stored.
+	InsertIndexedSettlement(orderID string, blockNumber uint64, details SettlementDetails) error
+
+	// IndexedOrder returns an order, or sql.ErrNoRows if the order has not
+	// been indexed.
+	IndexedOrder(orderID string) (IndexedOrder, error)
+
+	// IndexedOrders returns a page of orders. It returns ErrUnknownOrderState
+	// if the query filters by a state that orders cannot be indexed in.
+	IndexedOrders(query IndexQuery) (IndexedOrderPage, error)
+}
+
+const selectIndexedOrders = `SELECT o.order_id, o.position, o.trader, o.state, o.block_number, o.confirmer, o.match_id, o.pending_state, o.pending_block,
+	s.block_number, s.order_is_buy, s.matched_id, s.priority_token, s.secondary_token, s.priority_volume, s.secondary_volume, s.priority_fee, s.secondary_fee
+	FROM indexed_orders o LEFT JOIN indexed_settlements s ON s.order_id=o.order_id`
+
+type orderIndex struct {
+	*DB
+}
+
+// NewOrderIndex returns an OrderIndex that stores orders in the database at
+// the URL.
+func NewOrderIndex(databaseURL string) (OrderIndex, error) {
+	db, err := OpenDB(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	return NewOrderIndexWithDB(db), nil
+}
+
+// NewOrderIndexWithDB returns an OrderIndex that stores orders in an open
+// database.
+func NewOrderIndexWithDB(db *DB) OrderIndex {
+	return &orderIndex{db}
+}
+
+func (index *orderIndex) NextOrderPosition() (uint64, error) {
+	var next int64
+	if err := index.QueryRow("SELECT COALESCE(MAX(position)+1, 0) FROM indexed_orders").Scan(&next); err != nil {
+		return 0, err
+	}
+	return uint64(next), nil
+}
+
+func (index *orderIndex) InsertIndexedOrder(order IndexedOrder) error {
+	_, err := index.Exec(`INSERT INTO indexed_orders (order_id, position, trader, state, block_number, confirmer, match_id, pending_state, pending_block)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) ON CONFLICT (order_id) DO NOTHING`,
+		order.OrderID, int64(order.Position), normalizeAddress(order.Trader), order.State, int64(order.BlockNumber), order.Confirmer, order.MatchID, order.PendingState, int64(order.PendingBlock))
+	return err
+}
+
+func (index *orderIndex) UpdateIndexedOrder(order IndexedOrder) error {
+	res, err := index.Exec("UPDATE indexed_orders SET state=$2, block_number=$3, confirmer=$4, match_id=$5, pending_state=$6, pending_block=$7 WHERE order_id=$1",
+		order.OrderID, order.State, int64(order.BlockNumber), order.Confirmer, order.MatchID, order.PendingState, int64(order.PendingBlock))
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (index *orderIndex) OpenIndexedOrders() ([]IndexedOrder, error) {
+	return index.selectIndexedOrders(selectIndexedOrders+" WHERE o.state=$1 ORDER BY o.position", OrderStateOpen)
+}
+
+func (index *orderIndex) InsertIndexedSettlement(orderID string, blockNumber uint64, details SettlementDetails) error {
+	_, err := index.Exec(`INSERT INTO indexed_settlements (order_id, block_number, order_is_buy, matched_id, priority_token, secondary_token, priority_volume, secondary_volume, priority_fee, secondary_fee)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) ON CONFLICT (order_id) DO NOTHING`,
+		orderID, int64(blockNumber), details.OrderIsBuy, details.MatchedID, int64(details.PriorityToken), int64(details.SecondaryToken), details.PriorityVolume, details.SecondaryVolume, details.PriorityFee, details.SecondaryFee)
+	return err
+}
+
+func (index *orderIndex) IndexedOrder(orderID string) (IndexedOrder, error) {
+	return scanIndexedOrder(index.QueryRow(selectIndexedOrders+" WHERE o.order_id=$1", orderID))
+}
+
+func (index *orderIndex) IndexedOrders(query IndexQuery) (IndexedOrderPage, error) {
+	conditions := []string{}
+	args := []interface{}{}
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if query.Trader != "" {
+		where("o.trader=$%d", normalizeAddress(query.Trader))
+	}
+	switch query.State {
+	case "":
+	case OrderStateOpen, OrderStateCanceled:
+		where("o.state=$%d", query.State)
+	case OrderStateConfirmed:
+		where("o.state=$%d AND s.order_id IS NULL", query.State)
+	case OrderStateSettled:
+		where("o.state=$%d AND s.order_id IS NOT NULL", OrderStateConfirmed)
+	default:
+		return IndexedOrderPage{}, ErrUnknownOrderState
+	}
+	if query.FromBlock > 0 {
+		where("o.block_number>=$%d", int64(query.FromBlock))
+	}
+	if query.ToBlock > 0 {
+		where("o.block_number<=$%d", int64(query.ToBlock))
+	}
+
+	q := selectIndexedOrders
+	if len(conditions) > 0 {
+		q += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	q += fmt.Sprintf(" ORDER BY o.position DESC LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+	args = append(args, query.Limit+1, query.Offset)
+
+	orders, err := index.selectIndexedOrders(q, args...)
+	if err != nil {
+		return IndexedOrderPage{}, err
+	}
+	return newIndexedOrderPage(orders, query), nil
+}
+
+func (index *orderIndex) selectIndexedOrders(query string, args ...interface{}) ([]IndexedOrder, error) {
+	rows, err := index.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orders := []IndexedOrder{}
+	for rows.Next() {
+		order, err := scanIndexedOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+	return orders, rows.Err()
+}
+
+// scanIndexedOrder scans a row selected by selectIndexedOrders.
+func scanIndexedOrder(row interface {
+	Scan(dest ...interface{}) error
+}) (IndexedOrder, error) {
+	var position, blockNumber, pendingBlock int64
+	var settledAt, priorityToken, secondaryToken sql.NullInt64
+	var orderIsBuy sql.NullBool
+	var matchedID, priorityVolume, secondaryVolume, priorityFee, secondaryFee sql.NullString
+
+	order := IndexedOrder{}
+	if err := row.Scan(&order.OrderID, &position, &order.Trader, &order.State, &blockNumber, &order.Confirmer, &order.MatchID, &order.PendingState, &pendingBlock,
+		&settledAt, &orderIsBuy, &matchedID, &priorityToken, &secondaryToken, &priorityVolume, &secondaryVolume, &priorityFee, &secondaryFee); err != nil {
+		return IndexedOrder{}, err
+	}
+	order.Position = uint64(position)
+	order.BlockNumber = uint64(blockNumber)
+	order.PendingBlock = uint64(pendingBlock)
+	if settledAt.Valid {
+		order.settle(uint64(settledAt.Int64), SettlementDetails{
+			OrderIsBuy:      orderIsBuy.Bool,
+			MatchedID:       matchedID.String,
+			PriorityToken:   uint32(priorityToken.Int64),
+			SecondaryToken:  uint32(secondaryToken.Int64),
+			PriorityVolume:  priorityVolume.String,
+			SecondaryVolume: secondaryVolume.String,
+			PriorityFee:     priorityFee.String,
+			SecondaryFee:    secondaryFee.String,
+		})
+	}
+	return order, nil
+}
+
+// settle sets the settlement of the order. Confirmed orders are settled once
+// their settlement has been indexed.
+func (order *IndexedOrder) settle(blockNumber uint64, details SettlementDetails) {
+	order.SettledAt = blockNumber
+	order.Settlement = &details
+	if order.State == OrderStateConfirmed {
+		order.State = OrderStateSettled
+	}
+}
+
+// newIndexedOrderPage returns the page of orders selected by the query, from
+// at most one more order than the limit of the query.
+func newIndexedOrderPage(orders []IndexedOrder, query IndexQuery) IndexedOrderPage {
+	page := IndexedOrderPage{Orders: orders, Offset: query.Offset, Limit: query.Limit}
+	if len(orders) > query.Limit {
+		page.Orders = orders[:query.Limit]
+		page.More = true
+	}
+	return page
+}
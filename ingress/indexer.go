@@ -0,0 +1,408 @@
+package ingress
+
+import (
+	"encoding/base64"
+	"expvar"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/republicprotocol/renex-ingress-go/contract"
+)
+
+// OrderIndexPageSize is the maximum number of orders that the OrderIndexer
+// loads from the Orderbook in a single request.
+var OrderIndexPageSize uint64 = 500
+
+// orderIndexCursor is the name of the cursor stored by the OrderIndexer.
+const orderIndexCursor = "order_index"
+
+// orderIndexMetrics are the counts of the OrderIndexer, published by expvar
+// under "order_indexer".
+var orderIndexMetrics = expvar.NewMap("order_indexer")
+
+// An OrderIndexer indexes the orders of the Orderbook contract, and their
+// settlements by the RenExSettlement contract, into an OrderIndex. Orders are
+// backfilled from the start of the Orderbook, and indexed once the block in
+// which they were opened, or confirmed or canceled, is confirmed. The
+// Orderbook does not log changes to the state of orders, so the state of open
+// orders is polled, and changes are only indexed once they have been observed
+// for WatchConfirmations blocks. Settlements are indexed by following the
+// events of the RenExSettlement contract.
+type OrderIndexer interface {
+	// Run syncs the indexer on every interval until the done channel is
+	// closed. Errors are written to the returned channel.
+	Run(done <-chan struct{}) <-chan error
+
+	// Sync indexes the orders that have been opened, the changes to the state
+	// of open orders, and the settlements in all confirmed blocks after the
+	// stored cursor. When no cursor is stored, settlements are indexed from
+	// the start block. Orders are only indexed while the indexer holds the
+	// lease on the cursor, so that the processes of the Ingress do not index
+	// the same orders.
+	Sync() error
+}
+
+type orderIndexer struct {
+	binder     OrderIndexContractBinder
+	index      OrderIndex
+	cursorer   Cursorer
+	leaser     Leaser
+	startBlock uint64
+	interval   time.Duration
+}
+
+// NewOrderIndexer returns an OrderIndexer that stores orders using the
+// OrderIndex.
+func NewOrderIndexer(binder OrderIndexContractBinder, index OrderIndex, cursorer Cursorer, leaser Leaser, startBlock uint64, interval time.Duration) OrderIndexer {
+	return &orderIndexer{
+		binder:     binder,
+		index:      index,
+		cursorer:   cursorer,
+		leaser:     leaser,
+		startBlock: startBlock,
+		interval:   interval,
+	}
+}
+
+// Run implements the OrderIndexer interface.
+func (indexer *orderIndexer) Run(done <-chan struct{}) <-chan error {
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(errs)
+
+		ticker := time.NewTicker(indexer.interval)
+		defer ticker.Stop()
+
+		for {
+			if err := indexer.Sync(); err != nil {
+				select {
+				case <-done:
+					return
+				case errs <- err:
+				}
+			}
+
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return errs
+}
+
+// Sync implements the OrderIndexer interface.
+func (indexer *orderIndexer) Sync() error {
+	if err := indexer.renewLease(); err != nil {
+		if err == errLeaseLost {
+			orderIndexMetrics.Add("skipped", 1)
+			return nil
+		}
+		return err
+	}
+
+	latest, err := indexer.binder.BlockNumber()
+	if err != nil {
+		return fmt.Errorf("cannot get latest block: %v", err)
+	}
+	if latest < WatchConfirmations {
+		return nil
+	}
+	end := latest - WatchConfirmations
+
+	err = indexer.indexOrders(end)
+	if err == nil {
+		err = indexer.refreshOrders(latest)
+	}
+	if err == nil {
+		err = indexer.indexSettlements(end)
+	}
+	if err == errLeaseLost {
+		// Another process has taken over the index.
+		return nil
+	}
+	return err
+}
+
+// renewLease acquires, or renews, the lease of the indexer on its cursor
+// before each page of orders and each range of blocks. It returns
+// errLeaseLost if the lease is held by another process.
+func (indexer *orderIndexer) renewLease() error {
+	held, err := holdLease(indexer.leaser, orderIndexCursor)
+	if err != nil {
+		return err
+	}
+	if !held {
+		return errLeaseLost
+	}
+	return nil
+}
+
+// indexOrders indexes the orders of the Orderbook after the last indexed
+// order, until it finds an order with a block number after the end block.
+// Open orders are indexed as open, and orders that have been confirmed or
+// canceled are indexed in their state once the block in which they were
+// changed is confirmed.
+func (indexer *orderIndexer) indexOrders(end uint64) error {
+	next, err := indexer.index.NextOrderPosition()
+	if err != nil {
+		return fmt.Errorf("cannot load next order position: %v", err)
+	}
+
+	for {
+		if err := indexer.renewLease(); err != nil {
+			return err
+		}
+		orders, err := indexer.binder.OrderbookOrders(next, OrderIndexPageSize)
+		if err != nil {
+			return fmt.Errorf("cannot get orders from position %v: %v", next, err)
+		}
+		blocks, err := indexer.openBlockNumbers(orders)
+		if err != nil {
+			return err
+		}
+		for i, summary := range orders {
+			id := base64.StdEncoding.EncodeToString(summary.OrderID[:])
+			indexed := IndexedOrder{
+				OrderID:  id,
+				Position: next,
+				Trader:   summary.Trader.Hex(),
+				State:    OrderStateOpen,
+			}
+			if block, ok := blocks[i]; ok {
+				indexed.BlockNumber = block
+			} else {
+				// The details of confirmed and canceled orders are only
+				// known to the Orderbook.
+				order, err := indexer.binder.OrderbookOrder(summary.OrderID)
+				if err != nil {
+					return fmt.Errorf("cannot get order=%v: %v", id, err)
+				}
+				indexed.setState(order)
+			}
+			if indexed.BlockNumber > end {
+				return nil
+			}
+
+			if err := indexer.index.InsertIndexedOrder(indexed); err != nil {
+				return fmt.Errorf("cannot index order=%v: %v", id, err)
+			}
+			orderIndexMetrics.Add("orders", 1)
+			next++
+		}
+		if uint64(len(orders)) < OrderIndexPageSize {
+			return nil
+		}
+	}
+}
+
+// openBlockNumbers returns the block numbers of the orders of a page that are
+// open, by their index in the page. The Orderbook stores orders in the order
+// in which they were opened, so the block numbers of open orders do not
+// decrease. The block number of the open orders between two open orders that
+// were opened in the same block is known without getting them from the
+// Orderbook, so the Orderbook is called about once for each block in which
+// orders were opened instead of once for each order.
+func (indexer *orderIndexer) openBlockNumbers(orders []contract.OrderbookOrder) (map[int]uint64, error) {
+	open := []int{}
+	for i, order := range orders {
+		if state := orderbookState(order.State); state != OrderStateConfirmed && state != OrderStateCanceled {
+			open = append(open, i)
+		}
+	}
+	blocks := make(map[int]uint64, len(open))
+	if len(open) == 0 {
+		return blocks, nil
+	}
+
+	blockNumber := func(i int) (uint64, error) {
+		if block, ok := blocks[i]; ok {
+			return block, nil
+		}
+		id := base64.StdEncoding.EncodeToString(orders[i].OrderID[:])
+		order, err := indexer.binder.OrderbookOrder(orders[i].OrderID)
+		if err != nil {
+			return 0, fmt.Errorf("cannot get order=%v: %v", id, err)
+		}
+		if order.State != orders[i].State {
+			// The block number of an order changes with its state.
+			return 0, fmt.Errorf("cannot get order=%v: order changed while indexing", id)
+		}
+		blocks[i] = order.BlockNumber
+		return order.BlockNumber, nil
+	}
+
+	var bisect func(lo, hi int) error
+	bisect = func(lo, hi int) error {
+		loBlock, err := blockNumber(open[lo])
+		if err != nil {
+			return err
+		}
+		hiBlock, err := blockNumber(open[hi])
+		if err != nil {
+			return err
+		}
+		if loBlock == hiBlock {
+			for k := lo + 1; k < hi; k++ {
+				blocks[open[k]] = loBlock
+			}
+			return nil
+		}
+		if hi-lo <= 1 {
+			return nil
+		}
+		mid := (lo + hi) / 2
+		if err := bisect(lo, mid); err != nil {
+			return err
+		}
+		return bisect(mid, hi)
+	}
+	if err := bisect(0, len(open)-1); err != nil {
+		return nil, err
+	}
+	return blocks, nil
+}
+
+// refreshOrders checks the state of the open orders in the Orderbook, and
+// indexes the changes that have been observed for WatchConfirmations blocks.
+// Only the pages of the Orderbook that start at, or include, an open order are
+// loaded, so orders that are no longer open are not loaded again.
+func (indexer *orderIndexer) refreshOrders(latest uint64) error {
+	open, err := indexer.index.OpenIndexedOrders()
+	if err != nil {
+		return fmt.Errorf("cannot load open orders: %v", err)
+	}
+
+	for i := 0; i < len(open); {
+		if err := indexer.renewLease(); err != nil {
+			return err
+		}
+		offset := open[i].Position
+		summaries, err := indexer.binder.OrderbookOrders(offset, OrderIndexPageSize)
+		if err != nil {
+			return fmt.Errorf("cannot get orders from position %v: %v", offset, err)
+		}
+		for ; i < len(open) && open[i].Position < offset+uint64(len(summaries)); i++ {
+			if err := indexer.refreshOrder(open[i], summaries[open[i].Position-offset], latest); err != nil {
+				return err
+			}
+		}
+		if uint64(len(summaries)) < OrderIndexPageSize {
+			return nil
+		}
+	}
+	return nil
+}
+
+// refreshOrder indexes the change to the state of an open order once it has
+// been observed for WatchConfirmations blocks. Changes that are undone by a
+// reorg before then are forgotten.
+func (indexer *orderIndexer) refreshOrder(order IndexedOrder, summary contract.OrderbookOrder, latest uint64) error {
+	if id := base64.StdEncoding.EncodeToString(summary.OrderID[:]); id != order.OrderID {
+		return fmt.Errorf("cannot refresh order=%v: position %v of the orderbook is order=%v", order.OrderID, order.Position, id)
+	}
+
+	switch state := orderbookState(summary.State); {
+	case state == order.State:
+		if order.PendingState == "" {
+			return nil
+		}
+		order.PendingState, order.PendingBlock = "", 0
+		orderIndexMetrics.Add("reorged", 1)
+	case state != OrderStateConfirmed && state != OrderStateCanceled:
+		return nil
+	case state != order.PendingState:
+		order.PendingState, order.PendingBlock = state, latest
+	case latest >= order.PendingBlock+WatchConfirmations:
+		details, err := indexer.binder.OrderbookOrder(summary.OrderID)
+		if err != nil {
+			return fmt.Errorf("cannot get order=%v: %v", order.OrderID, err)
+		}
+		order.setState(details)
+		order.PendingState, order.PendingBlock = "", 0
+		orderIndexMetrics.Add(state, 1)
+	default:
+		return nil
+	}
+
+	if err := indexer.index.UpdateIndexedOrder(order); err != nil {
+		return fmt.Errorf("cannot update order=%v: %v", order.OrderID, err)
+	}
+	return nil
+}
+
+// indexSettlements indexes the settlements of orders in the blocks from the
+// stored cursor to the end block.
+func (indexer *orderIndexer) indexSettlements(end uint64) error {
+	next, ok, err := indexer.cursorer.Cursor(orderIndexCursor)
+	if err != nil {
+		return fmt.Errorf("cannot load order index cursor: %v", err)
+	}
+	if !ok {
+		next = indexer.startBlock
+	}
+
+	for next <= end {
+		if err := indexer.renewLease(); err != nil {
+			return err
+		}
+		to := next + WatchBlockRange - 1
+		if to > end {
+			to = end
+		}
+		settlements, err := indexer.binder.OrderSettlements(next, to)
+		if err != nil {
+			return fmt.Errorf("cannot filter order settlements from block %v to %v: %v", next, to, err)
+		}
+		for _, settlement := range settlements {
+			id := base64.StdEncoding.EncodeToString(settlement.OrderID[:])
+			details, err := indexer.binder.GetMatchDetails(settlement.OrderID)
+			if err != nil {
+				return fmt.Errorf("cannot get match details for order=%v: %v", id, err)
+			}
+			if !details.Settled {
+				continue
+			}
+			if err := indexer.index.InsertIndexedSettlement(id, settlement.BlockNumber, *settlementDetails(details)); err != nil {
+				return fmt.Errorf("cannot index settlement of order=%v: %v", id, err)
+			}
+		}
+		if err := indexer.cursorer.UpdateCursor(orderIndexCursor, to+1); err != nil {
+			return fmt.Errorf("cannot store order index cursor: %v", err)
+		}
+		orderIndexMetrics.Add("settlements", int64(len(settlements)))
+		next = to + 1
+	}
+	return nil
+}
+
+// setState sets the state, block number, confirmer and match of the order to
+// those stored by the Orderbook.
+func (order *IndexedOrder) setState(details contract.OrderbookOrder) {
+	order.State = orderbookState(details.State)
+	order.BlockNumber = details.BlockNumber
+	if details.Confirmer != (common.Address{}) {
+		order.Confirmer = details.Confirmer.Hex()
+	}
+	if details.MatchID != ([32]byte{}) {
+		order.MatchID = base64.StdEncoding.EncodeToString(details.MatchID[:])
+	}
+}
+
+// orderbookState returns the state of an order in the Orderbook.
+func orderbookState(state uint8) string {
+	switch state {
+	case orderStateOpen:
+		return OrderStateOpen
+	case orderStateConfirmed:
+		return OrderStateConfirmed
+	case orderStateCanceled:
+		return OrderStateCanceled
+	default:
+		return OrderStatePending
+	}
+}
@@ -0,0 +1,381 @@
+package ingress_test
+
+import (
+	"database/sql"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/republicprotocol/renex-ingress-go/ingress"
+
+	"github.com/republicprotocol/renex-ingress-go/contract"
+)
+
+var _ = Describe("Order indexer", func() {
+
+	trader := common.HexToAddress("0x62026B5aC38f1b186c7af0b18Aee8B2EccC2d852")
+	other := common.HexToAddress("0x5B3B5D4d8b4C53F6eD9e1e2C30A4fE1b2e8D7e1A")
+
+	newMemoryStorage := func() (OrderIndex, Cursorer, Leaser) {
+		return NewMemoryOrderIndex(), NewMemoryCursorer(), NewMemoryLeaser()
+	}
+
+	newSQLiteStorage := func() (OrderIndex, Cursorer, Leaser) {
+		db, err := OpenDB(SQLiteURLPrefix + ":memory:")
+		Expect(err).ShouldNot(HaveOccurred())
+		_, err = db.Migrate()
+		Expect(err).ShouldNot(HaveOccurred())
+		return NewOrderIndexWithDB(db), NewCursorerWithDB(db), NewLeaserWithDB(db)
+	}
+
+	orderIDs := func(page IndexedOrderPage) []string {
+		ids := make([]string, len(page.Orders))
+		for i, order := range page.Orders {
+			ids[i] = order.OrderID
+		}
+		return ids
+	}
+
+	for _, backend := range []struct {
+		name       string
+		newStorage func() (OrderIndex, Cursorer, Leaser)
+	}{
+		{"memory", newMemoryStorage},
+		{"sqlite", newSQLiteStorage},
+	} {
+		backend := backend
+
+		Context("when using "+backend.name+" storage", func() {
+
+			var binder *mockOrderbookBinder
+			var index OrderIndex
+			var indexer OrderIndexer
+			var buy, sell, third string
+
+			BeforeEach(func() {
+				binder = newMockOrderbookBinder()
+				var cursorer Cursorer
+				var leaser Leaser
+				index, cursorer, leaser = backend.newStorage()
+				indexer = NewOrderIndexer(binder, index, cursorer, leaser, 0, time.Hour)
+				buy, sell, third = newPartialSwap(1).OrderID, newPartialSwap(2).OrderID, newPartialSwap(3).OrderID
+			})
+
+			It("should index orders once the block in which they were opened is confirmed", func() {
+				binder.open(buy, trader, 10)
+				binder.open(sell, other, 20)
+				binder.latest = 10 + WatchConfirmations
+				Expect(indexer.Sync()).ShouldNot(HaveOccurred())
+
+				order, err := index.IndexedOrder(buy)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(order.Position).Should(Equal(uint64(0)))
+				Expect(order.Trader).Should(Equal("0x62026b5ac38f1b186c7af0b18aee8b2eccc2d852"))
+				Expect(order.State).Should(Equal(OrderStateOpen))
+				Expect(order.BlockNumber).Should(Equal(uint64(10)))
+				_, err = index.IndexedOrder(sell)
+				Expect(err).Should(Equal(sql.ErrNoRows))
+
+				binder.latest = 20 + WatchConfirmations
+				Expect(indexer.Sync()).ShouldNot(HaveOccurred())
+				page, err := index.IndexedOrders(IndexQuery{Limit: 10})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(orderIDs(page)).Should(Equal([]string{sell, buy}))
+				Expect(page.Orders[0].Position).Should(Equal(uint64(1)))
+			})
+
+			It("should index changes to the state of orders once they are confirmed", func() {
+				binder.open(buy, trader, 10)
+				binder.open(sell, other, 10)
+				binder.latest = 10 + WatchConfirmations
+				Expect(indexer.Sync()).ShouldNot(HaveOccurred())
+
+				binder.confirm(buy, sell, binder.latest)
+				binder.confirm(sell, buy, binder.latest)
+				Expect(indexer.Sync()).ShouldNot(HaveOccurred())
+				binder.latest += WatchConfirmations - 1
+				Expect(indexer.Sync()).ShouldNot(HaveOccurred())
+				order, err := index.IndexedOrder(buy)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(order.State).Should(Equal(OrderStateOpen))
+
+				binder.latest++
+				Expect(indexer.Sync()).ShouldNot(HaveOccurred())
+				order, err = index.IndexedOrder(buy)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(order.State).Should(Equal(OrderStateConfirmed))
+				Expect(order.BlockNumber).Should(Equal(uint64(10 + WatchConfirmations)))
+				Expect(order.Confirmer).Should(Equal(mockConfirmer.Hex()))
+				Expect(order.MatchID).Should(Equal(sell))
+
+				open, err := index.OpenIndexedOrders()
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(open).Should(BeEmpty())
+			})
+
+			It("should forget changes to the state of orders that are undone by a reorg", func() {
+				binder.open(buy, trader, 10)
+				binder.latest = 10 + WatchConfirmations
+				Expect(indexer.Sync()).ShouldNot(HaveOccurred())
+
+				binder.cancel(buy, binder.latest)
+				Expect(indexer.Sync()).ShouldNot(HaveOccurred())
+				binder.reopen(buy, 10)
+				binder.latest++
+				Expect(indexer.Sync()).ShouldNot(HaveOccurred())
+				binder.latest += WatchConfirmations
+				Expect(indexer.Sync()).ShouldNot(HaveOccurred())
+
+				order, err := index.IndexedOrder(buy)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(order.State).Should(Equal(OrderStateOpen))
+			})
+
+			It("should backfill orders that have been canceled", func() {
+				binder.open(buy, trader, 10)
+				binder.cancel(buy, 12)
+				binder.latest = 12 + WatchConfirmations
+				Expect(indexer.Sync()).ShouldNot(HaveOccurred())
+				binder.latest += WatchConfirmations
+				Expect(indexer.Sync()).ShouldNot(HaveOccurred())
+
+				order, err := index.IndexedOrder(buy)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(order.State).Should(Equal(OrderStateCanceled))
+				Expect(order.BlockNumber).Should(Equal(uint64(12)))
+			})
+
+			It("should get orders that were opened in the same block once", func() {
+				ids := make([]string, 20)
+				for i := range ids {
+					ids[i] = newPartialSwap(byte(i + 10)).OrderID
+					binder.open(ids[i], trader, 10+uint64(i/10))
+				}
+				binder.latest = 11 + WatchConfirmations
+				Expect(indexer.Sync()).ShouldNot(HaveOccurred())
+				Expect(binder.numOrderCalls).Should(BeNumerically("<=", 6))
+
+				page, err := index.IndexedOrders(IndexQuery{Limit: 20})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(page.Orders).Should(HaveLen(20))
+				for _, order := range page.Orders {
+					Expect(order.BlockNumber).Should(Equal(10 + uint64(order.Position/10)))
+				}
+			})
+
+			Context("when orders are loaded in small pages", func() {
+
+				var pageSize uint64
+
+				BeforeEach(func() {
+					pageSize = OrderIndexPageSize
+					OrderIndexPageSize = 2
+				})
+
+				AfterEach(func() {
+					OrderIndexPageSize = pageSize
+				})
+
+				It("should only load the pages of the orderbook with open orders", func() {
+					ids := make([]string, 6)
+					for i := range ids {
+						ids[i] = newPartialSwap(byte(i + 10)).OrderID
+						binder.open(ids[i], trader, 10)
+					}
+					binder.latest = 10 + WatchConfirmations
+					Expect(indexer.Sync()).ShouldNot(HaveOccurred())
+
+					for _, id := range ids[1:5] {
+						binder.cancel(id, binder.latest)
+					}
+					Expect(indexer.Sync()).ShouldNot(HaveOccurred())
+					binder.latest += WatchConfirmations
+					Expect(indexer.Sync()).ShouldNot(HaveOccurred())
+					open, err := index.OpenIndexedOrders()
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(open).Should(HaveLen(2))
+
+					binder.offsets = nil
+					Expect(indexer.Sync()).ShouldNot(HaveOccurred())
+					Expect(binder.offsets).Should(Equal([]uint64{6, 0, 5}))
+				})
+			})
+
+			It("should index settlements from the stored cursor", func() {
+				binder.open(buy, trader, 10)
+				binder.open(sell, other, 10)
+				binder.open(third, trader, 11)
+				binder.confirm(buy, sell, 11)
+				binder.confirm(sell, buy, 11)
+				binder.settle(buy, sell, 12)
+				binder.latest = 12 + WatchConfirmations
+				Expect(indexer.Sync()).ShouldNot(HaveOccurred())
+				binder.latest += WatchConfirmations
+				Expect(indexer.Sync()).ShouldNot(HaveOccurred())
+				Expect(binder.filtered).Should(Equal([][2]uint64{{0, 12}, {13, 12 + WatchConfirmations}}))
+
+				order, err := index.IndexedOrder(buy)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(order.State).Should(Equal(OrderStateSettled))
+				Expect(order.SettledAt).Should(Equal(uint64(12)))
+				Expect(order.Settlement).ShouldNot(BeNil())
+				Expect(order.Settlement.OrderIsBuy).Should(BeTrue())
+				Expect(order.Settlement.MatchedID).Should(Equal(sell))
+				Expect(order.Settlement.PriorityVolume).Should(Equal("100"))
+				Expect(order.Settlement.SecondaryVolume).Should(Equal("200"))
+
+				page, err := index.IndexedOrders(IndexQuery{State: OrderStateSettled, Limit: 10})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(orderIDs(page)).Should(Equal([]string{sell, buy}))
+				page, err = index.IndexedOrders(IndexQuery{State: OrderStateConfirmed, Limit: 10})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(page.Orders).Should(BeEmpty())
+			})
+
+			It("should filter and paginate indexed orders", func() {
+				binder.open(buy, trader, 10)
+				binder.open(sell, other, 11)
+				binder.open(third, trader, 12)
+				binder.latest = 12 + WatchConfirmations
+				Expect(indexer.Sync()).ShouldNot(HaveOccurred())
+
+				page, err := index.IndexedOrders(IndexQuery{Trader: trader.Hex(), Limit: 1})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(orderIDs(page)).Should(Equal([]string{third}))
+				Expect(page.More).Should(BeTrue())
+				page, err = index.IndexedOrders(IndexQuery{Trader: trader.Hex(), Offset: 1, Limit: 1})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(orderIDs(page)).Should(Equal([]string{buy}))
+				Expect(page.More).Should(BeFalse())
+
+				page, err = index.IndexedOrders(IndexQuery{State: OrderStateOpen, FromBlock: 11, ToBlock: 11, Limit: 10})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(orderIDs(page)).Should(Equal([]string{sell}))
+
+				_, err = index.IndexedOrders(IndexQuery{State: OrderStatePending, Limit: 10})
+				Expect(err).Should(Equal(ErrUnknownOrderState))
+			})
+		})
+	}
+
+	It("should not index orders while another process holds the lease", func() {
+		db := newSQLiteDB()
+		held, err := NewLeaserWithDB(db).AcquireLease("order_index", time.Hour)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(held).Should(BeTrue())
+
+		binder := newMockOrderbookBinder()
+		index := NewOrderIndexWithDB(db)
+		indexer := NewOrderIndexer(binder, index, NewCursorerWithDB(db), NewLeaserWithDB(db), 0, time.Hour)
+		binder.open(newPartialSwap(1).OrderID, trader, 10)
+		binder.latest = 10 + WatchConfirmations
+		Expect(indexer.Sync()).ShouldNot(HaveOccurred())
+
+		page, err := index.IndexedOrders(IndexQuery{Limit: 10})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(page.Orders).Should(BeEmpty())
+	})
+})
+
+// mockOrderbookBinder is an Orderbook, and a RenExSettlement contract that
+// settles orders with a priority volume of 100 and a secondary volume of
+// 200.
+type mockOrderbookBinder struct {
+	*mockSettlementBinder
+	orders  []contract.OrderbookOrder
+	details map[[32]byte]contract.MatchDetails
+
+	// offsets are the offsets of the pages of orders that have been loaded,
+	// and numOrderCalls is the number of orders that have been loaded one at
+	// a time.
+	offsets       []uint64
+	numOrderCalls int
+}
+
+func newMockOrderbookBinder() *mockOrderbookBinder {
+	return &mockOrderbookBinder{
+		mockSettlementBinder: &mockSettlementBinder{},
+		orders:               []contract.OrderbookOrder{},
+		details:              map[[32]byte]contract.MatchDetails{},
+	}
+}
+
+func (binder *mockOrderbookBinder) open(id string, trader common.Address, block uint64) {
+	binder.orders = append(binder.orders, contract.OrderbookOrder{OrderID: mockOrderID(id), Trader: trader, State: 1, BlockNumber: block})
+}
+
+func (binder *mockOrderbookBinder) confirm(id, match string, block uint64) {
+	order := binder.order(id)
+	order.State, order.Confirmer, order.MatchID, order.BlockNumber = 2, mockConfirmer, mockOrderID(match), block
+}
+
+func (binder *mockOrderbookBinder) cancel(id string, block uint64) {
+	order := binder.order(id)
+	order.State, order.BlockNumber = 3, block
+}
+
+// reopen the order, as if its last change had been undone by a reorg.
+func (binder *mockOrderbookBinder) reopen(id string, block uint64) {
+	order := binder.order(id)
+	order.State, order.Confirmer, order.MatchID, order.BlockNumber = 1, common.Address{}, [32]byte{}, block
+}
+
+// settle the buy order with the sell order in the block.
+func (binder *mockOrderbookBinder) settle(buy, sell string, block uint64) {
+	binder.mockSettlementBinder.settle(buy, block)
+	binder.mockSettlementBinder.settle(sell, block)
+	binder.details[mockOrderID(buy)] = contract.MatchDetails{
+		Settled:         true,
+		OrderIsBuy:      true,
+		MatchedID:       mockOrderID(sell),
+		PriorityVolume:  big.NewInt(100),
+		SecondaryVolume: big.NewInt(200),
+	}
+	binder.details[mockOrderID(sell)] = contract.MatchDetails{
+		Settled:         true,
+		OrderIsBuy:      false,
+		MatchedID:       mockOrderID(buy),
+		PriorityVolume:  big.NewInt(100),
+		SecondaryVolume: big.NewInt(200),
+	}
+}
+
+func (binder *mockOrderbookBinder) order(id string) *contract.OrderbookOrder {
+	for i := range binder.orders {
+		if binder.orders[i].OrderID == mockOrderID(id) {
+			return &binder.orders[i]
+		}
+	}
+	Fail("unknown order " + id)
+	return nil
+}
+
+func (binder *mockOrderbookBinder) OrderbookOrders(offset, limit uint64) ([]contract.OrderbookOrder, error) {
+	binder.offsets = append(binder.offsets, offset)
+	orders := []contract.OrderbookOrder{}
+	for i := offset; i < offset+limit && i < uint64(len(binder.orders)); i++ {
+		orders = append(orders, contract.OrderbookOrder{
+			OrderID: binder.orders[i].OrderID,
+			Trader:  binder.orders[i].Trader,
+			State:   binder.orders[i].State,
+		})
+	}
+	return orders, nil
+}
+
+func (binder *mockOrderbookBinder) OrderbookOrder(id [32]byte) (contract.OrderbookOrder, error) {
+	binder.numOrderCalls++
+	for _, order := range binder.orders {
+		if order.OrderID == id {
+			return order, nil
+		}
+	}
+	return contract.OrderbookOrder{}, nil
+}
+
+func (binder *mockOrderbookBinder) GetMatchDetails(id [32]byte) (contract.MatchDetails, error) {
+	return binder.details[id], nil
+}
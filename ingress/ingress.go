@@ -17,6 +17,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/getsentry/raven-go"
+	"github.com/republicprotocol/renex-ingress-go/config"
 	"github.com/republicprotocol/republic-go/crypto"
 	"github.com/republicprotocol/republic-go/dispatch"
 	"github.com/republicprotocol/republic-go/logger"
@@ -65,8 +66,6 @@ type Ingress interface {
 	// cancel orders.
 	ProcessRequests(done <-chan struct{}) <-chan error
 
-	WyreVerified(trader [20]byte) (bool, error)
-
 	// GetOrderTrader of the given order id
 	GetOrderTrader(orderID [32]byte) (common.Address, error)
 
@@ -75,6 +74,34 @@ type Ingress interface {
 
 	// Loginer interface implements login database interaction functions.
 	Loginer
+
+	// Approver interface implements manual trader approval functions.
+	Approver
+
+	// Noncer interface implements the storage of nonces for signed requests.
+	Noncer
+
+	// KYCVerifier interface implements trader verification functions.
+	KYCVerifier
+
+	// Webhooker interface implements the storage of trader webhooks.
+	Webhooker
+
+	// Sessioner interface implements the storage of sessions for event
+	// streams.
+	Sessioner
+
+	// Subscriber interface implements the streaming of trader events.
+	Subscriber
+
+	// Orderer interface implements the storage of approved orders.
+	Orderer
+
+	// OrderIndex interface implements queries of the indexed Orderbook.
+	OrderIndex
+
+	// Balancer interface implements queries of the balances of traders.
+	Balancer
 }
 
 type ingress struct {
@@ -90,23 +117,62 @@ type ingress struct {
 	podsPrev map[[32]byte]registry.Pod
 
 	queueRequests chan Request
+	notifier      Notifier
 	Swapper
 	Loginer
+	Approver
+	Noncer
+	KYCVerifier
+	Webhooker
+	Sessioner
+	Subscriber
+	Orderer
+	OrderIndex
+	Balancer
+}
+
+// Services are the storage backends and background services that an Ingress
+// is built from. The Notifier is notified when an order is approved, and when
+// the fragments of an order have been delivered, and the Subscriber streams
+// the events of traders.
+type Services struct {
+	Swapper     Swapper
+	Loginer     Loginer
+	Approver    Approver
+	Noncer      Noncer
+	KYCVerifier KYCVerifier
+	Webhooker   Webhooker
+	Sessioner   Sessioner
+	Notifier    Notifier
+	Subscriber  Subscriber
+	Orderer     Orderer
+	OrderIndex  OrderIndex
+	Balancer    Balancer
 }
 
 // NewIngress returns an Ingress. The background services of the Ingress must
 // be started separately by calling Ingress.OpenOrderProcess and
 // Ingress.OpenOrderFragmentsProcess.
-func NewIngress(ecdsaKey crypto.EcdsaKey, contract ContractBinder, renExContract RenExContractBinder, swarmer swarm.Swarmer, orderbookClient orderbook.Client, epochPollInterval time.Duration, swapper Swapper, loginer Loginer) Ingress {
+func NewIngress(conf config.Config, ecdsaKey crypto.EcdsaKey, contract ContractBinder, renExContract RenExContractBinder, swarmer swarm.Swarmer, orderbookClient orderbook.Client, services Services) Ingress {
 	ingress := &ingress{
 		ecdsaKey:          ecdsaKey,
 		contract:          contract,
 		renExContract:     renExContract,
 		swarmer:           swarmer,
-		Swapper:           swapper,
-		Loginer:           loginer,
+		Swapper:           services.Swapper,
+		Loginer:           services.Loginer,
+		Approver:          services.Approver,
+		Noncer:            services.Noncer,
+		KYCVerifier:       services.KYCVerifier,
+		Webhooker:         services.Webhooker,
+		Sessioner:         services.Sessioner,
+		Subscriber:        services.Subscriber,
+		Orderer:           services.Orderer,
+		OrderIndex:        services.OrderIndex,
+		Balancer:          services.Balancer,
+		notifier:          services.Notifier,
 		orderbookClient:   orderbookClient,
-		epochPollInterval: epochPollInterval,
+		epochPollInterval: conf.EpochPollInterval,
 
 		podsMu:   new(sync.RWMutex),
 		podsCurr: map[[32]byte]registry.Pod{},
@@ -258,10 +324,29 @@ func (ingress *ingress) OpenOrder(trader [20]byte, orderID order.ID, orderFragme
 	}
 	fmt.Println("Signature:", hex.EncodeToString(signature))
 
+	approved := ApprovedOrder{
+		OrderID:    base64.StdEncoding.EncodeToString(orderID[:]),
+		Trader:     common.BytesToAddress(trader[:]).Hex(),
+		ApprovedAt: time.Now().Unix(),
+	}
+	if err := ingress.InsertOrder(approved); err != nil {
+		return [65]byte{}, fmt.Errorf("cannot store approval of order = %v: %v", orderID, err)
+	}
+
+	event := Event{
+		Type:    EventOrderApproved,
+		OrderID: approved.OrderID,
+		Trader:  approved.Trader,
+	}
+	if err := ingress.notifier.Notify(event); err != nil {
+		log.Printf("[error] (open) cannot notify trader of order = %v: %v", orderID, err)
+	}
+
 	for i := range orderFragmentMappings {
 		go func(i int) {
 			log.Printf("[info] (open) queueing order fragments order = %v at depth = %v", orderID, i)
 			ingress.queueRequests <- OpenOrderFragmentMappingRequest{
+				trader:                  trader,
 				orderID:                 orderID,
 				orderFragmentMapping:    orderFragmentMappings[i],
 				orderFragmentEpochDepth: i,
@@ -274,15 +359,6 @@ func (ingress *ingress) OpenOrder(trader [20]byte, orderID order.ID, orderFragme
 	return signature65, nil
 }
 
-func (ingress *ingress) WyreVerified(trader [20]byte) (bool, error) {
-	// BalanceOf returns 1 if the trader is verified and 0 otherwise.
-	balance, err := ingress.renExContract.BalanceOf(trader)
-	if err != nil {
-		return false, err
-	}
-	return balance.Cmp(big.NewInt(0)) == 1, nil
-}
-
 func WithdrawalMessage(trader [20]byte, tokenID uint32, traderNonce *big.Int) ([]byte, error) {
 	buf := new(bytes.Buffer)
 	if err := binary.Write(buf, binary.BigEndian, []byte("Republic Protocol: withdraw: ")); err != nil {
@@ -423,6 +499,19 @@ func (ingress *ingress) processOpenOrderFragmentMappingRequest(req OpenOrderFrag
 		}
 		return
 	}
+
+	event := Event{
+		Type:    EventFragmentsDelivered,
+		OrderID: base64.StdEncoding.EncodeToString(req.orderID[:]),
+		Trader:  common.BytesToAddress(req.trader[:]).Hex(),
+		Data:    FragmentsDelivery{Depth: req.orderFragmentEpochDepth},
+	}
+	if err := ingress.notifier.Notify(event); err != nil {
+		select {
+		case <-done:
+		case errs <- fmt.Errorf("[error] (open) cannot notify trader of order = %v: %v", req.orderID, err):
+		}
+	}
 }
 
 func (ingress *ingress) sendOrderFragmentsToPod(pod registry.Pod, orderFragments []OrderFragment) error {
@@ -3,6 +3,8 @@ package ingress_test
 import (
 	"context"
 	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"math/big"
@@ -15,6 +17,7 @@ import (
 	. "github.com/onsi/gomega"
 	. "github.com/republicprotocol/renex-ingress-go/ingress"
 
+	"github.com/republicprotocol/renex-ingress-go/config"
 	"github.com/republicprotocol/republic-go/crypto"
 	"github.com/republicprotocol/republic-go/identity"
 	"github.com/republicprotocol/republic-go/order"
@@ -29,6 +32,7 @@ var _ = Describe("Ingress", func() {
 	var contract ContractBinder
 	var renExContract RenExContractBinder
 	var ingress Ingress
+	var notifier *mockNotifier
 	var done chan struct{}
 	var errChSync <-chan error
 	var errChProcess <-chan error
@@ -50,7 +54,23 @@ var _ = Describe("Ingress", func() {
 		swarmer := mockSwarmer{}
 		orderbookClient := mockOrderbookClient{}
 
-		ingress = NewIngress(ecdsaKey, contract, renExContract, &swarmer, &orderbookClient, time.Millisecond, &mockSwapper{}, &mockLoginer{})
+		conf := config.Config{EpochPollInterval: time.Millisecond}
+		notifier = &mockNotifier{mu: new(sync.Mutex)}
+		db := newSQLiteDB()
+		ingress = NewIngress(conf, ecdsaKey, contract, renExContract, &swarmer, &orderbookClient, Services{
+			Swapper:     &mockSwapper{},
+			Loginer:     &mockLoginer{},
+			Approver:    &mockApprover{},
+			Noncer:      NewNoncerWithDB(db),
+			KYCVerifier: NewDisabledKYCVerifier(),
+			Webhooker:   NewWebhookerWithDB(db),
+			Sessioner:   NewSessionerWithDB(db),
+			Notifier:    notifier,
+			Subscriber:  NewEventStream(newMockSwapContractBinder(), NewMemoryBroadcaster(), time.Hour),
+			Orderer:     NewOrdererWithDB(db, newMockSwapContractBinder()),
+			OrderIndex:  NewOrderIndexWithDB(db),
+			Balancer:    NewBalancer(newMockBalanceBinder()),
+		})
 		errChSync = ingress.Sync(done)
 		errChProcess = ingress.ProcessRequests(done)
 
@@ -127,6 +147,12 @@ var _ = Describe("Ingress", func() {
 			signature, err := ingress.OpenOrder(trader, ord.ID, orderFragmentMappingsIn)
 			Expect(signature).ShouldNot(BeNil())
 			Expect(err).ShouldNot(HaveOccurred())
+			Expect(notifier.eventTypes(base64.StdEncoding.EncodeToString(ord.ID[:]))).Should(ContainElement(EventOrderApproved))
+
+			approved, err := ingress.ApprovedOrders(common.BytesToAddress(trader[:]).Hex(), 0, 10)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(approved).Should(HaveLen(1))
+			Expect(approved[0].OrderID).Should(Equal(base64.StdEncoding.EncodeToString(ord.ID[:])))
 		})
 
 		It("should not open orders with an insufficient number of order fragments", func() {
@@ -493,11 +519,31 @@ func (swapper *mockSwapper) FinalizedSwap(id string) (FinalizedSwap, bool, error
 	return FinalizedSwap{}, false, nil
 }
 
+func (swapper *mockSwapper) SwapStatus(id string) (SwapStatus, error) {
+	return SwapStatus{OrderID: id}, nil
+}
+
+func (swapper *mockSwapper) MarkRefundableSwaps(now int64) ([]RefundableSwap, error) {
+	return []RefundableSwap{}, nil
+}
+
+func (swapper *mockSwapper) RefundableSwaps() ([]RefundableSwap, error) {
+	return []RefundableSwap{}, nil
+}
+
+func (swapper *mockSwapper) UnfinalizedPartialSwaps(before int64) ([]string, error) {
+	return []string{}, nil
+}
+
+func (swapper *mockSwapper) DeletePartialSwap(id string) error {
+	return nil
+}
+
 type mockLoginer struct {
 }
 
-func (Loginer *mockLoginer) SelectLogin(address string) (int64, string, error) {
-	return 0, "", nil
+func (Loginer *mockLoginer) SelectLogin(address string) (Login, error) {
+	return Login{}, nil
 }
 
 func (Loginer *mockLoginer) InsertLogin(address, referrer string) error {
@@ -511,3 +557,83 @@ func (Loginer *mockLoginer) UpdateLogin(address string, kyberUID int64, kycType
 func (Loginer *mockLoginer) Authorize(authorizer, authorizedAddr string) error {
 	return nil
 }
+
+func (loginer *mockLoginer) StaleLogins(verifiedBefore int64, limit int) ([]Login, error) {
+	return []Login{}, nil
+}
+
+func (loginer *mockLoginer) RevokeLogin(address string, kycType int, reason string) error {
+	return nil
+}
+
+func (loginer *mockLoginer) KYCAudits(address string) ([]KYCAudit, error) {
+	return []KYCAudit{}, nil
+}
+
+func (loginer *mockLoginer) SelectLoginByReferralCode(code string) (Login, error) {
+	return Login{}, sql.ErrNoRows
+}
+
+func (loginer *mockLoginer) ReferredLogins(referrer string) ([]Login, error) {
+	return []Login{}, nil
+}
+
+func (loginer *mockLoginer) AuthorizedLogins(authorizer string) ([]Login, error) {
+	return []Login{}, nil
+}
+
+func (loginer *mockLoginer) Unauthorize(authorizer, authorizedAddr string) error {
+	return nil
+}
+
+type mockApprover struct {
+}
+
+func (approver *mockApprover) ApprovedTraders() ([]ApprovedTrader, error) {
+	return []ApprovedTrader{}, nil
+}
+
+func (approver *mockApprover) InsertApprovedTrader(trader ApprovedTrader) error {
+	return nil
+}
+
+func (approver *mockApprover) DeleteApprovedTrader(address string) error {
+	return nil
+}
+
+func (approver *mockApprover) TraderApproved(address string) (bool, error) {
+	return false, nil
+}
+
+// mockNotifier records the Events that it is notified of.
+type mockNotifier struct {
+	mu     *sync.Mutex
+	events []Event
+	err    error
+}
+
+func (notifier *mockNotifier) Notify(event Event) error {
+	notifier.mu.Lock()
+	defer notifier.mu.Unlock()
+
+	if notifier.err != nil {
+		return notifier.err
+	}
+	notifier.events = append(notifier.events, event)
+	return nil
+}
+
+// eventTypes returns the types of the Events of the order, in the order in
+// which they were notified.
+func (notifier *mockNotifier) eventTypes(orderID string) []string {
+	notifier.mu.Lock()
+	defer notifier.mu.Unlock()
+
+	types := []string{}
+	for _, event := range notifier.events {
+		if event.OrderID == orderID {
+			types = append(types, event.Type)
+		}
+	}
+	return types
+}
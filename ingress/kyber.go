@@ -0,0 +1,57 @@
+package ingress
+
+import (
+	"context"
+
+	"github.com/republicprotocol/renex-ingress-go/config"
+	"github.com/republicprotocol/renex-ingress-go/kyber"
+)
+
+type kyberKYCProvider struct {
+	client *kyber.Client
+}
+
+// NewKyberKYCProvider returns a KYCProvider that verifies traders that have
+// linked their address to a Kyber account. Traders must first link their
+// Kyber account by logging in with Kyber.
+func NewKyberKYCProvider(client *kyber.Client) KYCProvider {
+	return &kyberKYCProvider{
+		client: client,
+	}
+}
+
+func (provider *kyberKYCProvider) Name() string {
+	return config.KYCProviderKyber
+}
+
+func (provider *kyberKYCProvider) KYCType() int {
+	return KYCKyber
+}
+
+func (provider *kyberKYCProvider) Recorded(login Login) bool {
+	return login.KYCKyber != 0
+}
+
+// Verify returns true if the address is still an active wallet of the Kyber
+// account that is linked to the trader.
+func (provider *kyberKYCProvider) Verify(address string, login Login) (bool, int64, error) {
+	if login.KYCKyber == 0 {
+		return false, 0, nil
+	}
+
+	user, err := provider.client.AuthorizedUser(context.Background(), login.KYCKyber)
+	if err != nil {
+		if err == kyber.ErrUserNotFound {
+			// The trader has revoked the authorization of the Ingress
+			return false, 0, nil
+		}
+		return false, 0, err
+	}
+
+	// The trader is verified if the address is still linked to the trader's
+	// Kyber account
+	if !user.HasAddress(address) {
+		return false, 0, nil
+	}
+	return true, login.KYCKyber, nil
+}
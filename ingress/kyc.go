@@ -0,0 +1,348 @@
+package ingress
+
+import (
+	"database/sql"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/republicprotocol/renex-ingress-go/config"
+	"github.com/republicprotocol/renex-ingress-go/kyber"
+)
+
+// A KYCProvider verifies the identity of traders using an external service,
+// such as Wyre or Kyber.
+type KYCProvider interface {
+	// Name of the provider, used in errors and configuration.
+	Name() string
+
+	// KYCType that is stored by the Loginer when a trader is verified by the
+	// provider.
+	KYCType() int
+
+	// Recorded returns true if the Login holds a verification from the
+	// provider.
+	Recorded(login Login) bool
+
+	// Verify the trader with the provider. It returns true if the trader is
+	// verified, and the reference to the trader that is stored by the
+	// Loginer (e.g. the Kyber UID). The Login is empty if the trader has not
+	// logged in.
+	Verify(address string, login Login) (bool, int64, error)
+}
+
+// A KYCLink is a KYCProvider in a chain of providers.
+type KYCLink struct {
+	Provider KYCProvider
+
+	// Freshness is the duration for which a recorded verification is trusted
+	// without verifying the trader with the provider again. A zero value
+	// means the trader is always verified with the provider.
+	Freshness time.Duration
+}
+
+// A KYCVerifier checks whether traders have been verified.
+type KYCVerifier interface {
+	// TraderVerified returns the KYC type with which the trader has been
+	// verified, or KYCNone if the trader has not been verified.
+	TraderVerified(address string) (int, error)
+
+	// ForgetVerification discards any result for the trader that is cached
+	// by the KYCVerifier. It is called when the verification of a trader is
+	// known to have changed.
+	ForgetVerification(address string)
+}
+
+type kycChain struct {
+	loginer Loginer
+	links   []KYCLink
+}
+
+// NewKYCChain returns a KYCVerifier that tries each KYCLink in order until
+// one of them verifies the trader. Verifications are stored using the
+// Loginer.
+func NewKYCChain(loginer Loginer, links ...KYCLink) KYCVerifier {
+	return &kycChain{
+		loginer: loginer,
+		links:   links,
+	}
+}
+
+// TraderVerified implements the KYCVerifier interface.
+func (chain *kycChain) TraderVerified(address string) (int, error) {
+	address = normalizeAddress(address)
+	login, err := chain.loginer.SelectLogin(address)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			return KYCNone, fmt.Errorf("cannot get verification information from database: %v", err)
+		}
+		login = Login{}
+	}
+
+	for _, link := range chain.links {
+		if link.Freshness > 0 && link.Provider.Recorded(login) {
+			if time.Since(time.Unix(login.LastVerifiedAt, 0)) < link.Freshness {
+				return link.Provider.KYCType(), nil
+			}
+		}
+
+		verified, reference, err := link.Provider.Verify(address, login)
+		if err != nil {
+			return KYCNone, fmt.Errorf("cannot check %v verification: %v", link.Provider.Name(), err)
+		}
+		if !verified {
+			continue
+		}
+		if link.Freshness == 0 && link.Provider.Recorded(login) {
+			// The verification is already recorded and its age is not
+			// used, so there is nothing to write.
+			return link.Provider.KYCType(), nil
+		}
+		if err := chain.loginer.UpdateLogin(address, reference, link.Provider.KYCType()); err != nil {
+			return KYCNone, fmt.Errorf("cannot update %v verification information in database: %v", link.Provider.Name(), err)
+		}
+		return link.Provider.KYCType(), nil
+	}
+	return KYCNone, nil
+}
+
+// ForgetVerification implements the KYCVerifier interface. The chain does not
+// cache results.
+func (chain *kycChain) ForgetVerification(address string) {
+}
+
+// kycSweepThreshold is the minimum number of cached results before expired
+// results are removed from a KYC cache.
+const kycSweepThreshold = 1024
+
+type kycResult struct {
+	kycType int
+	expiry  time.Time
+}
+
+type kycLookup struct {
+	done    chan struct{}
+	kycType int
+	err     error
+}
+
+type kycCache struct {
+	verifier    KYCVerifier
+	positiveTTL time.Duration
+	negativeTTL time.Duration
+
+	mu      *sync.Mutex
+	results map[string]kycResult
+	lookups map[string]*kycLookup
+	sweepAt int
+}
+
+// NewKYCCache returns a KYCVerifier that caches the results of another
+// KYCVerifier. Traders that are verified are cached for the positive TTL, and
+// traders that are not verified are cached for the negative TTL. Errors are
+// not cached. Concurrent lookups for the same trader are collapsed into a
+// single lookup.
+func NewKYCCache(verifier KYCVerifier, positiveTTL, negativeTTL time.Duration) KYCVerifier {
+	return &kycCache{
+		verifier:    verifier,
+		positiveTTL: positiveTTL,
+		negativeTTL: negativeTTL,
+
+		mu:      new(sync.Mutex),
+		results: map[string]kycResult{},
+		lookups: map[string]*kycLookup{},
+		sweepAt: kycSweepThreshold,
+	}
+}
+
+// TraderVerified implements the KYCVerifier interface.
+func (cache *kycCache) TraderVerified(address string) (int, error) {
+	address = normalizeAddress(address)
+
+	cache.mu.Lock()
+	if result, ok := cache.results[address]; ok && time.Now().Before(result.expiry) {
+		cache.mu.Unlock()
+		return result.kycType, nil
+	}
+	if lookup, ok := cache.lookups[address]; ok {
+		cache.mu.Unlock()
+		<-lookup.done
+		return lookup.kycType, lookup.err
+	}
+	lookup := &kycLookup{done: make(chan struct{})}
+	cache.lookups[address] = lookup
+	cache.mu.Unlock()
+
+	lookup.kycType, lookup.err = cache.verifier.TraderVerified(address)
+	close(lookup.done)
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	// The lookup is not cached if the trader was forgotten while it was in
+	// progress, because the result may be stale.
+	if cache.lookups[address] != lookup {
+		return lookup.kycType, lookup.err
+	}
+	delete(cache.lookups, address)
+	if lookup.err == nil {
+		ttl := cache.negativeTTL
+		if lookup.kycType != KYCNone {
+			ttl = cache.positiveTTL
+		}
+		if ttl > 0 {
+			cache.results[address] = kycResult{kycType: lookup.kycType, expiry: time.Now().Add(ttl)}
+			cache.sweep()
+		}
+	}
+	return lookup.kycType, lookup.err
+}
+
+// ForgetVerification implements the KYCVerifier interface.
+func (cache *kycCache) ForgetVerification(address string) {
+	address = normalizeAddress(address)
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	delete(cache.results, address)
+	delete(cache.lookups, address)
+	cache.verifier.ForgetVerification(address)
+}
+
+// sweep removes expired results once the number of cached results reaches
+// the sweep threshold. It must be called while holding the mutex.
+func (cache *kycCache) sweep() {
+	if len(cache.results) < cache.sweepAt {
+		return
+	}
+	now := time.Now()
+	for address, result := range cache.results {
+		if !now.Before(result.expiry) {
+			delete(cache.results, address)
+		}
+	}
+	cache.sweepAt = 2 * len(cache.results)
+	if cache.sweepAt < kycSweepThreshold {
+		cache.sweepAt = kycSweepThreshold
+	}
+}
+
+// NewKYCVerifier returns the KYCVerifier defined by the KYC settings of the
+// Config. Wyre verification is checked using the binder, and results are
+// cached for the configured TTLs.
+func NewKYCVerifier(conf config.Config, loginer Loginer, binder RenExContractBinder) (KYCVerifier, error) {
+	if conf.DisableKYC {
+		return NewDisabledKYCVerifier(), nil
+	}
+	links, err := NewKYCLinks(conf, binder)
+	if err != nil {
+		return nil, err
+	}
+	return NewKYCCache(NewKYCChain(loginer, links...), conf.KYCCacheTTL, conf.KYCNegativeCacheTTL), nil
+}
+
+// NewKYCLinks returns the chain of KYCLinks defined by the KYC providers of
+// the Config.
+func NewKYCLinks(conf config.Config, binder RenExContractBinder) ([]KYCLink, error) {
+	return newKYCLinks(conf, binder, !conf.DisableWyreWatcher)
+}
+
+// NewReverifyKYCLinks returns the chain of KYCLinks defined by the KYC
+// providers of the Config for a KYCReverifier. Wyre verifications are always
+// checked against the Wyre contract, because trusting recorded verifications
+// would never revoke them.
+func NewReverifyKYCLinks(conf config.Config, binder RenExContractBinder) ([]KYCLink, error) {
+	return newKYCLinks(conf, binder, false)
+}
+
+func newKYCLinks(conf config.Config, binder RenExContractBinder, watchWyre bool) ([]KYCLink, error) {
+	kyberClient := kyber.NewClient(conf.Kyber.URL, conf.Kyber.ID, conf.Kyber.Secret, conf.Kyber.Timeout)
+	links := make([]KYCLink, 0, len(conf.KYCProviders))
+	for _, provider := range conf.KYCProviders {
+		link := KYCLink{Freshness: provider.Freshness}
+		switch provider.Name {
+		case config.KYCProviderWyre:
+			if watchWyre {
+				link.Provider = NewWatchedWyreKYCProvider(binder)
+			} else {
+				link.Provider = NewWyreKYCProvider(binder)
+			}
+		case config.KYCProviderKyber:
+			link.Provider = NewKyberKYCProvider(kyberClient)
+		default:
+			return nil, fmt.Errorf("unsupported kyc provider %v", provider.Name)
+		}
+		links = append(links, link)
+	}
+	return links, nil
+}
+
+type disabledKYCVerifier struct {
+}
+
+// NewDisabledKYCVerifier returns a KYCVerifier that treats all traders as
+// verified by Wyre. It is used when KYC is disabled.
+func NewDisabledKYCVerifier() KYCVerifier {
+	return &disabledKYCVerifier{}
+}
+
+// TraderVerified implements the KYCVerifier interface.
+func (verifier *disabledKYCVerifier) TraderVerified(address string) (int, error) {
+	return KYCWyre, nil
+}
+
+// ForgetVerification implements the KYCVerifier interface.
+func (verifier *disabledKYCVerifier) ForgetVerification(address string) {
+}
+
+type wyreKYCProvider struct {
+	binder  RenExContractBinder
+	watched bool
+}
+
+// NewWyreKYCProvider returns a KYCProvider that verifies traders holding a
+// Wyre KYC token.
+func NewWyreKYCProvider(binder RenExContractBinder) KYCProvider {
+	return &wyreKYCProvider{
+		binder: binder,
+	}
+}
+
+// NewWatchedWyreKYCProvider returns a KYCProvider that verifies traders
+// holding a Wyre KYC token, when Wyre verifications are kept up to date by a
+// WyreWatcher. Recorded verifications are trusted without checking the Wyre
+// contract.
+func NewWatchedWyreKYCProvider(binder RenExContractBinder) KYCProvider {
+	return &wyreKYCProvider{
+		binder:  binder,
+		watched: true,
+	}
+}
+
+func (provider *wyreKYCProvider) Name() string {
+	return config.KYCProviderWyre
+}
+
+func (provider *wyreKYCProvider) KYCType() int {
+	return KYCWyre
+}
+
+func (provider *wyreKYCProvider) Recorded(login Login) bool {
+	return login.KYCWyre != ""
+}
+
+func (provider *wyreKYCProvider) Verify(address string, login Login) (bool, int64, error) {
+	if provider.watched && provider.Recorded(login) {
+		return true, 0, nil
+	}
+
+	// BalanceOf returns 1 if the trader is verified and 0 otherwise.
+	balance, err := provider.binder.BalanceOf(common.HexToAddress(address))
+	if err != nil {
+		return false, 0, err
+	}
+	return balance.Cmp(big.NewInt(0)) == 1, 0, nil
+}
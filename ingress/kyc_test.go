@@ -0,0 +1,257 @@
+package ingress_test
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/republicprotocol/renex-ingress-go/ingress"
+)
+
+var _ = Describe("KYC chain", func() {
+
+	var loginer Loginer
+	var wyre, kyber *mockKYCProvider
+
+	BeforeEach(func() {
+		loginer = NewLoginerWithDB(newSQLiteDB())
+		wyre = &mockKYCProvider{kycType: KYCWyre}
+		kyber = &mockKYCProvider{kycType: KYCKyber, reference: 42}
+		Expect(loginer.InsertLogin("0xtrader", "")).ShouldNot(HaveOccurred())
+	})
+
+	It("should not verify traders when no provider verifies them", func() {
+		chain := NewKYCChain(loginer, KYCLink{Provider: wyre}, KYCLink{Provider: kyber})
+		kycType, err := chain.TraderVerified("0xtrader")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(kycType).Should(Equal(KYCNone))
+		Expect(wyre.numVerified).Should(Equal(1))
+		Expect(kyber.numVerified).Should(Equal(1))
+	})
+
+	It("should stop at the first provider that verifies the trader", func() {
+		wyre.verified = true
+		kyber.verified = true
+		chain := NewKYCChain(loginer, KYCLink{Provider: wyre}, KYCLink{Provider: kyber})
+		kycType, err := chain.TraderVerified("0xtrader")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(kycType).Should(Equal(KYCWyre))
+		Expect(kyber.numVerified).Should(Equal(0))
+	})
+
+	It("should store verifications", func() {
+		kyber.verified = true
+		chain := NewKYCChain(loginer, KYCLink{Provider: wyre}, KYCLink{Provider: kyber})
+		kycType, err := chain.TraderVerified("0xTRADER")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(kycType).Should(Equal(KYCKyber))
+
+		login, err := loginer.SelectLogin("0xtrader")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(login.KYCKyber).Should(Equal(int64(42)))
+	})
+
+	It("should trust fresh verifications without asking the provider", func() {
+		kyber.verified = true
+		chain := NewKYCChain(loginer, KYCLink{Provider: kyber, Freshness: time.Hour})
+		_, err := chain.TraderVerified("0xtrader")
+		Expect(err).ShouldNot(HaveOccurred())
+
+		kyber.verified = false
+		kycType, err := chain.TraderVerified("0xtrader")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(kycType).Should(Equal(KYCKyber))
+		Expect(kyber.numVerified).Should(Equal(1))
+	})
+
+	It("should always ask providers without a freshness window", func() {
+		wyre.verified = true
+		chain := NewKYCChain(loginer, KYCLink{Provider: wyre})
+		_, err := chain.TraderVerified("0xtrader")
+		Expect(err).ShouldNot(HaveOccurred())
+
+		wyre.verified = false
+		kycType, err := chain.TraderVerified("0xtrader")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(kycType).Should(Equal(KYCNone))
+		Expect(wyre.numVerified).Should(Equal(2))
+	})
+
+	It("should not store verifications that have not changed", func() {
+		wyre.verified = true
+		counter := &updateCountingLoginer{Loginer: loginer}
+		chain := NewKYCChain(counter, KYCLink{Provider: wyre})
+		for i := 0; i < 3; i++ {
+			kycType, err := chain.TraderVerified("0xtrader")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(kycType).Should(Equal(KYCWyre))
+		}
+		Expect(counter.numUpdates).Should(Equal(1))
+	})
+
+	It("should return an error when a provider returns an error", func() {
+		wyre.err = errors.New("cannot connect to ethereum")
+		kyber.verified = true
+		chain := NewKYCChain(loginer, KYCLink{Provider: wyre}, KYCLink{Provider: kyber})
+		_, err := chain.TraderVerified("0xtrader")
+		Expect(err).Should(HaveOccurred())
+	})
+
+	It("should verify all traders when kyc is disabled", func() {
+		kycType, err := NewDisabledKYCVerifier().TraderVerified("0xunknown")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(kycType).ShouldNot(Equal(KYCNone))
+	})
+})
+
+var _ = Describe("KYC cache", func() {
+
+	var verifier *mockKYCVerifier
+
+	BeforeEach(func() {
+		verifier = &mockKYCVerifier{mu: new(sync.Mutex), kycType: KYCWyre}
+	})
+
+	It("should cache verified traders", func() {
+		cache := NewKYCCache(verifier, time.Hour, time.Hour)
+		for i := 0; i < 3; i++ {
+			kycType, err := cache.TraderVerified("0xtrader")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(kycType).Should(Equal(KYCWyre))
+		}
+		Expect(verifier.lookups()).Should(Equal(1))
+	})
+
+	It("should expire unverified traders after the negative ttl", func() {
+		verifier.kycType = KYCNone
+		cache := NewKYCCache(verifier, time.Hour, 10*time.Millisecond)
+		_, err := cache.TraderVerified("0xtrader")
+		Expect(err).ShouldNot(HaveOccurred())
+		_, err = cache.TraderVerified("0xtrader")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(verifier.lookups()).Should(Equal(1))
+
+		time.Sleep(20 * time.Millisecond)
+		_, err = cache.TraderVerified("0xtrader")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(verifier.lookups()).Should(Equal(2))
+	})
+
+	It("should not cache errors", func() {
+		verifier.err = errors.New("cannot connect to ethereum")
+		cache := NewKYCCache(verifier, time.Hour, time.Hour)
+		_, err := cache.TraderVerified("0xtrader")
+		Expect(err).Should(HaveOccurred())
+		_, err = cache.TraderVerified("0xtrader")
+		Expect(err).Should(HaveOccurred())
+		Expect(verifier.lookups()).Should(Equal(2))
+	})
+
+	It("should collapse concurrent lookups for the same trader", func() {
+		verifier.block = make(chan struct{})
+		cache := NewKYCCache(verifier, time.Hour, time.Hour)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+				kycType, err := cache.TraderVerified("0xTRADER")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(kycType).Should(Equal(KYCWyre))
+			}()
+		}
+		Eventually(verifier.lookups).Should(Equal(1))
+		close(verifier.block)
+		wg.Wait()
+		Expect(verifier.lookups()).Should(Equal(1))
+	})
+
+	It("should look up forgotten traders again", func() {
+		cache := NewKYCCache(verifier, time.Hour, time.Hour)
+		_, err := cache.TraderVerified("0xtrader")
+		Expect(err).ShouldNot(HaveOccurred())
+
+		cache.ForgetVerification("0xTRADER")
+		_, err = cache.TraderVerified("0xtrader")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(verifier.lookups()).Should(Equal(2))
+	})
+})
+
+type mockKYCVerifier struct {
+	mu           *sync.Mutex
+	kycType      int
+	err          error
+	block        chan struct{}
+	numLookups   int
+	numForgotten int
+	forgotten    []string
+}
+
+func (verifier *mockKYCVerifier) TraderVerified(address string) (int, error) {
+	verifier.mu.Lock()
+	verifier.numLookups++
+	verifier.mu.Unlock()
+	if verifier.block != nil {
+		<-verifier.block
+	}
+	return verifier.kycType, verifier.err
+}
+
+func (verifier *mockKYCVerifier) ForgetVerification(address string) {
+	verifier.mu.Lock()
+	defer verifier.mu.Unlock()
+	verifier.numForgotten++
+	verifier.forgotten = append(verifier.forgotten, address)
+}
+
+func (verifier *mockKYCVerifier) lookups() int {
+	verifier.mu.Lock()
+	defer verifier.mu.Unlock()
+	return verifier.numLookups
+}
+
+type updateCountingLoginer struct {
+	Loginer
+	numUpdates int
+}
+
+func (loginer *updateCountingLoginer) UpdateLogin(address string, kyberUID int64, kycType int) error {
+	loginer.numUpdates++
+	return loginer.Loginer.UpdateLogin(address, kyberUID, kycType)
+}
+
+type mockKYCProvider struct {
+	kycType     int
+	reference   int64
+	verified    bool
+	err         error
+	numVerified int
+}
+
+func (provider *mockKYCProvider) Name() string {
+	return "mock"
+}
+
+func (provider *mockKYCProvider) KYCType() int {
+	return provider.kycType
+}
+
+func (provider *mockKYCProvider) Recorded(login Login) bool {
+	switch provider.kycType {
+	case KYCWyre:
+		return login.KYCWyre != ""
+	case KYCKyber:
+		return login.KYCKyber != 0
+	}
+	return false
+}
+
+func (provider *mockKYCProvider) Verify(address string, login Login) (bool, int64, error) {
+	provider.numVerified++
+	return provider.verified, provider.reference, provider.err
+}
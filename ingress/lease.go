@@ -0,0 +1,75 @@
+package ingress
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/satori/go.uuid"
+)
+
+// The schema of the leases table is defined by Migrations.
+
+// WatchLeaseDuration is the duration of the lease that a watcher holds on its
+// cursor. Watchers renew the lease before each range of blocks, so it must be
+// longer than the time taken to process a range of blocks.
+var WatchLeaseDuration = time.Minute
+
+// A Leaser grants leases on the jobs that are run by every process of the
+// Ingress, so that each job is only run by one process at a time. Leases
+// expire, so that another process takes over a job once its holder stops.
+type Leaser interface {
+	// AcquireLease acquires the lease on a job for the duration, or renews it
+	// if the Leaser already holds it. It returns false if the lease is held
+	// by another Leaser and has not expired.
+	AcquireLease(name string, duration time.Duration) (bool, error)
+}
+
+type leaser struct {
+	*DB
+	holder string
+}
+
+// NewLeaser returns a Leaser that stores leases in the database at the URL.
+// Each Leaser is a different holder.
+func NewLeaser(databaseURL string) (Leaser, error) {
+	db, err := OpenDB(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	return NewLeaserWithDB(db), nil
+}
+
+// NewLeaserWithDB returns a Leaser that stores leases in an open database.
+// Each Leaser is a different holder.
+func NewLeaserWithDB(db *DB) Leaser {
+	return &leaser{db, uuid.NewV4().String()}
+}
+
+func (leaser *leaser) AcquireLease(name string, duration time.Duration) (bool, error) {
+	now := time.Now()
+	res, err := leaser.Exec("INSERT INTO leases (name, holder, expires_at) VALUES ($1, $2, $3) ON CONFLICT (name) DO UPDATE SET holder=excluded.holder, expires_at=excluded.expires_at WHERE leases.holder=excluded.holder OR leases.expires_at <= $4",
+		name, leaser.holder, now.Add(duration).UnixNano(), now.UnixNano())
+	if err != nil {
+		return false, err
+	}
+	acquired, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return acquired > 0, nil
+}
+
+// errLeaseLost is returned by a job that stops because its lease is held by
+// another process.
+var errLeaseLost = errors.New("lease is held by another process")
+
+// holdLease acquires, or renews, the lease on the cursor of a watcher. It
+// returns false if the watcher is run by another process.
+func holdLease(leaser Leaser, name string) (bool, error) {
+	held, err := leaser.AcquireLease(name, WatchLeaseDuration)
+	if err != nil {
+		return false, fmt.Errorf("cannot acquire lease on %v: %v", name, err)
+	}
+	return held, nil
+}
@@ -0,0 +1,73 @@
+package ingress_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/republicprotocol/renex-ingress-go/ingress"
+)
+
+var _ = Describe("Leaser", func() {
+
+	for _, backend := range []struct {
+		name      string
+		newLeaser func() Leaser
+	}{
+		{"memory", NewMemoryLeaser},
+		{"sqlite", func() Leaser { return NewLeaserWithDB(newSQLiteDB()) }},
+	} {
+		backend := backend
+
+		Context("when using "+backend.name+" storage", func() {
+
+			It("should acquire and renew leases", func() {
+				leaser := backend.newLeaser()
+				for i := 0; i < 2; i++ {
+					held, err := leaser.AcquireLease("settlement", time.Hour)
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(held).Should(BeTrue())
+				}
+				held, err := leaser.AcquireLease("wyre", time.Hour)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(held).Should(BeTrue())
+			})
+		})
+	}
+
+	Context("when leases are stored in a database", func() {
+
+		var db *DB
+
+		BeforeEach(func() {
+			db = newSQLiteDB()
+		})
+
+		It("should not grant leases that are held by another leaser", func() {
+			held, err := NewLeaserWithDB(db).AcquireLease("settlement", time.Hour)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(held).Should(BeTrue())
+
+			held, err = NewLeaserWithDB(db).AcquireLease("settlement", time.Hour)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(held).Should(BeFalse())
+		})
+
+		It("should grant leases that have expired to another leaser", func() {
+			leaser, other := NewLeaserWithDB(db), NewLeaserWithDB(db)
+			held, err := leaser.AcquireLease("settlement", 50*time.Millisecond)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(held).Should(BeTrue())
+
+			Eventually(func() bool {
+				held, err := other.AcquireLease("settlement", time.Hour)
+				Expect(err).ShouldNot(HaveOccurred())
+				return held
+			}).Should(BeTrue())
+
+			held, err = leaser.AcquireLease("settlement", time.Hour)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(held).Should(BeFalse())
+		})
+	})
+})
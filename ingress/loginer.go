@@ -5,49 +5,235 @@ import (
 	"strings"
 	"time"
 
-	_ "github.com/lib/pq"
 	"github.com/satori/go.uuid"
 )
 
+// The schema of the traders table is defined by Migrations.
+
 const (
 	KYCNone  int = 0
 	KYCWyre  int = 1
 	KYCKyber int = 2
 )
 
+// Login is a trader that has logged in to the Ingress.
+type Login struct {
+	Address      string
+	Referrer     string
+	ReferralCode string
+	CreatedAt    int64
+
+	// KYCWyre is the address that holds the Wyre KYC token of the trader, and
+	// KYCKyber is the UID of the Kyber account of the trader. They are empty
+	// if the trader has not been verified by the respective provider.
+	KYCWyre  string
+	KYCKyber int64
+
+	// Authorizer is the address of the trader that authorized this address to
+	// share its verification. It is empty for traders that logged in
+	// directly.
+	Authorizer string
+
+	// LastVerifiedAt is the unix timestamp of the most recent verification of
+	// the trader, or zero if the trader has never been verified.
+	LastVerifiedAt int64
+}
+
+// A KYCAudit records a change to the verification of a trader that was not
+// requested by the trader (e.g. a revocation found by re-verification).
+type KYCAudit struct {
+	Address   string
+	KYCType   int
+	Action    string
+	Reason    string
+	CreatedAt int64
+}
+
+// Actions recorded by a KYCAudit.
+const (
+	KYCAuditRevoked = "revoked"
+)
+
 type Loginer interface {
-	SelectLogin(address string) (int64, string, error)
+	SelectLogin(address string) (Login, error)
 	InsertLogin(address, referrer string) error
 	UpdateLogin(address string, kyberUID int64, kycType int) error
 	Authorize(authorizer, authorizedAddr string) error
+
+	// StaleLogins returns at most limit traders that logged in directly, hold
+	// a verification, and were last verified before the unix timestamp. The
+	// least recently verified traders are returned first.
+	StaleLogins(verifiedBefore int64, limit int) ([]Login, error)
+
+	// RevokeLogin clears the verification of the KYC type from the trader and
+	// the addresses it has authorized, and records a KYCAudit.
+	RevokeLogin(address string, kycType int, reason string) error
+
+	// KYCAudits returns the KYCAudits of the trader, oldest first.
+	KYCAudits(address string) ([]KYCAudit, error)
+
+	// SelectLoginByReferralCode returns the trader with the referral code.
+	SelectLoginByReferralCode(code string) (Login, error)
+
+	// ReferredLogins returns the traders referred by the referrer, in the
+	// order that they logged in.
+	ReferredLogins(referrer string) ([]Login, error)
+
+	// AuthorizedLogins returns the addresses authorized by the authorizer, in
+	// the order that they were authorized.
+	AuthorizedLogins(authorizer string) ([]Login, error)
+
+	// Unauthorize revokes an address authorized by the authorizer. The
+	// verification inherited by the address is cleared, and it no longer
+	// inherits verifications of the authorizer. It returns sql.ErrNoRows if
+	// the address is not authorized by the authorizer.
+	Unauthorize(authorizer, authorizedAddr string) error
 }
 
 type loginer struct {
-	*sql.DB
+	*DB
 }
 
+// NewLoginer returns a Loginer that stores traders in the database at the
+// URL.
 func NewLoginer(databaseURL string) (Loginer, error) {
-	db, err := sql.Open("postgres", databaseURL)
+	db, err := OpenDB(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	return NewLoginerWithDB(db), nil
+}
+
+// NewLoginerWithDB returns a Loginer that stores traders in an open database.
+func NewLoginerWithDB(db *DB) Loginer {
 	return &loginer{
 		db,
-	}, err
+	}
+}
+
+func (loginer *loginer) SelectLogin(address string) (Login, error) {
+	row := loginer.QueryRow("SELECT "+loginColumns+" FROM traders WHERE address=$1", strings.ToLower(address))
+	return scanLogin(row)
+}
+
+func (loginer *loginer) SelectLoginByReferralCode(code string) (Login, error) {
+	row := loginer.QueryRow("SELECT "+loginColumns+" FROM traders WHERE referral_code=$1", code)
+	return scanLogin(row)
 }
 
-func (loginer *loginer) SelectLogin(address string) (int64, string, error) {
-	var sqlKyberUID sql.NullInt64
-	var sqlTimestamp sql.NullString
-	if err := loginer.QueryRow("SELECT kyc_kyber, last_verified_at FROM traders WHERE address=$1", strings.ToLower(address)).Scan(&sqlKyberUID, &sqlTimestamp); err != nil {
-		return 0, "", err
+func (loginer *loginer) ReferredLogins(referrer string) ([]Login, error) {
+	rows, err := loginer.Query("SELECT "+loginColumns+" FROM traders WHERE referrer=$1 ORDER BY created_at", strings.ToLower(referrer))
+	if err != nil {
+		return nil, err
 	}
-	var kyberUID int64
-	var timestamp string
-	if sqlKyberUID.Valid {
-		kyberUID = sqlKyberUID.Int64
+	return scanLogins(rows)
+}
+
+func (loginer *loginer) AuthorizedLogins(authorizer string) ([]Login, error) {
+	rows, err := loginer.Query("SELECT "+loginColumns+" FROM traders WHERE authorizer=$1 ORDER BY created_at", strings.ToLower(authorizer))
+	if err != nil {
+		return nil, err
+	}
+	return scanLogins(rows)
+}
+
+func (loginer *loginer) Unauthorize(authorizer, authorizedAddr string) error {
+	res, err := loginer.Exec("UPDATE traders SET kyc_wyre=NULL, kyc_kyber=NULL, authorizer=NULL, last_verified_at=NULL WHERE address=$1 AND authorizer=$2", strings.ToLower(authorizedAddr), strings.ToLower(authorizer))
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
 	}
-	if sqlTimestamp.Valid {
-		timestamp = sqlTimestamp.String
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (loginer *loginer) StaleLogins(verifiedBefore int64, limit int) ([]Login, error) {
+	rows, err := loginer.Query("SELECT "+loginColumns+" FROM traders WHERE (authorizer IS NULL OR authorizer='') AND (kyc_wyre IS NOT NULL OR kyc_kyber IS NOT NULL) AND last_verified_at < $1 ORDER BY last_verified_at LIMIT $2", verifiedBefore, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanLogins(rows)
+}
+
+func (loginer *loginer) RevokeLogin(address string, kycType int, reason string) error {
+	address = strings.ToLower(address)
+	switch kycType {
+	case KYCWyre:
+		if _, err := loginer.Exec("UPDATE traders SET kyc_wyre=NULL WHERE address=$1 OR authorizer=$1", address); err != nil {
+			return err
+		}
+	case KYCKyber:
+		if _, err := loginer.Exec("UPDATE traders SET kyc_kyber=NULL WHERE address=$1 OR authorizer=$1", address); err != nil {
+			return err
+		}
+	default:
+		return ErrUnknownKYCType
+	}
+	_, err := loginer.Exec("INSERT INTO kyc_audit (address, kyc_type, action, reason, created_at) VALUES ($1, $2, $3, $4, $5)", address, kycType, KYCAuditRevoked, reason, time.Now().Unix())
+	return err
+}
+
+func (loginer *loginer) KYCAudits(address string) ([]KYCAudit, error) {
+	rows, err := loginer.Query("SELECT address, kyc_type, action, reason, created_at FROM kyc_audit WHERE address=$1 ORDER BY created_at", strings.ToLower(address))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	audits := []KYCAudit{}
+	for rows.Next() {
+		audit := KYCAudit{}
+		if err := rows.Scan(&audit.Address, &audit.KYCType, &audit.Action, &audit.Reason, &audit.CreatedAt); err != nil {
+			return nil, err
+		}
+		audits = append(audits, audit)
+	}
+	return audits, rows.Err()
+}
+
+// loginColumns are the columns of the traders table that are scanned by
+// scanLogin.
+const loginColumns = "address, referrer, referral_code, created_at, kyc_wyre, kyc_kyber, authorizer, last_verified_at"
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanLogins scans all rows into Logins and closes the rows.
+func scanLogins(rows *sql.Rows) ([]Login, error) {
+	defer rows.Close()
+
+	logins := []Login{}
+	for rows.Next() {
+		login, err := scanLogin(rows)
+		if err != nil {
+			return nil, err
+		}
+		logins = append(logins, login)
+	}
+	return logins, rows.Err()
+}
+
+func scanLogin(row scanner) (Login, error) {
+	var referrer, referralCode, kycWyre, authorizer sql.NullString
+	var createdAt, kycKyber, lastVerifiedAt sql.NullInt64
+	login := Login{}
+	if err := row.Scan(&login.Address, &referrer, &referralCode, &createdAt, &kycWyre, &kycKyber, &authorizer, &lastVerifiedAt); err != nil {
+		return Login{}, err
 	}
-	return kyberUID, timestamp, nil
+	login.Referrer = referrer.String
+	login.ReferralCode = referralCode.String
+	login.CreatedAt = createdAt.Int64
+	login.KYCWyre = kycWyre.String
+	login.KYCKyber = kycKyber.Int64
+	login.Authorizer = authorizer.String
+	login.LastVerifiedAt = lastVerifiedAt.Int64
+	return login, nil
 }
 
 func (loginer *loginer) InsertLogin(address, referrer string) error {
@@ -83,8 +269,11 @@ func (loginer *loginer) UpdateLogin(address string, kyberUID int64, kycType int)
 	return nil
 }
 
+// Authorize an address to share the verification of the authorizer. An
+// address that has logged in, or that is authorized by another trader, is not
+// changed. Addresses whose authorization was revoked can be authorized again.
 func (loginer *loginer) Authorize(authorizer, authorizedAddr string) error {
 	timestamp := time.Now().Unix()
-	_, err := loginer.Exec("INSERT INTO traders (address, kyc_wyre, kyc_kyber, authorizer, created_at, last_verified_at) SELECT $1,kyc_wyre,kyc_kyber,$2::VARCHAR,$3,last_verified_at FROM traders where address=$2 ON CONFLICT DO NOTHING", strings.ToLower(authorizedAddr), strings.ToLower(authorizer), timestamp)
+	_, err := loginer.Exec("INSERT INTO traders (address, kyc_wyre, kyc_kyber, authorizer, created_at, last_verified_at) SELECT $1,kyc_wyre,kyc_kyber,CAST($2 AS VARCHAR),$3,last_verified_at FROM traders where address=$2 ON CONFLICT (address) DO UPDATE SET kyc_wyre=excluded.kyc_wyre, kyc_kyber=excluded.kyc_kyber, authorizer=excluded.authorizer, last_verified_at=excluded.last_verified_at WHERE traders.authorizer IS NULL AND traders.referral_code IS NULL", strings.ToLower(authorizedAddr), strings.ToLower(authorizer), timestamp)
 	return err
 }
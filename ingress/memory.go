@@ -0,0 +1,1133 @@
+package ingress
+
+import (
+	"database/sql"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/satori/go.uuid"
+)
+
+// memoryTrader is a row of the traders table that is stored in memory.
+type memoryTrader struct {
+	address        string
+	referrer       *string
+	referralCode   string
+	createdAt      int64
+	kycWyre        string
+	kycKyber       *int64
+	authorizer     string
+	lastVerifiedAt *int64
+}
+
+// login returns the Login stored by the memoryTrader.
+func (trader *memoryTrader) login() Login {
+	login := Login{
+		Address:      trader.address,
+		ReferralCode: trader.referralCode,
+		CreatedAt:    trader.createdAt,
+		KYCWyre:      trader.kycWyre,
+		Authorizer:   trader.authorizer,
+	}
+	if trader.referrer != nil {
+		login.Referrer = *trader.referrer
+	}
+	if trader.kycKyber != nil {
+		login.KYCKyber = *trader.kycKyber
+	}
+	if trader.lastVerifiedAt != nil {
+		login.LastVerifiedAt = *trader.lastVerifiedAt
+	}
+	return login
+}
+
+type memoryLoginer struct {
+	mu      *sync.RWMutex
+	traders map[string]*memoryTrader
+	audits  []KYCAudit
+}
+
+// NewMemoryLoginer returns a Loginer that stores traders in memory. It is safe
+// for concurrent use, and is intended for tests and local development.
+func NewMemoryLoginer() Loginer {
+	return &memoryLoginer{
+		mu:      new(sync.RWMutex),
+		traders: map[string]*memoryTrader{},
+	}
+}
+
+func (loginer *memoryLoginer) SelectLogin(address string) (Login, error) {
+	loginer.mu.RLock()
+	defer loginer.mu.RUnlock()
+
+	trader, ok := loginer.traders[strings.ToLower(address)]
+	if !ok {
+		return Login{}, sql.ErrNoRows
+	}
+	return trader.login(), nil
+}
+
+func (loginer *memoryLoginer) InsertLogin(address, referrer string) error {
+	loginer.mu.Lock()
+	defer loginer.mu.Unlock()
+
+	address = strings.ToLower(address)
+	if _, ok := loginer.traders[address]; ok {
+		return nil
+	}
+	referrer = strings.ToLower(referrer)
+	loginer.traders[address] = &memoryTrader{
+		address:      address,
+		referrer:     &referrer,
+		referralCode: uuid.NewV4().String(),
+		createdAt:    time.Now().Unix(),
+	}
+	return nil
+}
+
+func (loginer *memoryLoginer) UpdateLogin(address string, kyberUID int64, kycType int) error {
+	loginer.mu.Lock()
+	defer loginer.mu.Unlock()
+
+	timestamp := time.Now().Unix()
+	address = strings.ToLower(address)
+	switch kycType {
+	case KYCWyre:
+		for _, trader := range loginer.traders {
+			if trader.address == address || trader.authorizer == address {
+				trader.kycWyre = address
+				trader.lastVerifiedAt = &timestamp
+			}
+		}
+	case KYCKyber:
+		for _, trader := range loginer.traders {
+			if trader.address == address || trader.authorizer == address {
+				uid := kyberUID
+				trader.kycKyber = &uid
+				trader.lastVerifiedAt = &timestamp
+			}
+		}
+
+		// Use original referral code.
+		linked := loginer.tradersWithKyberUID(kyberUID)
+		if len(linked) == 0 {
+			return sql.ErrNoRows
+		}
+		if referrer := linked[0].referrer; referrer != nil {
+			for _, trader := range linked {
+				trader.referrer = referrer
+			}
+		}
+	}
+	return nil
+}
+
+func (loginer *memoryLoginer) Authorize(authorizer, authorizedAddr string) error {
+	loginer.mu.Lock()
+	defer loginer.mu.Unlock()
+
+	authorizer = strings.ToLower(authorizer)
+	authorizedAddr = strings.ToLower(authorizedAddr)
+	trader, ok := loginer.traders[authorizer]
+	if !ok {
+		return nil
+	}
+	authorized, ok := loginer.traders[authorizedAddr]
+	if ok && (authorized.authorizer != "" || authorized.referralCode != "") {
+		return nil
+	}
+	if !ok {
+		authorized = &memoryTrader{
+			address:   authorizedAddr,
+			createdAt: time.Now().Unix(),
+		}
+		loginer.traders[authorizedAddr] = authorized
+	}
+	authorized.kycWyre = trader.kycWyre
+	authorized.kycKyber = trader.kycKyber
+	authorized.authorizer = authorizer
+	authorized.lastVerifiedAt = trader.lastVerifiedAt
+	return nil
+}
+
+func (loginer *memoryLoginer) AuthorizedLogins(authorizer string) ([]Login, error) {
+	loginer.mu.RLock()
+	defer loginer.mu.RUnlock()
+
+	authorizer = strings.ToLower(authorizer)
+	logins := []Login{}
+	for _, trader := range loginer.traders {
+		if trader.authorizer != "" && trader.authorizer == authorizer {
+			logins = append(logins, trader.login())
+		}
+	}
+	sort.Slice(logins, func(i, j int) bool {
+		return logins[i].CreatedAt < logins[j].CreatedAt
+	})
+	return logins, nil
+}
+
+func (loginer *memoryLoginer) Unauthorize(authorizer, authorizedAddr string) error {
+	loginer.mu.Lock()
+	defer loginer.mu.Unlock()
+
+	trader, ok := loginer.traders[strings.ToLower(authorizedAddr)]
+	if !ok || trader.authorizer == "" || trader.authorizer != strings.ToLower(authorizer) {
+		return sql.ErrNoRows
+	}
+	trader.kycWyre = ""
+	trader.kycKyber = nil
+	trader.authorizer = ""
+	trader.lastVerifiedAt = nil
+	return nil
+}
+
+func (loginer *memoryLoginer) SelectLoginByReferralCode(code string) (Login, error) {
+	loginer.mu.RLock()
+	defer loginer.mu.RUnlock()
+
+	for _, trader := range loginer.traders {
+		if trader.referralCode != "" && trader.referralCode == code {
+			return trader.login(), nil
+		}
+	}
+	return Login{}, sql.ErrNoRows
+}
+
+func (loginer *memoryLoginer) ReferredLogins(referrer string) ([]Login, error) {
+	loginer.mu.RLock()
+	defer loginer.mu.RUnlock()
+
+	referrer = strings.ToLower(referrer)
+	logins := []Login{}
+	for _, trader := range loginer.traders {
+		if trader.referrer != nil && *trader.referrer == referrer {
+			logins = append(logins, trader.login())
+		}
+	}
+	sort.Slice(logins, func(i, j int) bool {
+		return logins[i].CreatedAt < logins[j].CreatedAt
+	})
+	return logins, nil
+}
+
+func (loginer *memoryLoginer) StaleLogins(verifiedBefore int64, limit int) ([]Login, error) {
+	loginer.mu.RLock()
+	defer loginer.mu.RUnlock()
+
+	logins := []Login{}
+	for _, trader := range loginer.traders {
+		if trader.authorizer != "" || (trader.kycWyre == "" && trader.kycKyber == nil) {
+			continue
+		}
+		if trader.lastVerifiedAt == nil || *trader.lastVerifiedAt >= verifiedBefore {
+			continue
+		}
+		logins = append(logins, trader.login())
+	}
+	sort.Slice(logins, func(i, j int) bool {
+		return logins[i].LastVerifiedAt < logins[j].LastVerifiedAt
+	})
+	if len(logins) > limit {
+		logins = logins[:limit]
+	}
+	return logins, nil
+}
+
+func (loginer *memoryLoginer) RevokeLogin(address string, kycType int, reason string) error {
+	loginer.mu.Lock()
+	defer loginer.mu.Unlock()
+
+	address = strings.ToLower(address)
+	if kycType != KYCWyre && kycType != KYCKyber {
+		return ErrUnknownKYCType
+	}
+	for _, trader := range loginer.traders {
+		if trader.address != address && trader.authorizer != address {
+			continue
+		}
+		if kycType == KYCWyre {
+			trader.kycWyre = ""
+		} else {
+			trader.kycKyber = nil
+		}
+	}
+	loginer.audits = append(loginer.audits, KYCAudit{
+		Address:   address,
+		KYCType:   kycType,
+		Action:    KYCAuditRevoked,
+		Reason:    reason,
+		CreatedAt: time.Now().Unix(),
+	})
+	return nil
+}
+
+func (loginer *memoryLoginer) KYCAudits(address string) ([]KYCAudit, error) {
+	loginer.mu.RLock()
+	defer loginer.mu.RUnlock()
+
+	address = strings.ToLower(address)
+	audits := []KYCAudit{}
+	for _, audit := range loginer.audits {
+		if audit.Address == address {
+			audits = append(audits, audit)
+		}
+	}
+	return audits, nil
+}
+
+// tradersWithKyberUID returns the traders linked to a Kyber account, ordered
+// by the time at which they were created.
+func (loginer *memoryLoginer) tradersWithKyberUID(kyberUID int64) []*memoryTrader {
+	traders := []*memoryTrader{}
+	for _, trader := range loginer.traders {
+		if trader.kycKyber != nil && *trader.kycKyber == kyberUID {
+			traders = append(traders, trader)
+		}
+	}
+	sort.Slice(traders, func(i, j int) bool {
+		return traders[i].createdAt < traders[j].createdAt
+	})
+	return traders
+}
+
+type memorySwapper struct {
+	mu             *sync.RWMutex
+	binder         SwapContractBinder
+	notifier       Notifier
+	partialSwaps   map[string]PartialSwap
+	finalizedSwaps map[string]memoryFinalizedSwap
+}
+
+// memoryFinalizedSwap is a row of the finalized_swap table that is stored in
+// memory.
+type memoryFinalizedSwap struct {
+	swap     FinalizedSwap
+	canceled bool
+}
+
+// NewMemorySwapper returns a Swapper that stores partial swaps in memory, and
+// notifies the Notifier of finalized swaps. It is safe for concurrent use, and
+// is intended for tests and local development.
+func NewMemorySwapper(binder SwapContractBinder, notifier Notifier) Swapper {
+	return &memorySwapper{
+		mu:             new(sync.RWMutex),
+		binder:         binder,
+		notifier:       notifier,
+		partialSwaps:   map[string]PartialSwap{},
+		finalizedSwaps: map[string]memoryFinalizedSwap{},
+	}
+}
+
+func (swapper *memorySwapper) InsertPartialSwap(swap PartialSwap) error {
+	swapper.mu.Lock()
+	defer swapper.mu.Unlock()
+
+	if stored, ok := swapper.partialSwaps[swap.OrderID]; ok {
+		return checkPartialSwap(stored, swap)
+	}
+	for _, stored := range swapper.partialSwaps {
+		if stored.SecretHash == swap.SecretHash {
+			return ErrSecretHashReused
+		}
+	}
+	swapper.partialSwaps[swap.OrderID] = swap
+	return nil
+}
+
+func (swapper *memorySwapper) PartialSwap(id string) (PartialSwap, error) {
+	swapper.mu.RLock()
+	defer swapper.mu.RUnlock()
+
+	swap, ok := swapper.partialSwaps[id]
+	if !ok {
+		return PartialSwap{OrderID: id}, sql.ErrNoRows
+	}
+	return swap, nil
+}
+
+func (swapper *memorySwapper) FinalizedSwap(id string) (FinalizedSwap, bool, error) {
+	swapper.mu.RLock()
+	finalized, ok := swapper.finalizedSwaps[id]
+	swapper.mu.RUnlock()
+	if ok {
+		return finalized.swap, finalized.canceled, nil
+	}
+
+	swap, canceled, err := finalizeSwap(swapper.binder, swapper, id)
+	if err != nil {
+		return FinalizedSwap{}, false, err
+	}
+
+	swapper.mu.Lock()
+	if finalized, ok := swapper.finalizedSwaps[id]; ok {
+		swapper.mu.Unlock()
+		return finalized.swap, finalized.canceled, nil
+	}
+	swapper.finalizedSwaps[id] = memoryFinalizedSwap{swap: swap, canceled: canceled}
+	swapper.mu.Unlock()
+
+	if !canceled {
+		if err := notifyFinalizedSwap(swapper.notifier, swapper, swap); err != nil {
+			log.Printf("[error] (swapper) cannot notify finalized swap of order = %v: %v", id, err)
+		}
+	}
+	return swap, canceled, nil
+}
+
+func (swapper *memorySwapper) SwapStatus(id string) (SwapStatus, error) {
+	return swapStatus(swapper.binder, swapper, id)
+}
+
+func (swapper *memorySwapper) MarkRefundableSwaps(now int64) ([]RefundableSwap, error) {
+	swapper.mu.Lock()
+	defer swapper.mu.Unlock()
+
+	marked := []RefundableSwap{}
+	for id, finalized := range swapper.finalizedSwaps {
+		if finalized.canceled || finalized.swap.RefundableAt != 0 || finalized.swap.TimeLock > now {
+			continue
+		}
+		finalized.swap.RefundableAt = now
+		swapper.finalizedSwaps[id] = finalized
+		marked = append(marked, RefundableSwap{
+			OrderID:      id,
+			KycAddr:      swapper.partialSwaps[id].KycAddr,
+			TimeLock:     finalized.swap.TimeLock,
+			RefundableAt: now,
+		})
+	}
+	sort.Slice(marked, func(i, j int) bool {
+		return marked[i].OrderID < marked[j].OrderID
+	})
+	return marked, nil
+}
+
+func (swapper *memorySwapper) RefundableSwaps() ([]RefundableSwap, error) {
+	swapper.mu.RLock()
+	defer swapper.mu.RUnlock()
+
+	swaps := []RefundableSwap{}
+	for id, finalized := range swapper.finalizedSwaps {
+		if finalized.swap.RefundableAt == 0 {
+			continue
+		}
+		swaps = append(swaps, RefundableSwap{
+			OrderID:      id,
+			KycAddr:      swapper.partialSwaps[id].KycAddr,
+			TimeLock:     finalized.swap.TimeLock,
+			RefundableAt: finalized.swap.RefundableAt,
+		})
+	}
+	sort.Slice(swaps, func(i, j int) bool {
+		if swaps[i].RefundableAt != swaps[j].RefundableAt {
+			return swaps[i].RefundableAt < swaps[j].RefundableAt
+		}
+		return swaps[i].OrderID < swaps[j].OrderID
+	})
+	return swaps, nil
+}
+
+func (swapper *memorySwapper) UnfinalizedPartialSwaps(before int64) ([]string, error) {
+	swapper.mu.RLock()
+	defer swapper.mu.RUnlock()
+
+	ids := []string{}
+	for id, swap := range swapper.partialSwaps {
+		registeredAt := swap.CreatedAt
+		if registeredAt == 0 {
+			registeredAt = swap.TimeLock
+		}
+		if _, ok := swapper.finalizedSwaps[id]; ok || registeredAt >= before {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func (swapper *memorySwapper) DeletePartialSwap(id string) error {
+	swapper.mu.Lock()
+	defer swapper.mu.Unlock()
+
+	delete(swapper.partialSwaps, id)
+	return nil
+}
+
+type memoryApprover struct {
+	mu      *sync.RWMutex
+	traders map[string]ApprovedTrader
+}
+
+// NewMemoryApprover returns an Approver that stores approved traders in
+// memory. The seed traders are approved when the Approver is created.
+func NewMemoryApprover(seed []string) Approver {
+	approver := &memoryApprover{
+		mu:      new(sync.RWMutex),
+		traders: map[string]ApprovedTrader{},
+	}
+	timestamp := time.Now().Unix()
+	for _, address := range seed {
+		approver.traders[normalizeAddress(address)] = ApprovedTrader{
+			Address:    normalizeAddress(address),
+			ApprovedBy: ApprovedTraderSeeder,
+			CreatedAt:  timestamp,
+		}
+	}
+	return approver
+}
+
+func (approver *memoryApprover) ApprovedTraders() ([]ApprovedTrader, error) {
+	approver.mu.RLock()
+	defer approver.mu.RUnlock()
+
+	traders := make([]ApprovedTrader, 0, len(approver.traders))
+	for _, trader := range approver.traders {
+		traders = append(traders, trader)
+	}
+	sort.Slice(traders, func(i, j int) bool {
+		return traders[i].CreatedAt < traders[j].CreatedAt
+	})
+	return traders, nil
+}
+
+func (approver *memoryApprover) InsertApprovedTrader(trader ApprovedTrader) error {
+	approver.mu.Lock()
+	defer approver.mu.Unlock()
+
+	trader.Address = normalizeAddress(trader.Address)
+	if existing, ok := approver.traders[trader.Address]; ok {
+		trader.CreatedAt = existing.CreatedAt
+	}
+	if trader.CreatedAt == 0 {
+		trader.CreatedAt = time.Now().Unix()
+	}
+	approver.traders[trader.Address] = trader
+	return nil
+}
+
+func (approver *memoryApprover) DeleteApprovedTrader(address string) error {
+	approver.mu.Lock()
+	defer approver.mu.Unlock()
+
+	address = normalizeAddress(address)
+	if _, ok := approver.traders[address]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(approver.traders, address)
+	return nil
+}
+
+func (approver *memoryApprover) TraderApproved(address string) (bool, error) {
+	approver.mu.RLock()
+	defer approver.mu.RUnlock()
+
+	trader, ok := approver.traders[normalizeAddress(address)]
+	if !ok {
+		return false, nil
+	}
+	return !trader.Expired(time.Now()), nil
+}
+
+type memoryCursorer struct {
+	mu      *sync.RWMutex
+	cursors map[string]uint64
+}
+
+// NewMemoryCursorer returns a Cursorer that stores cursors in memory. It is
+// safe for concurrent use, and is intended for tests and local development.
+func NewMemoryCursorer() Cursorer {
+	return &memoryCursorer{
+		mu:      new(sync.RWMutex),
+		cursors: map[string]uint64{},
+	}
+}
+
+func (cursorer *memoryCursorer) Cursor(name string) (uint64, bool, error) {
+	cursorer.mu.RLock()
+	defer cursorer.mu.RUnlock()
+
+	block, ok := cursorer.cursors[name]
+	return block, ok, nil
+}
+
+func (cursorer *memoryCursorer) UpdateCursor(name string, block uint64) error {
+	cursorer.mu.Lock()
+	defer cursorer.mu.Unlock()
+
+	cursorer.cursors[name] = block
+	return nil
+}
+
+type memoryLeaser struct{}
+
+// NewMemoryLeaser returns a Leaser for jobs that store data in memory. Data
+// in memory is only used by one process, so its leases are always acquired.
+func NewMemoryLeaser() Leaser {
+	return memoryLeaser{}
+}
+
+func (memoryLeaser) AcquireLease(name string, duration time.Duration) (bool, error) {
+	return true, nil
+}
+
+type memoryBroadcaster struct{}
+
+// NewMemoryBroadcaster returns a Broadcaster for a process that stores data in
+// memory. Data in memory is only used by one process, so there are no other
+// processes to broadcast Events to.
+func NewMemoryBroadcaster() Broadcaster {
+	return memoryBroadcaster{}
+}
+
+func (memoryBroadcaster) Broadcast(event Event) error {
+	return nil
+}
+
+func (memoryBroadcaster) Received() ([]Event, error) {
+	return []Event{}, nil
+}
+
+func (memoryBroadcaster) PurgeBroadcasts() error {
+	return nil
+}
+
+type memoryNoncer struct {
+	mu     *sync.Mutex
+	nonces map[string]int64
+}
+
+// NewMemoryNoncer returns a Noncer that stores nonces in memory. It is safe
+// for concurrent use, and is intended for tests and local development.
+func NewMemoryNoncer() Noncer {
+	return &memoryNoncer{
+		mu:     new(sync.Mutex),
+		nonces: map[string]int64{},
+	}
+}
+
+func (noncer *memoryNoncer) InsertNonce(nonce string, expiresAt int64) error {
+	noncer.mu.Lock()
+	defer noncer.mu.Unlock()
+
+	now := time.Now().Unix()
+	for n, exp := range noncer.nonces {
+		if exp <= now {
+			delete(noncer.nonces, n)
+		}
+	}
+	noncer.nonces[nonce] = expiresAt
+	return nil
+}
+
+func (noncer *memoryNoncer) ConsumeNonce(nonce string) error {
+	noncer.mu.Lock()
+	defer noncer.mu.Unlock()
+
+	expiresAt, ok := noncer.nonces[nonce]
+	if !ok {
+		return ErrInvalidNonce
+	}
+	delete(noncer.nonces, nonce)
+	if expiresAt <= time.Now().Unix() {
+		return ErrInvalidNonce
+	}
+	return nil
+}
+
+type memorySession struct {
+	trader    string
+	expiresAt int64
+}
+
+type memorySessioner struct {
+	mu       *sync.Mutex
+	sessions map[string]memorySession
+}
+
+// NewMemorySessioner returns a Sessioner that stores sessions in memory. It is
+// safe for concurrent use, and is intended for tests and local development.
+func NewMemorySessioner() Sessioner {
+	return &memorySessioner{
+		mu:       new(sync.Mutex),
+		sessions: map[string]memorySession{},
+	}
+}
+
+func (sessioner *memorySessioner) InsertSession(token, trader string, expiresAt int64) error {
+	sessioner.mu.Lock()
+	defer sessioner.mu.Unlock()
+
+	now := time.Now().Unix()
+	for t, session := range sessioner.sessions {
+		if session.expiresAt <= now {
+			delete(sessioner.sessions, t)
+		}
+	}
+	sessioner.sessions[token] = memorySession{normalizeAddress(trader), expiresAt}
+	return nil
+}
+
+func (sessioner *memorySessioner) SessionTrader(token string) (string, error) {
+	sessioner.mu.Lock()
+	defer sessioner.mu.Unlock()
+
+	session, ok := sessioner.sessions[token]
+	if !ok || session.expiresAt <= time.Now().Unix() {
+		return "", ErrInvalidSession
+	}
+	return session.trader, nil
+}
+
+type memoryWebhooker struct {
+	mu         *sync.RWMutex
+	webhooks   map[string]Webhook
+	orders     map[string]WebhookOrder
+	deliveries map[[2]string]WebhookDelivery
+	claims     map[[2]string]int64
+}
+
+// NewMemoryWebhooker returns a Webhooker that stores webhooks in memory. It is
+// safe for concurrent use, and is intended for tests and local development.
+func NewMemoryWebhooker() Webhooker {
+	return &memoryWebhooker{
+		mu:         new(sync.RWMutex),
+		webhooks:   map[string]Webhook{},
+		orders:     map[string]WebhookOrder{},
+		deliveries: map[[2]string]WebhookDelivery{},
+		claims:     map[[2]string]int64{},
+	}
+}
+
+func (webhooker *memoryWebhooker) InsertWebhook(webhook Webhook) error {
+	webhooker.mu.Lock()
+	defer webhooker.mu.Unlock()
+
+	webhook.Trader = normalizeAddress(webhook.Trader)
+	webhook.RegisteredBy = normalizeAddress(webhook.RegisteredBy)
+	webhooker.webhooks[webhook.ID] = webhook
+	return nil
+}
+
+func (webhooker *memoryWebhooker) Webhook(id string) (Webhook, error) {
+	webhooker.mu.RLock()
+	defer webhooker.mu.RUnlock()
+
+	webhook, ok := webhooker.webhooks[id]
+	if !ok {
+		return Webhook{}, sql.ErrNoRows
+	}
+	return webhook, nil
+}
+
+func (webhooker *memoryWebhooker) Webhooks(trader string) ([]Webhook, error) {
+	webhooker.mu.RLock()
+	defer webhooker.mu.RUnlock()
+
+	trader = normalizeAddress(trader)
+	webhooks := []Webhook{}
+	for _, webhook := range webhooker.webhooks {
+		if webhook.Trader == trader {
+			webhook.Secret = ""
+			webhooks = append(webhooks, webhook)
+		}
+	}
+	sort.Slice(webhooks, func(i, j int) bool {
+		if webhooks[i].CreatedAt != webhooks[j].CreatedAt {
+			return webhooks[i].CreatedAt < webhooks[j].CreatedAt
+		}
+		return webhooks[i].ID < webhooks[j].ID
+	})
+	return webhooks, nil
+}
+
+func (webhooker *memoryWebhooker) DeleteWebhook(id string) error {
+	webhooker.mu.Lock()
+	defer webhooker.mu.Unlock()
+
+	if _, ok := webhooker.webhooks[id]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(webhooker.webhooks, id)
+	for key, delivery := range webhooker.deliveries {
+		if delivery.WebhookID == id && delivery.DeliveredAt == 0 && delivery.FailedAt == 0 {
+			delete(webhooker.deliveries, key)
+		}
+	}
+	return nil
+}
+
+func (webhooker *memoryWebhooker) InsertWebhookOrder(order WebhookOrder) error {
+	webhooker.mu.Lock()
+	defer webhooker.mu.Unlock()
+
+	if _, ok := webhooker.orders[order.OrderID]; ok {
+		return nil
+	}
+	order.Trader = normalizeAddress(order.Trader)
+	webhooker.orders[order.OrderID] = order
+	return nil
+}
+
+func (webhooker *memoryWebhooker) WebhookOrder(orderID string) (WebhookOrder, error) {
+	webhooker.mu.RLock()
+	defer webhooker.mu.RUnlock()
+
+	order, ok := webhooker.orders[orderID]
+	if !ok {
+		return WebhookOrder{}, sql.ErrNoRows
+	}
+	return order, nil
+}
+
+func (webhooker *memoryWebhooker) UnconfirmedWebhookOrders() ([]WebhookOrder, error) {
+	webhooker.mu.RLock()
+	defer webhooker.mu.RUnlock()
+
+	orders := []WebhookOrder{}
+	for _, order := range webhooker.orders {
+		if !order.Confirmed {
+			orders = append(orders, order)
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		if orders[i].CreatedAt != orders[j].CreatedAt {
+			return orders[i].CreatedAt < orders[j].CreatedAt
+		}
+		return orders[i].OrderID < orders[j].OrderID
+	})
+	return orders, nil
+}
+
+func (webhooker *memoryWebhooker) ConfirmWebhookOrder(orderID string) error {
+	webhooker.mu.Lock()
+	defer webhooker.mu.Unlock()
+
+	if order, ok := webhooker.orders[orderID]; ok {
+		order.Confirmed = true
+		webhooker.orders[orderID] = order
+	}
+	return nil
+}
+
+func (webhooker *memoryWebhooker) DeleteWebhookOrder(orderID string) error {
+	webhooker.mu.Lock()
+	defer webhooker.mu.Unlock()
+
+	delete(webhooker.orders, orderID)
+	return nil
+}
+
+func (webhooker *memoryWebhooker) InsertWebhookDelivery(delivery WebhookDelivery) error {
+	webhooker.mu.Lock()
+	defer webhooker.mu.Unlock()
+
+	key := [2]string{delivery.WebhookID, delivery.EventID}
+	if _, ok := webhooker.deliveries[key]; ok {
+		return nil
+	}
+	webhooker.deliveries[key] = delivery
+	return nil
+}
+
+func (webhooker *memoryWebhooker) PendingWebhookDeliveries(now int64, limit int) ([]WebhookDelivery, error) {
+	webhooker.mu.RLock()
+	defer webhooker.mu.RUnlock()
+
+	deliveries := []WebhookDelivery{}
+	for key, delivery := range webhooker.deliveries {
+		if delivery.DeliveredAt == 0 && delivery.FailedAt == 0 && delivery.NextAttemptAt <= now && webhooker.claims[key] <= now {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	sortWebhookDeliveries(deliveries, func(delivery WebhookDelivery) int64 { return delivery.NextAttemptAt })
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, nil
+}
+
+func (webhooker *memoryWebhooker) ClaimWebhookDelivery(delivery WebhookDelivery, now, claimedUntil int64) (bool, error) {
+	webhooker.mu.Lock()
+	defer webhooker.mu.Unlock()
+
+	key := [2]string{delivery.WebhookID, delivery.EventID}
+	stored, ok := webhooker.deliveries[key]
+	if !ok || stored.Attempts != delivery.Attempts || stored.DeliveredAt != 0 || stored.FailedAt != 0 || webhooker.claims[key] > now {
+		return false, nil
+	}
+	webhooker.claims[key] = claimedUntil
+	return true, nil
+}
+
+func (webhooker *memoryWebhooker) UpdateWebhookDelivery(delivery WebhookDelivery) error {
+	webhooker.mu.Lock()
+	defer webhooker.mu.Unlock()
+
+	key := [2]string{delivery.WebhookID, delivery.EventID}
+	stored, ok := webhooker.deliveries[key]
+	if !ok {
+		return nil
+	}
+	delete(webhooker.claims, key)
+	stored.Attempts = delivery.Attempts
+	stored.NextAttemptAt = delivery.NextAttemptAt
+	stored.LastError = delivery.LastError
+	stored.DeliveredAt = delivery.DeliveredAt
+	stored.FailedAt = delivery.FailedAt
+	webhooker.deliveries[key] = stored
+	return nil
+}
+
+func (webhooker *memoryWebhooker) FailedWebhookDeliveries() ([]WebhookDelivery, error) {
+	webhooker.mu.RLock()
+	defer webhooker.mu.RUnlock()
+
+	deliveries := []WebhookDelivery{}
+	for _, delivery := range webhooker.deliveries {
+		if delivery.FailedAt != 0 {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	sortWebhookDeliveries(deliveries, func(delivery WebhookDelivery) int64 { return delivery.FailedAt })
+	return deliveries, nil
+}
+
+// sortWebhookDeliveries sorts deliveries by a timestamp, and then by their
+// webhook and event, in the same order as the database.
+func sortWebhookDeliveries(deliveries []WebhookDelivery, timestamp func(WebhookDelivery) int64) {
+	sort.Slice(deliveries, func(i, j int) bool {
+		if timestamp(deliveries[i]) != timestamp(deliveries[j]) {
+			return timestamp(deliveries[i]) < timestamp(deliveries[j])
+		}
+		if deliveries[i].WebhookID != deliveries[j].WebhookID {
+			return deliveries[i].WebhookID < deliveries[j].WebhookID
+		}
+		return deliveries[i].EventID < deliveries[j].EventID
+	})
+}
+
+type memoryOrderer struct {
+	mu     *sync.RWMutex
+	binder OrderContractBinder
+	orders map[string]ApprovedOrder
+}
+
+// NewMemoryOrderer returns an Orderer that stores approved orders in memory.
+// It is safe for concurrent use, and is intended for tests and local
+// development.
+func NewMemoryOrderer(binder OrderContractBinder) Orderer {
+	return &memoryOrderer{
+		mu:     new(sync.RWMutex),
+		binder: binder,
+		orders: map[string]ApprovedOrder{},
+	}
+}
+
+func (orderer *memoryOrderer) InsertOrder(order ApprovedOrder) error {
+	orderer.mu.Lock()
+	defer orderer.mu.Unlock()
+
+	if _, ok := orderer.orders[order.OrderID]; !ok {
+		order.Trader = normalizeAddress(order.Trader)
+		orderer.orders[order.OrderID] = order
+	}
+	return nil
+}
+
+func (orderer *memoryOrderer) ApprovedOrder(orderID string) (ApprovedOrder, error) {
+	orderer.mu.RLock()
+	defer orderer.mu.RUnlock()
+
+	order, ok := orderer.orders[orderID]
+	if !ok {
+		return ApprovedOrder{}, sql.ErrNoRows
+	}
+	return order, nil
+}
+
+func (orderer *memoryOrderer) ApprovedOrders(trader string, offset, limit int) ([]ApprovedOrder, error) {
+	orderer.mu.RLock()
+	defer orderer.mu.RUnlock()
+
+	trader = normalizeAddress(trader)
+	orders := []ApprovedOrder{}
+	for _, order := range orderer.orders {
+		if order.Trader == trader {
+			orders = append(orders, order)
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		if orders[i].ApprovedAt != orders[j].ApprovedAt {
+			return orders[i].ApprovedAt > orders[j].ApprovedAt
+		}
+		return orders[i].OrderID > orders[j].OrderID
+	})
+	if offset >= len(orders) {
+		return []ApprovedOrder{}, nil
+	}
+	orders = orders[offset:]
+	if limit < len(orders) {
+		orders = orders[:limit]
+	}
+	return orders, nil
+}
+
+func (orderer *memoryOrderer) TraderOrders(trader string, query OrderQuery) (OrderPage, error) {
+	return traderOrders(orderer.binder, orderer, trader, query)
+}
+
+// memorySettlement is a row of the indexed_settlements table that is stored
+// in memory.
+type memorySettlement struct {
+	blockNumber uint64
+	details     SettlementDetails
+}
+
+type memoryOrderIndex struct {
+	mu          *sync.RWMutex
+	orders      map[string]IndexedOrder
+	settlements map[string]memorySettlement
+}
+
+// NewMemoryOrderIndex returns an OrderIndex that stores orders in memory. It
+// is safe for concurrent use, and is intended for tests and local
+// development.
+func NewMemoryOrderIndex() OrderIndex {
+	return &memoryOrderIndex{
+		mu:          new(sync.RWMutex),
+		orders:      map[string]IndexedOrder{},
+		settlements: map[string]memorySettlement{},
+	}
+}
+
+func (index *memoryOrderIndex) NextOrderPosition() (uint64, error) {
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	next := uint64(0)
+	for _, order := range index.orders {
+		if order.Position >= next {
+			next = order.Position + 1
+		}
+	}
+	return next, nil
+}
+
+func (index *memoryOrderIndex) InsertIndexedOrder(order IndexedOrder) error {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	if _, ok := index.orders[order.OrderID]; !ok {
+		order.Trader = normalizeAddress(order.Trader)
+		order.SettledAt, order.Settlement = 0, nil
+		index.orders[order.OrderID] = order
+	}
+	return nil
+}
+
+func (index *memoryOrderIndex) UpdateIndexedOrder(order IndexedOrder) error {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	stored, ok := index.orders[order.OrderID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	stored.State = order.State
+	stored.BlockNumber = order.BlockNumber
+	stored.Confirmer = order.Confirmer
+	stored.MatchID = order.MatchID
+	stored.PendingState = order.PendingState
+	stored.PendingBlock = order.PendingBlock
+	index.orders[order.OrderID] = stored
+	return nil
+}
+
+func (index *memoryOrderIndex) OpenIndexedOrders() ([]IndexedOrder, error) {
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	orders := []IndexedOrder{}
+	for _, order := range index.orders {
+		if order.State == OrderStateOpen {
+			orders = append(orders, index.indexedOrder(order))
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		return orders[i].Position < orders[j].Position
+	})
+	return orders, nil
+}
+
+func (index *memoryOrderIndex) InsertIndexedSettlement(orderID string, blockNumber uint64, details SettlementDetails) error {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	if _, ok := index.settlements[orderID]; !ok {
+		index.settlements[orderID] = memorySettlement{blockNumber, details}
+	}
+	return nil
+}
+
+func (index *memoryOrderIndex) IndexedOrder(orderID string) (IndexedOrder, error) {
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	order, ok := index.orders[orderID]
+	if !ok {
+		return IndexedOrder{}, sql.ErrNoRows
+	}
+	return index.indexedOrder(order), nil
+}
+
+func (index *memoryOrderIndex) IndexedOrders(query IndexQuery) (IndexedOrderPage, error) {
+	switch query.State {
+	case "", OrderStateOpen, OrderStateConfirmed, OrderStateSettled, OrderStateCanceled:
+	default:
+		return IndexedOrderPage{}, ErrUnknownOrderState
+	}
+
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	trader := normalizeAddress(query.Trader)
+	orders := []IndexedOrder{}
+	for _, order := range index.orders {
+		order = index.indexedOrder(order)
+		if query.Trader != "" && order.Trader != trader {
+			continue
+		}
+		if query.State != "" && order.State != query.State {
+			continue
+		}
+		if order.BlockNumber < query.FromBlock || (query.ToBlock > 0 && order.BlockNumber > query.ToBlock) {
+			continue
+		}
+		orders = append(orders, order)
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		return orders[i].Position > orders[j].Position
+	})
+	if query.Offset >= len(orders) {
+		return newIndexedOrderPage([]IndexedOrder{}, query), nil
+	}
+	orders = orders[query.Offset:]
+	if query.Limit+1 < len(orders) {
+		orders = orders[:query.Limit+1]
+	}
+	return newIndexedOrderPage(orders, query), nil
+}
+
+// indexedOrder returns the order with its settlement. It must be called while
+// holding the mutex.
+func (index *memoryOrderIndex) indexedOrder(order IndexedOrder) IndexedOrder {
+	if settlement, ok := index.settlements[order.OrderID]; ok {
+		order.settle(settlement.blockNumber, settlement.details)
+	}
+	return order
+}
@@ -0,0 +1,555 @@
+package ingress
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Migration is a versioned change to the database schema. Migrations are
+// compiled into the binary so that a new database can be bootstrapped without
+// any other files. Statements must be valid for every Dialect and, once a
+// Migration has been released, it must never be changed.
+type Migration struct {
+	Version    int
+	Name       string
+	Statements []string
+}
+
+// Checksum of the statements of the Migration. It is recorded when the
+// Migration is applied, and used to detect Migrations that have been changed
+// after they were applied.
+func (migration Migration) Checksum() string {
+	hash := sha256.Sum256([]byte(strings.Join(migration.Statements, ";\n")))
+	return hex.EncodeToString(hash[:])
+}
+
+// Migrations that define the database schema, in order of version. The first
+// Migration uses CREATE TABLE IF NOT EXISTS so that it can be applied to
+// databases that were created before migrations were introduced.
+var Migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "create traders, swaps and withdrawals",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS traders (
+				address          varchar PRIMARY KEY,
+				referrer         varchar,
+				referral_code    varchar,
+				created_at       bigint,
+				kyc_wyre         varchar,
+				kyc_kyber        bigint,
+				authorizer       varchar,
+				last_verified_at bigint
+			)`,
+			`CREATE TABLE IF NOT EXISTS partial_swap (
+				order_id     varchar PRIMARY KEY,
+				kyc_addr     varchar,
+				send_to      varchar,
+				receive_from varchar,
+				time_lock    int,
+				secret_hash  varchar
+			)`,
+			`CREATE TABLE IF NOT EXISTS finalized_swap (
+				order_id              varchar PRIMARY KEY,
+				send_to               varchar,
+				receive_from          varchar,
+				send_amount           varchar,
+				receive_amount        varchar,
+				secret_hash           varchar,
+				should_initiate_first boolean,
+				time_lock             int
+			)`,
+			`CREATE TABLE IF NOT EXISTS withdrawals (
+				hash      bytea,
+				address   varchar(42),
+				token     int,
+				amount    varchar,
+				timestamp bigint,
+				nonce     int
+			)`,
+		},
+	},
+	{
+		Version: 2,
+		Name:    "create approved traders",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS approved_traders (
+				address     varchar(42) PRIMARY KEY,
+				note        varchar,
+				approved_by varchar,
+				created_at  bigint,
+				expires_at  bigint
+			)`,
+		},
+	},
+	{
+		Version: 3,
+		Name:    "create kyc audit",
+		Statements: []string{
+			`CREATE TABLE kyc_audit (
+				address    varchar(42),
+				kyc_type   int,
+				action     varchar,
+				reason     varchar,
+				created_at bigint
+			)`,
+			`CREATE INDEX kyc_audit_address ON kyc_audit (address)`,
+		},
+	},
+	{
+		Version: 4,
+		Name:    "create watch cursors",
+		Statements: []string{
+			`CREATE TABLE watch_cursors (
+				name  varchar PRIMARY KEY,
+				block bigint
+			)`,
+		},
+	},
+	{
+		Version: 5,
+		Name:    "create nonces",
+		Statements: []string{
+			`CREATE TABLE nonces (
+				nonce      varchar PRIMARY KEY,
+				expires_at bigint
+			)`,
+			`CREATE INDEX nonces_expires_at ON nonces (expires_at)`,
+		},
+	},
+	{
+		Version: 6,
+		Name:    "store finalized and canceled swaps",
+		Statements: []string{
+			`ALTER TABLE finalized_swap ADD COLUMN canceled boolean NOT NULL DEFAULT false`,
+			`ALTER TABLE finalized_swap ADD COLUMN finalized_at bigint`,
+		},
+	},
+	{
+		Version: 7,
+		Name:    "record partial swap registration",
+		Statements: []string{
+			`ALTER TABLE partial_swap ADD COLUMN created_at bigint`,
+		},
+	},
+	{
+		Version: 8,
+		Name:    "record partial swap timelock gap and margin",
+		Statements: []string{
+			`ALTER TABLE partial_swap ADD COLUMN time_lock_gap bigint`,
+			`ALTER TABLE partial_swap ADD COLUMN time_lock_margin bigint`,
+		},
+	},
+	{
+		Version: 9,
+		Name:    "make partial swap secret hashes unique",
+		Statements: []string{
+			// Secret hashes that were reused before they were checked are
+			// only kept for the first partial swap that registered them.
+			`DELETE FROM partial_swap WHERE EXISTS (
+				SELECT 1 FROM partial_swap earlier WHERE earlier.secret_hash = partial_swap.secret_hash AND (
+					COALESCE(NULLIF(earlier.created_at, 0), earlier.time_lock) < COALESCE(NULLIF(partial_swap.created_at, 0), partial_swap.time_lock) OR
+					(COALESCE(NULLIF(earlier.created_at, 0), earlier.time_lock) = COALESCE(NULLIF(partial_swap.created_at, 0), partial_swap.time_lock) AND earlier.order_id < partial_swap.order_id)
+				)
+			)`,
+			`CREATE UNIQUE INDEX partial_swap_secret_hash ON partial_swap (secret_hash)`,
+		},
+	},
+	{
+		Version: 10,
+		Name:    "record refundable swaps",
+		Statements: []string{
+			`ALTER TABLE finalized_swap ADD COLUMN refundable_at bigint`,
+		},
+	},
+	{
+		Version: 11,
+		Name:    "create webhooks",
+		Statements: []string{
+			`CREATE TABLE webhooks (
+				id            varchar PRIMARY KEY,
+				trader        varchar NOT NULL,
+				url           varchar NOT NULL,
+				secret        varchar NOT NULL,
+				registered_by varchar NOT NULL,
+				created_at    bigint NOT NULL
+			)`,
+			`CREATE INDEX webhooks_trader ON webhooks (trader)`,
+			`CREATE TABLE webhook_orders (
+				order_id   varchar PRIMARY KEY,
+				trader     varchar NOT NULL,
+				confirmed  boolean NOT NULL DEFAULT false,
+				created_at bigint NOT NULL
+			)`,
+			`CREATE TABLE webhook_deliveries (
+				webhook_id      varchar NOT NULL,
+				event_id        varchar NOT NULL,
+				payload         varchar NOT NULL,
+				attempts        int NOT NULL DEFAULT 0,
+				next_attempt_at bigint NOT NULL,
+				last_error      varchar NOT NULL DEFAULT '',
+				delivered_at    bigint NOT NULL DEFAULT 0,
+				failed_at       bigint NOT NULL DEFAULT 0,
+				PRIMARY KEY (webhook_id, event_id)
+			)`,
+			`CREATE INDEX webhook_deliveries_next_attempt_at ON webhook_deliveries (next_attempt_at)`,
+		},
+	},
+	{
+		Version: 12,
+		Name:    "create sessions",
+		Statements: []string{
+			`CREATE TABLE sessions (
+				token      varchar PRIMARY KEY,
+				trader     varchar NOT NULL,
+				expires_at bigint NOT NULL
+			)`,
+		},
+	},
+	{
+		Version: 13,
+		Name:    "create orders",
+		Statements: []string{
+			`CREATE TABLE orders (
+				order_id    varchar PRIMARY KEY,
+				trader      varchar NOT NULL,
+				approved_at bigint NOT NULL
+			)`,
+			`CREATE INDEX orders_trader_approved_at ON orders (trader, approved_at)`,
+		},
+	},
+	{
+		Version: 14,
+		Name:    "create order index",
+		Statements: []string{
+			`CREATE TABLE indexed_orders (
+				order_id      varchar PRIMARY KEY,
+				position      bigint NOT NULL,
+				trader        varchar NOT NULL,
+				state         varchar NOT NULL,
+				block_number  bigint NOT NULL,
+				confirmer     varchar NOT NULL DEFAULT '',
+				match_id      varchar NOT NULL DEFAULT '',
+				pending_state varchar NOT NULL DEFAULT '',
+				pending_block bigint NOT NULL DEFAULT 0
+			)`,
+			`CREATE UNIQUE INDEX indexed_orders_position ON indexed_orders (position)`,
+			`CREATE INDEX indexed_orders_trader_position ON indexed_orders (trader, position)`,
+			`CREATE INDEX indexed_orders_state ON indexed_orders (state)`,
+			`CREATE TABLE indexed_settlements (
+				order_id         varchar PRIMARY KEY,
+				block_number     bigint NOT NULL,
+				order_is_buy     boolean NOT NULL,
+				matched_id       varchar NOT NULL,
+				priority_token   bigint NOT NULL,
+				secondary_token  bigint NOT NULL,
+				priority_volume  varchar NOT NULL,
+				secondary_volume varchar NOT NULL,
+				priority_fee     varchar NOT NULL,
+				secondary_fee    varchar NOT NULL
+			)`,
+		},
+	},
+	{
+		Version: 15,
+		Name:    "create leases",
+		Statements: []string{
+			`CREATE TABLE leases (
+				name       varchar PRIMARY KEY,
+				holder     varchar NOT NULL,
+				expires_at bigint NOT NULL
+			)`,
+		},
+	},
+	{
+		Version: 16,
+		Name:    "claim webhook deliveries",
+		Statements: []string{
+			`ALTER TABLE webhook_deliveries ADD COLUMN claimed_until bigint NOT NULL DEFAULT 0`,
+		},
+	},
+	{
+		Version: 17,
+		Name:    "create broadcast events",
+		Statements: []string{
+			`CREATE TABLE broadcast_events (
+				source text NOT NULL,
+				seq bigint NOT NULL,
+				payload text NOT NULL,
+				created_at bigint NOT NULL,
+				PRIMARY KEY (source, seq)
+			)`,
+			`CREATE INDEX broadcast_events_created_at ON broadcast_events (created_at)`,
+		},
+	},
+}
+
+// schemaColumns returns the columns of each table once all Migrations have
+// been applied. The columns are derived by applying the Migrations to a
+// scratch SQLite database, so that they cannot disagree with the Migrations.
+func schemaColumns() (map[string][]string, error) {
+	schemaColumnsOnce.Do(func() {
+		schemaColumnsCache, schemaColumnsErr = migratedColumns()
+	})
+	return schemaColumnsCache, schemaColumnsErr
+}
+
+var schemaColumnsOnce sync.Once
+var schemaColumnsCache map[string][]string
+var schemaColumnsErr error
+
+func migratedColumns() (map[string][]string, error) {
+	scratch, err := OpenDB(SQLiteURLPrefix + ":memory:")
+	if err != nil {
+		return nil, err
+	}
+	defer scratch.Close()
+
+	if err := scratch.createMigrationsTable(); err != nil {
+		return nil, err
+	}
+	for _, migration := range Migrations {
+		if err := scratch.applyMigration(migration); err != nil {
+			return nil, fmt.Errorf("cannot apply migration %v (%v) to a scratch database: %v", migration.Version, migration.Name, err)
+		}
+	}
+
+	rows, err := scratch.Query("SELECT name FROM sqlite_master WHERE type = 'table' AND name <> 'schema_migrations' AND name NOT LIKE 'sqlite_%'")
+	if err != nil {
+		return nil, err
+	}
+	tables := []string{}
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		tables = append(tables, table)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string][]string, len(tables))
+	for _, table := range tables {
+		if columns[table], err = scratch.tableColumns(table); err != nil {
+			return nil, err
+		}
+	}
+	return columns, nil
+}
+
+// tableColumns returns the lowercase names of the columns of the table.
+func (db *DB) tableColumns(table string) ([]string, error) {
+	rows, err := db.Query(fmt.Sprintf("SELECT * FROM %v WHERE 1=0", table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	for i := range columns {
+		columns[i] = strings.ToLower(columns[i])
+	}
+	return columns, nil
+}
+
+// SchemaStatus reports the state of the database schema compared to the
+// Migrations compiled into the binary.
+type SchemaStatus struct {
+	// Version of the latest Migration applied to the database.
+	Version int
+	// Pending Migrations that have not been applied.
+	Pending []Migration
+	// Drift describes differences between the database and the Migrations
+	// that have been applied to it.
+	Drift []string
+}
+
+// SchemaStatus returns the SchemaStatus of the database. The columns of the
+// database are only compared to the expected schema when there are no
+// pending Migrations.
+func (db *DB) SchemaStatus() (SchemaStatus, error) {
+	if err := db.createMigrationsTable(); err != nil {
+		return SchemaStatus{}, err
+	}
+	applied, err := db.appliedMigrations()
+	if err != nil {
+		return SchemaStatus{}, err
+	}
+
+	status := SchemaStatus{
+		Pending: []Migration{},
+		Drift:   []string{},
+	}
+	known := map[int]bool{}
+	for _, migration := range Migrations {
+		known[migration.Version] = true
+		checksum, ok := applied[migration.Version]
+		if !ok {
+			status.Pending = append(status.Pending, migration)
+			continue
+		}
+		if checksum != migration.Checksum() {
+			status.Drift = append(status.Drift, fmt.Sprintf("migration %v (%v) has changed since it was applied", migration.Version, migration.Name))
+		}
+		status.Version = migration.Version
+	}
+	versions := make([]int, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	sort.Ints(versions)
+	for _, version := range versions {
+		if !known[version] {
+			status.Drift = append(status.Drift, fmt.Sprintf("migration %v is unknown to this binary", version))
+			if version > status.Version {
+				status.Version = version
+			}
+		}
+	}
+
+	if len(status.Pending) == 0 {
+		drift, err := db.columnDrift()
+		if err != nil {
+			return SchemaStatus{}, err
+		}
+		status.Drift = append(status.Drift, drift...)
+	}
+	return status, nil
+}
+
+// Migrate applies all pending Migrations. It returns ErrSchemaDrift, without
+// applying any Migrations, if the database has drifted from the Migrations
+// that have been applied to it.
+func (db *DB) Migrate() (SchemaStatus, error) {
+	status, err := db.SchemaStatus()
+	if err != nil {
+		return status, err
+	}
+	if len(status.Drift) > 0 {
+		return status, ErrSchemaDrift
+	}
+	for _, migration := range status.Pending {
+		if err := db.applyMigration(migration); err != nil {
+			return status, fmt.Errorf("cannot apply migration %v (%v): %v", migration.Version, migration.Name, err)
+		}
+	}
+	if status, err = db.SchemaStatus(); err != nil {
+		return status, err
+	}
+	if len(status.Drift) > 0 {
+		return status, ErrSchemaDrift
+	}
+	return status, nil
+}
+
+// CheckSchema returns ErrSchemaDrift if the database has drifted from the
+// Migrations that have been applied to it, and ErrPendingMigrations if
+// Migrations need to be applied.
+func (db *DB) CheckSchema() (SchemaStatus, error) {
+	status, err := db.SchemaStatus()
+	if err != nil {
+		return status, err
+	}
+	if len(status.Drift) > 0 {
+		return status, ErrSchemaDrift
+	}
+	if len(status.Pending) > 0 {
+		return status, ErrPendingMigrations
+	}
+	return status, nil
+}
+
+func (db *DB) createMigrationsTable() error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    int PRIMARY KEY,
+		name       varchar,
+		checksum   varchar,
+		applied_at bigint
+	)`)
+	return err
+}
+
+// appliedMigrations returns the checksums of the applied Migrations, by
+// version.
+func (db *DB) appliedMigrations() (map[int]string, error) {
+	rows, err := db.Query("SELECT version, checksum FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]string{}
+	for rows.Next() {
+		var version int
+		var checksum sql.NullString
+		if err := rows.Scan(&version, &checksum); err != nil {
+			return nil, err
+		}
+		applied[version] = checksum.String
+	}
+	return applied, rows.Err()
+}
+
+func (db *DB) applyMigration(migration Migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, statement := range migration.Statements {
+		if _, err := tx.Exec(db.rebind(statement)); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if _, err := tx.Exec(db.rebind("INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES ($1, $2, $3, $4)"),
+		migration.Version, migration.Name, migration.Checksum(), time.Now().Unix()); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// columnDrift describes the tables and columns of the expected schema that
+// are missing from the database. Additional tables and columns are allowed.
+func (db *DB) columnDrift() ([]string, error) {
+	expected, err := schemaColumns()
+	if err != nil {
+		return nil, err
+	}
+	tables := make([]string, 0, len(expected))
+	for table := range expected {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	drift := []string{}
+	for _, table := range tables {
+		columns, err := db.tableColumns(table)
+		if err != nil {
+			drift = append(drift, fmt.Sprintf("table %v is missing", table))
+			continue
+		}
+		existing := make(map[string]bool, len(columns))
+		for _, column := range columns {
+			existing[column] = true
+		}
+		for _, column := range expected[table] {
+			if !existing[column] {
+				drift = append(drift, fmt.Sprintf("column %v.%v is missing", table, column))
+			}
+		}
+	}
+	return drift, nil
+}
@@ -0,0 +1,150 @@
+package ingress_test
+
+import (
+	"database/sql"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/republicprotocol/renex-ingress-go/ingress"
+)
+
+var _ = Describe("Migrations", func() {
+
+	var db *DB
+
+	BeforeEach(func() {
+		var err error
+		db, err = OpenDB(SQLiteURLPrefix + ":memory:")
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(db.Close()).ShouldNot(HaveOccurred())
+	})
+
+	It("should have consecutive versions", func() {
+		for i, migration := range Migrations {
+			Expect(migration.Version).Should(Equal(i + 1))
+		}
+	})
+
+	It("should report pending migrations for a new database", func() {
+		status, err := db.CheckSchema()
+		Expect(err).Should(Equal(ErrPendingMigrations))
+		Expect(status.Version).Should(Equal(0))
+		Expect(status.Pending).Should(HaveLen(len(Migrations)))
+	})
+
+	It("should apply all pending migrations", func() {
+		status, err := db.Migrate()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(status.Version).Should(Equal(len(Migrations)))
+		Expect(status.Pending).Should(BeEmpty())
+		Expect(status.Drift).Should(BeEmpty())
+
+		_, err = db.CheckSchema()
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	It("should not apply migrations more than once", func() {
+		_, err := db.Migrate()
+		Expect(err).ShouldNot(HaveOccurred())
+		status, err := db.Migrate()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(status.Version).Should(Equal(len(Migrations)))
+	})
+
+	It("should apply migrations to databases created before migrations", func() {
+		_, err := db.Exec("CREATE TABLE traders (address varchar PRIMARY KEY, referrer varchar, referral_code varchar, created_at bigint, kyc_wyre varchar, kyc_kyber bigint, authorizer varchar, last_verified_at bigint)")
+		Expect(err).ShouldNot(HaveOccurred())
+		_, err = db.Migrate()
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	It("should keep the first partial swap of secret hashes that were reused", func() {
+		_, err := db.Exec("CREATE TABLE partial_swap (order_id varchar PRIMARY KEY, kyc_addr varchar, send_to varchar, receive_from varchar, time_lock int, secret_hash varchar)")
+		Expect(err).ShouldNot(HaveOccurred())
+		for _, swap := range []struct {
+			orderID  string
+			timeLock int64
+		}{{"second", 200}, {"first", 100}, {"third", 200}} {
+			_, err := db.Exec("INSERT INTO partial_swap (order_id, kyc_addr, send_to, receive_from, time_lock, secret_hash) VALUES ($1, '', '', '', $2, 'reused')", swap.orderID, swap.timeLock)
+			Expect(err).ShouldNot(HaveOccurred())
+		}
+		_, err = db.Migrate()
+		Expect(err).ShouldNot(HaveOccurred())
+
+		swapper := NewSwapperWithDB(db, nil, nil)
+		_, err = swapper.PartialSwap("first")
+		Expect(err).ShouldNot(HaveOccurred())
+		for _, orderID := range []string{"second", "third"} {
+			_, err = swapper.PartialSwap(orderID)
+			Expect(err).Should(Equal(sql.ErrNoRows))
+		}
+	})
+
+	Context("when the schema has drifted", func() {
+
+		BeforeEach(func() {
+			_, err := db.Migrate()
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("should refuse migrations that have changed", func() {
+			_, err := db.Exec("UPDATE schema_migrations SET checksum=$1 WHERE version=$2", "changed", 1)
+			Expect(err).ShouldNot(HaveOccurred())
+			status, err := db.Migrate()
+			Expect(err).Should(Equal(ErrSchemaDrift))
+			Expect(status.Drift).Should(HaveLen(1))
+		})
+
+		It("should refuse migrations that are unknown", func() {
+			_, err := db.Exec("INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES ($1, $2, $3, $4)", len(Migrations)+1, "unknown", "", 0)
+			Expect(err).ShouldNot(HaveOccurred())
+			status, err := db.CheckSchema()
+			Expect(err).Should(Equal(ErrSchemaDrift))
+			Expect(status.Version).Should(Equal(len(Migrations) + 1))
+		})
+
+		It("should refuse missing tables", func() {
+			_, err := db.Exec("DROP TABLE approved_traders")
+			Expect(err).ShouldNot(HaveOccurred())
+			status, err := db.CheckSchema()
+			Expect(err).Should(Equal(ErrSchemaDrift))
+			Expect(status.Drift).Should(ConsistOf("table approved_traders is missing"))
+		})
+
+		It("should refuse missing columns that were added by a migration", func() {
+			_, err := db.Exec("DROP TABLE partial_swap")
+			Expect(err).ShouldNot(HaveOccurred())
+			_, err = db.Exec("CREATE TABLE partial_swap (order_id varchar PRIMARY KEY, kyc_addr varchar, send_to varchar, receive_from varchar, time_lock int, secret_hash varchar, created_at bigint)")
+			Expect(err).ShouldNot(HaveOccurred())
+			status, err := db.CheckSchema()
+			Expect(err).Should(Equal(ErrSchemaDrift))
+			Expect(status.Drift).Should(ConsistOf("column partial_swap.time_lock_gap is missing"))
+		})
+
+		It("should expect every table that is created by the migrations", func() {
+			rows, err := db.Query("SELECT name FROM sqlite_master WHERE type = 'table' AND name <> 'schema_migrations' AND name NOT LIKE 'sqlite_%'")
+			Expect(err).ShouldNot(HaveOccurred())
+			tables := []string{}
+			for rows.Next() {
+				var table string
+				Expect(rows.Scan(&table)).ShouldNot(HaveOccurred())
+				tables = append(tables, table)
+			}
+			Expect(rows.Close()).ShouldNot(HaveOccurred())
+			Expect(tables).ShouldNot(BeEmpty())
+
+			drift := make([]string, len(tables))
+			for i, table := range tables {
+				_, err := db.Exec("DROP TABLE " + table)
+				Expect(err).ShouldNot(HaveOccurred())
+				drift[i] = "table " + table + " is missing"
+			}
+			status, err := db.CheckSchema()
+			Expect(err).Should(Equal(ErrSchemaDrift))
+			Expect(status.Drift).Should(ConsistOf(drift))
+		})
+	})
+})
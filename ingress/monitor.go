@@ -0,0 +1,164 @@
+package ingress
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// swapMonitorMetrics are the counts of the SwapMonitor, published by expvar
+// under "swap_monitor".
+var swapMonitorMetrics = expvar.NewMap("swap_monitor")
+
+// A SwapCheck summarizes a pass of a SwapMonitor.
+type SwapCheck struct {
+	// Refundable is the number of finalized swaps that were marked as
+	// refundable.
+	Refundable int
+	// Purged is the number of abandoned partial swaps that were deleted.
+	Purged int
+}
+
+// A SwapMonitor periodically marks finalized swaps that are past their
+// timelock as refundable, and purges partial swaps of orders that have not
+// been settled within the retention period. Traders are notified when their
+// swaps expire.
+type SwapMonitor interface {
+	// Run checks swaps on every interval until the done channel is closed.
+	// Errors are written to the returned channel.
+	Run(done <-chan struct{}) <-chan error
+
+	// Check runs a single pass over all swaps.
+	Check() (SwapCheck, error)
+}
+
+type swapMonitor struct {
+	swapper   Swapper
+	binder    SwapContractBinder
+	notifier  Notifier
+	retention time.Duration
+	interval  time.Duration
+}
+
+// NewSwapMonitor returns a SwapMonitor that purges partial swaps registered
+// more than the retention period ago, unless the RenExSettlement contract
+// has settled the order.
+func NewSwapMonitor(swapper Swapper, binder SwapContractBinder, notifier Notifier, retention, interval time.Duration) SwapMonitor {
+	return &swapMonitor{
+		swapper:   swapper,
+		binder:    binder,
+		notifier:  notifier,
+		retention: retention,
+		interval:  interval,
+	}
+}
+
+// Run implements the SwapMonitor interface.
+func (monitor *swapMonitor) Run(done <-chan struct{}) <-chan error {
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(errs)
+
+		ticker := time.NewTicker(monitor.interval)
+		defer ticker.Stop()
+
+		for {
+			if _, err := monitor.Check(); err != nil {
+				select {
+				case <-done:
+					return
+				case errs <- err:
+				}
+			}
+
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return errs
+}
+
+// Check implements the SwapMonitor interface.
+func (monitor *swapMonitor) Check() (SwapCheck, error) {
+	check := SwapCheck{}
+	defer func() {
+		swapMonitorMetrics.Add("runs", 1)
+		swapMonitorMetrics.Add("refundable", int64(check.Refundable))
+		swapMonitorMetrics.Add("purged", int64(check.Purged))
+	}()
+
+	// Marking, notifying and purging are independent, so a failure does not
+	// prevent the remaining steps of the pass. All errors are returned.
+	errs := []string{}
+	now := time.Now()
+	refundable, err := monitor.swapper.MarkRefundableSwaps(now.Unix())
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("cannot mark refundable swaps: %v", err))
+	}
+	check.Refundable = len(refundable)
+	if err := monitor.notifyExpiredSwaps(refundable); err != nil {
+		errs = append(errs, err.Error())
+	}
+
+	ids, err := monitor.swapper.UnfinalizedPartialSwaps(now.Add(-monitor.retention).Unix())
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("cannot load unfinalized partial swaps: %v", err))
+	}
+	var firstErr error
+	for _, id := range ids {
+		if err := monitor.purgePartialSwap(id, &check); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		errs = append(errs, fmt.Sprintf("cannot purge partial swaps: %v", firstErr))
+	}
+
+	if len(errs) > 0 {
+		return check, errors.New(strings.Join(errs, "; "))
+	}
+	return check, nil
+}
+
+// notifyExpiredSwaps notifies the traders of the swaps that were marked as
+// refundable in this pass. Every swap is notified, even if notifying another
+// swap fails.
+func (monitor *swapMonitor) notifyExpiredSwaps(swaps []RefundableSwap) error {
+	var firstErr error
+	for _, swap := range swaps {
+		event := Event{Type: EventSwapExpired, OrderID: swap.OrderID, Trader: swap.KycAddr, Data: swap}
+		if err := monitor.notifier.Notify(event); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("cannot notify expired swap of order=%v: %v", swap.OrderID, err)
+		}
+	}
+	return firstErr
+}
+
+// purgePartialSwap deletes the partial swap of an order that has not been
+// settled. Partial swaps of settled orders are kept, so that the swap can be
+// finalized when the matched order registers its partial swap.
+func (monitor *swapMonitor) purgePartialSwap(id string, check *SwapCheck) error {
+	orderID, err := orderIdStringToBytes(id)
+	if err != nil {
+		return fmt.Errorf("cannot decode order=%v: %v", id, err)
+	}
+	details, err := monitor.binder.GetMatchDetails(orderID)
+	if err != nil {
+		return fmt.Errorf("cannot get match details for order=%v: %v", id, err)
+	}
+	if details.Settled {
+		return nil
+	}
+	if err := monitor.swapper.DeletePartialSwap(id); err != nil {
+		return fmt.Errorf("cannot delete partial swap for order=%v: %v", id, err)
+	}
+	check.Purged++
+	return nil
+}
@@ -0,0 +1,114 @@
+package ingress_test
+
+import (
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/republicprotocol/renex-ingress-go/ingress"
+)
+
+var _ = Describe("Swap monitor", func() {
+
+	var db *DB
+	var binder *mockSwapContractBinder
+	var swapper Swapper
+	var notifier *mockNotifier
+	var monitor SwapMonitor
+
+	BeforeEach(func() {
+		db = newSQLiteDB()
+		binder = newMockSwapContractBinder()
+		notifier = &mockNotifier{mu: new(sync.Mutex)}
+		swapper = NewSwapperWithDB(db, binder, notifier)
+		monitor = NewSwapMonitor(swapper, binder, notifier, time.Hour, time.Hour)
+	})
+
+	// insertExpiredSwap stores a finalized swap for the order whose timelock
+	// has expired.
+	insertExpiredSwap := func(orderID string) {
+		_, err := db.Exec("INSERT INTO finalized_swap (order_id, send_to, receive_from, send_amount, receive_amount, secret_hash, should_initiate_first, time_lock, canceled, finalized_at) VALUES ($1,'','','','','',false,$2,false,$2)", orderID, time.Now().Add(-time.Hour).Unix())
+		Expect(err).ShouldNot(HaveOccurred())
+	}
+
+	It("should purge abandoned partial swaps of unsettled orders", func() {
+		swap := newPartialSwap(1)
+		Expect(swapper.InsertPartialSwap(swap)).ShouldNot(HaveOccurred())
+
+		check, err := monitor.Check()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(check).Should(Equal(SwapCheck{Purged: 1}))
+		_, err = swapper.PartialSwap(swap.OrderID)
+		Expect(err).Should(Equal(sql.ErrNoRows))
+	})
+
+	It("should keep partial swaps of settled orders", func() {
+		buy, sell := newPartialSwap(1), newPartialSwap(2)
+		Expect(swapper.InsertPartialSwap(buy)).ShouldNot(HaveOccurred())
+		binder.settle(buy.OrderID, sell.OrderID)
+
+		check, err := monitor.Check()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(check).Should(Equal(SwapCheck{}))
+		_, err = swapper.PartialSwap(buy.OrderID)
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	It("should keep partial swaps within the retention period", func() {
+		swap := newPartialSwap(1)
+		swap.CreatedAt = time.Now().Unix()
+		Expect(swapper.InsertPartialSwap(swap)).ShouldNot(HaveOccurred())
+
+		check, err := monitor.Check()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(check).Should(Equal(SwapCheck{}))
+		_, err = swapper.PartialSwap(swap.OrderID)
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	It("should not mark swaps before their timelock expires", func() {
+		buy, sell := newPartialSwap(1), newPartialSwap(2)
+		Expect(swapper.InsertPartialSwap(buy)).ShouldNot(HaveOccurred())
+		Expect(swapper.InsertPartialSwap(sell)).ShouldNot(HaveOccurred())
+		binder.settle(buy.OrderID, sell.OrderID)
+		_, _, err := swapper.FinalizedSwap(buy.OrderID)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		check, err := monitor.Check()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(check.Refundable).Should(Equal(0))
+		swaps, err := swapper.RefundableSwaps()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(swaps).Should(BeEmpty())
+	})
+
+	It("should notify swaps once when they are marked as refundable", func() {
+		insertExpiredSwap("expired")
+
+		check, err := monitor.Check()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(check.Refundable).Should(Equal(1))
+		Expect(notifier.eventTypes("expired")).Should(Equal([]string{EventSwapExpired}))
+
+		check, err = monitor.Check()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(check.Refundable).Should(Equal(0))
+		Expect(notifier.eventTypes("expired")).Should(Equal([]string{EventSwapExpired}))
+	})
+
+	It("should purge partial swaps when notifying expired swaps fails", func() {
+		insertExpiredSwap("expired")
+		swap := newPartialSwap(1)
+		Expect(swapper.InsertPartialSwap(swap)).ShouldNot(HaveOccurred())
+		notifier.err = errors.New("cannot deliver event")
+
+		check, err := monitor.Check()
+		Expect(err).Should(HaveOccurred())
+		Expect(check).Should(Equal(SwapCheck{Refundable: 1, Purged: 1}))
+		_, err = swapper.PartialSwap(swap.OrderID)
+		Expect(err).Should(Equal(sql.ErrNoRows))
+	})
+})
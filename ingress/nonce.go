@@ -0,0 +1,63 @@
+package ingress
+
+import (
+	"time"
+)
+
+// The schema of the nonces table is defined by Migrations.
+
+// A Noncer stores the nonces that are issued to traders to sign requests.
+// Nonces are shared by all instances of the Ingress, and can only be consumed
+// once before they expire.
+type Noncer interface {
+	// InsertNonce stores a nonce that can be consumed until the unix
+	// timestamp. Expired nonces are deleted.
+	InsertNonce(nonce string, expiresAt int64) error
+
+	// ConsumeNonce deletes the nonce. It returns ErrInvalidNonce if the nonce
+	// is unknown, has expired, or has already been consumed.
+	ConsumeNonce(nonce string) error
+}
+
+type noncer struct {
+	*DB
+}
+
+// NewNoncer returns a Noncer that stores nonces in the database at the URL.
+func NewNoncer(databaseURL string) (Noncer, error) {
+	db, err := OpenDB(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	return NewNoncerWithDB(db), nil
+}
+
+// NewNoncerWithDB returns a Noncer that stores nonces in an open database.
+func NewNoncerWithDB(db *DB) Noncer {
+	return &noncer{
+		db,
+	}
+}
+
+func (noncer *noncer) InsertNonce(nonce string, expiresAt int64) error {
+	if _, err := noncer.Exec("DELETE FROM nonces WHERE expires_at <= $1", time.Now().Unix()); err != nil {
+		return err
+	}
+	_, err := noncer.Exec("INSERT INTO nonces (nonce, expires_at) VALUES ($1, $2)", nonce, expiresAt)
+	return err
+}
+
+func (noncer *noncer) ConsumeNonce(nonce string) error {
+	res, err := noncer.Exec("DELETE FROM nonces WHERE nonce=$1 AND expires_at > $2", nonce, time.Now().Unix())
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrInvalidNonce
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package ingress_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/republicprotocol/renex-ingress-go/ingress"
+)
+
+var _ = Describe("Noncer", func() {
+
+	newSQLiteNoncer := func() Noncer {
+		db, err := OpenDB(SQLiteURLPrefix + ":memory:")
+		Expect(err).ShouldNot(HaveOccurred())
+		_, err = db.Migrate()
+		Expect(err).ShouldNot(HaveOccurred())
+		return NewNoncerWithDB(db)
+	}
+
+	for _, backend := range []struct {
+		name      string
+		newNoncer func() Noncer
+	}{
+		{"memory", NewMemoryNoncer},
+		{"sqlite", newSQLiteNoncer},
+	} {
+		backend := backend
+
+		Context("when using "+backend.name+" storage", func() {
+
+			It("should consume nonces once", func() {
+				noncer := backend.newNoncer()
+				Expect(noncer.InsertNonce("nonce", time.Now().Add(time.Minute).Unix())).ShouldNot(HaveOccurred())
+				Expect(noncer.ConsumeNonce("nonce")).ShouldNot(HaveOccurred())
+				Expect(noncer.ConsumeNonce("nonce")).Should(Equal(ErrInvalidNonce))
+			})
+
+			It("should not consume unknown nonces", func() {
+				Expect(backend.newNoncer().ConsumeNonce("unknown")).Should(Equal(ErrInvalidNonce))
+			})
+
+			It("should not consume expired nonces", func() {
+				noncer := backend.newNoncer()
+				Expect(noncer.InsertNonce("nonce", time.Now().Add(-time.Second).Unix())).ShouldNot(HaveOccurred())
+				Expect(noncer.ConsumeNonce("nonce")).Should(Equal(ErrInvalidNonce))
+			})
+		})
+	}
+})
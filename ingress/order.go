@@ -0,0 +1,316 @@
+package ingress
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math/big"
+
+	"github.com/republicprotocol/renex-ingress-go/contract"
+)
+
+// The schema of the orders table is defined by Migrations.
+
+// States of the orders of traders. Orders are pending until they are opened
+// in the Orderbook, and confirmed orders are settled once their match has
+// been settled by the RenExSettlement contract.
+const (
+	OrderStatePending   = "pending"
+	OrderStateOpen      = "open"
+	OrderStateConfirmed = "confirmed"
+	OrderStateSettled   = "settled"
+	OrderStateCanceled  = "canceled"
+)
+
+// orderBatchSize is the number of approved orders that are loaded at a time
+// when filtering orders by their state.
+const orderBatchSize = 100
+
+// OrderScanLimit is the maximum number of approved orders that are described
+// using the Orderbook for a page of orders that is filtered by their state.
+// Each order is described by several calls to the Orderbook, so a page that
+// reaches the limit is returned with a Cursor from which to continue.
+var OrderScanLimit = 500
+
+// An ApprovedOrder is an order that the Ingress has approved to be opened in
+// the Orderbook.
+type ApprovedOrder struct {
+	OrderID    string
+	Trader     string
+	ApprovedAt int64
+}
+
+// A TraderOrder is an order approved by the Ingress, and its state in the
+// Orderbook. The block number and depth are set once the order is no longer
+// pending, the confirmer and match once it is confirmed, and the Settlement
+// once it is settled.
+type TraderOrder struct {
+	OrderID     string             `json:"orderID"`
+	Trader      string             `json:"trader"`
+	State       string             `json:"state"`
+	ApprovedAt  int64              `json:"approvedAt"`
+	BlockNumber uint64             `json:"blockNumber,omitempty"`
+	Depth       uint64             `json:"depth,omitempty"`
+	Confirmer   string             `json:"confirmer,omitempty"`
+	MatchID     string             `json:"matchID,omitempty"`
+	Settlement  *SettlementDetails `json:"settlement,omitempty"`
+}
+
+// SettlementDetails are the details of the settlement of an order by the
+// RenExSettlement contract. Volumes and fees are decimal strings.
+type SettlementDetails struct {
+	OrderIsBuy      bool   `json:"orderIsBuy"`
+	MatchedID       string `json:"matchedID"`
+	PriorityToken   uint32 `json:"priorityToken"`
+	SecondaryToken  uint32 `json:"secondaryToken"`
+	PriorityVolume  string `json:"priorityVolume"`
+	SecondaryVolume string `json:"secondaryVolume"`
+	PriorityFee     string `json:"priorityFee"`
+	SecondaryFee    string `json:"secondaryFee"`
+}
+
+// An OrderQuery selects a page of the orders of a trader. Orders are filtered
+// by their state, unless the State is empty. Unfiltered pages start after
+// Offset orders, and filtered pages start at the Cursor of the previous page.
+type OrderQuery struct {
+	State  string
+	Offset int
+	Cursor int
+	Limit  int
+}
+
+// An OrderPage is a page of the orders of a trader, most recently approved
+// first. More is true if there are orders after the page. When filtering by
+// state, the next page starts at the Cursor, and a page can have fewer orders
+// than the limit, or none, when there are more orders to be checked.
+type OrderPage struct {
+	Orders []TraderOrder `json:"orders"`
+	Offset int           `json:"offset"`
+	Cursor int           `json:"cursor,omitempty"`
+	Limit  int           `json:"limit"`
+	More   bool          `json:"more"`
+}
+
+// An Orderer stores the orders that have been approved by the Ingress, and
+// describes them using the Orderbook.
+type Orderer interface {
+	// InsertOrder stores the approval of an order, unless it is already
+	// stored.
+	InsertOrder(order ApprovedOrder) error
+
+	// ApprovedOrder returns the approval of an order, or sql.ErrNoRows if the
+	// Ingress has not approved the order.
+	ApprovedOrder(orderID string) (ApprovedOrder, error)
+
+	// ApprovedOrders returns at most limit orders of the trader after
+	// skipping offset orders, most recently approved first.
+	ApprovedOrders(trader string, offset, limit int) ([]ApprovedOrder, error)
+
+	// TraderOrders returns a page of the orders of the trader. It returns
+	// ErrUnknownOrderState if the query filters by an unknown state.
+	TraderOrders(trader string, query OrderQuery) (OrderPage, error)
+}
+
+type orderer struct {
+	*DB
+	binder OrderContractBinder
+}
+
+// NewOrderer returns an Orderer that stores approved orders in the database at
+// the URL.
+func NewOrderer(databaseURL string, binder OrderContractBinder) (Orderer, error) {
+	db, err := OpenDB(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	return NewOrdererWithDB(db, binder), nil
+}
+
+// NewOrdererWithDB returns an Orderer that stores approved orders in an open
+// database.
+func NewOrdererWithDB(db *DB, binder OrderContractBinder) Orderer {
+	return &orderer{db, binder}
+}
+
+func (orderer *orderer) InsertOrder(order ApprovedOrder) error {
+	_, err := orderer.Exec("INSERT INTO orders (order_id, trader, approved_at) VALUES ($1, $2, $3) ON CONFLICT (order_id) DO NOTHING",
+		order.OrderID, normalizeAddress(order.Trader), order.ApprovedAt)
+	return err
+}
+
+func (orderer *orderer) ApprovedOrder(orderID string) (ApprovedOrder, error) {
+	order := ApprovedOrder{}
+	err := orderer.QueryRow("SELECT order_id, trader, approved_at FROM orders WHERE order_id=$1", orderID).Scan(&order.OrderID, &order.Trader, &order.ApprovedAt)
+	return order, err
+}
+
+func (orderer *orderer) ApprovedOrders(trader string, offset, limit int) ([]ApprovedOrder, error) {
+	rows, err := orderer.Query("SELECT order_id, trader, approved_at FROM orders WHERE trader=$1 ORDER BY approved_at DESC, order_id DESC LIMIT $2 OFFSET $3", normalizeAddress(trader), limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orders := []ApprovedOrder{}
+	for rows.Next() {
+		order := ApprovedOrder{}
+		if err := rows.Scan(&order.OrderID, &order.Trader, &order.ApprovedAt); err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+	return orders, rows.Err()
+}
+
+func (orderer *orderer) TraderOrders(trader string, query OrderQuery) (OrderPage, error) {
+	return traderOrders(orderer.binder, orderer, trader, query)
+}
+
+// traderOrders returns a page of the approved orders of the trader, described
+// using the Orderbook. When filtering by state, approved orders are loaded in
+// batches from the cursor until the page is full, because their state is only
+// known to the Orderbook. At most OrderScanLimit approved orders are checked,
+// and the page returns the cursor of the first order that was not checked.
+func traderOrders(binder OrderContractBinder, orderer Orderer, trader string, query OrderQuery) (OrderPage, error) {
+	switch query.State {
+	case "", OrderStatePending, OrderStateOpen, OrderStateConfirmed, OrderStateSettled, OrderStateCanceled:
+	default:
+		return OrderPage{}, ErrUnknownOrderState
+	}
+
+	page := OrderPage{Orders: []TraderOrder{}, Offset: query.Offset, Limit: query.Limit}
+	if query.State == "" {
+		// Without a filter, the offset can be applied to the approved orders.
+		approved, err := orderer.ApprovedOrders(trader, query.Offset, query.Limit+1)
+		if err != nil {
+			return OrderPage{}, fmt.Errorf("cannot load approved orders of trader=%v: %v", trader, err)
+		}
+		if len(approved) > query.Limit {
+			approved, page.More = approved[:query.Limit], true
+		}
+		for _, order := range approved {
+			traderOrder, err := describeOrder(binder, order)
+			if err != nil {
+				return OrderPage{}, err
+			}
+			page.Orders = append(page.Orders, traderOrder)
+		}
+		return page, nil
+	}
+
+	cursor, scanned := query.Cursor, 0
+	for {
+		batchSize := orderBatchSize
+		if remaining := OrderScanLimit - scanned; remaining < batchSize {
+			batchSize = remaining
+		}
+		// One more order is loaded to know whether there are more orders
+		// after the scanned orders.
+		approved, err := orderer.ApprovedOrders(trader, cursor, batchSize+1)
+		if err != nil {
+			return OrderPage{}, fmt.Errorf("cannot load approved orders of trader=%v: %v", trader, err)
+		}
+		for i, order := range approved {
+			if len(page.Orders) == query.Limit || scanned == OrderScanLimit {
+				page.Cursor, page.More = cursor, true
+				return page, nil
+			}
+			if i == batchSize {
+				break
+			}
+			traderOrder, err := describeOrder(binder, order)
+			if err != nil {
+				return OrderPage{}, err
+			}
+			cursor++
+			scanned++
+			if traderOrder.State == query.State {
+				page.Orders = append(page.Orders, traderOrder)
+			}
+		}
+		if len(approved) <= batchSize {
+			return page, nil
+		}
+	}
+}
+
+// describeOrder returns the approved order and its state in the Orderbook.
+func describeOrder(binder OrderContractBinder, approved ApprovedOrder) (TraderOrder, error) {
+	id, err := orderIdStringToBytes(approved.OrderID)
+	if err != nil {
+		return TraderOrder{}, fmt.Errorf("cannot decode order=%v: %v", approved.OrderID, err)
+	}
+	order := TraderOrder{
+		OrderID:    approved.OrderID,
+		Trader:     approved.Trader,
+		State:      OrderStatePending,
+		ApprovedAt: approved.ApprovedAt,
+	}
+
+	state, err := binder.OrderState(id)
+	if err != nil {
+		return TraderOrder{}, fmt.Errorf("cannot get order state for order=%v: %v", approved.OrderID, err)
+	}
+	switch state {
+	case orderStateOpen:
+		order.State = OrderStateOpen
+	case orderStateConfirmed:
+		order.State = OrderStateConfirmed
+	case orderStateCanceled:
+		order.State = OrderStateCanceled
+	default:
+		return order, nil
+	}
+
+	if order.BlockNumber, err = binder.OrderBlockNumber(id); err != nil {
+		return TraderOrder{}, fmt.Errorf("cannot get order block number for order=%v: %v", approved.OrderID, err)
+	}
+	if order.Depth, err = binder.OrderDepth(id); err != nil {
+		return TraderOrder{}, fmt.Errorf("cannot get order depth for order=%v: %v", approved.OrderID, err)
+	}
+	if state != orderStateConfirmed {
+		return order, nil
+	}
+
+	confirmer, err := binder.OrderConfirmer(id)
+	if err != nil {
+		return TraderOrder{}, fmt.Errorf("cannot get order confirmer for order=%v: %v", approved.OrderID, err)
+	}
+	order.Confirmer = confirmer.Hex()
+	match, err := binder.OrderMatch(id)
+	if err != nil {
+		return TraderOrder{}, fmt.Errorf("cannot get order match for order=%v: %v", approved.OrderID, err)
+	}
+	order.MatchID = base64.StdEncoding.EncodeToString(match[:])
+
+	details, err := binder.GetMatchDetails(id)
+	if err != nil {
+		return TraderOrder{}, fmt.Errorf("cannot get match details for order=%v: %v", approved.OrderID, err)
+	}
+	if details.Settled {
+		order.State = OrderStateSettled
+		order.Settlement = settlementDetails(details)
+	}
+	return order, nil
+}
+
+func settlementDetails(details contract.MatchDetails) *SettlementDetails {
+	return &SettlementDetails{
+		OrderIsBuy:      details.OrderIsBuy,
+		MatchedID:       base64.StdEncoding.EncodeToString(details.MatchedID[:]),
+		PriorityToken:   details.PriorityToken,
+		SecondaryToken:  details.SecondaryToken,
+		PriorityVolume:  bigIntString(details.PriorityVolume),
+		SecondaryVolume: bigIntString(details.SecondaryVolume),
+		PriorityFee:     bigIntString(details.PriorityFee),
+		SecondaryFee:    bigIntString(details.SecondaryFee),
+	}
+}
+
+// bigIntString returns the decimal string of a value returned by a contract,
+// which is nil when the value has not been set.
+func bigIntString(value *big.Int) string {
+	if value == nil {
+		return "0"
+	}
+	return value.String()
+}
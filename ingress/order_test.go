@@ -0,0 +1,187 @@
+package ingress_test
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/republicprotocol/renex-ingress-go/ingress"
+)
+
+var _ = Describe("Orderer", func() {
+
+	trader := common.HexToAddress("0x62026B5aC38f1b186c7af0b18Aee8B2EccC2d852")
+
+	newSQLiteOrderer := func(binder OrderContractBinder) Orderer {
+		db, err := OpenDB(SQLiteURLPrefix + ":memory:")
+		Expect(err).ShouldNot(HaveOccurred())
+		_, err = db.Migrate()
+		Expect(err).ShouldNot(HaveOccurred())
+		return NewOrdererWithDB(db, binder)
+	}
+
+	orderIDs := func(page OrderPage) []string {
+		ids := make([]string, len(page.Orders))
+		for i, order := range page.Orders {
+			ids[i] = order.OrderID
+		}
+		return ids
+	}
+
+	for _, backend := range []struct {
+		name       string
+		newOrderer func(binder OrderContractBinder) Orderer
+	}{
+		{"memory", NewMemoryOrderer},
+		{"sqlite", newSQLiteOrderer},
+	} {
+		backend := backend
+
+		Context("when using "+backend.name+" storage", func() {
+
+			var binder *mockSwapContractBinder
+			var orderer Orderer
+			var orders [5]string
+
+			BeforeEach(func() {
+				binder = newMockSwapContractBinder()
+				orderer = backend.newOrderer(binder)
+				for i := range orders {
+					orders[i] = newPartialSwap(byte(i + 1)).OrderID
+					Expect(orderer.InsertOrder(ApprovedOrder{OrderID: orders[i], Trader: trader.Hex(), ApprovedAt: int64(i + 1)})).ShouldNot(HaveOccurred())
+				}
+				Expect(orderer.InsertOrder(ApprovedOrder{OrderID: newPartialSwap(9).OrderID, Trader: "0x1", ApprovedAt: 9})).ShouldNot(HaveOccurred())
+			})
+
+			It("should return the orders of the trader most recently approved first", func() {
+				page, err := orderer.TraderOrders(trader.Hex(), OrderQuery{Limit: 2})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(orderIDs(page)).Should(Equal([]string{orders[4], orders[3]}))
+				Expect(page.More).Should(BeTrue())
+
+				page, err = orderer.TraderOrders(trader.Hex(), OrderQuery{Offset: 4, Limit: 2})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(orderIDs(page)).Should(Equal([]string{orders[0]}))
+				Expect(page.More).Should(BeFalse())
+			})
+
+			It("should ignore orders that are approved more than once", func() {
+				Expect(orderer.InsertOrder(ApprovedOrder{OrderID: orders[0], Trader: trader.Hex(), ApprovedAt: 10})).ShouldNot(HaveOccurred())
+				page, err := orderer.TraderOrders(trader.Hex(), OrderQuery{Limit: 10})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(page.Orders).Should(HaveLen(5))
+				Expect(page.Orders[4].ApprovedAt).Should(Equal(int64(1)))
+			})
+
+			It("should return the approval of an order", func() {
+				order, err := orderer.ApprovedOrder(orders[2])
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(order).Should(Equal(ApprovedOrder{OrderID: orders[2], Trader: strings.ToLower(trader.Hex()), ApprovedAt: 3}))
+
+				_, err = orderer.ApprovedOrder(newPartialSwap(8).OrderID)
+				Expect(err).Should(Equal(sql.ErrNoRows))
+			})
+
+			It("should describe the orders using the orderbook", func() {
+				binder.open(orders[1], trader)
+				binder.cancel(orders[2])
+				binder.settle(orders[3], orders[4])
+
+				page, err := orderer.TraderOrders(trader.Hex(), OrderQuery{Limit: 10})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(page.Orders).Should(HaveLen(5))
+
+				settled, canceled, open, pending := page.Orders[1], page.Orders[2], page.Orders[3], page.Orders[4]
+				Expect(pending.State).Should(Equal(OrderStatePending))
+				Expect(pending.BlockNumber).Should(BeZero())
+				Expect(open.State).Should(Equal(OrderStateOpen))
+				Expect(open.BlockNumber).Should(Equal(uint64(42)))
+				Expect(open.Depth).Should(Equal(uint64(6)))
+				Expect(open.Confirmer).Should(BeEmpty())
+				Expect(canceled.State).Should(Equal(OrderStateCanceled))
+				Expect(settled.State).Should(Equal(OrderStateSettled))
+				Expect(settled.Confirmer).Should(Equal(mockConfirmer.Hex()))
+				Expect(settled.MatchID).Should(Equal(orders[4]))
+				Expect(settled.Settlement).ShouldNot(BeNil())
+				Expect(settled.Settlement.OrderIsBuy).Should(BeTrue())
+				Expect(settled.Settlement.PriorityVolume).Should(Equal("100"))
+				Expect(settled.Settlement.PriorityFee).Should(Equal("0"))
+			})
+
+			It("should filter the orders by their state", func() {
+				binder.open(orders[0], trader)
+				binder.open(orders[2], trader)
+				binder.open(orders[4], trader)
+
+				page, err := orderer.TraderOrders(trader.Hex(), OrderQuery{State: OrderStateOpen, Limit: 1})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(orderIDs(page)).Should(Equal([]string{orders[4]}))
+				Expect(page.More).Should(BeTrue())
+
+				page, err = orderer.TraderOrders(trader.Hex(), OrderQuery{State: OrderStateOpen, Cursor: page.Cursor, Limit: 1})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(orderIDs(page)).Should(Equal([]string{orders[2]}))
+				Expect(page.More).Should(BeTrue())
+
+				page, err = orderer.TraderOrders(trader.Hex(), OrderQuery{State: OrderStateOpen, Cursor: page.Cursor, Limit: 1})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(orderIDs(page)).Should(Equal([]string{orders[0]}))
+				Expect(page.More).Should(BeFalse())
+
+				page, err = orderer.TraderOrders(trader.Hex(), OrderQuery{State: OrderStatePending, Limit: 10})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(orderIDs(page)).Should(Equal([]string{orders[3], orders[1]}))
+				Expect(page.More).Should(BeFalse())
+			})
+
+			Context("when the number of orders that are checked is limited", func() {
+
+				var scanLimit int
+
+				BeforeEach(func() {
+					scanLimit = OrderScanLimit
+					OrderScanLimit = 2
+				})
+
+				AfterEach(func() {
+					OrderScanLimit = scanLimit
+				})
+
+				It("should return a cursor from which to continue filtering the orders", func() {
+					binder.open(orders[0], trader)
+
+					page, err := orderer.TraderOrders(trader.Hex(), OrderQuery{State: OrderStateOpen, Limit: 10})
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(page.Orders).Should(BeEmpty())
+					Expect(page.More).Should(BeTrue())
+					Expect(page.Cursor).Should(Equal(2))
+
+					page, err = orderer.TraderOrders(trader.Hex(), OrderQuery{State: OrderStateOpen, Cursor: page.Cursor, Limit: 10})
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(page.Orders).Should(BeEmpty())
+					Expect(page.More).Should(BeTrue())
+					Expect(page.Cursor).Should(Equal(4))
+
+					page, err = orderer.TraderOrders(trader.Hex(), OrderQuery{State: OrderStateOpen, Cursor: page.Cursor, Limit: 10})
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(orderIDs(page)).Should(Equal([]string{orders[0]}))
+					Expect(page.More).Should(BeFalse())
+				})
+			})
+
+			It("should return an error for unknown states", func() {
+				_, err := orderer.TraderOrders(trader.Hex(), OrderQuery{State: "unknown", Limit: 10})
+				Expect(err).Should(Equal(ErrUnknownOrderState))
+			})
+
+			It("should not return the orders of other traders", func() {
+				page, err := orderer.TraderOrders("0x2", OrderQuery{Limit: 10})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(page.Orders).Should(BeEmpty())
+			})
+		})
+	}
+})
@@ -17,6 +17,7 @@ type Request interface {
 // An OpenOrderFragmentMappingRequest is a Request for the Ingress to open an
 // order.Order by forwarding order.Fragments to their respective Darknodes.
 type OpenOrderFragmentMappingRequest struct {
+	trader                  [20]byte
 	orderID                 order.ID
 	orderFragmentMapping    OrderFragmentMapping
 	orderFragmentEpochDepth int
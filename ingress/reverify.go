@@ -0,0 +1,191 @@
+package ingress
+
+import (
+	"expvar"
+	"fmt"
+	"time"
+)
+
+// reverifyBatchSize is the number of stale traders that are loaded at a time
+// during re-verification.
+const reverifyBatchSize = 100
+
+// reverifyMetrics are the counts of the KYCReverifier, published by expvar
+// under "kyc_reverify".
+var reverifyMetrics = expvar.NewMap("kyc_reverify")
+
+// A Reverification summarizes a pass of a KYCReverifier.
+type Reverification struct {
+	// Checked is the number of traders that were re-verified.
+	Checked int
+	// Reverified is the number of verifications that are still valid.
+	Reverified int
+	// Revoked is the number of verifications that have been cleared.
+	Revoked int
+	// Failed is the number of verifications that could not be checked, and
+	// are left unchanged until the next pass.
+	Failed int
+}
+
+// A KYCReverifier periodically re-verifies traders whose verification has not
+// been checked recently, and clears verifications that have been revoked
+// (e.g. a Kyber user unlinking a wallet).
+type KYCReverifier interface {
+	// Run re-verifies traders on every interval until the done channel is
+	// closed. Errors are written to the returned channel.
+	Run(done <-chan struct{}) <-chan error
+
+	// Reverify runs a single pass over all stale traders.
+	Reverify() (Reverification, error)
+}
+
+type kycReverifier struct {
+	loginer  Loginer
+	verifier KYCVerifier
+	links    []KYCLink
+	maxAge   time.Duration
+	interval time.Duration
+}
+
+// NewKYCReverifier returns a KYCReverifier that checks traders last verified
+// more than maxAge ago against the providers of the links. Cached results of
+// the KYCVerifier are forgotten when a verification is revoked.
+func NewKYCReverifier(loginer Loginer, verifier KYCVerifier, links []KYCLink, maxAge, interval time.Duration) KYCReverifier {
+	return &kycReverifier{
+		loginer:  loginer,
+		verifier: verifier,
+		links:    links,
+		maxAge:   maxAge,
+		interval: interval,
+	}
+}
+
+// Run implements the KYCReverifier interface.
+func (reverifier *kycReverifier) Run(done <-chan struct{}) <-chan error {
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(errs)
+
+		ticker := time.NewTicker(reverifier.interval)
+		defer ticker.Stop()
+
+		for {
+			if _, err := reverifier.Reverify(); err != nil {
+				select {
+				case <-done:
+					return
+				case errs <- err:
+				}
+			}
+
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return errs
+}
+
+// Reverify implements the KYCReverifier interface.
+func (reverifier *kycReverifier) Reverify() (Reverification, error) {
+	reverification := Reverification{}
+	defer func() {
+		reverifyMetrics.Add("runs", 1)
+		reverifyMetrics.Add("checked", int64(reverification.Checked))
+		reverifyMetrics.Add("reverified", int64(reverification.Reverified))
+		reverifyMetrics.Add("revoked", int64(reverification.Revoked))
+		reverifyMetrics.Add("failed", int64(reverification.Failed))
+	}()
+
+	// Traders that fail to be re-verified remain stale, so traders that have
+	// already been checked in this pass are skipped. The pass ends when a
+	// batch has no new traders.
+	verifiedBefore := time.Now().Add(-reverifier.maxAge).Unix()
+	checked := map[string]bool{}
+	var firstErr error
+	for {
+		limit := len(checked) + reverifyBatchSize
+		logins, err := reverifier.loginer.StaleLogins(verifiedBefore, limit)
+		if err != nil {
+			return reverification, fmt.Errorf("cannot load stale traders: %v", err)
+		}
+
+		unchecked := 0
+		for _, login := range logins {
+			if checked[login.Address] {
+				continue
+			}
+			checked[login.Address] = true
+			unchecked++
+
+			reverification.Checked++
+			if err := reverifier.reverifyLogin(login, &reverification); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		if unchecked == 0 || len(logins) < limit {
+			break
+		}
+	}
+
+	if firstErr != nil {
+		return reverification, fmt.Errorf("cannot re-verify %v verifications: %v", reverification.Failed, firstErr)
+	}
+	return reverification, nil
+}
+
+// reverifyLogin checks each verification recorded for the trader with its
+// provider. Verifications that are still valid are refreshed, and the others
+// are revoked. Cached results of the trader and the addresses it authorized
+// are forgotten when a verification is revoked.
+func (reverifier *kycReverifier) reverifyLogin(login Login, reverification *Reverification) error {
+	var lastErr error
+	revoked := false
+	for _, link := range reverifier.links {
+		provider := link.Provider
+		if !provider.Recorded(login) {
+			continue
+		}
+
+		verified, reference, err := provider.Verify(login.Address, login)
+		if err != nil {
+			reverification.Failed++
+			lastErr = fmt.Errorf("cannot check %v verification of %v: %v", provider.Name(), login.Address, err)
+			continue
+		}
+		if verified {
+			if err := reverifier.loginer.UpdateLogin(login.Address, reference, provider.KYCType()); err != nil {
+				reverification.Failed++
+				lastErr = fmt.Errorf("cannot update %v verification of %v: %v", provider.Name(), login.Address, err)
+				continue
+			}
+			reverification.Reverified++
+			continue
+		}
+		if err := reverifier.loginer.RevokeLogin(login.Address, provider.KYCType(), fmt.Sprintf("%v verification no longer valid", provider.Name())); err != nil {
+			reverification.Failed++
+			lastErr = fmt.Errorf("cannot revoke %v verification of %v: %v", provider.Name(), login.Address, err)
+			continue
+		}
+		reverification.Revoked++
+		revoked = true
+	}
+
+	if revoked {
+		// Authorized addresses inherit the verification of the trader, so
+		// their cached results are forgotten as well.
+		reverifier.verifier.ForgetVerification(login.Address)
+		authorized, err := reverifier.loginer.AuthorizedLogins(login.Address)
+		if err != nil {
+			return fmt.Errorf("cannot load addresses authorized by %v: %v", login.Address, err)
+		}
+		for _, authorizedLogin := range authorized {
+			reverifier.verifier.ForgetVerification(authorizedLogin.Address)
+		}
+	}
+	return lastErr
+}
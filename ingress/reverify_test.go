@@ -0,0 +1,148 @@
+package ingress_test
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/republicprotocol/renex-ingress-go/config"
+	. "github.com/republicprotocol/renex-ingress-go/ingress"
+)
+
+var _ = Describe("KYC reverifier", func() {
+
+	var loginer Loginer
+	var verifier *mockKYCVerifier
+	var wyre, kyber *mockKYCProvider
+	var links []KYCLink
+
+	// A negative age treats every verification as stale.
+	stale := -time.Hour
+
+	BeforeEach(func() {
+		loginer = NewLoginerWithDB(newSQLiteDB())
+		verifier = &mockKYCVerifier{mu: new(sync.Mutex), kycType: KYCWyre}
+		wyre = &mockKYCProvider{kycType: KYCWyre}
+		kyber = &mockKYCProvider{kycType: KYCKyber, reference: 42}
+		links = []KYCLink{{Provider: wyre}, {Provider: kyber, Freshness: time.Hour}}
+
+		Expect(loginer.InsertLogin("0xtrader", "")).ShouldNot(HaveOccurred())
+		Expect(loginer.UpdateLogin("0xtrader", 42, KYCKyber)).ShouldNot(HaveOccurred())
+	})
+
+	It("should revoke verifications that are no longer valid", func() {
+		reverifier := NewKYCReverifier(loginer, verifier, links, stale, time.Hour)
+		reverification, err := reverifier.Reverify()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(reverification).Should(Equal(Reverification{Checked: 1, Revoked: 1}))
+		Expect(wyre.numVerified).Should(Equal(0))
+
+		login, err := loginer.SelectLogin("0xtrader")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(login.KYCKyber).Should(BeZero())
+		audits, err := loginer.KYCAudits("0xtrader")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(audits).Should(HaveLen(1))
+		Expect(verifier.numForgotten).Should(Equal(1))
+	})
+
+	It("should forget the verifications of authorized addresses when revoking", func() {
+		Expect(loginer.Authorize("0xtrader", "0xauthorized")).ShouldNot(HaveOccurred())
+		reverifier := NewKYCReverifier(loginer, verifier, links, stale, time.Hour)
+		reverification, err := reverifier.Reverify()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(reverification.Revoked).Should(Equal(1))
+		Expect(verifier.forgotten).Should(ConsistOf("0xtrader", "0xauthorized"))
+	})
+
+	It("should check recorded wyre verifications against the wyre contract", func() {
+		conf := config.Config{KYCProviders: []config.KYCProviderConfig{{Name: config.KYCProviderWyre}}}
+		login := Login{Address: "0xtrader", KYCWyre: "0xtrader"}
+
+		watchedLinks, err := NewKYCLinks(conf, &unverifiedRenExBinder{newRenExBinder()})
+		Expect(err).ShouldNot(HaveOccurred())
+		verified, _, err := watchedLinks[0].Provider.Verify(login.Address, login)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(verified).Should(BeTrue())
+
+		reverifyLinks, err := NewReverifyKYCLinks(conf, &unverifiedRenExBinder{newRenExBinder()})
+		Expect(err).ShouldNot(HaveOccurred())
+		verified, _, err = reverifyLinks[0].Provider.Verify(login.Address, login)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(verified).Should(BeFalse())
+	})
+
+	It("should keep verifications that are still valid", func() {
+		kyber.verified = true
+		reverifier := NewKYCReverifier(loginer, verifier, links, stale, time.Hour)
+		reverification, err := reverifier.Reverify()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(reverification).Should(Equal(Reverification{Checked: 1, Reverified: 1}))
+
+		login, err := loginer.SelectLogin("0xtrader")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(login.KYCKyber).Should(Equal(int64(42)))
+		Expect(verifier.numForgotten).Should(Equal(0))
+	})
+
+	It("should not check traders that were verified recently", func() {
+		reverifier := NewKYCReverifier(loginer, verifier, links, time.Hour, time.Hour)
+		reverification, err := reverifier.Reverify()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(reverification.Checked).Should(Equal(0))
+		Expect(kyber.numVerified).Should(Equal(0))
+	})
+
+	It("should leave verifications unchanged when a provider returns an error", func() {
+		kyber.err = errors.New("kyber is unavailable")
+		reverifier := NewKYCReverifier(loginer, verifier, links, stale, time.Hour)
+		reverification, err := reverifier.Reverify()
+		Expect(err).Should(HaveOccurred())
+		Expect(reverification).Should(Equal(Reverification{Checked: 1, Failed: 1}))
+
+		login, err := loginer.SelectLogin("0xtrader")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(login.KYCKyber).Should(Equal(int64(42)))
+	})
+
+	It("should check every stale trader once when verifications fail", func() {
+		for i := 0; i < 250; i++ {
+			address := fmt.Sprintf("0x%040d", i)
+			Expect(loginer.InsertLogin(address, "")).ShouldNot(HaveOccurred())
+			Expect(loginer.UpdateLogin(address, 42, KYCKyber)).ShouldNot(HaveOccurred())
+		}
+		kyber.err = errors.New("kyber is unavailable")
+		reverifier := NewKYCReverifier(loginer, verifier, links, stale, time.Hour)
+		reverification, err := reverifier.Reverify()
+		Expect(err).Should(HaveOccurred())
+		Expect(reverification.Checked).Should(Equal(251))
+		Expect(kyber.numVerified).Should(Equal(251))
+	})
+
+	It("should re-verify traders until done", func() {
+		done := make(chan struct{})
+		reverifier := NewKYCReverifier(loginer, verifier, links, stale, time.Hour)
+		errs := reverifier.Run(done)
+		Eventually(func() int {
+			login, err := loginer.SelectLogin("0xtrader")
+			Expect(err).ShouldNot(HaveOccurred())
+			return int(login.KYCKyber)
+		}).Should(BeZero())
+		close(done)
+		Eventually(errs).Should(BeClosed())
+	})
+})
+
+// unverifiedRenExBinder is a RenEx contract that holds no Wyre KYC tokens.
+type unverifiedRenExBinder struct {
+	*renExBinder
+}
+
+func (binder *unverifiedRenExBinder) BalanceOf(common.Address) (*big.Int, error) {
+	return big.NewInt(0), nil
+}
@@ -0,0 +1,60 @@
+package ingress
+
+import (
+	"database/sql"
+	"time"
+)
+
+// The schema of the sessions table is defined by Migrations.
+
+// A Sessioner stores the sessions that are issued to traders to stream their
+// events. Unlike nonces, a session can be used until it expires, so that
+// streams can reconnect without signing another request.
+type Sessioner interface {
+	// InsertSession stores a session of the trader that can be used until the
+	// unix timestamp. Expired sessions are deleted.
+	InsertSession(token, trader string, expiresAt int64) error
+
+	// SessionTrader returns the trader of the session. It returns
+	// ErrInvalidSession if the session is unknown, or has expired.
+	SessionTrader(token string) (string, error)
+}
+
+type sessioner struct {
+	*DB
+}
+
+// NewSessioner returns a Sessioner that stores sessions in the database at the
+// URL.
+func NewSessioner(databaseURL string) (Sessioner, error) {
+	db, err := OpenDB(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	return NewSessionerWithDB(db), nil
+}
+
+// NewSessionerWithDB returns a Sessioner that stores sessions in an open
+// database.
+func NewSessionerWithDB(db *DB) Sessioner {
+	return &sessioner{
+		db,
+	}
+}
+
+func (sessioner *sessioner) InsertSession(token, trader string, expiresAt int64) error {
+	if _, err := sessioner.Exec("DELETE FROM sessions WHERE expires_at <= $1", time.Now().Unix()); err != nil {
+		return err
+	}
+	_, err := sessioner.Exec("INSERT INTO sessions (token, trader, expires_at) VALUES ($1, $2, $3)", token, normalizeAddress(trader), expiresAt)
+	return err
+}
+
+func (sessioner *sessioner) SessionTrader(token string) (string, error) {
+	var trader string
+	err := sessioner.QueryRow("SELECT trader FROM sessions WHERE token=$1 AND expires_at > $2", token, time.Now().Unix()).Scan(&trader)
+	if err == sql.ErrNoRows {
+		return "", ErrInvalidSession
+	}
+	return trader, err
+}
@@ -0,0 +1,57 @@
+package ingress_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/republicprotocol/renex-ingress-go/ingress"
+)
+
+var _ = Describe("Sessioner", func() {
+
+	trader := "0x62026B5aC38f1b186c7af0b18Aee8B2EccC2d852"
+
+	newSQLiteSessioner := func() Sessioner {
+		db, err := OpenDB(SQLiteURLPrefix + ":memory:")
+		Expect(err).ShouldNot(HaveOccurred())
+		_, err = db.Migrate()
+		Expect(err).ShouldNot(HaveOccurred())
+		return NewSessionerWithDB(db)
+	}
+
+	for _, backend := range []struct {
+		name         string
+		newSessioner func() Sessioner
+	}{
+		{"memory", NewMemorySessioner},
+		{"sqlite", newSQLiteSessioner},
+	} {
+		backend := backend
+
+		Context("when using "+backend.name+" storage", func() {
+
+			It("should return the trader of a session until it expires", func() {
+				sessioner := backend.newSessioner()
+				Expect(sessioner.InsertSession("token", trader, time.Now().Add(time.Minute).Unix())).ShouldNot(HaveOccurred())
+				for i := 0; i < 2; i++ {
+					sessionTrader, err := sessioner.SessionTrader("token")
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(sessionTrader).Should(Equal("0x62026b5ac38f1b186c7af0b18aee8b2eccc2d852"))
+				}
+			})
+
+			It("should not return the trader of unknown sessions", func() {
+				_, err := backend.newSessioner().SessionTrader("unknown")
+				Expect(err).Should(Equal(ErrInvalidSession))
+			})
+
+			It("should not return the trader of expired sessions", func() {
+				sessioner := backend.newSessioner()
+				Expect(sessioner.InsertSession("token", trader, time.Now().Add(-time.Second).Unix())).ShouldNot(HaveOccurred())
+				_, err := sessioner.SessionTrader("token")
+				Expect(err).Should(Equal(ErrInvalidSession))
+			})
+		})
+	}
+})
@@ -0,0 +1,196 @@
+package ingress
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"expvar"
+	"fmt"
+	"time"
+
+	"github.com/republicprotocol/renex-ingress-go/contract"
+)
+
+// settlementCursor is the name of the cursor stored by the
+// SettlementWatcher.
+const settlementCursor = "settlement"
+
+// settlementMetrics are the counts of the SettlementWatcher, published by
+// expvar under "settlement_watcher".
+var settlementMetrics = expvar.NewMap("settlement_watcher")
+
+// A SettlementWatcher follows the settlements of orders by the
+// RenExSettlement contract, and finalizes the swaps of orders that have
+// registered a partial swap ahead of time, so that swaps can be returned
+// without waiting for the contract when they are requested. Traders are
+// notified when the orders approved by the Ingress are settled, and when their
+// swaps are finalized.
+type SettlementWatcher interface {
+	// Run syncs the watcher on every interval until the done channel is
+	// closed. Errors are written to the returned channel.
+	Run(done <-chan struct{}) <-chan error
+
+	// Sync processes all confirmed blocks after the stored cursor. When no
+	// cursor is stored, blocks are processed from the start block. Blocks are
+	// only processed while the watcher holds the lease on the cursor, so that
+	// the processes of the Ingress do not process the same blocks.
+	Sync() error
+}
+
+type settlementWatcher struct {
+	binder     SettlementContractBinder
+	swapper    Swapper
+	orderer    Orderer
+	cursorer   Cursorer
+	leaser     Leaser
+	notifier   Notifier
+	startBlock uint64
+	interval   time.Duration
+}
+
+// NewSettlementWatcher returns a SettlementWatcher that stores finalized
+// swaps using the Swapper, and notifies settlements of the orders approved by
+// the Orderer.
+func NewSettlementWatcher(binder SettlementContractBinder, swapper Swapper, orderer Orderer, cursorer Cursorer, leaser Leaser, notifier Notifier, startBlock uint64, interval time.Duration) SettlementWatcher {
+	return &settlementWatcher{
+		binder:     binder,
+		swapper:    swapper,
+		orderer:    orderer,
+		cursorer:   cursorer,
+		leaser:     leaser,
+		notifier:   notifier,
+		startBlock: startBlock,
+		interval:   interval,
+	}
+}
+
+// Run implements the SettlementWatcher interface.
+func (watcher *settlementWatcher) Run(done <-chan struct{}) <-chan error {
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(errs)
+
+		ticker := time.NewTicker(watcher.interval)
+		defer ticker.Stop()
+
+		for {
+			if err := watcher.Sync(); err != nil {
+				select {
+				case <-done:
+					return
+				case errs <- err:
+				}
+			}
+
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return errs
+}
+
+// Sync implements the SettlementWatcher interface.
+func (watcher *settlementWatcher) Sync() error {
+	held, err := holdLease(watcher.leaser, settlementCursor)
+	if err != nil {
+		return err
+	}
+	if !held {
+		settlementMetrics.Add("skipped", 1)
+		return nil
+	}
+
+	next, ok, err := watcher.cursorer.Cursor(settlementCursor)
+	if err != nil {
+		return fmt.Errorf("cannot load settlement cursor: %v", err)
+	}
+	if !ok {
+		next = watcher.startBlock
+	}
+
+	latest, err := watcher.binder.BlockNumber()
+	if err != nil {
+		return fmt.Errorf("cannot get latest block: %v", err)
+	}
+	if latest < WatchConfirmations {
+		return nil
+	}
+	end := latest - WatchConfirmations
+
+	for start := next; next <= end; {
+		// The lease is renewed before each range of blocks, and the watcher
+		// stops if another process has taken it over.
+		if next > start {
+			if held, err := holdLease(watcher.leaser, settlementCursor); err != nil || !held {
+				return err
+			}
+		}
+		to := next + WatchBlockRange - 1
+		if to > end {
+			to = end
+		}
+		settlements, err := watcher.binder.OrderSettlements(next, to)
+		if err != nil {
+			return fmt.Errorf("cannot filter order settlements from block %v to %v: %v", next, to, err)
+		}
+		for _, settlement := range settlements {
+			if err := watcher.processSettlement(settlement); err != nil {
+				return err
+			}
+		}
+		if err := watcher.cursorer.UpdateCursor(settlementCursor, to+1); err != nil {
+			return fmt.Errorf("cannot store settlement cursor: %v", err)
+		}
+		settlementMetrics.Add("settlements", int64(len(settlements)))
+		next = to + 1
+	}
+	return nil
+}
+
+// processSettlement notifies the trader of a settled order that was approved
+// by the Ingress, and finalizes the swap of the order, which notifies the
+// trader of the finalized swap. Orders that have not registered a partial swap
+// were not opened by a trader of this Ingress, and are not finalized. When the
+// matched order has not registered a partial swap, the swap is finalized when
+// it is requested instead.
+func (watcher *settlementWatcher) processSettlement(settlement contract.OrderSettlement) error {
+	id := base64.StdEncoding.EncodeToString(settlement.OrderID[:])
+	order, err := watcher.orderer.ApprovedOrder(id)
+	switch err {
+	case nil:
+		if err := watcher.notifier.Notify(Event{Type: EventOrderSettled, OrderID: id, Trader: order.Trader}); err != nil {
+			return fmt.Errorf("cannot notify settlement of order=%v: %v", id, err)
+		}
+	case sql.ErrNoRows:
+		settlementMetrics.Add("unapproved", 1)
+	default:
+		return fmt.Errorf("cannot get approval of order=%v: %v", id, err)
+	}
+
+	if _, err := watcher.swapper.PartialSwap(id); err != nil {
+		if err == sql.ErrNoRows {
+			settlementMetrics.Add("ignored", 1)
+			return nil
+		}
+		return fmt.Errorf("cannot get partial swap for order=%v: %v", id, err)
+	}
+
+	status, err := watcher.swapper.SwapStatus(id)
+	if err != nil {
+		return fmt.Errorf("cannot finalize swap for order=%v: %v", id, err)
+	}
+	if status.UnsafeTimeLock {
+		settlementMetrics.Add("unsafe", 1)
+		return nil
+	}
+	if status.FinalizedSwap == nil {
+		settlementMetrics.Add("pending", 1)
+		return nil
+	}
+	settlementMetrics.Add("finalized", 1)
+	return nil
+}
@@ -0,0 +1,206 @@
+package ingress_test
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/republicprotocol/renex-ingress-go/ingress"
+
+	"github.com/republicprotocol/renex-ingress-go/contract"
+)
+
+var _ = Describe("Settlement watcher", func() {
+
+	var binder *mockSettlementBinder
+	var swapBinder *mockSwapContractBinder
+	var swapper Swapper
+	var orderer Orderer
+	var cursorer Cursorer
+	var leaser Leaser
+	var db *DB
+	var notifier *mockNotifier
+	var watcher SettlementWatcher
+	var buy, sell PartialSwap
+
+	BeforeEach(func() {
+		binder = &mockSettlementBinder{}
+		swapBinder = newMockSwapContractBinder()
+		db = newSQLiteDB()
+		notifier = &mockNotifier{mu: new(sync.Mutex)}
+		swapper = NewSwapperWithDB(db, swapBinder, notifier)
+		orderer = NewOrdererWithDB(db, nil)
+		cursorer = NewCursorerWithDB(db)
+		leaser = NewLeaserWithDB(db)
+		watcher = NewSettlementWatcher(binder, swapper, orderer, cursorer, leaser, notifier, 0, time.Hour)
+
+		buy, sell = newPartialSwap(1), newPartialSwap(2)
+		for _, swap := range []PartialSwap{buy, sell} {
+			Expect(orderer.InsertOrder(ApprovedOrder{OrderID: swap.OrderID, Trader: swap.KycAddr, ApprovedAt: 1})).ShouldNot(HaveOccurred())
+		}
+	})
+
+	It("should finalize swaps when orders are settled", func() {
+		Expect(swapper.InsertPartialSwap(buy)).ShouldNot(HaveOccurred())
+		Expect(swapper.InsertPartialSwap(sell)).ShouldNot(HaveOccurred())
+		swapBinder.settle(buy.OrderID, sell.OrderID)
+		binder.settle(buy.OrderID, 10)
+		binder.settle(sell.OrderID, 10)
+		binder.latest = 10 + WatchConfirmations
+		Expect(watcher.Sync()).ShouldNot(HaveOccurred())
+
+		// Finalized swaps are returned without checking the contract
+		calls := swapBinder.numCalls()
+		for _, id := range []string{buy.OrderID, sell.OrderID} {
+			swap, canceled, err := swapper.FinalizedSwap(id)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(canceled).Should(BeFalse())
+			Expect(swap.OrderID).Should(Equal(id))
+		}
+		Expect(swapBinder.numCalls()).Should(Equal(calls))
+		Expect(notifier.eventTypes(buy.OrderID)).Should(Equal([]string{EventOrderSettled, EventSwapFinalized}))
+	})
+
+	It("should ignore orders without partial swaps", func() {
+		swapBinder.settle(buy.OrderID, sell.OrderID)
+		binder.settle(buy.OrderID, 10)
+		binder.settle(sell.OrderID, 10)
+		binder.latest = 10 + WatchConfirmations
+		Expect(watcher.Sync()).ShouldNot(HaveOccurred())
+		Expect(swapBinder.numCalls()).Should(Equal(0))
+		Expect(notifier.eventTypes(buy.OrderID)).Should(Equal([]string{EventOrderSettled}))
+	})
+
+	It("should not finalize swaps until the matched order registers a partial swap", func() {
+		Expect(swapper.InsertPartialSwap(buy)).ShouldNot(HaveOccurred())
+		swapBinder.settle(buy.OrderID, sell.OrderID)
+		binder.settle(buy.OrderID, 10)
+		binder.settle(sell.OrderID, 10)
+		binder.latest = 10 + WatchConfirmations
+		Expect(watcher.Sync()).ShouldNot(HaveOccurred())
+
+		status, err := swapper.SwapStatus(buy.OrderID)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(status.Settled).Should(BeTrue())
+		Expect(status.FinalizedSwap).Should(BeNil())
+	})
+
+	It("should notify swaps that are finalized when they are requested", func() {
+		Expect(swapper.InsertPartialSwap(buy)).ShouldNot(HaveOccurred())
+		swapBinder.settle(buy.OrderID, sell.OrderID)
+		binder.settle(buy.OrderID, 10)
+		binder.latest = 10 + WatchConfirmations
+		Expect(watcher.Sync()).ShouldNot(HaveOccurred())
+		Expect(notifier.eventTypes(buy.OrderID)).Should(Equal([]string{EventOrderSettled}))
+
+		Expect(swapper.InsertPartialSwap(sell)).ShouldNot(HaveOccurred())
+		for i := 0; i < 2; i++ {
+			_, canceled, err := swapper.FinalizedSwap(buy.OrderID)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(canceled).Should(BeFalse())
+		}
+		Expect(notifier.eventTypes(buy.OrderID)).Should(Equal([]string{EventOrderSettled, EventSwapFinalized}))
+	})
+
+	It("should not notify settlements of orders that were not approved", func() {
+		other := newPartialSwap(3)
+		binder.settle(other.OrderID, 10)
+		binder.latest = 10 + WatchConfirmations
+		Expect(watcher.Sync()).ShouldNot(HaveOccurred())
+		Expect(notifier.eventTypes(other.OrderID)).Should(BeEmpty())
+	})
+
+	It("should notify the trader that approved the order", func() {
+		binder.settle(buy.OrderID, 10)
+		binder.latest = 10 + WatchConfirmations
+		Expect(watcher.Sync()).ShouldNot(HaveOccurred())
+
+		notifier.mu.Lock()
+		defer notifier.mu.Unlock()
+		Expect(notifier.events).Should(HaveLen(1))
+		Expect(notifier.events[0].Trader).Should(Equal(strings.ToLower(buy.KycAddr)))
+	})
+
+	It("should not process blocks while another process holds the lease", func() {
+		held, err := NewLeaserWithDB(db).AcquireLease("settlement", time.Hour)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(held).Should(BeTrue())
+
+		binder.settle(buy.OrderID, 10)
+		binder.latest = 10 + WatchConfirmations
+		Expect(watcher.Sync()).ShouldNot(HaveOccurred())
+		Expect(binder.filtered).Should(BeEmpty())
+		Expect(notifier.eventTypes(buy.OrderID)).Should(BeEmpty())
+		_, ok, err := cursorer.Cursor("settlement")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(ok).Should(BeFalse())
+	})
+
+	It("should not process unconfirmed blocks", func() {
+		Expect(swapper.InsertPartialSwap(buy)).ShouldNot(HaveOccurred())
+		Expect(swapper.InsertPartialSwap(sell)).ShouldNot(HaveOccurred())
+		swapBinder.settle(buy.OrderID, sell.OrderID)
+		binder.settle(buy.OrderID, 10)
+		binder.latest = 10 + WatchConfirmations - 1
+		Expect(watcher.Sync()).ShouldNot(HaveOccurred())
+		Expect(swapBinder.numCalls()).Should(Equal(0))
+	})
+
+	It("should resume from the stored cursor", func() {
+		binder.latest = 3 * WatchBlockRange
+		Expect(watcher.Sync()).ShouldNot(HaveOccurred())
+		block, ok, err := cursorer.Cursor("settlement")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(ok).Should(BeTrue())
+		Expect(block).Should(Equal(uint64(3*WatchBlockRange - WatchConfirmations + 1)))
+
+		binder.filtered = nil
+		watcher = NewSettlementWatcher(binder, swapper, orderer, cursorer, leaser, notifier, 0, time.Hour)
+		binder.latest += 10
+		Expect(watcher.Sync()).ShouldNot(HaveOccurred())
+		Expect(binder.filtered).Should(Equal([][2]uint64{{block, block + 9}}))
+	})
+
+	It("should not advance the cursor when filtering fails", func() {
+		binder.err = errors.New("cannot connect to ethereum")
+		binder.latest = 100
+		Expect(watcher.Sync()).Should(HaveOccurred())
+		_, ok, err := cursorer.Cursor("settlement")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(ok).Should(BeFalse())
+	})
+})
+
+// mockSettlementBinder is a RenExSettlement contract with a list of order
+// settlements.
+type mockSettlementBinder struct {
+	latest      uint64
+	settlements []contract.OrderSettlement
+	filtered    [][2]uint64
+	err         error
+}
+
+func (binder *mockSettlementBinder) settle(id string, block uint64) {
+	binder.settlements = append(binder.settlements, contract.OrderSettlement{OrderID: mockOrderID(id), BlockNumber: block})
+}
+
+func (binder *mockSettlementBinder) BlockNumber() (uint64, error) {
+	return binder.latest, nil
+}
+
+func (binder *mockSettlementBinder) OrderSettlements(start, end uint64) ([]contract.OrderSettlement, error) {
+	if binder.err != nil {
+		return nil, binder.err
+	}
+	binder.filtered = append(binder.filtered, [2]uint64{start, end})
+	settlements := []contract.OrderSettlement{}
+	for _, settlement := range binder.settlements {
+		if settlement.BlockNumber >= start && settlement.BlockNumber <= end {
+			settlements = append(settlements, settlement)
+		}
+	}
+	return settlements, nil
+}
@@ -0,0 +1,847 @@
+package ingress_test
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/republicprotocol/renex-ingress-go/ingress"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/republicprotocol/renex-ingress-go/contract"
+)
+
+// storageBackend creates the Loginer and Swapper of a storage backend. All
+// storage backends must pass the same conformance tests.
+type storageBackend struct {
+	name       string
+	newStorage func(binder SwapContractBinder) (Loginer, Swapper)
+}
+
+func storageBackends() []storageBackend {
+	backends := []storageBackend{
+		{
+			name: "memory",
+			newStorage: func(binder SwapContractBinder) (Loginer, Swapper) {
+				return NewMemoryLoginer(), NewMemorySwapper(binder, &mockNotifier{mu: new(sync.Mutex)})
+			},
+		},
+		{
+			name: "sqlite",
+			newStorage: func(binder SwapContractBinder) (Loginer, Swapper) {
+				return newDBStorage(SQLiteURLPrefix+":memory:", binder)
+			},
+		},
+	}
+
+	// Postgres is only tested when a test database is available. All rows are
+	// deleted from the test database before each test.
+	if databaseURL := os.Getenv("TEST_DATABASE_URL"); databaseURL != "" {
+		backends = append(backends, storageBackend{
+			name: "postgres",
+			newStorage: func(binder SwapContractBinder) (Loginer, Swapper) {
+				return newDBStorage(databaseURL, binder)
+			},
+		})
+	}
+	return backends
+}
+
+// newSQLiteDB returns a migrated SQLite database that is stored in memory.
+func newSQLiteDB() *DB {
+	db, err := OpenDB(SQLiteURLPrefix + ":memory:")
+	Expect(err).ShouldNot(HaveOccurred())
+	_, err = db.Migrate()
+	Expect(err).ShouldNot(HaveOccurred())
+	return db
+}
+
+func newDBStorage(databaseURL string, binder SwapContractBinder) (Loginer, Swapper) {
+	db, err := OpenDB(databaseURL)
+	Expect(err).ShouldNot(HaveOccurred())
+	_, err = db.Migrate()
+	Expect(err).ShouldNot(HaveOccurred())
+	for _, table := range []string{"traders", "partial_swap", "finalized_swap", "kyc_audit"} {
+		_, err := db.Exec("DELETE FROM " + table)
+		Expect(err).ShouldNot(HaveOccurred())
+	}
+	return NewLoginerWithDB(db), NewSwapperWithDB(db, binder, &mockNotifier{mu: new(sync.Mutex)})
+}
+
+var _ = Describe("Storage backends", func() {
+
+	for _, backend := range storageBackends() {
+		backend := backend
+
+		Context("when using "+backend.name+" storage", func() {
+
+			var loginer Loginer
+			var swapper Swapper
+			var binder *mockSwapContractBinder
+
+			BeforeEach(func() {
+				binder = newMockSwapContractBinder()
+				loginer, swapper = backend.newStorage(binder)
+			})
+
+			Context("when storing traders", func() {
+
+				It("should return an error for traders that have not logged in", func() {
+					_, err := loginer.SelectLogin("0xunknown")
+					Expect(err).Should(Equal(sql.ErrNoRows))
+				})
+
+				It("should select traders that have logged in without kyc", func() {
+					Expect(loginer.InsertLogin("0xtrader", "0xreferrer")).ShouldNot(HaveOccurred())
+					login, err := loginer.SelectLogin("0xtrader")
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(login.Address).Should(Equal("0xtrader"))
+					Expect(login.Referrer).Should(Equal("0xreferrer"))
+					Expect(login.ReferralCode).ShouldNot(BeEmpty())
+					Expect(login.CreatedAt).ShouldNot(BeZero())
+					Expect(login.KYCWyre).Should(BeEmpty())
+					Expect(login.KYCKyber).Should(BeZero())
+					Expect(login.LastVerifiedAt).Should(BeZero())
+				})
+
+				It("should ignore traders that log in more than once", func() {
+					Expect(loginer.InsertLogin("0xtrader", "0xreferrer")).ShouldNot(HaveOccurred())
+					Expect(loginer.InsertLogin("0xtrader", "0xreferrer")).ShouldNot(HaveOccurred())
+				})
+
+				It("should ignore the case of addresses", func() {
+					Expect(loginer.InsertLogin("0xTRADER", "")).ShouldNot(HaveOccurred())
+					_, err := loginer.SelectLogin("0xtrader")
+					Expect(err).ShouldNot(HaveOccurred())
+				})
+
+				It("should record wyre verification", func() {
+					Expect(loginer.InsertLogin("0xtrader", "")).ShouldNot(HaveOccurred())
+					Expect(loginer.UpdateLogin("0xtrader", 0, KYCWyre)).ShouldNot(HaveOccurred())
+					login, err := loginer.SelectLogin("0xtrader")
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(login.KYCWyre).Should(Equal("0xtrader"))
+					Expect(login.KYCKyber).Should(BeZero())
+					Expect(login.LastVerifiedAt).ShouldNot(BeZero())
+				})
+
+				It("should record kyber verification", func() {
+					Expect(loginer.InsertLogin("0xtrader", "")).ShouldNot(HaveOccurred())
+					Expect(loginer.UpdateLogin("0xtrader", 42, KYCKyber)).ShouldNot(HaveOccurred())
+					login, err := loginer.SelectLogin("0xtrader")
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(login.KYCKyber).Should(Equal(int64(42)))
+					Expect(login.LastVerifiedAt).ShouldNot(BeZero())
+				})
+
+				It("should return an error when recording kyber verification for unknown traders", func() {
+					Expect(loginer.UpdateLogin("0xunknown", 42, KYCKyber)).Should(Equal(sql.ErrNoRows))
+				})
+			})
+
+			Context("when referring traders", func() {
+
+				It("should select traders by their referral code", func() {
+					Expect(loginer.InsertLogin("0xreferrer", "")).ShouldNot(HaveOccurred())
+					referrer, err := loginer.SelectLogin("0xreferrer")
+					Expect(err).ShouldNot(HaveOccurred())
+					login, err := loginer.SelectLoginByReferralCode(referrer.ReferralCode)
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(login.Address).Should(Equal("0xreferrer"))
+				})
+
+				It("should return an error for unknown referral codes", func() {
+					_, err := loginer.SelectLoginByReferralCode("unknown")
+					Expect(err).Should(Equal(sql.ErrNoRows))
+				})
+
+				It("should return the traders that were referred", func() {
+					Expect(loginer.InsertLogin("0xreferrer", "")).ShouldNot(HaveOccurred())
+					Expect(loginer.InsertLogin("0xfirst", "0xreferrer")).ShouldNot(HaveOccurred())
+					Expect(loginer.InsertLogin("0xsecond", "0xreferrer")).ShouldNot(HaveOccurred())
+					Expect(loginer.InsertLogin("0xother", "")).ShouldNot(HaveOccurred())
+					logins, err := loginer.ReferredLogins("0xreferrer")
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(logins).Should(HaveLen(2))
+
+					logins, err = loginer.ReferredLogins("0xother")
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(logins).Should(BeEmpty())
+				})
+			})
+
+			Context("when authorizing traders", func() {
+
+				It("should inherit the kyc of the authorizer", func() {
+					Expect(loginer.InsertLogin("0xauthorizer", "")).ShouldNot(HaveOccurred())
+					Expect(loginer.UpdateLogin("0xauthorizer", 42, KYCKyber)).ShouldNot(HaveOccurred())
+					Expect(loginer.Authorize("0xauthorizer", "0xauthorized")).ShouldNot(HaveOccurred())
+					login, err := loginer.SelectLogin("0xauthorized")
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(login.Authorizer).Should(Equal("0xauthorizer"))
+					Expect(login.KYCKyber).Should(Equal(int64(42)))
+					Expect(login.LastVerifiedAt).ShouldNot(BeZero())
+				})
+
+				It("should update the kyc of authorized traders", func() {
+					Expect(loginer.InsertLogin("0xauthorizer", "")).ShouldNot(HaveOccurred())
+					Expect(loginer.Authorize("0xauthorizer", "0xauthorized")).ShouldNot(HaveOccurred())
+					login, err := loginer.SelectLogin("0xauthorized")
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(login.KYCKyber).Should(BeZero())
+
+					Expect(loginer.UpdateLogin("0xauthorizer", 42, KYCKyber)).ShouldNot(HaveOccurred())
+					login, err = loginer.SelectLogin("0xauthorized")
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(login.KYCKyber).Should(Equal(int64(42)))
+				})
+
+				It("should list the addresses authorized by a trader", func() {
+					Expect(loginer.InsertLogin("0xauthorizer", "")).ShouldNot(HaveOccurred())
+					Expect(loginer.Authorize("0xauthorizer", "0xfirst")).ShouldNot(HaveOccurred())
+					Expect(loginer.Authorize("0xauthorizer", "0xsecond")).ShouldNot(HaveOccurred())
+					logins, err := loginer.AuthorizedLogins("0xAUTHORIZER")
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(logins).Should(HaveLen(2))
+				})
+
+				It("should clear the kyc of revoked addresses and stop inheritance", func() {
+					Expect(loginer.InsertLogin("0xauthorizer", "")).ShouldNot(HaveOccurred())
+					Expect(loginer.UpdateLogin("0xauthorizer", 42, KYCKyber)).ShouldNot(HaveOccurred())
+					Expect(loginer.Authorize("0xauthorizer", "0xauthorized")).ShouldNot(HaveOccurred())
+					Expect(loginer.Unauthorize("0xauthorizer", "0xauthorized")).ShouldNot(HaveOccurred())
+
+					login, err := loginer.SelectLogin("0xauthorized")
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(login.Authorizer).Should(BeEmpty())
+					Expect(login.KYCKyber).Should(BeZero())
+
+					Expect(loginer.UpdateLogin("0xauthorizer", 0, KYCWyre)).ShouldNot(HaveOccurred())
+					login, err = loginer.SelectLogin("0xauthorized")
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(login.KYCWyre).Should(BeEmpty())
+
+					logins, err := loginer.AuthorizedLogins("0xauthorizer")
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(logins).Should(BeEmpty())
+				})
+
+				It("should authorize revoked addresses again", func() {
+					Expect(loginer.InsertLogin("0xauthorizer", "")).ShouldNot(HaveOccurred())
+					Expect(loginer.UpdateLogin("0xauthorizer", 42, KYCKyber)).ShouldNot(HaveOccurred())
+					Expect(loginer.Authorize("0xauthorizer", "0xauthorized")).ShouldNot(HaveOccurred())
+					Expect(loginer.Unauthorize("0xauthorizer", "0xauthorized")).ShouldNot(HaveOccurred())
+					Expect(loginer.Authorize("0xauthorizer", "0xauthorized")).ShouldNot(HaveOccurred())
+
+					login, err := loginer.SelectLogin("0xauthorized")
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(login.Authorizer).Should(Equal("0xauthorizer"))
+					Expect(login.KYCKyber).Should(Equal(int64(42)))
+				})
+
+				It("should not revoke addresses authorized by another trader", func() {
+					Expect(loginer.InsertLogin("0xauthorizer", "")).ShouldNot(HaveOccurred())
+					Expect(loginer.Authorize("0xauthorizer", "0xauthorized")).ShouldNot(HaveOccurred())
+					Expect(loginer.Unauthorize("0xother", "0xauthorized")).Should(Equal(sql.ErrNoRows))
+					Expect(loginer.Unauthorize("0xauthorizer", "0xunknown")).Should(Equal(sql.ErrNoRows))
+				})
+
+				It("should not authorize traders for unknown authorizers", func() {
+					Expect(loginer.Authorize("0xunknown", "0xauthorized")).ShouldNot(HaveOccurred())
+					_, err := loginer.SelectLogin("0xauthorized")
+					Expect(err).Should(Equal(sql.ErrNoRows))
+				})
+			})
+
+			Context("when revoking verifications", func() {
+
+				It("should return stale traders that hold a verification", func() {
+					Expect(loginer.InsertLogin("0xunverified", "")).ShouldNot(HaveOccurred())
+					Expect(loginer.InsertLogin("0xtrader", "")).ShouldNot(HaveOccurred())
+					Expect(loginer.UpdateLogin("0xtrader", 42, KYCKyber)).ShouldNot(HaveOccurred())
+					Expect(loginer.Authorize("0xtrader", "0xauthorized")).ShouldNot(HaveOccurred())
+
+					logins, err := loginer.StaleLogins(time.Now().Unix()+1, 10)
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(logins).Should(HaveLen(1))
+					Expect(logins[0].Address).Should(Equal("0xtrader"))
+					Expect(logins[0].KYCKyber).Should(Equal(int64(42)))
+
+					logins, err = loginer.StaleLogins(time.Now().Unix()-60, 10)
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(logins).Should(BeEmpty())
+				})
+
+				It("should clear the verification of the trader and its authorized addresses", func() {
+					Expect(loginer.InsertLogin("0xtrader", "")).ShouldNot(HaveOccurred())
+					Expect(loginer.UpdateLogin("0xtrader", 0, KYCWyre)).ShouldNot(HaveOccurred())
+					Expect(loginer.UpdateLogin("0xtrader", 42, KYCKyber)).ShouldNot(HaveOccurred())
+					Expect(loginer.Authorize("0xtrader", "0xauthorized")).ShouldNot(HaveOccurred())
+
+					Expect(loginer.RevokeLogin("0xTRADER", KYCKyber, "unlinked")).ShouldNot(HaveOccurred())
+					for _, address := range []string{"0xtrader", "0xauthorized"} {
+						login, err := loginer.SelectLogin(address)
+						Expect(err).ShouldNot(HaveOccurred())
+						Expect(login.KYCKyber).Should(BeZero())
+						Expect(login.KYCWyre).ShouldNot(BeEmpty())
+					}
+
+					Expect(loginer.RevokeLogin("0xtrader", KYCWyre, "token burned")).ShouldNot(HaveOccurred())
+					logins, err := loginer.StaleLogins(time.Now().Unix()+1, 10)
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(logins).Should(BeEmpty())
+				})
+
+				It("should record an audit of revocations", func() {
+					Expect(loginer.InsertLogin("0xtrader", "")).ShouldNot(HaveOccurred())
+					Expect(loginer.UpdateLogin("0xtrader", 42, KYCKyber)).ShouldNot(HaveOccurred())
+					Expect(loginer.RevokeLogin("0xtrader", KYCKyber, "unlinked")).ShouldNot(HaveOccurred())
+
+					audits, err := loginer.KYCAudits("0xtrader")
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(audits).Should(HaveLen(1))
+					Expect(audits[0].KYCType).Should(Equal(KYCKyber))
+					Expect(audits[0].Action).Should(Equal(KYCAuditRevoked))
+					Expect(audits[0].Reason).Should(Equal("unlinked"))
+				})
+
+				It("should return an error for unknown kyc types", func() {
+					Expect(loginer.RevokeLogin("0xtrader", KYCNone, "")).Should(Equal(ErrUnknownKYCType))
+				})
+			})
+
+			Context("when storing partial swaps", func() {
+
+				It("should return an error for unknown partial swaps", func() {
+					_, err := swapper.PartialSwap("unknown")
+					Expect(err).Should(Equal(sql.ErrNoRows))
+				})
+
+				It("should return stored partial swaps", func() {
+					swap := newPartialSwap(1)
+					Expect(swapper.InsertPartialSwap(swap)).ShouldNot(HaveOccurred())
+					stored, err := swapper.PartialSwap(swap.OrderID)
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(stored).Should(Equal(swap))
+				})
+
+				It("should not overwrite stored partial swaps", func() {
+					swap := newPartialSwap(1)
+					Expect(swapper.InsertPartialSwap(swap)).ShouldNot(HaveOccurred())
+					overwrite := swap
+					overwrite.SecretHash = "overwrite"
+					Expect(swapper.InsertPartialSwap(overwrite)).Should(Equal(ErrPartialSwapConflict))
+					overwrite = swap
+					overwrite.SendTo = "overwrite"
+					Expect(swapper.InsertPartialSwap(overwrite)).Should(Equal(ErrPartialSwapConflict))
+					stored, err := swapper.PartialSwap(swap.OrderID)
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(stored).Should(Equal(swap))
+				})
+
+				It("should keep the first registration when a partial swap is registered again", func() {
+					swap := newPartialSwap(1)
+					swap.TimeLockGap, swap.TimeLockMargin = 7200, 3600
+					Expect(swapper.InsertPartialSwap(swap)).ShouldNot(HaveOccurred())
+					again := swap
+					again.CreatedAt += 60
+					Expect(swapper.InsertPartialSwap(again)).ShouldNot(HaveOccurred())
+					stored, err := swapper.PartialSwap(swap.OrderID)
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(stored).Should(Equal(swap))
+				})
+
+				It("should not change the timelock of stored partial swaps", func() {
+					swap := newPartialSwap(1)
+					Expect(swapper.InsertPartialSwap(swap)).ShouldNot(HaveOccurred())
+					again := swap
+					again.TimeLock += 60
+					Expect(swapper.InsertPartialSwap(again)).Should(Equal(ErrPartialSwapConflict))
+					stored, err := swapper.PartialSwap(swap.OrderID)
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(stored).Should(Equal(swap))
+				})
+
+				It("should reject secret hashes that belong to another order", func() {
+					swap := newPartialSwap(1)
+					Expect(swapper.InsertPartialSwap(swap)).ShouldNot(HaveOccurred())
+					other := newPartialSwap(2)
+					other.SecretHash = swap.SecretHash
+					Expect(swapper.InsertPartialSwap(other)).Should(Equal(ErrSecretHashReused))
+					_, err := swapper.PartialSwap(other.OrderID)
+					Expect(err).Should(Equal(sql.ErrNoRows))
+				})
+
+				It("should reject registrations that change the secret hash to one of another order", func() {
+					swap, other := newPartialSwap(1), newPartialSwap(2)
+					Expect(swapper.InsertPartialSwap(swap)).ShouldNot(HaveOccurred())
+					Expect(swapper.InsertPartialSwap(other)).ShouldNot(HaveOccurred())
+					overwrite := other
+					overwrite.SecretHash = swap.SecretHash
+					Expect(swapper.InsertPartialSwap(overwrite)).Should(Equal(ErrPartialSwapConflict))
+				})
+			})
+
+			Context("when finalizing swaps", func() {
+
+				var buy, sell PartialSwap
+
+				BeforeEach(func() {
+					buy, sell = newPartialSwap(1), newPartialSwap(2)
+					Expect(swapper.InsertPartialSwap(buy)).ShouldNot(HaveOccurred())
+					Expect(swapper.InsertPartialSwap(sell)).ShouldNot(HaveOccurred())
+					binder.settle(buy.OrderID, sell.OrderID)
+				})
+
+				It("should finalize swaps for buy orders", func() {
+					swap, canceled, err := swapper.FinalizedSwap(buy.OrderID)
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(canceled).Should(BeFalse())
+					Expect(swap.SendTo).Should(Equal(sell.ReceiveFrom))
+					Expect(swap.ReceiveFrom).Should(Equal(sell.SendTo))
+					Expect(swap.SendAmount).Should(Equal("100"))
+					Expect(swap.ReceiveAmount).Should(Equal("200"))
+					Expect(swap.SecretHash).Should(Equal(sell.SecretHash))
+					Expect(swap.TimeLock).Should(Equal(sell.TimeLock))
+					Expect(swap.ShouldInitiateFirst).Should(BeFalse())
+				})
+
+				It("should finalize swaps for sell orders", func() {
+					swap, canceled, err := swapper.FinalizedSwap(sell.OrderID)
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(canceled).Should(BeFalse())
+					Expect(swap.SendTo).Should(Equal(buy.ReceiveFrom))
+					Expect(swap.ReceiveFrom).Should(Equal(buy.SendTo))
+					Expect(swap.SendAmount).Should(Equal("200"))
+					Expect(swap.ReceiveAmount).Should(Equal("100"))
+					Expect(swap.SecretHash).Should(Equal(sell.SecretHash))
+					Expect(swap.TimeLock).Should(Equal(sell.TimeLock))
+					Expect(swap.ShouldInitiateFirst).Should(BeTrue())
+				})
+
+				It("should report canceled orders", func() {
+					swap := newPartialSwap(3)
+					Expect(swapper.InsertPartialSwap(swap)).ShouldNot(HaveOccurred())
+					binder.cancel(swap.OrderID)
+					_, canceled, err := swapper.FinalizedSwap(swap.OrderID)
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(canceled).Should(BeTrue())
+				})
+
+				It("should return stored finalized swaps without checking the contract", func() {
+					swap, _, err := swapper.FinalizedSwap(buy.OrderID)
+					Expect(err).ShouldNot(HaveOccurred())
+					calls := binder.numCalls()
+
+					binder.cancel(buy.OrderID)
+					stored, canceled, err := swapper.FinalizedSwap(buy.OrderID)
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(canceled).Should(BeFalse())
+					Expect(stored).Should(Equal(swap))
+					Expect(binder.numCalls()).Should(Equal(calls))
+				})
+
+				It("should store canceled orders", func() {
+					swap := newPartialSwap(3)
+					Expect(swapper.InsertPartialSwap(swap)).ShouldNot(HaveOccurred())
+					binder.cancel(swap.OrderID)
+					_, canceled, err := swapper.FinalizedSwap(swap.OrderID)
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(canceled).Should(BeTrue())
+
+					binder.settle(swap.OrderID, sell.OrderID)
+					_, canceled, err = swapper.FinalizedSwap(swap.OrderID)
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(canceled).Should(BeTrue())
+				})
+
+				It("should finalize both orders with the timelock of the initiator", func() {
+					buy, sell = newPartialSwap(3), newPartialSwap(4)
+					buy.TimeLockGap, sell.TimeLockGap = 3600, 7200
+					Expect(swapper.InsertPartialSwap(buy)).ShouldNot(HaveOccurred())
+					Expect(swapper.InsertPartialSwap(sell)).ShouldNot(HaveOccurred())
+					binder.settle(buy.OrderID, sell.OrderID)
+
+					swap, _, err := swapper.FinalizedSwap(sell.OrderID)
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(swap.TimeLock).Should(Equal(sell.TimeLock))
+					swap, _, err = swapper.FinalizedSwap(buy.OrderID)
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(swap.TimeLock).Should(Equal(sell.TimeLock))
+				})
+
+				It("should precede the timelock of the initiator by the larger gap", func() {
+					buy, sell = newPartialSwap(3), newPartialSwap(4)
+					sell.TimeLock = time.Now().Add(90 * time.Minute).Unix()
+					buy.TimeLockGap, sell.TimeLockGap = 7200, 3600
+					Expect(swapper.InsertPartialSwap(buy)).ShouldNot(HaveOccurred())
+					Expect(swapper.InsertPartialSwap(sell)).ShouldNot(HaveOccurred())
+					binder.settle(buy.OrderID, sell.OrderID)
+
+					_, _, err := swapper.FinalizedSwap(buy.OrderID)
+					Expect(err).Should(Equal(ErrUnsafeTimeLock))
+				})
+
+				It("should reject swaps when the timelock of the follower has expired", func() {
+					buy, sell = newPartialSwap(3), newPartialSwap(4)
+					sell.TimeLock = time.Now().Add(time.Hour).Unix()
+					sell.TimeLockGap = 7200
+					Expect(swapper.InsertPartialSwap(buy)).ShouldNot(HaveOccurred())
+					Expect(swapper.InsertPartialSwap(sell)).ShouldNot(HaveOccurred())
+					binder.settle(buy.OrderID, sell.OrderID)
+
+					for _, id := range []string{buy.OrderID, sell.OrderID} {
+						_, _, err := swapper.FinalizedSwap(id)
+						Expect(err).Should(Equal(ErrUnsafeTimeLock))
+						status, err := swapper.SwapStatus(id)
+						Expect(err).ShouldNot(HaveOccurred())
+						Expect(status.UnsafeTimeLock).Should(BeTrue())
+						Expect(status.FinalizedSwap).Should(BeNil())
+					}
+				})
+
+				Context("when the timelock of the follower is close", func() {
+
+					gap, margin := int64(7200), int64(3600)
+
+					// finalize returns the error of finalizing the follower
+					// of a swap whose timelock expires after the remaining
+					// number of seconds, when the margins of the buy and
+					// sell orders are configured.
+					finalize := func(remaining, buyMargin, sellMargin int64) error {
+						buy, sell = newPartialSwap(3), newPartialSwap(4)
+						sell.TimeLock = time.Now().Unix() + gap + remaining
+						sell.TimeLockGap = gap
+						buy.TimeLockMargin, sell.TimeLockMargin = buyMargin, sellMargin
+						Expect(swapper.InsertPartialSwap(buy)).ShouldNot(HaveOccurred())
+						Expect(swapper.InsertPartialSwap(sell)).ShouldNot(HaveOccurred())
+						binder.settle(buy.OrderID, sell.OrderID)
+						_, _, err := swapper.FinalizedSwap(buy.OrderID)
+						return err
+					}
+
+					It("should finalize swaps with more than the margin remaining", func() {
+						Expect(finalize(margin+60, 0, margin)).ShouldNot(HaveOccurred())
+					})
+
+					It("should reject swaps with less than the margin remaining", func() {
+						Expect(finalize(margin-60, 0, margin)).Should(Equal(ErrUnsafeTimeLock))
+					})
+
+					It("should use the larger margin of the two partial swaps", func() {
+						Expect(finalize(margin-60, margin, 0)).Should(Equal(ErrUnsafeTimeLock))
+					})
+				})
+
+				It("should return an error for unsettled orders", func() {
+					swap := newPartialSwap(3)
+					Expect(swapper.InsertPartialSwap(swap)).ShouldNot(HaveOccurred())
+					_, _, err := swapper.FinalizedSwap(swap.OrderID)
+					Expect(err).Should(HaveOccurred())
+				})
+			})
+
+			Context("when monitoring swaps", func() {
+
+				var buy, sell PartialSwap
+
+				BeforeEach(func() {
+					buy, sell = newPartialSwap(1), newPartialSwap(2)
+					Expect(swapper.InsertPartialSwap(buy)).ShouldNot(HaveOccurred())
+					Expect(swapper.InsertPartialSwap(sell)).ShouldNot(HaveOccurred())
+					binder.settle(buy.OrderID, sell.OrderID)
+				})
+
+				It("should mark finalized swaps past their timelock as refundable", func() {
+					for _, id := range []string{buy.OrderID, sell.OrderID} {
+						_, _, err := swapper.FinalizedSwap(id)
+						Expect(err).ShouldNot(HaveOccurred())
+					}
+					marked, err := swapper.MarkRefundableSwaps(sell.TimeLock - 1)
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(marked).Should(BeEmpty())
+
+					marked, err = swapper.MarkRefundableSwaps(sell.TimeLock)
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(marked).Should(ConsistOf(
+						RefundableSwap{OrderID: buy.OrderID, KycAddr: buy.KycAddr, TimeLock: sell.TimeLock, RefundableAt: sell.TimeLock},
+						RefundableSwap{OrderID: sell.OrderID, KycAddr: sell.KycAddr, TimeLock: sell.TimeLock, RefundableAt: sell.TimeLock},
+					))
+					marked, err = swapper.MarkRefundableSwaps(sell.TimeLock)
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(marked).Should(BeEmpty())
+					marked, err = swapper.MarkRefundableSwaps(sell.TimeLock + 1)
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(marked).Should(BeEmpty())
+
+					swaps, err := swapper.RefundableSwaps()
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(swaps).Should(Equal([]RefundableSwap{
+						{OrderID: buy.OrderID, KycAddr: buy.KycAddr, TimeLock: sell.TimeLock, RefundableAt: sell.TimeLock},
+						{OrderID: sell.OrderID, KycAddr: sell.KycAddr, TimeLock: sell.TimeLock, RefundableAt: sell.TimeLock},
+					}))
+					swap, _, err := swapper.FinalizedSwap(buy.OrderID)
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(swap.RefundableAt).Should(Equal(sell.TimeLock))
+				})
+
+				It("should not mark canceled orders as refundable", func() {
+					swap := newPartialSwap(3)
+					Expect(swapper.InsertPartialSwap(swap)).ShouldNot(HaveOccurred())
+					binder.cancel(swap.OrderID)
+					_, canceled, err := swapper.FinalizedSwap(swap.OrderID)
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(canceled).Should(BeTrue())
+
+					marked, err := swapper.MarkRefundableSwaps(swap.TimeLock)
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(marked).Should(BeEmpty())
+				})
+
+				It("should return partial swaps that have not been finalized", func() {
+					swap := newPartialSwap(3)
+					Expect(swapper.InsertPartialSwap(swap)).ShouldNot(HaveOccurred())
+					_, _, err := swapper.FinalizedSwap(buy.OrderID)
+					Expect(err).ShouldNot(HaveOccurred())
+
+					ids, err := swapper.UnfinalizedPartialSwaps(sell.CreatedAt + 1)
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(ids).Should(Equal([]string{sell.OrderID}))
+
+					Expect(swapper.DeletePartialSwap(sell.OrderID)).ShouldNot(HaveOccurred())
+					_, err = swapper.PartialSwap(sell.OrderID)
+					Expect(err).Should(Equal(sql.ErrNoRows))
+					ids, err = swapper.UnfinalizedPartialSwaps(swap.CreatedAt + 1)
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(ids).Should(Equal([]string{swap.OrderID}))
+				})
+			})
+
+			Context("when getting swap status", func() {
+
+				It("should report orders without partial swaps", func() {
+					swap := newPartialSwap(1)
+					status, err := swapper.SwapStatus(swap.OrderID)
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(status.PartialSwap).Should(BeNil())
+					Expect(status.Matched).Should(BeFalse())
+					Expect(status.FinalizedSwap).Should(BeNil())
+				})
+
+				It("should report registered partial swaps that are not matched", func() {
+					swap := newPartialSwap(1)
+					Expect(swapper.InsertPartialSwap(swap)).ShouldNot(HaveOccurred())
+					status, err := swapper.SwapStatus(swap.OrderID)
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(*status.PartialSwap).Should(Equal(swap))
+					Expect(status.PartialSwap.CreatedAt).Should(Equal(int64(100)))
+					Expect(status.Matched).Should(BeFalse())
+					Expect(status.Settled).Should(BeFalse())
+				})
+
+				It("should report matched orders without a finalized swap until both partial swaps are registered", func() {
+					buy, sell := newPartialSwap(1), newPartialSwap(2)
+					Expect(swapper.InsertPartialSwap(buy)).ShouldNot(HaveOccurred())
+					binder.settle(buy.OrderID, sell.OrderID)
+					status, err := swapper.SwapStatus(buy.OrderID)
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(status.Matched).Should(BeTrue())
+					Expect(status.Settled).Should(BeTrue())
+					Expect(status.MatchedOrderID).Should(Equal(sell.OrderID))
+					Expect(status.MatchedPartialSwap).Should(BeNil())
+					Expect(status.FinalizedSwap).Should(BeNil())
+				})
+
+				It("should report settled orders with their finalized swap", func() {
+					buy, sell := newPartialSwap(1), newPartialSwap(2)
+					Expect(swapper.InsertPartialSwap(buy)).ShouldNot(HaveOccurred())
+					Expect(swapper.InsertPartialSwap(sell)).ShouldNot(HaveOccurred())
+					binder.settle(buy.OrderID, sell.OrderID)
+					status, err := swapper.SwapStatus(buy.OrderID)
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(*status.MatchedPartialSwap).Should(Equal(sell))
+					Expect(status.FinalizedSwap).ShouldNot(BeNil())
+					Expect(status.FinalizedSwap.SecretHash).Should(Equal(sell.SecretHash))
+					Expect(status.Canceled).Should(BeFalse())
+				})
+
+				It("should report canceled orders", func() {
+					swap := newPartialSwap(1)
+					Expect(swapper.InsertPartialSwap(swap)).ShouldNot(HaveOccurred())
+					binder.cancel(swap.OrderID)
+					status, err := swapper.SwapStatus(swap.OrderID)
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(status.Canceled).Should(BeTrue())
+					Expect(status.Matched).Should(BeFalse())
+				})
+			})
+		})
+	}
+})
+
+func newPartialSwap(i byte) PartialSwap {
+	var orderID [32]byte
+	orderID[0] = i
+	return PartialSwap{
+		OrderID:     base64.StdEncoding.EncodeToString(orderID[:]),
+		KycAddr:     "0xtrader",
+		SendTo:      "send" + strings.Repeat("0", int(i)),
+		ReceiveFrom: "receive" + strings.Repeat("0", int(i)),
+		SecretHash:  "secret" + strings.Repeat("0", int(i)),
+		TimeLock:    time.Now().Add(48*time.Hour).Unix() + int64(i),
+		CreatedAt:   int64(i) * 100,
+	}
+}
+
+type mockSwapContractBinder struct {
+	mu      *sync.Mutex
+	calls   int
+	states  map[[32]byte]uint8
+	details map[[32]byte]contract.MatchDetails
+	traders map[[32]byte]common.Address
+}
+
+func newMockSwapContractBinder() *mockSwapContractBinder {
+	return &mockSwapContractBinder{
+		mu:      new(sync.Mutex),
+		states:  map[[32]byte]uint8{},
+		details: map[[32]byte]contract.MatchDetails{},
+		traders: map[[32]byte]common.Address{},
+	}
+}
+
+// settle the buy and sell orders with a priority volume of 100 and a
+// secondary volume of 200.
+func (binder *mockSwapContractBinder) settle(buy, sell string) {
+	binder.mu.Lock()
+	defer binder.mu.Unlock()
+
+	buyID, sellID := mockOrderID(buy), mockOrderID(sell)
+	binder.states[buyID] = 2
+	binder.states[sellID] = 2
+	binder.details[buyID] = contract.MatchDetails{
+		Settled:         true,
+		OrderIsBuy:      true,
+		MatchedID:       sellID,
+		PriorityVolume:  big.NewInt(100),
+		SecondaryVolume: big.NewInt(200),
+	}
+	binder.details[sellID] = contract.MatchDetails{
+		Settled:         true,
+		OrderIsBuy:      false,
+		MatchedID:       buyID,
+		PriorityVolume:  big.NewInt(100),
+		SecondaryVolume: big.NewInt(200),
+	}
+}
+
+// open the order of the trader.
+func (binder *mockSwapContractBinder) open(id string, trader common.Address) {
+	binder.mu.Lock()
+	defer binder.mu.Unlock()
+
+	binder.states[mockOrderID(id)] = 1
+	binder.traders[mockOrderID(id)] = trader
+}
+
+func (binder *mockSwapContractBinder) cancel(id string) {
+	binder.mu.Lock()
+	defer binder.mu.Unlock()
+
+	binder.states[mockOrderID(id)] = 3
+}
+
+// numCalls returns the number of calls made to the contract.
+func (binder *mockSwapContractBinder) numCalls() int {
+	binder.mu.Lock()
+	defer binder.mu.Unlock()
+
+	return binder.calls
+}
+
+func (binder *mockSwapContractBinder) OrderState(id [32]byte) (uint8, error) {
+	binder.mu.Lock()
+	defer binder.mu.Unlock()
+
+	binder.calls++
+	return binder.states[id], nil
+}
+
+func (binder *mockSwapContractBinder) GetMatchDetails(id [32]byte) (contract.MatchDetails, error) {
+	binder.mu.Lock()
+	defer binder.mu.Unlock()
+
+	binder.calls++
+	return binder.details[id], nil
+}
+
+func (binder *mockSwapContractBinder) GetOrderTrader(id [32]byte) (common.Address, error) {
+	binder.mu.Lock()
+	defer binder.mu.Unlock()
+
+	binder.calls++
+	return binder.traders[id], nil
+}
+
+// OrderBlockNumber returns 42 for orders that have been opened.
+func (binder *mockSwapContractBinder) OrderBlockNumber(id [32]byte) (uint64, error) {
+	binder.mu.Lock()
+	defer binder.mu.Unlock()
+
+	binder.calls++
+	if binder.states[id] == 0 {
+		return 0, nil
+	}
+	return 42, nil
+}
+
+// OrderConfirmer returns the zero address for orders that have not been
+// confirmed.
+func (binder *mockSwapContractBinder) OrderConfirmer(id [32]byte) (common.Address, error) {
+	binder.mu.Lock()
+	defer binder.mu.Unlock()
+
+	binder.calls++
+	if binder.states[id] != 2 {
+		return common.Address{}, nil
+	}
+	return mockConfirmer, nil
+}
+
+func (binder *mockSwapContractBinder) OrderMatch(id [32]byte) ([32]byte, error) {
+	binder.mu.Lock()
+	defer binder.mu.Unlock()
+
+	binder.calls++
+	return binder.details[id].MatchedID, nil
+}
+
+// OrderDepth returns 6 for orders that have been opened.
+func (binder *mockSwapContractBinder) OrderDepth(id [32]byte) (uint64, error) {
+	binder.mu.Lock()
+	defer binder.mu.Unlock()
+
+	binder.calls++
+	if binder.states[id] == 0 {
+		return 0, nil
+	}
+	return 6, nil
+}
+
+// mockConfirmer is the Darknode that confirms the orders of the
+// mockSwapContractBinder.
+var mockConfirmer = common.HexToAddress("0x00000000000000000000000000000000000000c0")
+
+func mockOrderID(id string) [32]byte {
+	var orderID [32]byte
+	bytes, err := base64.StdEncoding.DecodeString(id)
+	Expect(err).ShouldNot(HaveOccurred())
+	copy(orderID[:], bytes)
+	return orderID
+}
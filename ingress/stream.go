@@ -0,0 +1,297 @@
+package ingress
+
+import (
+	"expvar"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// EventStreamBuffer is the number of Events buffered for each subscription.
+// Events are dropped for subscriptions that do not keep up.
+const EventStreamBuffer = 64
+
+// EventStreamOrderTTL is the duration for which orders are followed until
+// they are settled or canceled.
+const EventStreamOrderTTL = 7 * 24 * time.Hour
+
+// streamMetrics are the counts of the EventStream, published by expvar under
+// "event_stream".
+var streamMetrics = expvar.NewMap("event_stream")
+
+// A Subscriber streams the Events of traders.
+type Subscriber interface {
+	// Subscribe returns a channel of the Events of the trader. The channel is
+	// closed when the returned function is called.
+	Subscribe(trader string) (<-chan Event, func())
+}
+
+// EventStreamContractBinder defines the methods that the EventStream will
+// require to follow the orders of traders.
+type EventStreamContractBinder interface {
+	OrderState(id [32]byte) (uint8, error)
+}
+
+// An EventStream streams Events to the traders that are subscribed to them.
+// It follows the orders of subscribed traders in the Orderbook contract, so
+// that they are notified when their orders are opened, confirmed and
+// canceled. The Events that are observed by an instance of the Ingress are
+// broadcast to the other instances, so that traders receive them from any
+// instance that they are streaming from.
+type EventStream interface {
+	Notifier
+	Subscriber
+
+	// Run polls the followed orders on every interval, and receives the
+	// broadcast Events on every BroadcastInterval, until the done channel is
+	// closed. Errors are written to the returned channel.
+	Run(done <-chan struct{}) <-chan error
+
+	// Poll checks the state of the followed orders of subscribed traders, and
+	// purges the broadcast Events that can no longer be received.
+	Poll() error
+
+	// Receive streams the Events that were broadcast by the other instances
+	// of the Ingress.
+	Receive() error
+}
+
+type streamOrder struct {
+	trader    string
+	state     uint8
+	createdAt int64
+}
+
+type eventStream struct {
+	binder      EventStreamContractBinder
+	broadcaster Broadcaster
+	interval    time.Duration
+
+	mu            *sync.Mutex
+	nextID        int
+	subscriptions map[string]map[int]chan Event
+	orders        map[string]streamOrder
+}
+
+// NewEventStream returns an EventStream that checks the state of orders using
+// the Orderbook contract, and shares Events with the other instances of the
+// Ingress using the Broadcaster.
+func NewEventStream(binder EventStreamContractBinder, broadcaster Broadcaster, interval time.Duration) EventStream {
+	return &eventStream{
+		binder:      binder,
+		broadcaster: broadcaster,
+		interval:    interval,
+
+		mu:            new(sync.Mutex),
+		subscriptions: map[string]map[int]chan Event{},
+		orders:        map[string]streamOrder{},
+	}
+}
+
+// Notify implements the Notifier interface. Events without a trader are only
+// streamed if their order is followed, since the orders of other traders are
+// not looked up in the Orderbook contract.
+func (stream *eventStream) Notify(event Event) error {
+	if event.Trader == "" {
+		stream.mu.Lock()
+		order, followed := stream.orders[event.OrderID]
+		stream.mu.Unlock()
+		if !followed {
+			return nil
+		}
+		event.Trader = order.trader
+	}
+	event.Trader = normalizeAddress(event.Trader)
+	event.ID = eventID(event)
+	event.CreatedAt = time.Now().Unix()
+
+	stream.streamEvent(event)
+	if err := stream.broadcaster.Broadcast(event); err != nil {
+		return fmt.Errorf("cannot broadcast event=%v: %v", event.ID, err)
+	}
+	return nil
+}
+
+// Receive implements the EventStream interface.
+func (stream *eventStream) Receive() error {
+	events, err := stream.broadcaster.Received()
+	if err != nil {
+		return fmt.Errorf("cannot receive broadcast events: %v", err)
+	}
+	for _, event := range events {
+		stream.streamEvent(event)
+	}
+	streamMetrics.Add("received", int64(len(events)))
+	return nil
+}
+
+// streamEvent follows the order of an Event if its trader is subscribed, and
+// sends the Event to the subscriptions of its trader.
+func (stream *eventStream) streamEvent(event Event) {
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+
+	switch event.Type {
+	case EventOrderApproved, EventFragmentsDelivered:
+		if _, ok := stream.orders[event.OrderID]; !ok && len(stream.subscriptions[event.Trader]) > 0 {
+			stream.orders[event.OrderID] = streamOrder{trader: event.Trader, createdAt: time.Now().Unix()}
+		}
+	case EventOrderSettled:
+		delete(stream.orders, event.OrderID)
+	}
+	stream.publish(event)
+}
+
+// Subscribe implements the Subscriber interface.
+func (stream *eventStream) Subscribe(trader string) (<-chan Event, func()) {
+	trader = normalizeAddress(trader)
+	events := make(chan Event, EventStreamBuffer)
+
+	stream.mu.Lock()
+	id := stream.nextID
+	stream.nextID++
+	if stream.subscriptions[trader] == nil {
+		stream.subscriptions[trader] = map[int]chan Event{}
+	}
+	stream.subscriptions[trader][id] = events
+	stream.mu.Unlock()
+	streamMetrics.Add("subscriptions", 1)
+
+	once := new(sync.Once)
+	return events, func() {
+		once.Do(func() {
+			stream.mu.Lock()
+			delete(stream.subscriptions[trader], id)
+			if len(stream.subscriptions[trader]) == 0 {
+				delete(stream.subscriptions, trader)
+			}
+			stream.mu.Unlock()
+			streamMetrics.Add("subscriptions", -1)
+			close(events)
+		})
+	}
+}
+
+// Run implements the EventStream interface.
+func (stream *eventStream) Run(done <-chan struct{}) <-chan error {
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(errs)
+
+		ticker := time.NewTicker(stream.interval)
+		defer ticker.Stop()
+		receiveTicker := time.NewTicker(BroadcastInterval)
+		defer receiveTicker.Stop()
+
+		err := stream.Poll()
+		for {
+			if err != nil {
+				select {
+				case <-done:
+					return
+				case errs <- err:
+				}
+			}
+
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				err = stream.Poll()
+			case <-receiveTicker.C:
+				err = stream.Receive()
+			}
+		}
+	}()
+
+	return errs
+}
+
+// Poll implements the EventStream interface. Orders that have not been
+// settled or canceled within the EventStreamOrderTTL are forgotten. The
+// orders of traders that are not subscribed are kept, but not checked, so
+// that streams can reconnect without missing a change.
+func (stream *eventStream) Poll() error {
+	if err := stream.broadcaster.PurgeBroadcasts(); err != nil {
+		return fmt.Errorf("cannot purge broadcast events: %v", err)
+	}
+
+	expiredAt := time.Now().Add(-EventStreamOrderTTL).Unix()
+	orders := map[string]streamOrder{}
+
+	stream.mu.Lock()
+	for id, order := range stream.orders {
+		if order.createdAt < expiredAt {
+			delete(stream.orders, id)
+			continue
+		}
+		if len(stream.subscriptions[order.trader]) > 0 {
+			orders[id] = order
+		}
+	}
+	stream.mu.Unlock()
+
+	for id, order := range orders {
+		orderID, err := orderIdStringToBytes(id)
+		if err != nil {
+			return fmt.Errorf("cannot decode order=%v: %v", id, err)
+		}
+		state, err := stream.binder.OrderState(orderID)
+		if err != nil {
+			return fmt.Errorf("cannot get order state for order=%v: %v", id, err)
+		}
+		if state == order.state {
+			continue
+		}
+		stream.updateOrder(id, order, state)
+	}
+	return nil
+}
+
+// updateOrder stores the state of a followed order, and notifies its trader
+// of the change. Orders that are confirmed or canceled before they are
+// observed to be open are also notified as opened.
+func (stream *eventStream) updateOrder(id string, order streamOrder, state uint8) {
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+
+	if _, ok := stream.orders[id]; !ok {
+		return
+	}
+	if state == orderStateCanceled {
+		delete(stream.orders, id)
+	} else {
+		stream.orders[id] = streamOrder{trader: order.trader, state: state, createdAt: order.createdAt}
+	}
+
+	if order.state < orderStateOpen && state > orderStateOpen {
+		stream.publish(Event{Type: EventOrderOpened, OrderID: id, Trader: order.trader})
+	}
+	switch state {
+	case orderStateOpen:
+		stream.publish(Event{Type: EventOrderOpened, OrderID: id, Trader: order.trader})
+	case orderStateConfirmed:
+		stream.publish(Event{Type: EventOrderConfirmed, OrderID: id, Trader: order.trader})
+	case orderStateCanceled:
+		stream.publish(Event{Type: EventOrderCanceled, OrderID: id, Trader: order.trader})
+	}
+}
+
+// publish sends the Event to the subscriptions of its trader, without
+// blocking. Events that were not notified are given an ID and a timestamp. It
+// must be called while holding the mutex.
+func (stream *eventStream) publish(event Event) {
+	if event.ID == "" {
+		event.ID = eventID(event)
+		event.CreatedAt = time.Now().Unix()
+	}
+	for _, events := range stream.subscriptions[event.Trader] {
+		select {
+		case events <- event:
+			streamMetrics.Add("events", 1)
+		default:
+			streamMetrics.Add("dropped", 1)
+		}
+	}
+}
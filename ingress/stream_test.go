@@ -0,0 +1,200 @@
+package ingress_test
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/republicprotocol/renex-ingress-go/ingress"
+)
+
+var _ = Describe("Event stream", func() {
+
+	trader := common.HexToAddress("0x62026B5aC38f1b186c7af0b18Aee8B2EccC2d852")
+
+	var binder *mockSwapContractBinder
+	var stream EventStream
+	var buy, sell PartialSwap
+
+	// received returns the Events that have been streamed, without waiting
+	// for more Events.
+	received := func(events <-chan Event) []Event {
+		streamed := []Event{}
+		for {
+			select {
+			case event := <-events:
+				streamed = append(streamed, event)
+			default:
+				return streamed
+			}
+		}
+	}
+
+	eventTypes := func(events []Event) []string {
+		types := make([]string, len(events))
+		for i, event := range events {
+			types[i] = event.Type
+		}
+		return types
+	}
+
+	BeforeEach(func() {
+		binder = newMockSwapContractBinder()
+		stream = NewEventStream(binder, NewMemoryBroadcaster(), time.Hour)
+		buy, sell = newPartialSwap(1), newPartialSwap(2)
+	})
+
+	It("should stream events to the subscriptions of the trader", func() {
+		events, unsubscribe := stream.Subscribe(strings.ToLower(trader.Hex()))
+		defer unsubscribe()
+		others, unsubscribeOthers := stream.Subscribe("0x1")
+		defer unsubscribeOthers()
+
+		Expect(stream.Notify(Event{Type: EventOrderApproved, OrderID: buy.OrderID, Trader: trader.Hex()})).ShouldNot(HaveOccurred())
+		Expect(stream.Notify(Event{Type: EventFragmentsDelivered, OrderID: buy.OrderID, Trader: trader.Hex(), Data: FragmentsDelivery{Depth: 1}})).ShouldNot(HaveOccurred())
+
+		streamed := received(events)
+		Expect(eventTypes(streamed)).Should(Equal([]string{EventOrderApproved, EventFragmentsDelivered}))
+		Expect(streamed[1].ID).Should(Equal(EventFragmentsDelivered + ":" + buy.OrderID + ":1"))
+		Expect(received(others)).Should(BeEmpty())
+	})
+
+	It("should ignore events when no trader is subscribed", func() {
+		Expect(stream.Notify(Event{Type: EventOrderSettled, OrderID: buy.OrderID})).ShouldNot(HaveOccurred())
+		Expect(stream.Poll()).ShouldNot(HaveOccurred())
+		Expect(binder.numCalls()).Should(Equal(0))
+	})
+
+	It("should stream the settlement of orders that are not followed without looking up their trader", func() {
+		binder.open(buy.OrderID, trader)
+		events, unsubscribe := stream.Subscribe(trader.Hex())
+		defer unsubscribe()
+
+		Expect(stream.Notify(Event{Type: EventOrderSettled, OrderID: sell.OrderID, Trader: trader.Hex()})).ShouldNot(HaveOccurred())
+		Expect(stream.Notify(Event{Type: EventOrderSettled, OrderID: buy.OrderID})).ShouldNot(HaveOccurred())
+		streamed := received(events)
+		Expect(streamed).Should(HaveLen(1))
+		Expect(streamed[0].OrderID).Should(Equal(sell.OrderID))
+		Expect(binder.numCalls()).Should(Equal(0))
+	})
+
+	It("should stream changes to the state of followed orders", func() {
+		events, unsubscribe := stream.Subscribe(trader.Hex())
+		defer unsubscribe()
+
+		Expect(stream.Notify(Event{Type: EventOrderApproved, OrderID: buy.OrderID, Trader: trader.Hex()})).ShouldNot(HaveOccurred())
+		Expect(stream.Poll()).ShouldNot(HaveOccurred())
+		binder.open(buy.OrderID, trader)
+		Expect(stream.Poll()).ShouldNot(HaveOccurred())
+		Expect(stream.Poll()).ShouldNot(HaveOccurred())
+		binder.settle(buy.OrderID, sell.OrderID)
+		Expect(stream.Poll()).ShouldNot(HaveOccurred())
+		Expect(stream.Notify(Event{Type: EventOrderSettled, OrderID: buy.OrderID})).ShouldNot(HaveOccurred())
+
+		Expect(eventTypes(received(events))).Should(Equal([]string{EventOrderApproved, EventOrderOpened, EventOrderConfirmed, EventOrderSettled}))
+
+		calls := binder.numCalls()
+		Expect(stream.Poll()).ShouldNot(HaveOccurred())
+		Expect(binder.numCalls()).Should(Equal(calls))
+	})
+
+	It("should stream orders that are opened and canceled between polls", func() {
+		events, unsubscribe := stream.Subscribe(trader.Hex())
+		defer unsubscribe()
+
+		Expect(stream.Notify(Event{Type: EventOrderApproved, OrderID: buy.OrderID, Trader: trader.Hex()})).ShouldNot(HaveOccurred())
+		binder.cancel(buy.OrderID)
+		Expect(stream.Poll()).ShouldNot(HaveOccurred())
+		Expect(eventTypes(received(events))).Should(Equal([]string{EventOrderApproved, EventOrderOpened, EventOrderCanceled}))
+
+		calls := binder.numCalls()
+		Expect(stream.Poll()).ShouldNot(HaveOccurred())
+		Expect(binder.numCalls()).Should(Equal(calls))
+	})
+
+	It("should keep following orders while the trader is not subscribed", func() {
+		_, unsubscribe := stream.Subscribe(trader.Hex())
+		Expect(stream.Notify(Event{Type: EventOrderApproved, OrderID: buy.OrderID, Trader: trader.Hex()})).ShouldNot(HaveOccurred())
+		unsubscribe()
+
+		binder.open(buy.OrderID, trader)
+		Expect(stream.Poll()).ShouldNot(HaveOccurred())
+		Expect(binder.numCalls()).Should(Equal(0))
+
+		events, unsubscribe := stream.Subscribe(trader.Hex())
+		defer unsubscribe()
+		Expect(stream.Poll()).ShouldNot(HaveOccurred())
+		Expect(eventTypes(received(events))).Should(Equal([]string{EventOrderOpened}))
+	})
+
+	Context("when events are broadcast by another instance", func() {
+
+		var other EventStream
+
+		BeforeEach(func() {
+			db := newSQLiteDB()
+			stream = NewEventStream(binder, NewBroadcasterWithDB(db), time.Hour)
+			other = NewEventStream(binder, NewBroadcasterWithDB(db), time.Hour)
+		})
+
+		It("should stream the events to the subscriptions of the trader once", func() {
+			events, unsubscribe := stream.Subscribe(trader.Hex())
+			defer unsubscribe()
+
+			Expect(other.Notify(Event{Type: EventFragmentsDelivered, OrderID: buy.OrderID, Trader: trader.Hex(), Data: FragmentsDelivery{Depth: 1}})).ShouldNot(HaveOccurred())
+			Expect(stream.Receive()).ShouldNot(HaveOccurred())
+			Expect(stream.Receive()).ShouldNot(HaveOccurred())
+			Expect(other.Receive()).ShouldNot(HaveOccurred())
+
+			streamed := received(events)
+			Expect(eventTypes(streamed)).Should(Equal([]string{EventFragmentsDelivered}))
+			Expect(streamed[0].ID).Should(Equal(EventFragmentsDelivered + ":" + buy.OrderID + ":1"))
+		})
+
+		It("should follow the orders that are approved by the other instance", func() {
+			events, unsubscribe := stream.Subscribe(trader.Hex())
+			defer unsubscribe()
+
+			Expect(other.Notify(Event{Type: EventOrderApproved, OrderID: buy.OrderID, Trader: trader.Hex()})).ShouldNot(HaveOccurred())
+			Expect(stream.Receive()).ShouldNot(HaveOccurred())
+			binder.open(buy.OrderID, trader)
+			Expect(stream.Poll()).ShouldNot(HaveOccurred())
+
+			Expect(eventTypes(received(events))).Should(Equal([]string{EventOrderApproved, EventOrderOpened}))
+		})
+	})
+
+	It("should close subscriptions once", func() {
+		events, unsubscribe := stream.Subscribe(trader.Hex())
+		unsubscribe()
+		unsubscribe()
+		_, ok := <-events
+		Expect(ok).Should(BeFalse())
+		Expect(stream.Notify(Event{Type: EventOrderApproved, OrderID: buy.OrderID, Trader: trader.Hex()})).ShouldNot(HaveOccurred())
+	})
+})
+
+var _ = Describe("Multi notifier", func() {
+
+	It("should notify all notifiers and return the first error", func() {
+		first := &mockNotifier{mu: new(sync.Mutex)}
+		second := &mockNotifier{mu: new(sync.Mutex)}
+		notifier := MultiNotifier(first, errNotifier{}, second)
+
+		Expect(notifier.Notify(Event{Type: EventOrderApproved, OrderID: "order"})).Should(HaveOccurred())
+		Expect(first.eventTypes("order")).Should(Equal([]string{EventOrderApproved}))
+		Expect(second.eventTypes("order")).Should(Equal([]string{EventOrderApproved}))
+	})
+})
+
+// errNotifier returns an error whenever it is notified.
+type errNotifier struct{}
+
+func (errNotifier) Notify(event Event) error {
+	return errors.New("cannot notify")
+}
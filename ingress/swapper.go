@@ -4,34 +4,19 @@ import (
 	"database/sql"
 	"encoding/base64"
 	"fmt"
+	"log"
+	"time"
+)
 
-	_ "github.com/lib/pq"
+// The schema of the partial_swap and finalized_swap tables is defined by Migrations.
 
-	"github.com/republicprotocol/renex-ingress-go/contract"
+// Values of the state of an order in the Orderbook contract.
+const (
+	orderStateOpen      = 1
+	orderStateConfirmed = 2
+	orderStateCanceled  = 3
 )
 
-// TABLES
-//
-// CREATE TABLE partial_swap (
-//     order_id        varchar PRIMARY KEY,
-//     kyc_addr        varchar,
-//     send_to         varchar,
-//     receive_from    varchar,
-//     time_lock       int,
-//     secret_hash     varchar
-// );
-//
-// CREATE TABLE finalized_swap (
-//     order_id        	  varchar PRIMARY KEY,
-//     send_to               varchar,
-//     receive_from          varchar,
-//     send_amount           varchar,
-//     receive_amount        varchar,
-//     secret_hash           varchar,
-//     should_initiate_first boolean,
-//     time_lock             int
-// );
-
 type PartialSwap struct {
 	OrderID     string `json:"order_id"`
 	KycAddr     string `json:"kyc_addr"`
@@ -39,6 +24,20 @@ type PartialSwap struct {
 	ReceiveFrom string `json:"receive_from"`
 	SecretHash  string `json:"secret_hash"`
 	TimeLock    int64  `json:"time_lock"`
+
+	// CreatedAt is the unix timestamp at which the partial swap was
+	// registered by swapperd. It is zero for partial swaps registered before
+	// it was recorded.
+	CreatedAt int64 `json:"created_at"`
+
+	// TimeLockGap is the number of seconds by which the timelock of the
+	// follower must precede the TimeLock of the initiator. The TimeLock of the
+	// partial swap is only used when the order initiates the swap.
+	TimeLockGap int64 `json:"time_lock_gap"`
+
+	// TimeLockMargin is the number of seconds that must remain before the
+	// timelock of the follower expires when the swap is finalized.
+	TimeLockMargin int64 `json:"time_lock_margin"`
 }
 
 type FinalizedSwap struct {
@@ -50,62 +49,297 @@ type FinalizedSwap struct {
 	SecretHash          string `json:"secret_hash"`
 	ShouldInitiateFirst bool   `json:"should_initiate_first"`
 	TimeLock            int64  `json:"time_lock"`
+
+	// RefundableAt is the unix timestamp at which the swap was found to be
+	// past its TimeLock, so that the trader can refund its side of the swap.
+	// It is zero until the swap is refundable.
+	RefundableAt int64 `json:"refundable_at,omitempty"`
+}
+
+// A RefundableSwap is a finalized swap that is past its timelock.
+type RefundableSwap struct {
+	OrderID      string `json:"order_id"`
+	KycAddr      string `json:"kyc_addr"`
+	TimeLock     int64  `json:"time_lock"`
+	RefundableAt int64  `json:"refundable_at"`
+}
+
+// SwapStatus describes the progress of the atomic swap of an order. Partial
+// swaps are nil if they have not been registered, and the FinalizedSwap is nil
+// until the order has been settled.
+type SwapStatus struct {
+	OrderID            string         `json:"order_id"`
+	PartialSwap        *PartialSwap   `json:"partial_swap"`
+	Matched            bool           `json:"matched"`
+	Settled            bool           `json:"settled"`
+	MatchedOrderID     string         `json:"matched_order_id,omitempty"`
+	MatchedPartialSwap *PartialSwap   `json:"matched_partial_swap"`
+	FinalizedSwap      *FinalizedSwap `json:"finalized_swap"`
+	Canceled           bool           `json:"canceled"`
+
+	// UnsafeTimeLock is true if the swap cannot be finalized because the
+	// timelock of the follower has expired, or expires too soon.
+	UnsafeTimeLock bool `json:"unsafe_time_lock"`
 }
 
 type Swapper interface {
+	// InsertPartialSwap stores a partial swap. Registering the same partial
+	// swap again has no effect, and the timelock of the first registration is
+	// kept. ErrPartialSwapConflict is returned if the order has registered a
+	// partial swap with different parameters, and ErrSecretHashReused is
+	// returned if the secret hash belongs to another order.
 	InsertPartialSwap(swap PartialSwap) error
 
 	PartialSwap(id string) (PartialSwap, error)
 
+	// FinalizedSwap returns the FinalizedSwap for an order, and true if the
+	// order has been canceled. The first successful finalization, or
+	// cancellation, is stored and returned without checking the
+	// RenExSettlement contract again. The trader is notified when the first
+	// finalization is stored.
 	FinalizedSwap(id string) (FinalizedSwap, bool, error)
+
+	// SwapStatus returns the SwapStatus of an order.
+	SwapStatus(id string) (SwapStatus, error)
+
+	// MarkRefundableSwaps marks finalized swaps with a timelock at or before
+	// the unix timestamp as refundable, and returns the swaps that were
+	// marked by this call. Swaps that are marked concurrently by another call
+	// are not returned.
+	MarkRefundableSwaps(now int64) ([]RefundableSwap, error)
+
+	// RefundableSwaps returns the finalized swaps that have been marked as
+	// refundable, in the order in which they were marked.
+	RefundableSwaps() ([]RefundableSwap, error)
+
+	// UnfinalizedPartialSwaps returns the order IDs of partial swaps that
+	// were registered before the unix timestamp, and have not been
+	// finalized.
+	UnfinalizedPartialSwaps(before int64) ([]string, error)
+
+	// DeletePartialSwap deletes the partial swap of an order.
+	DeletePartialSwap(id string) error
 }
 
 type swapper struct {
-	*sql.DB
-	binder contract.Binder
+	*DB
+	binder   SwapContractBinder
+	notifier Notifier
 }
 
-func NewSwapper(databaseURL string, binder contract.Binder) (Swapper, error) {
-	db, err := sql.Open("postgres", databaseURL)
+// NewSwapper returns a Swapper that stores partial swaps in the database at
+// the URL, and notifies the Notifier of finalized swaps.
+func NewSwapper(databaseURL string, binder SwapContractBinder, notifier Notifier) (Swapper, error) {
+	db, err := OpenDB(databaseURL)
 	if err != nil {
 		return nil, err
 	}
-	swapper := &swapper{db, binder}
+	return NewSwapperWithDB(db, binder, notifier), nil
+}
 
-	return swapper, nil
+// NewSwapperWithDB returns a Swapper that stores partial swaps in an open
+// database, and notifies the Notifier of finalized swaps.
+func NewSwapperWithDB(db *DB, binder SwapContractBinder, notifier Notifier) Swapper {
+	return &swapper{db, binder, notifier}
 }
 
 func (swapper *swapper) InsertPartialSwap(swap PartialSwap) error {
-	_, err := swapper.Exec("INSERT INTO partial_swap (order_id, kyc_addr, send_to, receive_from ,secret_hash, time_lock) VALUES ($1,$2,$3,$4,$5,$6) ON CONFLICT DO NOTHING",
-		swap.OrderID, swap.KycAddr, swap.SendTo, swap.ReceiveFrom, swap.SecretHash, swap.TimeLock)
-	return err
+	// Secret hashes are unique, so registering a secret hash that belongs to
+	// another order violates the constraint instead of being ignored.
+	res, err := swapper.Exec("INSERT INTO partial_swap (order_id, kyc_addr, send_to, receive_from ,secret_hash, time_lock, created_at, time_lock_gap, time_lock_margin) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9) ON CONFLICT (order_id) DO NOTHING",
+		swap.OrderID, swap.KycAddr, swap.SendTo, swap.ReceiveFrom, swap.SecretHash, swap.TimeLock, swap.CreatedAt, swap.TimeLockGap, swap.TimeLockMargin)
+	if err != nil {
+		if uniqueViolation(err) {
+			return ErrSecretHashReused
+		}
+		return err
+	}
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if inserted > 0 {
+		return nil
+	}
+
+	stored, err := swapper.PartialSwap(swap.OrderID)
+	if err != nil {
+		return err
+	}
+	return checkPartialSwap(stored, swap)
+}
+
+// checkPartialSwap returns ErrPartialSwapConflict if a partial swap that is
+// registered again does not have the same parameters as the stored partial
+// swap.
+func checkPartialSwap(stored, swap PartialSwap) error {
+	if stored.KycAddr != swap.KycAddr || stored.SendTo != swap.SendTo || stored.ReceiveFrom != swap.ReceiveFrom || stored.SecretHash != swap.SecretHash || stored.TimeLock != swap.TimeLock {
+		return ErrPartialSwapConflict
+	}
+	return nil
 }
 
 func (swapper *swapper) PartialSwap(id string) (PartialSwap, error) {
 	var swap PartialSwap
+	var createdAt, timeLockGap, timeLockMargin sql.NullInt64
 	swap.OrderID = id
-	err := swapper.QueryRow("SELECT kyc_addr, send_to, receive_from, secret_hash, time_lock FROM partial_swap WHERE order_id = $1", id).
-		Scan(&swap.KycAddr, &swap.SendTo, &swap.ReceiveFrom, &swap.SecretHash, &swap.TimeLock)
+	err := swapper.QueryRow("SELECT kyc_addr, send_to, receive_from, secret_hash, time_lock, created_at, time_lock_gap, time_lock_margin FROM partial_swap WHERE order_id = $1", id).
+		Scan(&swap.KycAddr, &swap.SendTo, &swap.ReceiveFrom, &swap.SecretHash, &swap.TimeLock, &createdAt, &timeLockGap, &timeLockMargin)
+	swap.CreatedAt = createdAt.Int64
+	swap.TimeLockGap = timeLockGap.Int64
+	swap.TimeLockMargin = timeLockMargin.Int64
 	return swap, err
 }
 
 func (swapper *swapper) FinalizedSwap(id string) (FinalizedSwap, bool, error) {
+	var swap FinalizedSwap
+	var canceled bool
+	var refundableAt sql.NullInt64
+	err := swapper.QueryRow("SELECT send_to, receive_from, send_amount, receive_amount, secret_hash, should_initiate_first, time_lock, canceled, refundable_at FROM finalized_swap WHERE order_id = $1", id).
+		Scan(&swap.SendTo, &swap.ReceiveFrom, &swap.SendAmount, &swap.ReceiveAmount, &swap.SecretHash, &swap.ShouldInitiateFirst, &swap.TimeLock, &canceled, &refundableAt)
+	if err == nil {
+		swap.OrderID = id
+		swap.RefundableAt = refundableAt.Int64
+		return swap, canceled, nil
+	}
+	if err != sql.ErrNoRows {
+		return FinalizedSwap{}, false, err
+	}
+
+	swap, canceled, err = finalizeSwap(swapper.binder, swapper, id)
+	if err != nil {
+		return FinalizedSwap{}, false, err
+	}
+	res, err := swapper.Exec("INSERT INTO finalized_swap (order_id, send_to, receive_from, send_amount, receive_amount, secret_hash, should_initiate_first, time_lock, canceled, finalized_at) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10) ON CONFLICT DO NOTHING",
+		id, swap.SendTo, swap.ReceiveFrom, swap.SendAmount, swap.ReceiveAmount, swap.SecretHash, swap.ShouldInitiateFirst, swap.TimeLock, canceled, time.Now().Unix())
+	if err != nil {
+		return FinalizedSwap{}, false, fmt.Errorf("cannot store finalized swap for order=%v, err=%v", id, err)
+	}
+
+	// Only the call that stores the finalization notifies the trader, so that
+	// concurrent finalizations of the same order are notified once. The swap
+	// has been finalized even if the notification fails.
+	if n, err := res.RowsAffected(); err == nil && n > 0 && !canceled {
+		if err := notifyFinalizedSwap(swapper.notifier, swapper, swap); err != nil {
+			log.Printf("[error] (swapper) cannot notify finalized swap of order = %v: %v", id, err)
+		}
+	}
+	return swap, canceled, nil
+}
+
+// notifyFinalizedSwap notifies the trader of the partial swap of an order that
+// its swap has been finalized.
+func notifyFinalizedSwap(notifier Notifier, swapper Swapper, swap FinalizedSwap) error {
+	pSwap, err := swapper.PartialSwap(swap.OrderID)
+	if err != nil {
+		return err
+	}
+	return notifier.Notify(Event{Type: EventSwapFinalized, OrderID: swap.OrderID, Trader: pSwap.KycAddr, Data: swap})
+}
+
+func (swapper *swapper) SwapStatus(id string) (SwapStatus, error) {
+	return swapStatus(swapper.binder, swapper, id)
+}
+
+func (swapper *swapper) MarkRefundableSwaps(now int64) ([]RefundableSwap, error) {
+	rows, err := swapper.Query("SELECT f.order_id, COALESCE(p.kyc_addr, ''), f.time_lock FROM finalized_swap f LEFT JOIN partial_swap p ON p.order_id = f.order_id WHERE f.refundable_at IS NULL AND NOT f.canceled AND f.time_lock <= $1 ORDER BY f.order_id", now)
+	if err != nil {
+		return nil, err
+	}
+	expired := []RefundableSwap{}
+	for rows.Next() {
+		swap := RefundableSwap{RefundableAt: now}
+		if err := rows.Scan(&swap.OrderID, &swap.KycAddr, &swap.TimeLock); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		expired = append(expired, swap)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Each swap is only marked if it has not been marked since it was
+	// loaded, so that concurrent calls do not return the same swap.
+	marked := []RefundableSwap{}
+	for _, swap := range expired {
+		res, err := swapper.Exec("UPDATE finalized_swap SET refundable_at = $1 WHERE order_id = $2 AND refundable_at IS NULL", now, swap.OrderID)
+		if err != nil {
+			return marked, err
+		}
+		updated, err := res.RowsAffected()
+		if err != nil {
+			return marked, err
+		}
+		if updated > 0 {
+			marked = append(marked, swap)
+		}
+	}
+	return marked, nil
+}
+
+func (swapper *swapper) RefundableSwaps() ([]RefundableSwap, error) {
+	rows, err := swapper.Query("SELECT f.order_id, COALESCE(p.kyc_addr, ''), f.time_lock, f.refundable_at FROM finalized_swap f LEFT JOIN partial_swap p ON p.order_id = f.order_id WHERE f.refundable_at IS NOT NULL ORDER BY f.refundable_at, f.order_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	swaps := []RefundableSwap{}
+	for rows.Next() {
+		var swap RefundableSwap
+		if err := rows.Scan(&swap.OrderID, &swap.KycAddr, &swap.TimeLock, &swap.RefundableAt); err != nil {
+			return nil, err
+		}
+		swaps = append(swaps, swap)
+	}
+	return swaps, rows.Err()
+}
+
+func (swapper *swapper) UnfinalizedPartialSwaps(before int64) ([]string, error) {
+	rows, err := swapper.Query("SELECT p.order_id FROM partial_swap p WHERE COALESCE(NULLIF(p.created_at, 0), p.time_lock) < $1 AND NOT EXISTS (SELECT 1 FROM finalized_swap f WHERE f.order_id = p.order_id) ORDER BY p.order_id", before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (swapper *swapper) DeletePartialSwap(id string) error {
+	_, err := swapper.Exec("DELETE FROM partial_swap WHERE order_id = $1", id)
+	return err
+}
+
+// finalizeSwap constructs the FinalizedSwap for an order using the match
+// details from the RenExSettlement contract and the partial swaps of both
+// orders in the match. It returns true if the order has been canceled.
+func finalizeSwap(binder SwapContractBinder, swapper Swapper, id string) (FinalizedSwap, bool, error) {
 	orderID, err := orderIdStringToBytes(id)
 	if err != nil {
 		return FinalizedSwap{}, false, err
 	}
 
 	// Check if the order has been canceled
-	status, err := swapper.binder.OrderState(orderID)
+	status, err := binder.OrderState(orderID)
 	if err != nil {
 		return FinalizedSwap{}, false, err
 	}
-	if status == 3 {
-		return FinalizedSwap{}, true, nil
+	if status == orderStateCanceled {
+		return FinalizedSwap{OrderID: id}, true, nil
 	}
 
 	// Get settlement details
-	details, err := swapper.binder.GetMatchDetails(orderID)
+	details, err := binder.GetMatchDetails(orderID)
 	if err != nil {
 		return FinalizedSwap{}, false, fmt.Errorf("cannot get match details for order=%v, err=%v", id, err)
 	}
@@ -132,12 +366,18 @@ func (swapper *swapper) FinalizedSwap(id string) (FinalizedSwap, bool, error) {
 	priorityAmount := details.PriorityVolume.String()
 	secondaryAmount := details.SecondaryVolume.String()
 	if details.OrderIsBuy {
+		if err := checkFollowerTimeLock(matchedPartialSwap, pSwap); err != nil {
+			return FinalizedSwap{}, false, err
+		}
 		swap.SendAmount = priorityAmount
 		swap.ReceiveAmount = secondaryAmount
 		swap.ShouldInitiateFirst = false
 		swap.SecretHash = matchedPartialSwap.SecretHash
 		swap.TimeLock = matchedPartialSwap.TimeLock
 	} else {
+		if err := checkFollowerTimeLock(pSwap, matchedPartialSwap); err != nil {
+			return FinalizedSwap{}, false, err
+		}
 		swap.SendAmount = secondaryAmount
 		swap.ReceiveAmount = priorityAmount
 		swap.ShouldInitiateFirst = true
@@ -148,6 +388,87 @@ func (swapper *swapper) FinalizedSwap(id string) (FinalizedSwap, bool, error) {
 	return swap, false, nil
 }
 
+// checkFollowerTimeLock returns ErrUnsafeTimeLock unless the timelock of the
+// follower of a swap, which precedes the timelock of the initiator by the
+// larger gap of the two partial swaps, expires after more than the larger
+// margin of the two partial swaps.
+func checkFollowerTimeLock(initiator, follower PartialSwap) error {
+	gap, margin := initiator.TimeLockGap, initiator.TimeLockMargin
+	if follower.TimeLockGap > gap {
+		gap = follower.TimeLockGap
+	}
+	if follower.TimeLockMargin > margin {
+		margin = follower.TimeLockMargin
+	}
+	if initiator.TimeLock-gap-time.Now().Unix() <= margin {
+		return ErrUnsafeTimeLock
+	}
+	return nil
+}
+
+// swapStatus constructs the SwapStatus of an order using the state and match
+// details from the RenExSettlement contract. The swap is finalized once both
+// partial swaps have been registered and the order has been settled.
+func swapStatus(binder SwapContractBinder, swapper Swapper, id string) (SwapStatus, error) {
+	orderID, err := orderIdStringToBytes(id)
+	if err != nil {
+		return SwapStatus{}, err
+	}
+
+	status := SwapStatus{OrderID: id}
+	pSwap, err := swapper.PartialSwap(id)
+	if err != nil && err != sql.ErrNoRows {
+		return SwapStatus{}, fmt.Errorf("cannot get partial swap for order=%v, err=%v", id, err)
+	}
+	if err == nil {
+		status.PartialSwap = &pSwap
+	}
+
+	state, err := binder.OrderState(orderID)
+	if err != nil {
+		return SwapStatus{}, err
+	}
+	if state == orderStateCanceled {
+		status.Canceled = true
+		return status, nil
+	}
+
+	details, err := binder.GetMatchDetails(orderID)
+	if err != nil {
+		return SwapStatus{}, fmt.Errorf("cannot get match details for order=%v, err=%v", id, err)
+	}
+	if state != orderStateConfirmed || details.MatchedID == [32]byte{} {
+		return status, nil
+	}
+	status.Matched = true
+	status.Settled = details.Settled
+	status.MatchedOrderID = base64.StdEncoding.EncodeToString(details.MatchedID[:])
+
+	matchedPartialSwap, err := swapper.PartialSwap(status.MatchedOrderID)
+	if err != nil && err != sql.ErrNoRows {
+		return SwapStatus{}, fmt.Errorf("cannot get matched partial swap for order=%v, err=%v", status.MatchedOrderID, err)
+	}
+	if err == nil {
+		status.MatchedPartialSwap = &matchedPartialSwap
+	}
+
+	if status.Settled && status.PartialSwap != nil && status.MatchedPartialSwap != nil {
+		swap, canceled, err := swapper.FinalizedSwap(id)
+		if err == ErrUnsafeTimeLock {
+			status.UnsafeTimeLock = true
+			return status, nil
+		}
+		if err != nil {
+			return SwapStatus{}, err
+		}
+		status.Canceled = canceled
+		if !canceled {
+			status.FinalizedSwap = &swap
+		}
+	}
+	return status, nil
+}
+
 func orderIdStringToBytes(id string) ([32]byte, error) {
 	orderIDBytes, err := base64.StdEncoding.DecodeString(id)
 	if err != nil {
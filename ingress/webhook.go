@@ -0,0 +1,322 @@
+package ingress
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/satori/go.uuid"
+)
+
+// The schema of the webhooks, webhook_orders and webhook_deliveries tables is
+// defined by Migrations.
+
+// Events of the orders and swaps of a trader, that are delivered to the
+// webhooks and the event streams of the trader.
+const (
+	EventOrderApproved      = "order.approved"
+	EventFragmentsDelivered = "order.fragments_delivered"
+	EventOrderOpened        = "order.opened"
+	EventOrderConfirmed     = "order.confirmed"
+	EventOrderCanceled      = "order.canceled"
+	EventOrderSettled       = "order.settled"
+	EventSwapFinalized      = "swap.finalized"
+	EventSwapExpired        = "swap.expired"
+)
+
+// A Webhook is a URL to which the events of a trader are delivered. The
+// Secret is used to sign the events, and is only returned when the Webhook is
+// registered.
+type Webhook struct {
+	ID           string `json:"id"`
+	Trader       string `json:"trader"`
+	URL          string `json:"url"`
+	Secret       string `json:"secret,omitempty"`
+	RegisteredBy string `json:"registeredBy"`
+	CreatedAt    int64  `json:"createdAt"`
+}
+
+// NewWebhook returns a Webhook for the trader with a random ID and secret.
+// The Webhook is registered by the trader, or by an address that the trader
+// has authorized.
+func NewWebhook(trader, url, registeredBy string) (Webhook, error) {
+	secret := [32]byte{}
+	if _, err := rand.Read(secret[:]); err != nil {
+		return Webhook{}, err
+	}
+	return Webhook{
+		ID:           uuid.NewV4().String(),
+		Trader:       normalizeAddress(trader),
+		URL:          url,
+		Secret:       hex.EncodeToString(secret[:]),
+		RegisteredBy: normalizeAddress(registeredBy),
+		CreatedAt:    time.Now().Unix(),
+	}, nil
+}
+
+// An Event is a change to an order or a swap of a trader. The ID of an Event
+// is unique to its type and order (see eventID), so that an Event observed
+// more than once is only delivered once to each Webhook.
+type Event struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	OrderID   string      `json:"orderID"`
+	Trader    string      `json:"trader"`
+	Data      interface{} `json:"data,omitempty"`
+	CreatedAt int64       `json:"createdAt"`
+}
+
+// FragmentsDelivery is the data of an EventFragmentsDelivered. The fragments
+// of an order are delivered separately for each epoch depth.
+type FragmentsDelivery struct {
+	Depth int `json:"depth"`
+}
+
+// eventID returns the ID of the Event, which is unique to its type and order,
+// and to its epoch depth when fragments are delivered.
+func eventID(event Event) string {
+	if delivery, ok := event.Data.(FragmentsDelivery); ok {
+		return fmt.Sprintf("%v:%v:%v", event.Type, event.OrderID, delivery.Depth)
+	}
+	return event.Type + ":" + event.OrderID
+}
+
+// A WebhookOrder is an order for which a trader with Webhooks has been sent
+// fragments. Orders are followed until they are confirmed, and forgotten
+// once they are settled or canceled.
+type WebhookOrder struct {
+	OrderID   string
+	Trader    string
+	Confirmed bool
+	CreatedAt int64
+}
+
+// A WebhookDelivery is an Event that is delivered to a Webhook. Deliveries
+// that fail are retried until they succeed, or until the maximum number of
+// attempts is reached and the failure is recorded.
+type WebhookDelivery struct {
+	WebhookID     string `json:"webhookID"`
+	EventID       string `json:"eventID"`
+	Payload       string `json:"payload"`
+	Attempts      int    `json:"attempts"`
+	NextAttemptAt int64  `json:"nextAttemptAt"`
+	LastError     string `json:"lastError,omitempty"`
+	DeliveredAt   int64  `json:"deliveredAt,omitempty"`
+	FailedAt      int64  `json:"failedAt,omitempty"`
+}
+
+// A Webhooker stores the Webhooks of traders, the orders that are followed
+// for them, and the deliveries of their Events.
+type Webhooker interface {
+	InsertWebhook(webhook Webhook) error
+
+	// Webhook returns sql.ErrNoRows if the Webhook does not exist.
+	Webhook(id string) (Webhook, error)
+
+	// Webhooks returns the Webhooks of the trader, without their secrets.
+	Webhooks(trader string) ([]Webhook, error)
+
+	// DeleteWebhook deletes the Webhook and its pending deliveries. It
+	// returns sql.ErrNoRows if the Webhook does not exist.
+	DeleteWebhook(id string) error
+
+	// InsertWebhookOrder stores the order, unless it is already stored.
+	InsertWebhookOrder(order WebhookOrder) error
+
+	// WebhookOrder returns sql.ErrNoRows if the order is not followed.
+	WebhookOrder(orderID string) (WebhookOrder, error)
+
+	// UnconfirmedWebhookOrders returns the orders that have not been
+	// confirmed, ordered by the time at which they were stored.
+	UnconfirmedWebhookOrders() ([]WebhookOrder, error)
+
+	ConfirmWebhookOrder(orderID string) error
+
+	DeleteWebhookOrder(orderID string) error
+
+	// InsertWebhookDelivery stores the delivery, unless the Event has already
+	// been delivered to the Webhook.
+	InsertWebhookDelivery(delivery WebhookDelivery) error
+
+	// PendingWebhookDeliveries returns at most limit deliveries that are due
+	// to be attempted at the unix timestamp, and that are not claimed,
+	// ordered by when they are due.
+	PendingWebhookDeliveries(now int64, limit int) ([]WebhookDelivery, error)
+
+	// ClaimWebhookDelivery claims a pending delivery until the unix timestamp
+	// claimedUntil, so that it is only attempted by one process at a time.
+	// It returns false if the delivery is claimed at the unix timestamp now,
+	// or if it has been attempted since it was loaded.
+	ClaimWebhookDelivery(delivery WebhookDelivery, now, claimedUntil int64) (bool, error)
+
+	// UpdateWebhookDelivery stores the outcome of an attempt, and releases the
+	// claim on the delivery.
+	UpdateWebhookDelivery(delivery WebhookDelivery) error
+
+	// FailedWebhookDeliveries returns the deliveries that have failed after
+	// the maximum number of attempts, ordered by when they failed.
+	FailedWebhookDeliveries() ([]WebhookDelivery, error)
+}
+
+type webhooker struct {
+	*DB
+}
+
+// NewWebhooker returns a Webhooker that stores webhooks in the database at the
+// URL.
+func NewWebhooker(databaseURL string) (Webhooker, error) {
+	db, err := OpenDB(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	return NewWebhookerWithDB(db), nil
+}
+
+// NewWebhookerWithDB returns a Webhooker that stores webhooks in an open
+// database.
+func NewWebhookerWithDB(db *DB) Webhooker {
+	return &webhooker{
+		db,
+	}
+}
+
+func (webhooker *webhooker) InsertWebhook(webhook Webhook) error {
+	_, err := webhooker.Exec("INSERT INTO webhooks (id, trader, url, secret, registered_by, created_at) VALUES ($1, $2, $3, $4, $5, $6)",
+		webhook.ID, normalizeAddress(webhook.Trader), webhook.URL, webhook.Secret, normalizeAddress(webhook.RegisteredBy), webhook.CreatedAt)
+	return err
+}
+
+func (webhooker *webhooker) Webhook(id string) (Webhook, error) {
+	webhook := Webhook{}
+	err := webhooker.QueryRow("SELECT id, trader, url, secret, registered_by, created_at FROM webhooks WHERE id=$1", id).
+		Scan(&webhook.ID, &webhook.Trader, &webhook.URL, &webhook.Secret, &webhook.RegisteredBy, &webhook.CreatedAt)
+	return webhook, err
+}
+
+func (webhooker *webhooker) Webhooks(trader string) ([]Webhook, error) {
+	rows, err := webhooker.Query("SELECT id, trader, url, registered_by, created_at FROM webhooks WHERE trader=$1 ORDER BY created_at, id", normalizeAddress(trader))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []Webhook{}
+	for rows.Next() {
+		webhook := Webhook{}
+		if err := rows.Scan(&webhook.ID, &webhook.Trader, &webhook.URL, &webhook.RegisteredBy, &webhook.CreatedAt); err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, rows.Err()
+}
+
+func (webhooker *webhooker) DeleteWebhook(id string) error {
+	res, err := webhooker.Exec("DELETE FROM webhooks WHERE id=$1", id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	_, err = webhooker.Exec("DELETE FROM webhook_deliveries WHERE webhook_id=$1 AND delivered_at=0 AND failed_at=0", id)
+	return err
+}
+
+func (webhooker *webhooker) InsertWebhookOrder(order WebhookOrder) error {
+	_, err := webhooker.Exec("INSERT INTO webhook_orders (order_id, trader, confirmed, created_at) VALUES ($1, $2, $3, $4) ON CONFLICT (order_id) DO NOTHING",
+		order.OrderID, normalizeAddress(order.Trader), order.Confirmed, order.CreatedAt)
+	return err
+}
+
+func (webhooker *webhooker) WebhookOrder(orderID string) (WebhookOrder, error) {
+	order := WebhookOrder{}
+	err := webhooker.QueryRow("SELECT order_id, trader, confirmed, created_at FROM webhook_orders WHERE order_id=$1", orderID).
+		Scan(&order.OrderID, &order.Trader, &order.Confirmed, &order.CreatedAt)
+	return order, err
+}
+
+func (webhooker *webhooker) UnconfirmedWebhookOrders() ([]WebhookOrder, error) {
+	rows, err := webhooker.Query("SELECT order_id, trader, confirmed, created_at FROM webhook_orders WHERE NOT confirmed ORDER BY created_at, order_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orders := []WebhookOrder{}
+	for rows.Next() {
+		order := WebhookOrder{}
+		if err := rows.Scan(&order.OrderID, &order.Trader, &order.Confirmed, &order.CreatedAt); err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+	return orders, rows.Err()
+}
+
+func (webhooker *webhooker) ConfirmWebhookOrder(orderID string) error {
+	_, err := webhooker.Exec("UPDATE webhook_orders SET confirmed=TRUE WHERE order_id=$1", orderID)
+	return err
+}
+
+func (webhooker *webhooker) DeleteWebhookOrder(orderID string) error {
+	_, err := webhooker.Exec("DELETE FROM webhook_orders WHERE order_id=$1", orderID)
+	return err
+}
+
+func (webhooker *webhooker) InsertWebhookDelivery(delivery WebhookDelivery) error {
+	_, err := webhooker.Exec("INSERT INTO webhook_deliveries (webhook_id, event_id, payload, attempts, next_attempt_at, last_error, delivered_at, failed_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) ON CONFLICT (webhook_id, event_id) DO NOTHING",
+		delivery.WebhookID, delivery.EventID, delivery.Payload, delivery.Attempts, delivery.NextAttemptAt, delivery.LastError, delivery.DeliveredAt, delivery.FailedAt)
+	return err
+}
+
+func (webhooker *webhooker) PendingWebhookDeliveries(now int64, limit int) ([]WebhookDelivery, error) {
+	return webhooker.selectWebhookDeliveries("WHERE delivered_at=0 AND failed_at=0 AND next_attempt_at <= $1 AND claimed_until <= $1 ORDER BY next_attempt_at, webhook_id, event_id LIMIT $2", now, limit)
+}
+
+func (webhooker *webhooker) ClaimWebhookDelivery(delivery WebhookDelivery, now, claimedUntil int64) (bool, error) {
+	res, err := webhooker.Exec("UPDATE webhook_deliveries SET claimed_until=$1 WHERE webhook_id=$2 AND event_id=$3 AND attempts=$4 AND delivered_at=0 AND failed_at=0 AND claimed_until <= $5",
+		claimedUntil, delivery.WebhookID, delivery.EventID, delivery.Attempts, now)
+	if err != nil {
+		return false, err
+	}
+	claimed, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return claimed > 0, nil
+}
+
+func (webhooker *webhooker) UpdateWebhookDelivery(delivery WebhookDelivery) error {
+	_, err := webhooker.Exec("UPDATE webhook_deliveries SET attempts=$1, next_attempt_at=$2, last_error=$3, delivered_at=$4, failed_at=$5, claimed_until=0 WHERE webhook_id=$6 AND event_id=$7",
+		delivery.Attempts, delivery.NextAttemptAt, delivery.LastError, delivery.DeliveredAt, delivery.FailedAt, delivery.WebhookID, delivery.EventID)
+	return err
+}
+
+func (webhooker *webhooker) FailedWebhookDeliveries() ([]WebhookDelivery, error) {
+	return webhooker.selectWebhookDeliveries("WHERE failed_at>0 ORDER BY failed_at, webhook_id, event_id")
+}
+
+func (webhooker *webhooker) selectWebhookDeliveries(where string, args ...interface{}) ([]WebhookDelivery, error) {
+	rows, err := webhooker.Query("SELECT webhook_id, event_id, payload, attempts, next_attempt_at, last_error, delivered_at, failed_at FROM webhook_deliveries "+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		delivery := WebhookDelivery{}
+		if err := rows.Scan(&delivery.WebhookID, &delivery.EventID, &delivery.Payload, &delivery.Attempts, &delivery.NextAttemptAt, &delivery.LastError, &delivery.DeliveredAt, &delivery.FailedAt); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
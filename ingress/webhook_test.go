@@ -0,0 +1,470 @@
+package ingress_test
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/republicprotocol/renex-ingress-go/ingress"
+)
+
+const webhookTrader = "0x5B3B5D4d8b4C53F6eD9e1e2C30A4fE1b2e8D7e1A"
+
+var _ = Describe("Webhooker", func() {
+
+	newSQLiteWebhooker := func() Webhooker {
+		db, err := OpenDB(SQLiteURLPrefix + ":memory:")
+		Expect(err).ShouldNot(HaveOccurred())
+		_, err = db.Migrate()
+		Expect(err).ShouldNot(HaveOccurred())
+		return NewWebhookerWithDB(db)
+	}
+
+	for _, backend := range []struct {
+		name         string
+		newWebhooker func() Webhooker
+	}{
+		{"memory", NewMemoryWebhooker},
+		{"sqlite", newSQLiteWebhooker},
+	} {
+		backend := backend
+
+		Context("when using "+backend.name+" storage", func() {
+
+			var webhooker Webhooker
+			var webhook Webhook
+
+			BeforeEach(func() {
+				var err error
+				webhooker = backend.newWebhooker()
+				webhook, err = NewWebhook(webhookTrader, "https://example.com/hook", webhookTrader)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(webhooker.InsertWebhook(webhook)).ShouldNot(HaveOccurred())
+			})
+
+			It("should store webhooks without returning their secrets when listed", func() {
+				stored, err := webhooker.Webhook(webhook.ID)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(stored).Should(Equal(webhook))
+
+				webhooks, err := webhooker.Webhooks(webhookTrader)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(webhooks).Should(HaveLen(1))
+				Expect(webhooks[0].ID).Should(Equal(webhook.ID))
+				Expect(webhooks[0].Secret).Should(BeEmpty())
+			})
+
+			It("should delete webhooks and their pending deliveries", func() {
+				Expect(webhooker.InsertWebhookDelivery(WebhookDelivery{WebhookID: webhook.ID, EventID: "event", Payload: "{}", NextAttemptAt: 1})).ShouldNot(HaveOccurred())
+				Expect(webhooker.DeleteWebhook(webhook.ID)).ShouldNot(HaveOccurred())
+				Expect(webhooker.DeleteWebhook(webhook.ID)).Should(Equal(sql.ErrNoRows))
+
+				_, err := webhooker.Webhook(webhook.ID)
+				Expect(err).Should(Equal(sql.ErrNoRows))
+				deliveries, err := webhooker.PendingWebhookDeliveries(1, 10)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(deliveries).Should(BeEmpty())
+			})
+
+			It("should follow orders until they are forgotten", func() {
+				order := WebhookOrder{OrderID: "order", Trader: webhookTrader, CreatedAt: 100}
+				Expect(webhooker.InsertWebhookOrder(order)).ShouldNot(HaveOccurred())
+				Expect(webhooker.InsertWebhookOrder(WebhookOrder{OrderID: "order", Trader: "0x1", CreatedAt: 200})).ShouldNot(HaveOccurred())
+
+				stored, err := webhooker.WebhookOrder("order")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(stored.Trader).Should(Equal(webhook.Trader))
+				Expect(stored.CreatedAt).Should(Equal(int64(100)))
+
+				orders, err := webhooker.UnconfirmedWebhookOrders()
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(orders).Should(HaveLen(1))
+				Expect(webhooker.ConfirmWebhookOrder("order")).ShouldNot(HaveOccurred())
+				orders, err = webhooker.UnconfirmedWebhookOrders()
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(orders).Should(BeEmpty())
+
+				Expect(webhooker.DeleteWebhookOrder("order")).ShouldNot(HaveOccurred())
+				_, err = webhooker.WebhookOrder("order")
+				Expect(err).Should(Equal(sql.ErrNoRows))
+			})
+
+			It("should store each event once per webhook", func() {
+				first := WebhookDelivery{WebhookID: webhook.ID, EventID: "first", Payload: "{}", NextAttemptAt: 10}
+				second := WebhookDelivery{WebhookID: webhook.ID, EventID: "second", Payload: "{}", NextAttemptAt: 20}
+				Expect(webhooker.InsertWebhookDelivery(second)).ShouldNot(HaveOccurred())
+				Expect(webhooker.InsertWebhookDelivery(first)).ShouldNot(HaveOccurred())
+				Expect(webhooker.InsertWebhookDelivery(WebhookDelivery{WebhookID: webhook.ID, EventID: "first", Payload: "{}", NextAttemptAt: 0})).ShouldNot(HaveOccurred())
+
+				deliveries, err := webhooker.PendingWebhookDeliveries(5, 10)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(deliveries).Should(BeEmpty())
+				deliveries, err = webhooker.PendingWebhookDeliveries(20, 10)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(deliveries).Should(Equal([]WebhookDelivery{first, second}))
+				deliveries, err = webhooker.PendingWebhookDeliveries(20, 1)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(deliveries).Should(Equal([]WebhookDelivery{first}))
+			})
+
+			It("should record delivered and failed deliveries", func() {
+				first := WebhookDelivery{WebhookID: webhook.ID, EventID: "first", Payload: "{}", NextAttemptAt: 10}
+				second := WebhookDelivery{WebhookID: webhook.ID, EventID: "second", Payload: "{}", NextAttemptAt: 10}
+				Expect(webhooker.InsertWebhookDelivery(first)).ShouldNot(HaveOccurred())
+				Expect(webhooker.InsertWebhookDelivery(second)).ShouldNot(HaveOccurred())
+
+				first.Attempts, first.DeliveredAt = 1, 10
+				second.Attempts, second.LastError, second.FailedAt = WebhookMaxAttempts, "unexpected status code 500", 10
+				Expect(webhooker.UpdateWebhookDelivery(first)).ShouldNot(HaveOccurred())
+				Expect(webhooker.UpdateWebhookDelivery(second)).ShouldNot(HaveOccurred())
+
+				deliveries, err := webhooker.PendingWebhookDeliveries(10, 10)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(deliveries).Should(BeEmpty())
+				deliveries, err = webhooker.FailedWebhookDeliveries()
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(deliveries).Should(Equal([]WebhookDelivery{second}))
+			})
+
+			It("should claim each delivery once until the claim expires", func() {
+				delivery := WebhookDelivery{WebhookID: webhook.ID, EventID: "first", Payload: "{}", NextAttemptAt: 10}
+				Expect(webhooker.InsertWebhookDelivery(delivery)).ShouldNot(HaveOccurred())
+
+				claimed, err := webhooker.ClaimWebhookDelivery(delivery, 10, 20)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(claimed).Should(BeTrue())
+				claimed, err = webhooker.ClaimWebhookDelivery(delivery, 15, 25)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(claimed).Should(BeFalse())
+				deliveries, err := webhooker.PendingWebhookDeliveries(15, 10)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(deliveries).Should(BeEmpty())
+
+				deliveries, err = webhooker.PendingWebhookDeliveries(20, 10)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(deliveries).Should(Equal([]WebhookDelivery{delivery}))
+				claimed, err = webhooker.ClaimWebhookDelivery(delivery, 20, 30)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(claimed).Should(BeTrue())
+			})
+
+			It("should not claim deliveries that were attempted since they were loaded", func() {
+				delivery := WebhookDelivery{WebhookID: webhook.ID, EventID: "first", Payload: "{}", NextAttemptAt: 10}
+				Expect(webhooker.InsertWebhookDelivery(delivery)).ShouldNot(HaveOccurred())
+				claimed, err := webhooker.ClaimWebhookDelivery(delivery, 10, 20)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(claimed).Should(BeTrue())
+
+				attempted := delivery
+				attempted.Attempts, attempted.LastError = 1, "unexpected status code 500"
+				Expect(webhooker.UpdateWebhookDelivery(attempted)).ShouldNot(HaveOccurred())
+
+				claimed, err = webhooker.ClaimWebhookDelivery(delivery, 10, 20)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(claimed).Should(BeFalse())
+				claimed, err = webhooker.ClaimWebhookDelivery(attempted, 10, 20)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(claimed).Should(BeTrue())
+			})
+		})
+	}
+})
+
+var _ = Describe("Webhook dispatcher", func() {
+
+	var server *httptest.Server
+	var receiver *mockWebhookReceiver
+	var webhooker Webhooker
+	var binder *mockSwapContractBinder
+	var dispatcher WebhookDispatcher
+	var webhook Webhook
+	var buy, sell PartialSwap
+
+	BeforeEach(func() {
+		var err error
+		receiver = &mockWebhookReceiver{mu: new(sync.Mutex), status: http.StatusOK}
+		server = httptest.NewServer(receiver)
+		webhooker = NewWebhookerWithDB(newSQLiteDB())
+		binder = newMockSwapContractBinder()
+		dispatcher = NewWebhookDispatcherWithClient(webhooker, binder, &http.Client{Timeout: WebhookTimeout}, time.Hour)
+
+		webhook, err = NewWebhook(webhookTrader, server.URL, webhookTrader)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(webhooker.InsertWebhook(webhook)).ShouldNot(HaveOccurred())
+		buy, sell = newPartialSwap(1), newPartialSwap(2)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should deliver signed events to the webhooks of the trader", func() {
+		Expect(dispatcher.Notify(Event{Type: EventSwapFinalized, OrderID: buy.OrderID, Trader: webhookTrader})).ShouldNot(HaveOccurred())
+		Expect(dispatcher.Dispatch()).ShouldNot(HaveOccurred())
+
+		requests := receiver.received()
+		Expect(requests).Should(HaveLen(1))
+		Expect(requests[0].header.Get(WebhookEventHeader)).Should(Equal(EventSwapFinalized + ":" + buy.OrderID))
+		Expect(requests[0].header.Get(WebhookSignatureHeader)).Should(Equal(WebhookSignature(webhook.Secret, requests[0].body)))
+		event := Event{}
+		Expect(json.Unmarshal(requests[0].body, &event)).ShouldNot(HaveOccurred())
+		Expect(event.Type).Should(Equal(EventSwapFinalized))
+		Expect(event.OrderID).Should(Equal(buy.OrderID))
+		Expect(event.Trader).Should(Equal(webhook.Trader))
+	})
+
+	It("should deliver each event once", func() {
+		event := Event{Type: EventSwapExpired, OrderID: buy.OrderID, Trader: webhookTrader}
+		Expect(dispatcher.Notify(event)).ShouldNot(HaveOccurred())
+		Expect(dispatcher.Notify(event)).ShouldNot(HaveOccurred())
+		Expect(dispatcher.Dispatch()).ShouldNot(HaveOccurred())
+		Expect(dispatcher.Notify(event)).ShouldNot(HaveOccurred())
+		Expect(dispatcher.Dispatch()).ShouldNot(HaveOccurred())
+		Expect(receiver.received()).Should(HaveLen(1))
+	})
+
+	It("should ignore events of traders without webhooks", func() {
+		Expect(dispatcher.Notify(Event{Type: EventFragmentsDelivered, OrderID: buy.OrderID, Trader: "0x1"})).ShouldNot(HaveOccurred())
+		Expect(dispatcher.Notify(Event{Type: EventOrderSettled, OrderID: sell.OrderID})).ShouldNot(HaveOccurred())
+		Expect(dispatcher.Dispatch()).ShouldNot(HaveOccurred())
+		Expect(receiver.received()).Should(BeEmpty())
+		_, err := webhooker.WebhookOrder(buy.OrderID)
+		Expect(err).Should(Equal(sql.ErrNoRows))
+	})
+
+	It("should deliver each event once when several processes dispatch events", func() {
+		others := make([]WebhookDispatcher, 3)
+		for i := range others {
+			others[i] = NewWebhookDispatcherWithClient(webhooker, binder, &http.Client{Timeout: WebhookTimeout}, time.Hour)
+		}
+		for i := byte(0); i < 10; i++ {
+			Expect(dispatcher.Notify(Event{Type: EventSwapExpired, OrderID: newPartialSwap(i).OrderID, Trader: webhookTrader})).ShouldNot(HaveOccurred())
+		}
+
+		var wg sync.WaitGroup
+		for _, other := range append(others, dispatcher) {
+			wg.Add(1)
+			go func(other WebhookDispatcher) {
+				defer GinkgoRecover()
+				defer wg.Done()
+				Expect(other.Dispatch()).ShouldNot(HaveOccurred())
+			}(other)
+		}
+		wg.Wait()
+		Expect(dispatcher.Dispatch()).ShouldNot(HaveOccurred())
+
+		Expect(receiver.received()).Should(HaveLen(10))
+	})
+
+	It("should notify traders when their orders are confirmed and settled", func() {
+		Expect(dispatcher.Notify(Event{Type: EventFragmentsDelivered, OrderID: buy.OrderID, Trader: webhookTrader})).ShouldNot(HaveOccurred())
+		Expect(dispatcher.Dispatch()).ShouldNot(HaveOccurred())
+		binder.settle(buy.OrderID, sell.OrderID)
+		Expect(dispatcher.Dispatch()).ShouldNot(HaveOccurred())
+		Expect(dispatcher.Notify(Event{Type: EventOrderSettled, OrderID: buy.OrderID})).ShouldNot(HaveOccurred())
+		Expect(dispatcher.Dispatch()).ShouldNot(HaveOccurred())
+
+		Expect(receiver.eventTypes()).Should(Equal([]string{EventFragmentsDelivered, EventOrderConfirmed, EventOrderSettled}))
+		_, err := webhooker.WebhookOrder(buy.OrderID)
+		Expect(err).Should(Equal(sql.ErrNoRows))
+	})
+
+	It("should notify traders of confirmations that were not observed before settlement", func() {
+		Expect(dispatcher.Notify(Event{Type: EventFragmentsDelivered, OrderID: buy.OrderID, Trader: webhookTrader})).ShouldNot(HaveOccurred())
+		Expect(dispatcher.Notify(Event{Type: EventOrderSettled, OrderID: buy.OrderID})).ShouldNot(HaveOccurred())
+		Expect(dispatcher.Dispatch()).ShouldNot(HaveOccurred())
+
+		Expect(receiver.eventTypes()).Should(ConsistOf(EventFragmentsDelivered, EventOrderConfirmed, EventOrderSettled))
+	})
+
+	It("should forget canceled orders", func() {
+		Expect(dispatcher.Notify(Event{Type: EventFragmentsDelivered, OrderID: buy.OrderID, Trader: webhookTrader})).ShouldNot(HaveOccurred())
+		binder.cancel(buy.OrderID)
+		Expect(dispatcher.Dispatch()).ShouldNot(HaveOccurred())
+		_, err := webhooker.WebhookOrder(buy.OrderID)
+		Expect(err).Should(Equal(sql.ErrNoRows))
+	})
+
+	It("should retry failed deliveries with a backoff", func() {
+		receiver.setStatus(http.StatusInternalServerError)
+		Expect(dispatcher.Notify(Event{Type: EventSwapExpired, OrderID: buy.OrderID, Trader: webhookTrader})).ShouldNot(HaveOccurred())
+		Expect(dispatcher.Dispatch()).ShouldNot(HaveOccurred())
+		Expect(dispatcher.Dispatch()).ShouldNot(HaveOccurred())
+		Expect(receiver.received()).Should(HaveLen(1))
+
+		now := time.Now().Unix()
+		deliveries, err := webhooker.PendingWebhookDeliveries(now, 10)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(deliveries).Should(BeEmpty())
+		deliveries, err = webhooker.PendingWebhookDeliveries(now+int64(WebhookBackoff/time.Second), 10)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(deliveries).Should(HaveLen(1))
+		Expect(deliveries[0].Attempts).Should(Equal(1))
+		Expect(deliveries[0].LastError).Should(ContainSubstring("500"))
+	})
+
+	It("should not attempt further deliveries to a webhook after a failed delivery", func() {
+		receiver.setStatus(http.StatusInternalServerError)
+		Expect(dispatcher.Notify(Event{Type: EventSwapExpired, OrderID: buy.OrderID, Trader: webhookTrader})).ShouldNot(HaveOccurred())
+		Expect(dispatcher.Notify(Event{Type: EventSwapExpired, OrderID: sell.OrderID, Trader: webhookTrader})).ShouldNot(HaveOccurred())
+		Expect(dispatcher.Dispatch()).ShouldNot(HaveOccurred())
+		Expect(receiver.received()).Should(HaveLen(1))
+
+		deliveries, err := webhooker.PendingWebhookDeliveries(time.Now().Unix(), 10)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(deliveries).Should(HaveLen(1))
+		Expect(deliveries[0].Attempts).Should(BeZero())
+	})
+
+	It("should deliver events to other webhooks while a webhook is unresponsive", func() {
+		release := make(chan struct{})
+		hanging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-release
+		}))
+		defer hanging.Close()
+		defer close(release)
+
+		unresponsive, err := NewWebhook(webhookTrader, hanging.URL, webhookTrader)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(webhooker.InsertWebhook(unresponsive)).ShouldNot(HaveOccurred())
+		Expect(dispatcher.Notify(Event{Type: EventSwapExpired, OrderID: buy.OrderID, Trader: webhookTrader})).ShouldNot(HaveOccurred())
+
+		dispatched := make(chan error, 1)
+		go func() {
+			dispatched <- dispatcher.Dispatch()
+		}()
+		Eventually(receiver.received).Should(HaveLen(1))
+		Consistently(dispatched).ShouldNot(Receive())
+
+		release <- struct{}{}
+		Eventually(dispatched).Should(Receive(BeNil()))
+	})
+
+	Context("when deliveries are retried without a backoff", func() {
+
+		var backoff time.Duration
+
+		BeforeEach(func() {
+			backoff = WebhookBackoff
+			WebhookBackoff = 0
+		})
+
+		AfterEach(func() {
+			WebhookBackoff = backoff
+		})
+
+		It("should record deliveries that fail after the maximum number of attempts", func() {
+			receiver.setStatus(http.StatusInternalServerError)
+			Expect(dispatcher.Notify(Event{Type: EventSwapExpired, OrderID: buy.OrderID, Trader: webhookTrader})).ShouldNot(HaveOccurred())
+			for i := 0; i < WebhookMaxAttempts+1; i++ {
+				Expect(dispatcher.Dispatch()).ShouldNot(HaveOccurred())
+			}
+			Expect(receiver.received()).Should(HaveLen(WebhookMaxAttempts))
+
+			deliveries, err := webhooker.FailedWebhookDeliveries()
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(deliveries).Should(HaveLen(1))
+			Expect(deliveries[0].Attempts).Should(Equal(WebhookMaxAttempts))
+			Expect(deliveries[0].FailedAt).ShouldNot(BeZero())
+		})
+
+		It("should deliver events once the webhook recovers", func() {
+			receiver.setStatus(http.StatusInternalServerError)
+			Expect(dispatcher.Notify(Event{Type: EventSwapExpired, OrderID: buy.OrderID, Trader: webhookTrader})).ShouldNot(HaveOccurred())
+			Expect(dispatcher.Dispatch()).ShouldNot(HaveOccurred())
+			receiver.setStatus(http.StatusNoContent)
+			Expect(dispatcher.Dispatch()).ShouldNot(HaveOccurred())
+			Expect(dispatcher.Dispatch()).ShouldNot(HaveOccurred())
+			Expect(receiver.received()).Should(HaveLen(2))
+
+			deliveries, err := webhooker.FailedWebhookDeliveries()
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(deliveries).Should(BeEmpty())
+		})
+	})
+})
+
+var _ = Describe("Webhook client", func() {
+
+	It("should not connect to private addresses", func() {
+		receiver := &mockWebhookReceiver{mu: new(sync.Mutex), status: http.StatusOK}
+		server := httptest.NewServer(receiver)
+		defer server.Close()
+
+		_, err := NewWebhookClient().Post(server.URL, "application/json", nil)
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).Should(ContainSubstring(ErrPrivateWebhookAddress.Error()))
+		Expect(receiver.received()).Should(BeEmpty())
+	})
+
+	It("should only consider public addresses to be public", func() {
+		for _, ip := range []string{
+			"127.0.0.1", "::1", "0.0.0.0", "::", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254", "fe80::1", "fd00::1",
+			"100.64.0.1", "198.18.0.1", "198.19.255.255", "192.0.0.8", "192.0.2.1", "198.51.100.1", "203.0.113.1", "240.0.0.1", "255.255.255.255", "224.0.0.1",
+			"::ffff:127.0.0.1", "::ffff:10.0.0.1", "::ffff:100.64.0.1", "::ffff:203.0.113.1", "::10.0.0.1",
+			"64:ff9b::7f00:1", "64:ff9b::a9fe:a9fe", "64:ff9b::c633:6401", "64:ff9b:1::808:808", "2002:a00:1::", "2001:db8::1", "2001::1", "ff02::1",
+		} {
+			Expect(PublicIP(net.ParseIP(ip))).Should(BeFalse(), ip)
+		}
+		for _, ip := range []string{"8.8.8.8", "1.1.1.1", "172.32.0.1", "100.128.0.1", "2001:4860:4860::8888", "::ffff:8.8.8.8", "64:ff9b::808:808", "2002:808:808::"} {
+			Expect(PublicIP(net.ParseIP(ip))).Should(BeTrue(), ip)
+		}
+	})
+})
+
+// mockWebhookReceiver records the requests that it receives, and responds
+// with its status.
+type mockWebhookReceiver struct {
+	mu       *sync.Mutex
+	status   int
+	requests []mockWebhookRequest
+}
+
+type mockWebhookRequest struct {
+	header http.Header
+	body   []byte
+}
+
+func (receiver *mockWebhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	Expect(err).ShouldNot(HaveOccurred())
+
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+
+	receiver.requests = append(receiver.requests, mockWebhookRequest{header: r.Header, body: body})
+	w.WriteHeader(receiver.status)
+}
+
+func (receiver *mockWebhookReceiver) setStatus(status int) {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+
+	receiver.status = status
+}
+
+func (receiver *mockWebhookReceiver) received() []mockWebhookRequest {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+
+	return append([]mockWebhookRequest{}, receiver.requests...)
+}
+
+// eventTypes returns the types of the events that were received, in the order
+// in which they were received.
+func (receiver *mockWebhookReceiver) eventTypes() []string {
+	types := []string{}
+	for _, request := range receiver.received() {
+		event := Event{}
+		Expect(json.Unmarshal(request.body, &event)).ShouldNot(HaveOccurred())
+		types = append(types, event.Type)
+	}
+	return types
+}
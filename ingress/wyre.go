@@ -0,0 +1,214 @@
+package ingress
+
+import (
+	"database/sql"
+	"expvar"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/republicprotocol/renex-ingress-go/contract"
+)
+
+// WatchConfirmations is the number of blocks that a watcher waits for before
+// processing the events of a block, so that events are unlikely to be undone
+// by a reorg.
+const WatchConfirmations = 6
+
+// WatchBlockRange is the maximum number of blocks from which a watcher
+// filters events in a single request.
+const WatchBlockRange = 5000
+
+// wyreCursor is the name of the cursor stored by the WyreWatcher.
+const wyreCursor = "wyre"
+
+// wyreMetrics are the counts of the WyreWatcher, published by expvar under
+// "wyre_watcher".
+var wyreMetrics = expvar.NewMap("wyre_watcher")
+
+// A WyreWatcher follows the mints, transfers and burns of Wyre KYC tokens, and
+// keeps the Wyre verification of traders up to date so that it can be
+// trusted without checking the Wyre contract on every request.
+type WyreWatcher interface {
+	// Run syncs the watcher on every interval until the done channel is
+	// closed. Errors are written to the returned channel.
+	Run(done <-chan struct{}) <-chan error
+
+	// Sync processes all confirmed blocks after the stored cursor. When no
+	// cursor is stored, blocks are processed from the start block. Blocks are
+	// only processed while the watcher holds the lease on the cursor, so that
+	// the processes of the Ingress do not process the same blocks.
+	Sync() error
+}
+
+type wyreWatcher struct {
+	binder     WyreContractBinder
+	loginer    Loginer
+	cursorer   Cursorer
+	leaser     Leaser
+	verifier   KYCVerifier
+	startBlock uint64
+	interval   time.Duration
+}
+
+// NewWyreWatcher returns a WyreWatcher that stores Wyre verifications using
+// the Loginer, and forgets cached results of the KYCVerifier when a
+// verification changes.
+func NewWyreWatcher(binder WyreContractBinder, loginer Loginer, cursorer Cursorer, leaser Leaser, verifier KYCVerifier, startBlock uint64, interval time.Duration) WyreWatcher {
+	return &wyreWatcher{
+		binder:     binder,
+		loginer:    loginer,
+		cursorer:   cursorer,
+		leaser:     leaser,
+		verifier:   verifier,
+		startBlock: startBlock,
+		interval:   interval,
+	}
+}
+
+// Run implements the WyreWatcher interface.
+func (watcher *wyreWatcher) Run(done <-chan struct{}) <-chan error {
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(errs)
+
+		ticker := time.NewTicker(watcher.interval)
+		defer ticker.Stop()
+
+		for {
+			if err := watcher.Sync(); err != nil {
+				select {
+				case <-done:
+					return
+				case errs <- err:
+				}
+			}
+
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return errs
+}
+
+// Sync implements the WyreWatcher interface.
+func (watcher *wyreWatcher) Sync() error {
+	held, err := holdLease(watcher.leaser, wyreCursor)
+	if err != nil {
+		return err
+	}
+	if !held {
+		wyreMetrics.Add("skipped", 1)
+		return nil
+	}
+
+	next, ok, err := watcher.cursorer.Cursor(wyreCursor)
+	if err != nil {
+		return fmt.Errorf("cannot load wyre cursor: %v", err)
+	}
+	if !ok {
+		next = watcher.startBlock
+	}
+
+	latest, err := watcher.binder.BlockNumber()
+	if err != nil {
+		return fmt.Errorf("cannot get latest block: %v", err)
+	}
+	if latest < WatchConfirmations {
+		return nil
+	}
+	end := latest - WatchConfirmations
+
+	for start := next; next <= end; {
+		// The lease is renewed before each range of blocks, and the watcher
+		// stops if another process has taken it over.
+		if next > start {
+			if held, err := holdLease(watcher.leaser, wyreCursor); err != nil || !held {
+				return err
+			}
+		}
+		to := next + WatchBlockRange - 1
+		if to > end {
+			to = end
+		}
+		transfers, err := watcher.binder.WyreTransfers(next, to)
+		if err != nil {
+			return fmt.Errorf("cannot filter wyre transfers from block %v to %v: %v", next, to, err)
+		}
+		if err := watcher.processTransfers(transfers); err != nil {
+			return err
+		}
+		if err := watcher.cursorer.UpdateCursor(wyreCursor, to+1); err != nil {
+			return fmt.Errorf("cannot store wyre cursor: %v", err)
+		}
+		wyreMetrics.Add("transfers", int64(len(transfers)))
+		next = to + 1
+	}
+	return nil
+}
+
+// processTransfers updates the Wyre verification of every address that sent
+// or received a token. The current balance of the address is used, rather
+// than the transfers, so that transfers before the start block do not need
+// to be processed.
+func (watcher *wyreWatcher) processTransfers(transfers []contract.WyreTransfer) error {
+	seen := map[common.Address]bool{}
+	for _, transfer := range transfers {
+		for _, addr := range []common.Address{transfer.From, transfer.To} {
+			if addr == (common.Address{}) || seen[addr] {
+				continue
+			}
+			seen[addr] = true
+			if err := watcher.updateVerification(addr); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (watcher *wyreWatcher) updateVerification(addr common.Address) error {
+	address := strings.ToLower(addr.Hex())
+	balance, err := watcher.binder.BalanceOf(addr)
+	if err != nil {
+		return fmt.Errorf("cannot get wyre balance of %v: %v", address, err)
+	}
+
+	if balance.Cmp(big.NewInt(0)) == 1 {
+		if err := watcher.loginer.UpdateLogin(address, 0, KYCWyre); err != nil {
+			return fmt.Errorf("cannot update wyre verification of %v: %v", address, err)
+		}
+		wyreMetrics.Add("verified", 1)
+	} else {
+		login, err := watcher.loginer.SelectLogin(address)
+		if err == sql.ErrNoRows || (err == nil && login.KYCWyre == "") {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("cannot get wyre verification of %v: %v", address, err)
+		}
+		if err := watcher.loginer.RevokeLogin(address, KYCWyre, "wyre token transferred or burned"); err != nil {
+			return fmt.Errorf("cannot revoke wyre verification of %v: %v", address, err)
+		}
+		wyreMetrics.Add("revoked", 1)
+	}
+
+	// Authorized addresses inherit the verification of the trader, so their
+	// cached results are forgotten as well.
+	watcher.verifier.ForgetVerification(address)
+	authorized, err := watcher.loginer.AuthorizedLogins(address)
+	if err != nil {
+		return fmt.Errorf("cannot load addresses authorized by %v: %v", address, err)
+	}
+	for _, login := range authorized {
+		watcher.verifier.ForgetVerification(login.Address)
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package ingress_test
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/republicprotocol/renex-ingress-go/ingress"
+
+	"github.com/republicprotocol/renex-ingress-go/contract"
+)
+
+var _ = Describe("Wyre watcher", func() {
+
+	trader := common.HexToAddress("0x0000000000000000000000000000000000000001")
+	other := common.HexToAddress("0x0000000000000000000000000000000000000002")
+	traderAddr := strings.ToLower(trader.Hex())
+
+	var binder *mockWyreBinder
+	var loginer Loginer
+	var cursorer Cursorer
+	var leaser Leaser
+	var db *DB
+	var verifier *mockKYCVerifier
+	var watcher WyreWatcher
+
+	BeforeEach(func() {
+		binder = newMockWyreBinder()
+		db = newSQLiteDB()
+		loginer = NewLoginerWithDB(db)
+		cursorer = NewCursorerWithDB(db)
+		leaser = NewLeaserWithDB(db)
+		verifier = &mockKYCVerifier{mu: new(sync.Mutex)}
+		watcher = NewWyreWatcher(binder, loginer, cursorer, leaser, verifier, 0, time.Hour)
+
+		Expect(loginer.InsertLogin(traderAddr, "")).ShouldNot(HaveOccurred())
+		Expect(loginer.Authorize(traderAddr, "0xauthorized")).ShouldNot(HaveOccurred())
+	})
+
+	It("should verify traders when a token is minted", func() {
+		binder.mint(trader, 10)
+		binder.latest = 10 + WatchConfirmations
+		Expect(watcher.Sync()).ShouldNot(HaveOccurred())
+
+		for _, address := range []string{traderAddr, "0xauthorized"} {
+			login, err := loginer.SelectLogin(address)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(login.KYCWyre).ShouldNot(BeEmpty())
+		}
+		Expect(verifier.forgotten).Should(ConsistOf(traderAddr, "0xauthorized"))
+	})
+
+	It("should revoke verifications when a token is transferred away", func() {
+		binder.mint(trader, 10)
+		binder.latest = 10 + WatchConfirmations
+		Expect(watcher.Sync()).ShouldNot(HaveOccurred())
+
+		binder.transfer(trader, other, 20)
+		binder.latest = 20 + WatchConfirmations
+		Expect(watcher.Sync()).ShouldNot(HaveOccurred())
+
+		for _, address := range []string{traderAddr, "0xauthorized"} {
+			login, err := loginer.SelectLogin(address)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(login.KYCWyre).Should(BeEmpty())
+		}
+		audits, err := loginer.KYCAudits(traderAddr)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(audits).Should(HaveLen(1))
+	})
+
+	It("should revoke verifications when a token is burned", func() {
+		binder.mint(trader, 10)
+		binder.latest = 10 + WatchConfirmations
+		Expect(watcher.Sync()).ShouldNot(HaveOccurred())
+
+		binder.transfer(trader, common.Address{}, 20)
+		binder.latest = 20 + WatchConfirmations
+		Expect(watcher.Sync()).ShouldNot(HaveOccurred())
+
+		login, err := loginer.SelectLogin(traderAddr)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(login.KYCWyre).Should(BeEmpty())
+		audits, err := loginer.KYCAudits(traderAddr)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(audits).Should(HaveLen(1))
+	})
+
+	It("should forget cached verifications of authorized addresses when a token is burned", func() {
+		binder.mint(trader, 10)
+		binder.latest = 10 + WatchConfirmations
+		Expect(watcher.Sync()).ShouldNot(HaveOccurred())
+		verifier.forgotten = nil
+
+		binder.transfer(trader, common.Address{}, 20)
+		binder.latest = 20 + WatchConfirmations
+		Expect(watcher.Sync()).ShouldNot(HaveOccurred())
+
+		login, err := loginer.SelectLogin("0xauthorized")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(login.KYCWyre).Should(BeEmpty())
+		Expect(verifier.forgotten).Should(ConsistOf(traderAddr, "0xauthorized"))
+	})
+
+	It("should not process unconfirmed blocks", func() {
+		binder.mint(trader, 10)
+		binder.latest = 10 + WatchConfirmations - 1
+		Expect(watcher.Sync()).ShouldNot(HaveOccurred())
+
+		login, err := loginer.SelectLogin(traderAddr)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(login.KYCWyre).Should(BeEmpty())
+	})
+
+	It("should resume from the stored cursor", func() {
+		binder.latest = 3 * WatchBlockRange
+		Expect(watcher.Sync()).ShouldNot(HaveOccurred())
+		block, ok, err := cursorer.Cursor("wyre")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(ok).Should(BeTrue())
+		Expect(block).Should(Equal(uint64(3*WatchBlockRange - WatchConfirmations + 1)))
+
+		binder.filtered = nil
+		watcher = NewWyreWatcher(binder, loginer, cursorer, leaser, verifier, 0, time.Hour)
+		binder.latest += 10
+		Expect(watcher.Sync()).ShouldNot(HaveOccurred())
+		Expect(binder.filtered).Should(Equal([][2]uint64{{block, block + 9}}))
+	})
+
+	It("should not process blocks while another process holds the lease", func() {
+		held, err := NewLeaserWithDB(db).AcquireLease("wyre", time.Hour)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(held).Should(BeTrue())
+
+		binder.mint(trader, 10)
+		binder.latest = 10 + WatchConfirmations
+		Expect(watcher.Sync()).ShouldNot(HaveOccurred())
+		Expect(binder.filtered).Should(BeEmpty())
+		login, err := loginer.SelectLogin(traderAddr)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(login.KYCWyre).Should(BeEmpty())
+	})
+
+	It("should not advance the cursor when filtering fails", func() {
+		binder.err = errors.New("cannot connect to ethereum")
+		binder.latest = 100
+		Expect(watcher.Sync()).Should(HaveOccurred())
+		_, ok, err := cursorer.Cursor("wyre")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(ok).Should(BeFalse())
+	})
+})
+
+// mockWyreBinder is a Wyre contract with a list of transfers, and balances
+// that reflect all of the transfers.
+type mockWyreBinder struct {
+	latest    uint64
+	transfers []contract.WyreTransfer
+	balances  map[common.Address]int64
+	filtered  [][2]uint64
+	err       error
+}
+
+func newMockWyreBinder() *mockWyreBinder {
+	return &mockWyreBinder{
+		balances: map[common.Address]int64{},
+	}
+}
+
+func (binder *mockWyreBinder) mint(to common.Address, block uint64) {
+	binder.transfer(common.Address{}, to, block)
+}
+
+func (binder *mockWyreBinder) transfer(from, to common.Address, block uint64) {
+	binder.transfers = append(binder.transfers, contract.WyreTransfer{From: from, To: to, TokenID: big.NewInt(1), BlockNumber: block})
+	binder.balances[from]--
+	binder.balances[to]++
+}
+
+func (binder *mockWyreBinder) BlockNumber() (uint64, error) {
+	return binder.latest, nil
+}
+
+func (binder *mockWyreBinder) WyreTransfers(start, end uint64) ([]contract.WyreTransfer, error) {
+	if binder.err != nil {
+		return nil, binder.err
+	}
+	binder.filtered = append(binder.filtered, [2]uint64{start, end})
+	transfers := []contract.WyreTransfer{}
+	for _, transfer := range binder.transfers {
+		if transfer.BlockNumber >= start && transfer.BlockNumber <= end {
+			transfers = append(transfers, transfer)
+		}
+	}
+	return transfers, nil
+}
+
+func (binder *mockWyreBinder) BalanceOf(addr common.Address) (*big.Int, error) {
+	return big.NewInt(binder.balances[addr]), nil
+}
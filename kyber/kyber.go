@@ -0,0 +1,275 @@
+// Package kyber implements a client for the Kyber KYC API. Traders link their
+// Kyber account to the Ingress using OAuth, after which the Ingress can check
+// the KYC status of the account using an app token obtained with its client
+// credentials.
+package kyber
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout is the timeout used for requests to the Kyber API when no
+// other timeout is configured.
+const DefaultTimeout = 10 * time.Second
+
+// TokenExpiryMargin is the duration before the expiry of the app token at
+// which it is refreshed.
+const TokenExpiryMargin = time.Minute
+
+// Values for the KYC status of a User.
+const (
+	StatusApproved = "approved"
+	StatusPending  = "pending"
+	StatusNone     = "none"
+)
+
+// ErrUnauthorized is returned when the Kyber API rejects the credentials or
+// token used for a request.
+var ErrUnauthorized = errors.New("kyber: unauthorized")
+
+// ErrUserNotFound is returned when the Kyber API does not know the user, or
+// the user has not authorized the Ingress.
+var ErrUserNotFound = errors.New("kyber: user not found")
+
+// ErrRateLimited is returned when the Kyber API is rate limiting requests.
+var ErrRateLimited = errors.New("kyber: rate limited")
+
+// ErrEmptyToken is returned when the Kyber API responds without an access
+// token (e.g. when an authorization code is invalid).
+var ErrEmptyToken = errors.New("kyber: empty access token")
+
+// StatusError is returned when the Kyber API responds with an unexpected
+// status code.
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+// Error implements the error interface.
+func (err *StatusError) Error() string {
+	return fmt.Sprintf("kyber: unexpected status %v: %v", err.StatusCode, err.Body)
+}
+
+// Token is an OAuth access token issued by the Kyber API.
+type Token struct {
+	Type         string `json:"token_type"`
+	AccessToken  string `json:"access_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// User is a Kyber account.
+type User struct {
+	UID       int64    `json:"uid"`
+	Status    string   `json:"kyc_status"`
+	Addresses []string `json:"active_wallets"`
+}
+
+// Approved returns true if the KYC of the User has been approved.
+func (user User) Approved() bool {
+	return user.Status == StatusApproved
+}
+
+// HasAddress returns true if the address is an active wallet of the User.
+// Addresses are compared without regard to case.
+func (user User) HasAddress(address string) bool {
+	for _, addr := range user.Addresses {
+		if strings.EqualFold(addr, address) {
+			return true
+		}
+	}
+	return false
+}
+
+// AuthorizationRequest is an OAuth authorization code exchange. The client
+// secret is added by the Client.
+type AuthorizationRequest struct {
+	GrantType   string `json:"grant_type"`
+	Code        string `json:"code"`
+	RedirectURI string `json:"redirect_uri"`
+	ClientID    string `json:"client_id"`
+}
+
+type authorizationRequest struct {
+	AuthorizationRequest
+	ClientSecret string `json:"client_secret"`
+}
+
+type usersResponse struct {
+	Users []User `json:"authorized_users"`
+}
+
+// Client for the Kyber API. It is safe for concurrent use. The app token is
+// cached and shared by all requests until shortly before it expires.
+type Client struct {
+	url        string
+	id         string
+	secret     string
+	timeout    time.Duration
+	httpClient *http.Client
+
+	tokenMu     *sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+// NewClient returns a Client for the Kyber API at the URL, authenticated
+// using the client credentials of the Ingress. Each request is cancelled
+// after the timeout, or after DefaultTimeout if the timeout is zero.
+func NewClient(url, id, secret string, timeout time.Duration) *Client {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Client{
+		url:        strings.TrimSuffix(url, "/"),
+		id:         id,
+		secret:     secret,
+		timeout:    timeout,
+		httpClient: &http.Client{},
+
+		tokenMu: new(sync.Mutex),
+	}
+}
+
+// AuthorizedUser returns the User with the UID, if the User has authorized
+// the Ingress. It returns ErrUserNotFound if the User is not found.
+func (client *Client) AuthorizedUser(ctx context.Context, uid int64) (User, error) {
+	users := usersResponse{}
+	err := client.withAppToken(ctx, func(token string) error {
+		query := url.Values{"access_token": {token}, "uid": {strconv.FormatInt(uid, 10)}}
+		return client.do(ctx, "GET", "/api/authorized_users?"+query.Encode(), "", nil, &users)
+	})
+	if err != nil {
+		return User{}, err
+	}
+	if len(users.Users) == 0 {
+		return User{}, ErrUserNotFound
+	}
+	return users.Users[0], nil
+}
+
+// ExchangeCode exchanges an OAuth authorization code, issued to a trader, for
+// an access token that can be used to get the Kyber account of the trader.
+func (client *Client) ExchangeCode(ctx context.Context, request AuthorizationRequest) (Token, error) {
+	body, err := json.Marshal(authorizationRequest{
+		AuthorizationRequest: request,
+		ClientSecret:         client.secret,
+	})
+	if err != nil {
+		return Token{}, err
+	}
+	token := Token{}
+	if err := client.do(ctx, "POST", "/oauth/token", "application/json", bytes.NewReader(body), &token); err != nil {
+		return Token{}, err
+	}
+	if token.AccessToken == "" {
+		return Token{}, ErrEmptyToken
+	}
+	return token, nil
+}
+
+// UserInfo returns the User that issued the access token.
+func (client *Client) UserInfo(ctx context.Context, accessToken string) (User, error) {
+	user := User{}
+	query := url.Values{"access_token": {accessToken}}
+	if err := client.do(ctx, "GET", "/api/user_info?"+query.Encode(), "", nil, &user); err != nil {
+		return User{}, err
+	}
+	return user, nil
+}
+
+// withAppToken calls f with the cached app token. If the token is rejected,
+// it is refreshed and f is called again.
+func (client *Client) withAppToken(ctx context.Context, f func(token string) error) error {
+	token, err := client.appToken(ctx, false)
+	if err != nil {
+		return err
+	}
+	if err := f(token); err != ErrUnauthorized {
+		return err
+	}
+	if token, err = client.appToken(ctx, true); err != nil {
+		return err
+	}
+	return f(token)
+}
+
+// appToken returns the cached app token, requesting a new token using the
+// client credentials when the cached token is about to expire or when
+// refresh is true.
+func (client *Client) appToken(ctx context.Context, refresh bool) (string, error) {
+	client.tokenMu.Lock()
+	defer client.tokenMu.Unlock()
+
+	if !refresh && client.token != "" && time.Now().Before(client.tokenExpiry) {
+		return client.token, nil
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}, "client_id": {client.id}, "client_secret": {client.secret}}
+	token := Token{}
+	if err := client.do(ctx, "POST", "/oauth/token", "application/x-www-form-urlencoded", strings.NewReader(form.Encode()), &token); err != nil {
+		return "", err
+	}
+	if token.AccessToken == "" {
+		return "", ErrEmptyToken
+	}
+	client.token = token.AccessToken
+	client.tokenExpiry = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - TokenExpiryMargin)
+	return client.token, nil
+}
+
+// do sends a request to the Kyber API and decodes the JSON response into v.
+func (client *Client) do(ctx context.Context, method, path, contentType string, body io.Reader, v interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, client.timeout)
+	defer cancel()
+
+	req, err := http.NewRequest(method, client.url+path, body)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("kyber: cannot send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("kyber: cannot read response: %v", err)
+	}
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return ErrUnauthorized
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrUserNotFound
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		return &StatusError{StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
+	if len(respBody) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(respBody, v); err != nil {
+		return fmt.Errorf("kyber: cannot decode response: %v", err)
+	}
+	return nil
+}
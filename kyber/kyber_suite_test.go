@@ -0,0 +1,13 @@
+package kyber_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestKyber(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Kyber Suite")
+}
@@ -0,0 +1,200 @@
+package kyber_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/republicprotocol/renex-ingress-go/kyber"
+)
+
+var _ = Describe("Kyber client", func() {
+
+	var kyberAPI *mockKyberAPI
+	var server *httptest.Server
+	var client *Client
+
+	BeforeEach(func() {
+		kyberAPI = newMockKyberAPI()
+		server = httptest.NewServer(kyberAPI)
+		client = NewClient(server.URL, "id", "secret", time.Second)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Context("when getting authorized users", func() {
+
+		It("should return the user", func() {
+			user, err := client.AuthorizedUser(context.Background(), 42)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(user.UID).Should(Equal(int64(42)))
+			Expect(user.Approved()).Should(BeTrue())
+			Expect(user.HasAddress("0xTRADER")).Should(BeTrue())
+		})
+
+		It("should return an error when the user is not found", func() {
+			_, err := client.AuthorizedUser(context.Background(), 7)
+			Expect(err).Should(Equal(ErrUserNotFound))
+		})
+
+		It("should cache the app token", func() {
+			for i := 0; i < 3; i++ {
+				_, err := client.AuthorizedUser(context.Background(), 42)
+				Expect(err).ShouldNot(HaveOccurred())
+			}
+			Expect(kyberAPI.numTokens()).Should(Equal(1))
+		})
+
+		It("should refresh the app token when it is about to expire", func() {
+			kyberAPI.expiresIn = int64(TokenExpiryMargin / time.Second)
+			for i := 0; i < 3; i++ {
+				_, err := client.AuthorizedUser(context.Background(), 42)
+				Expect(err).ShouldNot(HaveOccurred())
+			}
+			Expect(kyberAPI.numTokens()).Should(Equal(3))
+		})
+
+		It("should refresh the app token when it is rejected", func() {
+			_, err := client.AuthorizedUser(context.Background(), 42)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			kyberAPI.revokeTokens()
+			_, err = client.AuthorizedUser(context.Background(), 42)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(kyberAPI.numTokens()).Should(Equal(2))
+		})
+
+		It("should return an error when the client credentials are rejected", func() {
+			client = NewClient(server.URL, "id", "wrong", time.Second)
+			_, err := client.AuthorizedUser(context.Background(), 42)
+			Expect(err).Should(Equal(ErrUnauthorized))
+		})
+	})
+
+	Context("when exchanging authorization codes", func() {
+
+		It("should return the user that issued the code", func() {
+			token, err := client.ExchangeCode(context.Background(), AuthorizationRequest{GrantType: "authorization_code", Code: "code"})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			user, err := client.UserInfo(context.Background(), token.AccessToken)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(user.UID).Should(Equal(int64(42)))
+		})
+
+		It("should return an error for invalid codes", func() {
+			_, err := client.ExchangeCode(context.Background(), AuthorizationRequest{GrantType: "authorization_code", Code: "invalid"})
+			Expect(err).Should(Equal(ErrEmptyToken))
+		})
+	})
+
+	Context("when the kyber api fails", func() {
+
+		It("should return typed errors for status codes", func() {
+			kyberAPI.status = http.StatusTooManyRequests
+			_, err := client.UserInfo(context.Background(), "token")
+			Expect(err).Should(Equal(ErrRateLimited))
+
+			kyberAPI.status = http.StatusBadGateway
+			_, err = client.UserInfo(context.Background(), "token")
+			Expect(err).Should(BeAssignableToTypeOf(&StatusError{}))
+			Expect(err.(*StatusError).StatusCode).Should(Equal(http.StatusBadGateway))
+		})
+
+		It("should time out slow requests", func() {
+			client = NewClient(server.URL, "id", "secret", 10*time.Millisecond)
+			kyberAPI.delay = 100 * time.Millisecond
+			_, err := client.UserInfo(context.Background(), "token")
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+})
+
+// mockKyberAPI is a stand-in for the Kyber API that knows a single user with
+// the UID 42.
+type mockKyberAPI struct {
+	mu        *sync.Mutex
+	tokens    map[string]bool
+	issued    int
+	expiresIn int64
+	status    int
+	delay     time.Duration
+}
+
+func newMockKyberAPI() *mockKyberAPI {
+	return &mockKyberAPI{
+		mu:        new(sync.Mutex),
+		tokens:    map[string]bool{},
+		expiresIn: 3600,
+	}
+}
+
+func (api *mockKyberAPI) numTokens() int {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	return api.issued
+}
+
+func (api *mockKyberAPI) revokeTokens() {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	api.tokens = map[string]bool{}
+}
+
+func (api *mockKyberAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	time.Sleep(api.delay)
+	if api.status != 0 {
+		w.WriteHeader(api.status)
+		return
+	}
+
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	user := User{UID: 42, Status: StatusApproved, Addresses: []string{"0xtrader"}}
+	switch r.URL.Path {
+	case "/oauth/token":
+		if r.Header.Get("Content-Type") == "application/json" {
+			// Authorization code exchange
+			request := map[string]string{}
+			json.NewDecoder(r.Body).Decode(&request)
+			if request["code"] != "code" || request["client_secret"] != "secret" {
+				w.Write([]byte("{}"))
+				return
+			}
+		} else if r.FormValue("client_secret") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		api.issued++
+		token := fmt.Sprintf("token%v", api.issued)
+		api.tokens[token] = true
+		json.NewEncoder(w).Encode(Token{AccessToken: token, ExpiresIn: api.expiresIn})
+	case "/api/authorized_users":
+		if !api.tokens[r.URL.Query().Get("access_token")] {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		users := []User{}
+		if r.URL.Query().Get("uid") == "42" {
+			users = append(users, user)
+		}
+		json.NewEncoder(w).Encode(map[string][]User{"authorized_users": users})
+	case "/api/user_info":
+		if !api.tokens[r.URL.Query().Get("access_token")] {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(user)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
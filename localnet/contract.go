@@ -0,0 +1,96 @@
+package localnet
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/republicprotocol/renex-ingress-go/contract"
+	"github.com/republicprotocol/renex-ingress-go/contract/bindings"
+)
+
+// ContractVersion is the version string passed to all deployed contracts.
+const ContractVersion = "localnet"
+
+// deployContracts deploys the RenEx contracts, and the contracts that they
+// depend on, to the simulated backend. The auth account is registered as a
+// broker. An ERC20 token is deployed as a stand-in for the Wyre KYC token,
+// since both implement balanceOf(address).
+func deployContracts(auth *bind.TransactOpts, backend *backends.SimulatedBackend) (contract.RenExConfig, error) {
+	ren, _, _, err := bindings.DeployRepublicToken(auth, backend)
+	if err != nil {
+		return contract.RenExConfig{}, fmt.Errorf("cannot deploy RepublicToken: %v", err)
+	}
+	backend.Commit()
+
+	darknodeRegistryStore, _, _, err := bindings.DeployDarknodeRegistryStore(auth, backend, ContractVersion, ren)
+	if err != nil {
+		return contract.RenExConfig{}, fmt.Errorf("cannot deploy DarknodeRegistryStore: %v", err)
+	}
+	backend.Commit()
+
+	darknodeRegistry, _, _, err := bindings.DeployDarknodeRegistry(auth, backend, ContractVersion, ren, darknodeRegistryStore, big.NewInt(0), big.NewInt(1), big.NewInt(1))
+	if err != nil {
+		return contract.RenExConfig{}, fmt.Errorf("cannot deploy DarknodeRegistry: %v", err)
+	}
+	settlementRegistry, _, _, err := bindings.DeploySettlementRegistry(auth, backend, ContractVersion)
+	if err != nil {
+		return contract.RenExConfig{}, fmt.Errorf("cannot deploy SettlementRegistry: %v", err)
+	}
+	backend.Commit()
+
+	orderbook, _, _, err := bindings.DeployOrderbook(auth, backend, ContractVersion, ren, darknodeRegistry, settlementRegistry)
+	if err != nil {
+		return contract.RenExConfig{}, fmt.Errorf("cannot deploy Orderbook: %v", err)
+	}
+	rewardVault, _, _, err := bindings.DeployDarknodeRewardVault(auth, backend, ContractVersion, darknodeRegistry)
+	if err != nil {
+		return contract.RenExConfig{}, fmt.Errorf("cannot deploy DarknodeRewardVault: %v", err)
+	}
+	renExTokens, _, _, err := bindings.DeployRenExTokens(auth, backend, ContractVersion)
+	if err != nil {
+		return contract.RenExConfig{}, fmt.Errorf("cannot deploy RenExTokens: %v", err)
+	}
+	renExBrokerVerifierAddress, _, renExBrokerVerifier, err := bindings.DeployRenExBrokerVerifier(auth, backend, ContractVersion)
+	if err != nil {
+		return contract.RenExConfig{}, fmt.Errorf("cannot deploy RenExBrokerVerifier: %v", err)
+	}
+	wyre, _, _, err := bindings.DeployStandardToken(auth, backend)
+	if err != nil {
+		return contract.RenExConfig{}, fmt.Errorf("cannot deploy Wyre stand-in: %v", err)
+	}
+	backend.Commit()
+
+	renExBalancesAddress, _, renExBalances, err := bindings.DeployRenExBalances(auth, backend, ContractVersion, rewardVault, renExBrokerVerifierAddress)
+	if err != nil {
+		return contract.RenExConfig{}, fmt.Errorf("cannot deploy RenExBalances: %v", err)
+	}
+	backend.Commit()
+
+	renExSettlement, _, _, err := bindings.DeployRenExSettlement(auth, backend, ContractVersion, orderbook, renExTokens, renExBalancesAddress, auth.From, big.NewInt(100000000000))
+	if err != nil {
+		return contract.RenExConfig{}, fmt.Errorf("cannot deploy RenExSettlement: %v", err)
+	}
+	backend.Commit()
+
+	// Link the contracts together and register the Ingress as a broker.
+	if _, err := renExBalances.UpdateRenExSettlementContract(auth, renExSettlement); err != nil {
+		return contract.RenExConfig{}, fmt.Errorf("cannot update RenExSettlement in RenExBalances: %v", err)
+	}
+	if _, err := renExBrokerVerifier.UpdateBalancesContract(auth, renExBalancesAddress); err != nil {
+		return contract.RenExConfig{}, fmt.Errorf("cannot update RenExBalances in RenExBrokerVerifier: %v", err)
+	}
+	if _, err := renExBrokerVerifier.RegisterBroker(auth, auth.From); err != nil {
+		return contract.RenExConfig{}, fmt.Errorf("cannot register broker: %v", err)
+	}
+	backend.Commit()
+
+	return contract.RenExConfig{
+		Network:                    contract.NetworkLocal,
+		RenExBrokerVerifierAddress: renExBrokerVerifierAddress.Hex(),
+		RenExSettlementAddress:     renExSettlement.Hex(),
+		OrderbookAddress:           orderbook.Hex(),
+		WyreAddress:                wyre.Hex(),
+	}, nil
+}
@@ -0,0 +1,99 @@
+package localnet
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/republicprotocol/republic-go/crypto"
+	"github.com/republicprotocol/republic-go/grpc"
+	"github.com/republicprotocol/republic-go/identity"
+	"github.com/republicprotocol/republic-go/order"
+)
+
+// Darknode is a stand-in for a Darknode. It implements the orderbook gRPC
+// service and stores the order fragments that it receives, but it does not
+// decrypt or match them.
+type Darknode struct {
+	port         int
+	ecdsaKey     crypto.EcdsaKey
+	multiAddress identity.MultiAddress
+	server       *grpc.Server
+
+	fragmentsMu *sync.RWMutex
+	fragments   map[order.ID]order.EncryptedFragment
+}
+
+// NewDarknode returns a Darknode with a random identity that will listen on
+// the given port of the loopback interface.
+func NewDarknode(port int) (*Darknode, error) {
+	ecdsaKey, err := crypto.RandomEcdsaKey()
+	if err != nil {
+		return nil, err
+	}
+	address := identity.Address(ecdsaKey.Address())
+	multiAddress, err := identity.NewMultiAddressFromString(fmt.Sprintf("/ip4/127.0.0.1/tcp/%v/republic/%v", port, address))
+	if err != nil {
+		return nil, err
+	}
+	return &Darknode{
+		port:         port,
+		ecdsaKey:     ecdsaKey,
+		multiAddress: multiAddress,
+
+		fragmentsMu: new(sync.RWMutex),
+		fragments:   map[order.ID]order.EncryptedFragment{},
+	}, nil
+}
+
+// Address of the Darknode.
+func (darknode *Darknode) Address() identity.Address {
+	return darknode.multiAddress.Address()
+}
+
+// MultiAddress of the Darknode.
+func (darknode *Darknode) MultiAddress() identity.MultiAddress {
+	return darknode.multiAddress
+}
+
+// Start serving the orderbook gRPC service in the background.
+func (darknode *Darknode) Start() error {
+	darknode.server = grpc.NewServer()
+	service := grpc.NewOrderbookService(darknode)
+	service.Register(darknode.server)
+
+	go func() {
+		if err := darknode.server.Start(fmt.Sprintf("127.0.0.1:%v", darknode.port)); err != nil {
+			log.Printf("[error] (localnet) darknode %v stopped: %v", darknode.Address(), err)
+		}
+	}()
+	return nil
+}
+
+// Stop serving the orderbook gRPC service.
+func (darknode *Darknode) Stop() {
+	if darknode.server != nil {
+		darknode.server.Stop()
+	}
+}
+
+// OpenOrder implements the orderbook.Server interface.
+func (darknode *Darknode) OpenOrder(ctx context.Context, orderFragment order.EncryptedFragment) error {
+	darknode.fragmentsMu.Lock()
+	defer darknode.fragmentsMu.Unlock()
+
+	log.Printf("[info] (localnet) darknode %v received fragment for order = %v", darknode.Address(), orderFragment.OrderID)
+	darknode.fragments[orderFragment.OrderID] = orderFragment
+	return nil
+}
+
+// OrderFragment returns the order fragment received for an order, and false if
+// no order fragment has been received.
+func (darknode *Darknode) OrderFragment(orderID order.ID) (order.EncryptedFragment, bool) {
+	darknode.fragmentsMu.RLock()
+	defer darknode.fragmentsMu.RUnlock()
+
+	orderFragment, ok := darknode.fragments[orderID]
+	return orderFragment, ok
+}
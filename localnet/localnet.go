@@ -0,0 +1,205 @@
+// Package localnet runs a self-contained RenEx network in-process. The RenEx
+// contracts are deployed to a simulated Ethereum backend, and orders are sent
+// to stand-in Darknodes that implement the orderbook gRPC service. It is
+// intended for local development and does not require network access.
+package localnet
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/republicprotocol/renex-ingress-go/contract"
+	"github.com/republicprotocol/republic-go/identity"
+	"github.com/republicprotocol/republic-go/registry"
+	"github.com/republicprotocol/republic-go/swarm"
+)
+
+// BlockInterval is the interval at which the simulated backend mines blocks.
+var BlockInterval = 2 * time.Second
+
+// GasLimit of blocks mined by the simulated backend.
+const GasLimit = 8000000
+
+// Localnet is an in-process RenEx network.
+type Localnet struct {
+	Backend   *backends.SimulatedBackend
+	Config    contract.RenExConfig
+	Darknodes []*Darknode
+	Pod       registry.Pod
+}
+
+// New returns a Localnet with the RenEx contracts deployed by the auth
+// account, and a single pod of Darknodes listening on consecutive ports
+// starting from the base port. The Darknodes must be started by calling
+// Localnet.Start.
+func New(auth *bind.TransactOpts, numDarknodes, basePort int) (*Localnet, error) {
+	balance := new(big.Int).Exp(big.NewInt(10), big.NewInt(24), nil)
+	backend := backends.NewSimulatedBackend(core.GenesisAlloc{
+		auth.From: core.GenesisAccount{Balance: balance},
+	}, GasLimit)
+
+	config, err := deployContracts(auth, backend)
+	if err != nil {
+		return nil, fmt.Errorf("cannot deploy contracts: %v", err)
+	}
+
+	pod := registry.Pod{
+		Darknodes: make([]identity.Address, 0, numDarknodes),
+	}
+	if _, err := rand.Read(pod.Hash[:]); err != nil {
+		return nil, err
+	}
+	darknodes := make([]*Darknode, 0, numDarknodes)
+	for i := 0; i < numDarknodes; i++ {
+		darknode, err := NewDarknode(basePort + i)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create darknode: %v", err)
+		}
+		darknodes = append(darknodes, darknode)
+		pod.Darknodes = append(pod.Darknodes, darknode.Address())
+	}
+
+	return &Localnet{
+		Backend:   backend,
+		Config:    config,
+		Darknodes: darknodes,
+		Pod:       pod,
+	}, nil
+}
+
+// Start the Darknodes and mine blocks in the background. Closing the done
+// channel will stop all Darknodes.
+func (localnet *Localnet) Start(done <-chan struct{}) error {
+	for _, darknode := range localnet.Darknodes {
+		if err := darknode.Start(); err != nil {
+			return err
+		}
+	}
+
+	go func() {
+		ticker := time.NewTicker(BlockInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				for _, darknode := range localnet.Darknodes {
+					darknode.Stop()
+				}
+				return
+			case <-ticker.C:
+				localnet.Backend.Commit()
+			}
+		}
+	}()
+	return nil
+}
+
+// ContractBinder returns a binder that reports a single epoch containing the
+// pod of local Darknodes.
+func (localnet *Localnet) ContractBinder() *ContractBinder {
+	darknodes := make(identity.Addresses, len(localnet.Pod.Darknodes))
+	copy(darknodes, localnet.Pod.Darknodes)
+	return &ContractBinder{
+		epoch: registry.Epoch{
+			Hash:          localnet.Pod.Hash,
+			Pods:          []registry.Pod{localnet.Pod},
+			Darknodes:     darknodes,
+			BlockNumber:   big.NewInt(0),
+			BlockInterval: big.NewInt(1),
+		},
+	}
+}
+
+// Swarmer returns a swarm.Swarmer that can query the multi-addresses of the
+// local Darknodes.
+func (localnet *Localnet) Swarmer(multiAddress identity.MultiAddress) swarm.Swarmer {
+	multiAddresses := make(map[identity.Address]identity.MultiAddress, len(localnet.Darknodes))
+	for _, darknode := range localnet.Darknodes {
+		multiAddresses[darknode.Address()] = darknode.MultiAddress()
+	}
+	return &swarmer{
+		multiAddress:   multiAddress,
+		multiAddresses: multiAddresses,
+	}
+}
+
+// ContractBinder implements the ingress.ContractBinder interface for a
+// Localnet.
+type ContractBinder struct {
+	epoch registry.Epoch
+}
+
+// MinimumEpochInterval implements the ingress.ContractBinder interface.
+func (binder *ContractBinder) MinimumEpochInterval() (*big.Int, error) {
+	return big.NewInt(1), nil
+}
+
+// Epoch implements the ingress.ContractBinder interface.
+func (binder *ContractBinder) Epoch() (registry.Epoch, error) {
+	return binder.epoch, nil
+}
+
+// NextEpoch implements the ingress.ContractBinder interface. Local epochs
+// never change.
+func (binder *ContractBinder) NextEpoch() (registry.Epoch, error) {
+	return binder.epoch, nil
+}
+
+// PreviousEpoch implements the ingress.ContractBinder interface.
+func (binder *ContractBinder) PreviousEpoch() (registry.Epoch, error) {
+	return binder.epoch, nil
+}
+
+// Pods implements the ingress.ContractBinder interface.
+func (binder *ContractBinder) Pods() ([]registry.Pod, error) {
+	return binder.epoch.Pods, nil
+}
+
+// PreviousPods implements the ingress.ContractBinder interface.
+func (binder *ContractBinder) PreviousPods() ([]registry.Pod, error) {
+	return binder.epoch.Pods, nil
+}
+
+type swarmer struct {
+	multiAddress   identity.MultiAddress
+	multiAddresses map[identity.Address]identity.MultiAddress
+}
+
+func (swarmer *swarmer) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (swarmer *swarmer) Pong(ctx context.Context, to identity.MultiAddress) error {
+	return nil
+}
+
+func (swarmer *swarmer) BroadcastMultiAddress(ctx context.Context, multiAddress identity.MultiAddress) error {
+	return nil
+}
+
+func (swarmer *swarmer) Query(ctx context.Context, query identity.Address) (identity.MultiAddress, error) {
+	multiAddress, ok := swarmer.multiAddresses[query]
+	if !ok {
+		return identity.MultiAddress{}, swarm.ErrMultiAddressNotFound
+	}
+	return multiAddress, nil
+}
+
+func (swarmer *swarmer) MultiAddress() identity.MultiAddress {
+	return swarmer.multiAddress
+}
+
+func (swarmer *swarmer) Peers() (identity.MultiAddresses, error) {
+	peers := identity.MultiAddresses{swarmer.multiAddress}
+	for _, multiAddress := range swarmer.multiAddresses {
+		peers = append(peers, multiAddress)
+	}
+	return peers, nil
+}